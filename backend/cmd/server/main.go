@@ -1,45 +1,151 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"gym-admin/internal/config"
+	"gym-admin/internal/container"
+	"gym-admin/internal/job"
 	"gym-admin/internal/router"
 	"gym-admin/pkg/cache"
 	"gym-admin/pkg/database"
 	"gym-admin/pkg/jwt"
 	"gym-admin/pkg/logger"
+	"gym-admin/pkg/response"
+	"gym-admin/pkg/validate"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	// Release mode also keeps the mock payment gateway off
+	gin.SetMode(cfg.Server.Mode)
+
 	// Initialize logger
 	logger.InitLogger(cfg.Log)
 
 	// Initialize JWT
 	jwt.InitJWT(cfg.JWT.Secret)
 
+	// Error responses carry real HTTP statuses unless old clients need 200
+	response.SetStatusCompat(cfg.Server.StatusCompat)
+
+	// Request validation rules and their localized messages
+	if err := validate.Register(); err != nil {
+		log.Fatalf("Failed to register validators: %v", err)
+	}
+
 	// Initialize database
-	if err := database.InitDB(cfg.Database); err != nil {
+	db, err := database.Open(cfg.Database)
+	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	if err := migrateOnStart(db, cfg.Database); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
+	}
 
 	// Initialize Redis cache
-	if err := cache.InitRedis(cfg.Redis); err != nil {
+	redisCache, err := cache.NewRedis(cfg.Redis)
+	if err != nil {
 		log.Fatalf("Failed to initialize Redis: %v", err)
 	}
+	defer redisCache.Close()
+
+	// Wire payment gateways, repositories, services and controllers
+	c, err := container.New(db, redisCache, cfg.Payment)
+	if err != nil {
+		log.Fatalf("Failed to initialize payment: %v", err)
+	}
+
+	// Start background jobs
+	var scheduler *job.Scheduler
+	if cfg.Jobs.Enabled {
+		scheduler = job.NewScheduler(c.Cache)
+		if err := scheduler.Daily("analytics", cfg.Jobs.AnalyticsAt, c.AnalyticsService.RunNightly); err != nil {
+			log.Fatalf("Failed to schedule jobs: %v", err)
+		}
+		if err := scheduler.Every("occupancy", cfg.Jobs.OccupancyInterval, c.OccupancyService.RefreshRecent); err != nil {
+			log.Fatalf("Failed to schedule jobs: %v", err)
+		}
+		if err := scheduler.Every("order-expiry", cfg.Jobs.OrderExpiryInterval, c.OrderService.CloseExpiredOrders); err != nil {
+			log.Fatalf("Failed to schedule jobs: %v", err)
+		}
+		if err := scheduler.Every("refund-sync", cfg.Jobs.RefundSyncInterval, c.RefundService.SyncProcessingRefunds); err != nil {
+			log.Fatalf("Failed to schedule jobs: %v", err)
+		}
+		if err := scheduler.Every("points", cfg.Jobs.PointsInterval, c.PointsService.AwardPoints); err != nil {
+			log.Fatalf("Failed to schedule jobs: %v", err)
+		}
+		if err := scheduler.Every("referrals", cfg.Jobs.ReferralInterval, c.ReferralService.RewardReferrals); err != nil {
+			log.Fatalf("Failed to schedule jobs: %v", err)
+		}
+		scheduler.Start()
+	}
 
 	// Setup router
-	r := router.SetupRouter()
+	r := router.SetupRouter(c, cfg.Server)
 
 	// Start server
 	addr := cfg.Server.Host + ":" + cfg.Server.Port
-	log.Printf("Server starting on %s", addr)
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: addr, Handler: r}
+	go func() {
+		log.Printf("Server starting on %s", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Wait for a deploy or Ctrl-C, then let in-flight requests and running
+	// jobs finish before exiting
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	logger.Info("Shutting down", zap.String("signal", sig.String()))
+	c.HealthService.Drain()
+	if cfg.Server.DrainDelay > 0 {
+		// A second signal stops waiting
+		select {
+		case <-time.After(cfg.Server.DrainDelay):
+		case <-quit:
+		}
+	}
+
+	ctx := context.Background()
+	if cfg.Server.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Server.ShutdownTimeout)
+		defer cancel()
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Server did not shut down cleanly", zap.Error(err))
+	}
+	if scheduler != nil {
+		if err := scheduler.Shutdown(ctx); err != nil {
+			logger.Warn("Cancelled running jobs at shutdown", zap.Error(err))
+		}
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
 	}
+	logger.Info("Server stopped")
 }
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"gym-admin/internal/config"
+	"gym-admin/pkg/database"
+	"gym-admin/pkg/logger"
+	"gym-admin/pkg/migrate"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const migrateUsage = `usage: gym-admin migrate <command>
+
+commands:
+  up [N]          apply all pending migrations, or the next N
+  down [N]        revert the last N applied migrations (default 1)
+  status          list migrations and whether they are applied
+  force VERSION   mark VERSION applied after repairing a failed migration
+  create NAME     add an empty up/down pair to the migrations directory
+                  (-dir, default "migrations", run from backend/)`
+
+// runMigrate handles `gym-admin migrate ...`
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		fs := flag.NewFlagSet("create", flag.ContinueOnError)
+		dir := fs.String("dir", "migrations", "migrations directory")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return errors.New(migrateUsage)
+		}
+		up, down, err := migrate.Create(*dir, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return nil
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if !database.UsesMigrations(cfg.Database) {
+		return errors.New("migrations are for MySQL; SQLite databases take their schema from the models")
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		return err
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		n, err := countArg(args, 0)
+		if err != nil {
+			return err
+		}
+		done, err := migrator.Up(ctx, n)
+		printMigrations("applied", done)
+		return err
+	case "down":
+		n, err := countArg(args, 1)
+		if err != nil {
+			return err
+		}
+		done, err := migrator.Down(ctx, n)
+		printMigrations("reverted", done)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			state, appliedAt := "pending", ""
+			switch {
+			case s.Dirty:
+				state = "dirty"
+			case s.Missing:
+				state = "applied (not in this build)"
+			case s.Applied:
+				state = "applied"
+			}
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+	case "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		return migrator.Force(ctx, version)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func countArg(args []string, def int) (int, error) {
+	if len(args) < 2 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid count %q", args[1])
+	}
+	return n, nil
+}
+
+func printMigrations(verb string, migrations []migrate.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("no migrations", verb)
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %06d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+// migrateOnStart applies pending migrations at boot if configured to, and
+// otherwise warns when the schema is behind the build
+func migrateOnStart(db *gorm.DB, cfg config.DatabaseConfig) error {
+	if !database.UsesMigrations(cfg) {
+		return nil
+	}
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if !cfg.MigrateOnStart {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			logger.Warn("Database schema is behind, run migrate up", zap.Int("pending", pending))
+		}
+		return nil
+	}
+
+	done, err := migrator.Up(ctx, 0)
+	for _, m := range done {
+		logger.Info("Applied migration", zap.Int64("version", m.Version), zap.String("name", m.Name))
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+// Package apitest starts the full API against a fresh in-memory SQLite
+// database and an in-memory Redis, for integration tests:
+//
+//	func TestCreateUser(t *testing.T) {
+//		srv := apitest.New(t)
+//		resp := srv.Do(t, http.MethodPost, "/api/v1/users", body, srv.Token(t, 1, "admin"))
+//		if resp.Code != 200 { ... }
+//	}
+//
+// The JWT secret and logger are package globals, so tests using a Server
+// must not run in parallel.
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"gym-admin/internal/config"
+	"gym-admin/internal/container"
+	"gym-admin/internal/router"
+	"gym-admin/pkg/cache"
+	"gym-admin/pkg/database"
+	"gym-admin/pkg/jwt"
+	"gym-admin/pkg/logger"
+	"gym-admin/pkg/validate"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const (
+	jwtSecret  = "apitest-secret"
+	MockSecret = "apitest-mock-payment" // signs mock gateway notifications
+)
+
+type Server struct {
+	*httptest.Server
+	Container *container.Container
+	DB        *gorm.DB
+	Redis     *miniredis.Miniredis
+}
+
+// Response is a decoded API response. Status is the HTTP status; Code and
+// Error are the status and business error code in the body.
+type Response struct {
+	Status  int             `json:"-"`
+	Code    int             `json:"code"`
+	Error   string          `json:"error"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// New starts a server on a new empty database. It is shut down when the
+// test finishes.
+func New(t testing.TB) *Server {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	logger.Logger = zap.NewNop()
+	gormlogger.Default = gormlogger.Discard
+	jwt.InitJWT(jwtSecret)
+	if err := validate.Register(); err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+
+	db, err := database.Open(config.DatabaseConfig{Driver: database.DriverSQLite, Path: ":memory:"})
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	port, _ := strconv.Atoi(mr.Port())
+	redisCache, err := cache.NewRedis(config.RedisConfig{Host: mr.Host(), Port: port})
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	c, err := container.New(db, redisCache, config.PaymentConfig{Mock: config.MockPayConfig{Enabled: true, Secret: MockSecret}})
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+
+	srv := &Server{
+		Server:    httptest.NewServer(router.SetupRouter(c, config.ServerConfig{})),
+		Container: c,
+		DB:        db,
+		Redis:     mr,
+	}
+	t.Cleanup(func() {
+		srv.Close()
+		redisCache.Close()
+		mr.Close()
+		if sqlDB, err := srv.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return srv
+}
+
+// Token issues a bearer token for the given user and role
+func (s *Server) Token(t testing.TB, userID int64, role string) string {
+	t.Helper()
+	token, err := jwt.GenerateToken(userID, role, 3600)
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	return token
+}
+
+// Do sends body as JSON, with the token if one is given, and decodes the
+// response envelope
+func (s *Server) Do(t testing.TB, method, path string, body interface{}, token string) *Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("apitest: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, reader)
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("apitest: %s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	out := &Response{Status: resp.StatusCode}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("apitest: %s %s: decode response: %v", method, path, err)
+	}
+	return out
+}
+
+// Decode unmarshals the response data into v
+func (r *Response) Decode(t testing.TB, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(r.Data, v); err != nil {
+		t.Fatalf("apitest: decode data: %v", err)
+	}
+}
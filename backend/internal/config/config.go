@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
@@ -11,21 +13,46 @@ type Config struct {
 	JWT      JWTConfig      `mapstructure:"jwt"`
 	Log      LogConfig      `mapstructure:"log"`
 	OSS      OSSConfig      `mapstructure:"oss"`
+	Jobs     JobsConfig     `mapstructure:"jobs"`
+	Payment  PaymentConfig  `mapstructure:"payment"`
 }
 
 type ServerConfig struct {
 	Host string `mapstructure:"host"`
 	Port string `mapstructure:"port"`
 	Mode string `mapstructure:"mode"`
+	// RequestTimeout bounds each request; imports, exports and rebuilds get
+	// LongRequestTimeout instead. Zero means no limit.
+	RequestTimeout     time.Duration `mapstructure:"request_timeout"`
+	LongRequestTimeout time.Duration `mapstructure:"long_request_timeout"`
+	// StatusCompat answers errors with HTTP 200 and the status only in the
+	// response body, for clients not yet reading HTTP statuses
+	StatusCompat bool `mapstructure:"status_compat"`
+	// ShutdownTimeout is how long a stopping server waits for in-flight
+	// requests and running jobs before cutting them off. Zero waits as long
+	// as they take.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// DrainDelay is how long a stopping server keeps serving after /readyz
+	// starts failing, so that load balancers see it and stop sending new
+	// requests before the listener closes
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
 }
 
 type DatabaseConfig struct {
+	Driver   string `mapstructure:"driver"` // mysql 或 sqlite
+	Path     string `mapstructure:"path"`   // SQLite 数据库文件，":memory:" 为内存库
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
 	DBName   string `mapstructure:"dbname"`
 	Charset  string `mapstructure:"charset"`
+	// MigrateOnStart applies pending migrations at boot; otherwise they are
+	// left to `migrate up` and the server only warns about them
+	MigrateOnStart bool `mapstructure:"migrate_on_start"`
+	// QueryTimeout bounds each statement on top of the request's deadline.
+	// Zero means no limit.
+	QueryTimeout time.Duration `mapstructure:"query_timeout"`
 }
 
 type RedisConfig struct {
@@ -55,6 +82,47 @@ type OSSConfig struct {
 	BucketName      string `mapstructure:"bucket_name"`
 }
 
+type JobsConfig struct {
+	Enabled             bool          `mapstructure:"enabled"`
+	AnalyticsAt         string        `mapstructure:"analytics_at"` // HH:MM local time
+	OccupancyInterval   time.Duration `mapstructure:"occupancy_interval"`
+	OrderExpiryInterval time.Duration `mapstructure:"order_expiry_interval"`
+	RefundSyncInterval  time.Duration `mapstructure:"refund_sync_interval"`
+	PointsInterval      time.Duration `mapstructure:"points_interval"`
+	ReferralInterval    time.Duration `mapstructure:"referral_interval"`
+}
+
+type PaymentConfig struct {
+	NotifyBaseURL           string          `mapstructure:"notify_base_url"`           // 支付回调的外网地址
+	RefundApprovalThreshold float64         `mapstructure:"refund_approval_threshold"` // 超过该金额的退款需店长审批
+	Wechat                  WechatPayConfig `mapstructure:"wechat"`
+	Alipay                  AlipayConfig    `mapstructure:"alipay"`
+	Mock                    MockPayConfig   `mapstructure:"mock"`
+}
+
+type WechatPayConfig struct {
+	Enabled          bool   `mapstructure:"enabled"`
+	AppID            string `mapstructure:"app_id"`
+	MchID            string `mapstructure:"mch_id"`
+	SerialNo         string `mapstructure:"serial_no"` // 商户API证书序列号
+	PrivateKeyFile   string `mapstructure:"private_key_file"`
+	PlatformCertFile string `mapstructure:"platform_cert_file"`
+	APIv3Key         string `mapstructure:"api_v3_key"`
+}
+
+type AlipayConfig struct {
+	Enabled             bool   `mapstructure:"enabled"`
+	AppID               string `mapstructure:"app_id"`
+	PrivateKeyFile      string `mapstructure:"private_key_file"`
+	AlipayPublicKeyFile string `mapstructure:"alipay_public_key_file"`
+	GatewayURL          string `mapstructure:"gateway_url"` // 为空时使用正式环境
+}
+
+type MockPayConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Secret  string `mapstructure:"secret"`
+}
+
 func LoadConfig() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
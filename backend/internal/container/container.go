@@ -0,0 +1,161 @@
+// Package container builds the repositories, services and controllers once
+// at startup and hands each its dependencies.
+package container
+
+import (
+	"errors"
+	"fmt"
+	"gym-admin/internal/config"
+	"gym-admin/internal/controller"
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/cache"
+	"gym-admin/pkg/payment"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type Container struct {
+	DB    *gorm.DB
+	Cache cache.Cache
+
+	// Services run by background jobs or told about shutdown
+	AnalyticsService *service.AnalyticsService
+	OccupancyService *service.OccupancyService
+	OrderService     *service.OrderService
+	RefundService    *service.RefundService
+	PointsService    *service.PointsService
+	ReferralService  *service.ReferralService
+	HealthService    *service.HealthService
+
+	AuthController      *controller.AuthController
+	UserController      *controller.UserController
+	CardController      *controller.CardController
+	CoachController     *controller.CoachController
+	ReviewController    *controller.ReviewController
+	CheckInController   *controller.CheckInController
+	VoucherController   *controller.VoucherController
+	StatsController     *controller.StatsController
+	AnalyticsController *controller.AnalyticsController
+	ReportController    *controller.ReportController
+	OrderController     *controller.OrderController
+	RefundController    *controller.RefundController
+	PromotionController *controller.PromotionController
+	WalletController    *controller.WalletController
+	PointsController    *controller.PointsController
+	ReferralController  *controller.ReferralController
+	HealthController    *controller.HealthController
+}
+
+func New(db *gorm.DB, c cache.Cache, payments config.PaymentConfig) (*Container, error) {
+	repos := repository.NewRepositories(db)
+	tx := repository.NewTxManager(db)
+
+	gateways, err := newGateways(payments)
+	if err != nil {
+		return nil, err
+	}
+	gateways.Register(service.NewWalletGateway(repos.Wallets, tx))
+
+	userService := service.NewUserService(repos.Users, repos.Sequences, tx)
+	cardService := service.NewCardService(repos.Cards, repos.Sequences, tx)
+	coachService := service.NewCoachService(repos.Coaches)
+	exportService := service.NewExportService(repos.Users, repos.Coaches)
+	importService := service.NewUserImportService(userService, cardService, repos.Users, repos.Cards, tx)
+	reviewService := service.NewReviewService(repos.Reviews, repos.Courses, repos.Coaches)
+	statsService := service.NewStatsService(repos.Stats, c)
+	analyticsService := service.NewAnalyticsService(repos.Analytics)
+	occupancyService := service.NewOccupancyService(repos.Occupancy)
+	revenueService := service.NewRevenueService(repos.Revenue)
+	promotionService := service.NewPromotionService(repos.Promotions, repos.Cards)
+	walletService := service.NewWalletService(repos.Wallets, repos.Users)
+	orderService := service.NewOrderService(repos.Orders, repos.Users, repos.Cards, repos.Coaches, repos.Sequences, cardService, promotionService, walletService, gateways, tx)
+	refundService := service.NewRefundService(repos.Refunds, repos.Orders, repos.Wallets, repos.Sequences, gateways, payments.RefundApprovalThreshold, tx)
+	pointsService := service.NewPointsService(repos.Points, repos.Users, repos.Cards, repos.Promotions, tx)
+	referralService := service.NewReferralService(repos.Referrals, repos.Users, repos.Cards, repos.Promotions, tx)
+	checkInService := service.NewCheckInService(repos.Users, repos.Cards, tx)
+	voucherService := service.NewVoucherService(repos.Cards, repos.Users, cardService, tx)
+	healthService := service.NewHealthService(db, c)
+
+	return &Container{
+		DB:    db,
+		Cache: c,
+
+		AnalyticsService: analyticsService,
+		OccupancyService: occupancyService,
+		OrderService:     orderService,
+		RefundService:    refundService,
+		PointsService:    pointsService,
+		ReferralService:  referralService,
+		HealthService:    healthService,
+
+		AuthController:      controller.NewAuthController(userService),
+		UserController:      controller.NewUserController(userService, importService, exportService),
+		CardController:      controller.NewCardController(cardService),
+		CoachController:     controller.NewCoachController(coachService, exportService),
+		ReviewController:    controller.NewReviewController(reviewService),
+		CheckInController:   controller.NewCheckInController(checkInService),
+		VoucherController:   controller.NewVoucherController(voucherService),
+		StatsController:     controller.NewStatsController(statsService),
+		AnalyticsController: controller.NewAnalyticsController(analyticsService),
+		ReportController:    controller.NewReportController(occupancyService, revenueService, walletService, referralService),
+		OrderController:     controller.NewOrderController(orderService, gateways),
+		RefundController:    controller.NewRefundController(refundService),
+		PromotionController: controller.NewPromotionController(promotionService),
+		WalletController:    controller.NewWalletController(walletService),
+		PointsController:    controller.NewPointsController(pointsService),
+		ReferralController:  controller.NewReferralController(referralService),
+		HealthController:    controller.NewHealthController(healthService),
+	}, nil
+}
+
+// newGateways registers the offline methods and every online gateway enabled
+// in config
+func newGateways(cfg config.PaymentConfig) (*payment.Registry, error) {
+	gateways := payment.NewRegistry(
+		payment.NewOfflineGateway(payment.MethodCash, "cash", false),
+		payment.NewOfflineGateway(payment.MethodPOS, "pos", true),
+	)
+
+	base := strings.TrimRight(cfg.NotifyBaseURL, "/")
+	if cfg.Wechat.Enabled {
+		g, err := payment.NewWechatGateway(payment.WechatConfig{
+			AppID:            cfg.Wechat.AppID,
+			MchID:            cfg.Wechat.MchID,
+			SerialNo:         cfg.Wechat.SerialNo,
+			PrivateKeyFile:   cfg.Wechat.PrivateKeyFile,
+			PlatformCertFile: cfg.Wechat.PlatformCertFile,
+			APIv3Key:         cfg.Wechat.APIv3Key,
+		}, base+"/api/v1/payments/notify/wechat")
+		if err != nil {
+			return nil, fmt.Errorf("failed to init wechat pay: %w", err)
+		}
+		gateways.Register(g)
+	}
+	if cfg.Alipay.Enabled {
+		g, err := payment.NewAlipayGateway(payment.AlipayConfig{
+			AppID:               cfg.Alipay.AppID,
+			PrivateKeyFile:      cfg.Alipay.PrivateKeyFile,
+			AlipayPublicKeyFile: cfg.Alipay.AlipayPublicKeyFile,
+			GatewayURL:          cfg.Alipay.GatewayURL,
+		}, base+"/api/v1/payments/notify/alipay")
+		if err != nil {
+			return nil, fmt.Errorf("failed to init alipay: %w", err)
+		}
+		gateways.Register(g)
+	}
+	if cfg.Mock.Enabled {
+		// anyone knowing the secret can mark orders paid, so the mock gateway
+		// never runs in production
+		if gin.Mode() == gin.ReleaseMode {
+			return nil, errors.New("mock payment gateway must not be enabled in release mode")
+		}
+		if cfg.Mock.Secret == "" {
+			return nil, errors.New("mock payment gateway requires a secret")
+		}
+		gateways.Register(payment.NewMockGateway(cfg.Mock.Secret))
+	}
+	return gateways, nil
+}
@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsController struct {
+	service *service.AnalyticsService
+}
+
+func NewAnalyticsController(analyticsService *service.AnalyticsService) *AnalyticsController {
+	return &AnalyticsController{
+		service: analyticsService,
+	}
+}
+
+// GetCohorts returns the monthly registration cohort retention table
+func (ctrl *AnalyticsController) GetCohorts(c *gin.Context) {
+	months, _ := strconv.Atoi(c.DefaultQuery("months", "12"))
+
+	cohorts, err := ctrl.service.GetCohorts(c.Request.Context(), months)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, cohorts)
+}
+
+// GetRenewals returns the monthly card renewal rates
+func (ctrl *AnalyticsController) GetRenewals(c *gin.Context) {
+	months, _ := strconv.Atoi(c.DefaultQuery("months", "12"))
+
+	report, err := ctrl.service.GetRenewals(c.Request.Context(), months)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// ListAtRisk lists members with an active card who are about to churn
+func (ctrl *AnalyticsController) ListAtRisk(c *gin.Context) {
+	inactiveDays, _ := strconv.Atoi(c.DefaultQuery("inactive_days", "14"))
+	dropRate, _ := strconv.ParseFloat(c.DefaultQuery("drop_rate", "0.5"), 64)
+
+	q, ok := parseList(c, repository.AtRiskListSpec)
+	if !ok {
+		return
+	}
+
+	members, page, err := ctrl.service.ListAtRisk(c.Request.Context(), inactiveDays, dropRate, q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, members, page)
+}
+
+// Refresh rebuilds the analytics tables without waiting for the nightly job
+func (ctrl *AnalyticsController) Refresh(c *gin.Context) {
+	if err := ctrl.service.RunNightly(c.Request.Context()); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Analytics refreshed successfully", nil)
+}
@@ -13,14 +13,14 @@ type AuthController struct {
 	userService *service.UserService
 }
 
-func NewAuthController() *AuthController {
+func NewAuthController(userService *service.UserService) *AuthController {
 	return &AuthController{
-		userService: service.NewUserService(),
+		userService: userService,
 	}
 }
 
 type LoginRequest struct {
-	Phone    string `json:"phone" binding:"required"`
+	Phone    string `json:"phone" binding:"required,mobile"`
 	Password string `json:"password" binding:"required"`
 }
 
@@ -29,27 +29,29 @@ type LoginResponse struct {
 	User  interface{} `json:"user"`
 }
 
-// Login handles user login
+// Login checks the phone and password and issues a token carrying the user's role
 func (ctrl *AuthController) Login(c *gin.Context) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		response.BadRequest(c, "Invalid request: "+err.Error())
+		response.BindError(c, err)
 		return
 	}
 
-	// TODO: Implement password verification
-	// For now, just check if user exists
-	user, err := ctrl.userService.GetUserByPhone(req.Phone)
+	user, err := ctrl.userService.Authenticate(c.Request.Context(), req.Phone, req.Password)
 	if err != nil {
-		response.Unauthorized(c, "Invalid phone or password")
+		response.Fail(c, err)
 		return
 	}
 
 	// Load config for JWT expiration
 	cfg, _ := config.LoadConfig()
 
-	// Generate JWT token
-	token, err := jwt.GenerateToken(user.ID, "user", cfg.JWT.ExpireTime)
+	// Generate JWT token carrying the user's role
+	role := user.Role
+	if role == "" {
+		role = service.RoleMember
+	}
+	token, err := jwt.GenerateToken(user.ID, role, cfg.JWT.ExpireTime)
 	if err != nil {
 		response.InternalServerError(c, "Failed to generate token")
 		return
@@ -61,28 +63,25 @@ func (ctrl *AuthController) Login(c *gin.Context) {
 	})
 }
 
-// Register handles user registration
-func (ctrl *AuthController) Register(c *gin.Context) {
-	var user struct {
-		Name     string `json:"name" binding:"required"`
-		Phone    string `json:"phone" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+type RegisterRequest struct {
+	Name     string `json:"name" binding:"required,max=50"`
+	Phone    string `json:"phone" binding:"required,mobile"`
+	Password string `json:"password" binding:"required,min=6,max=72"`
+}
 
-	if err := c.ShouldBindJSON(&user); err != nil {
-		response.BadRequest(c, "Invalid request: "+err.Error())
+// Register signs up a member who can then log in with the password
+func (ctrl *AuthController) Register(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
 		return
 	}
 
-	// TODO: Hash password before storing
-	// For now, just create user without password field
-	newUser := &struct {
-		Name  string
-		Phone string
-	}{
-		Name:  user.Name,
-		Phone: user.Phone,
+	user, err := ctrl.userService.Register(c.Request.Context(), req.Name, req.Phone, req.Password)
+	if err != nil {
+		response.Fail(c, err)
+		return
 	}
 
-	response.SuccessWithMessage(c, "Registration successful", newUser)
+	response.SuccessWithMessage(c, "Registration successful", user)
 }
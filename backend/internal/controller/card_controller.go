@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CardController struct {
+	service *service.CardService
+}
+
+func NewCardController(cardService *service.CardService) *CardController {
+	return &CardController{
+		service: cardService,
+	}
+}
+
+type TransferCardRequest struct {
+	ToUserID int64  `json:"to_user_id" binding:"required"`
+	Remark   string `json:"remark" binding:"max=200"`
+}
+
+// TransferCard hands a card over to another member, who gets a new card with
+// what is left of it
+func (ctrl *CardController) TransferCard(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid card ID")
+		return
+	}
+
+	var req TransferCardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	card, err := ctrl.service.TransferCard(c.Request.Context(), id, req.ToUserID, c.GetInt64("user_id"), req.Remark)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, card)
+}
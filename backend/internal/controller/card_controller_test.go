@@ -0,0 +1,55 @@
+package controller_test
+
+import (
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"net/http"
+	"testing"
+)
+
+func TestTransferCard(t *testing.T) {
+	srv := apitest.New(t)
+	fromID := createMember(t, srv, "13800000031")
+	toID := createMember(t, srv, "13800000032")
+	cardType := createCardType(t, srv, 300)
+	if err := srv.DB.Model(cardType).Update("transfer_fee", 50).Error; err != nil {
+		t.Fatal(err)
+	}
+	card := buyCard(t, srv, fromID, cardType.ID)
+
+	path := fmt.Sprintf("/api/v1/cards/%d/transfer", card.ID)
+	body := map[string]interface{}{"to_user_id": toID, "remark": "转让给朋友"}
+	if resp := srv.Do(t, http.MethodPost, path, body, srv.Token(t, fromID, service.RoleMember)); resp.Status != http.StatusForbidden {
+		t.Errorf("member transferring: status %d, want 403", resp.Status)
+	}
+
+	resp := srv.Do(t, http.MethodPost, path, body, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("transfer: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var newCard models.MembershipCard
+	resp.Decode(t, &newCard)
+	if newCard.UserID != toID || newCard.PurchasePrice != 0 || !newCard.EndDate.Equal(card.EndDate) {
+		t.Errorf("new card = %+v, want one of member %d ending %s", newCard, toID, card.EndDate)
+	}
+
+	var old models.MembershipCard
+	if err := srv.DB.First(&old, card.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if old.Status != service.CardStatusTransferred {
+		t.Errorf("old card status = %d, want transferred", old.Status)
+	}
+	if n := countRows(t, srv, &models.CardOperationLog{}, "card_id = ? AND operation_type = ? AND amount = ?", card.ID, service.CardOpTransfer, 50); n != 1 {
+		t.Errorf("transfer logs with the fee = %d, want 1", n)
+	}
+	if n := countRows(t, srv, &models.CardOperationLog{}, "card_id = ? AND operation_type = ?", newCard.ID, service.CardOpOpen); n != 1 {
+		t.Errorf("opening logs of the new card = %d, want 1", n)
+	}
+
+	if resp := srv.Do(t, http.MethodPost, path, body, staffToken(t, srv)); resp.Status != http.StatusConflict {
+		t.Errorf("transferring again: status %d, want 409", resp.Status)
+	}
+}
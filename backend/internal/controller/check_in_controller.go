@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CheckInController struct {
+	service *service.CheckInService
+}
+
+func NewCheckInController(checkInService *service.CheckInService) *CheckInController {
+	return &CheckInController{
+		service: checkInService,
+	}
+}
+
+type CreateCheckInRequest struct {
+	UserID   int64  `json:"user_id" binding:"required"`
+	CardID   *int64 `json:"card_id"` // 不填时自动选择可用会员卡
+	Type     int8   `json:"check_in_type" binding:"required,oneof=1 2 3"`
+	DeviceID string `json:"device_id" binding:"max=50"`
+	Remark   string `json:"remark" binding:"max=200"`
+}
+
+// CreateCheckIn admits a member, taking a visit off a visit card
+func (ctrl *CheckInController) CreateCheckIn(c *gin.Context) {
+	var req CreateCheckInRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	checkIn, err := ctrl.service.CheckIn(c.Request.Context(), service.CheckInInput{
+		UserID:   req.UserID,
+		CardID:   req.CardID,
+		Type:     req.Type,
+		DeviceID: req.DeviceID,
+		Remark:   req.Remark,
+	})
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, checkIn)
+}
@@ -0,0 +1,86 @@
+package controller_test
+
+import (
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/metrics"
+	"net/http"
+	"testing"
+)
+
+func TestCheckInTakesVisits(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000041")
+	visitType := &models.CardType{
+		TypeName:      "次卡",
+		TypeCode:      "T2",
+		DurationType:  service.CardDurationTimes,
+		DurationValue: 2,
+		Price:         100,
+		Status:        1,
+	}
+	if err := srv.DB.Create(visitType).Error; err != nil {
+		t.Fatal(err)
+	}
+	card := buyCard(t, srv, userID, visitType.ID)
+
+	checkIn := func() *apitest.Response {
+		t.Helper()
+		return srv.Do(t, http.MethodPost, "/api/v1/checkins", map[string]interface{}{
+			"user_id": userID, "check_in_type": service.CheckInManual,
+		}, staffToken(t, srv))
+	}
+	succeeded := counterValue(t, metrics.CheckIns.WithLabelValues("manual", "success"))
+	denied := counterValue(t, metrics.CheckIns.WithLabelValues("manual", "denied"))
+	for i := 0; i < 2; i++ {
+		resp := checkIn()
+		if resp.Status != http.StatusOK {
+			t.Fatalf("check-in %d: status %d, %s %s", i+1, resp.Status, resp.Error, resp.Message)
+		}
+		var record models.CheckIn
+		resp.Decode(t, &record)
+		if record.CardID == nil || *record.CardID != card.ID {
+			t.Errorf("check-in %d on card %v, want %d", i+1, record.CardID, card.ID)
+		}
+	}
+	if resp := checkIn(); resp.Status != http.StatusConflict || resp.Error != "NO_VALID_CARD" {
+		t.Errorf("check-in without visits left: status %d, error %q", resp.Status, resp.Error)
+	}
+
+	if got := counterValue(t, metrics.CheckIns.WithLabelValues("manual", "success")) - succeeded; got != 2 {
+		t.Errorf("successful check-ins counted = %.0f, want 2", got)
+	}
+	if got := counterValue(t, metrics.CheckIns.WithLabelValues("manual", "denied")) - denied; got != 1 {
+		t.Errorf("denied check-ins counted = %.0f, want 1", got)
+	}
+
+	if err := srv.DB.First(card, card.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if card.RemainingTimes == nil || *card.RemainingTimes != 0 {
+		t.Errorf("remaining visits = %v, want 0", card.RemainingTimes)
+	}
+	if n := countRows(t, srv, &models.CheckIn{}, "user_id = ?", userID); n != 2 {
+		t.Errorf("check-ins = %d, want 2", n)
+	}
+}
+
+func TestCheckInDeniesBlacklisted(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000042")
+	buyCard(t, srv, userID, createCardType(t, srv, 300).ID)
+	if err := srv.DB.Model(&models.User{}).Where("id = ?", userID).Update("status", service.UserStatusBlacklist).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp := srv.Do(t, http.MethodPost, "/api/v1/checkins", map[string]interface{}{
+		"user_id": userID, "check_in_type": service.CheckInCard,
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusForbidden {
+		t.Errorf("blacklisted check-in: status %d, want 403", resp.Status)
+	}
+	if n := countRows(t, srv, &models.CheckIn{}, "user_id = ?", userID); n != 0 {
+		t.Errorf("check-ins = %d, want 0", n)
+	}
+}
@@ -1,33 +1,77 @@
 package controller
 
 import (
+	"fmt"
 	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
 	"gym-admin/internal/service"
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/logger"
 	"gym-admin/pkg/response"
+	"gym-admin/pkg/spreadsheet"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 type CoachController struct {
-	service *service.CoachService
+	service       *service.CoachService
+	exportService *service.ExportService
 }
 
-func NewCoachController() *CoachController {
+func NewCoachController(coachService *service.CoachService, exportService *service.ExportService) *CoachController {
 	return &CoachController{
-		service: service.NewCoachService(),
+		service:       coachService,
+		exportService: exportService,
 	}
 }
 
+// CreateCoachRequest is a coach profile; the coach number and status are
+// assigned by the service
+type CreateCoachRequest struct {
+	Name           string  `json:"name" binding:"required,max=50"`
+	Gender         int8    `json:"gender" binding:"omitempty,oneof=1 2"`
+	Phone          string  `json:"phone" binding:"required,mobile"`
+	Email          string  `json:"email" binding:"omitempty,email,max=100"`
+	AvatarURL      string  `json:"avatar_url" binding:"max=255"`
+	Specialties    string  `json:"specialties"`
+	Certifications string  `json:"certifications"`
+	Experience     int     `json:"experience" binding:"min=0"`
+	Introduction   string  `json:"introduction"`
+	HourlyRate     float64 `json:"hourly_rate" binding:"min=0"`
+	HireDate       string  `json:"hire_date" binding:"omitempty,date,notfuture"` // YYYY-MM-DD
+	Remark         string  `json:"remark"`
+}
+
 func (ctrl *CoachController) CreateCoach(c *gin.Context) {
-	var coach models.Coach
-	if err := c.ShouldBindJSON(&coach); err != nil {
-		response.BadRequest(c, "Invalid request: "+err.Error())
+	var req CreateCoachRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
 		return
 	}
 
-	if err := ctrl.service.CreateCoach(&coach); err != nil {
-		response.Error(c, 500, err.Error())
+	coach := models.Coach{
+		Name:           req.Name,
+		Gender:         req.Gender,
+		Phone:          req.Phone,
+		Email:          req.Email,
+		AvatarURL:      req.AvatarURL,
+		Specialties:    req.Specialties,
+		Certifications: req.Certifications,
+		Experience:     req.Experience,
+		Introduction:   req.Introduction,
+		HourlyRate:     req.HourlyRate,
+		Remark:         req.Remark,
+	}
+	if req.HireDate != "" {
+		hireDate, _ := time.ParseInLocation("2006-01-02", req.HireDate, time.Local)
+		coach.HireDate = &hireDate
+	}
+
+	if err := ctrl.service.CreateCoach(c.Request.Context(), &coach); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
@@ -41,9 +85,9 @@ func (ctrl *CoachController) GetCoach(c *gin.Context) {
 		return
 	}
 
-	coach, err := ctrl.service.GetCoach(id)
+	coach, err := ctrl.service.GetCoach(c.Request.Context(), id)
 	if err != nil {
-		response.NotFound(c, "Coach not found")
+		response.Fail(c, err)
 		return
 	}
 
@@ -51,28 +95,29 @@ func (ctrl *CoachController) GetCoach(c *gin.Context) {
 }
 
 func (ctrl *CoachController) ListCoaches(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
-
-	var status *int8
-	if statusStr := c.Query("status"); statusStr != "" {
-		s, _ := strconv.ParseInt(statusStr, 10, 8)
-		statusVal := int8(s)
-		status = &statusVal
+	// sort_by=rating|rating_asc predates the sort parameter
+	values := c.Request.URL.Query()
+	if values.Get("sort") == "" {
+		switch values.Get("sort_by") {
+		case "rating":
+			values.Set("sort", "-rating,-total_ratings,-created_at")
+		case "rating_asc":
+			values.Set("sort", "rating,-total_ratings,-created_at")
+		}
+	}
+	q, err := listquery.Parse(values, repository.CoachListSpec)
+	if err != nil {
+		response.Fail(c, err)
+		return
 	}
 
-	coaches, total, err := ctrl.service.ListCoaches(page, pageSize, status)
+	coaches, page, err := ctrl.service.ListCoaches(c.Request.Context(), q)
 	if err != nil {
-		response.InternalServerError(c, "Failed to get coaches")
+		response.Fail(c, err)
 		return
 	}
 
-	response.Success(c, gin.H{
-		"list":      coaches,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	successList(c, q, coaches, page)
 }
 
 func (ctrl *CoachController) UpdateCoach(c *gin.Context) {
@@ -82,14 +127,14 @@ func (ctrl *CoachController) UpdateCoach(c *gin.Context) {
 		return
 	}
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		response.BadRequest(c, "Invalid request: "+err.Error())
+	var req service.CoachUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
 		return
 	}
 
-	if err := ctrl.service.UpdateCoach(id, updates); err != nil {
-		response.Error(c, 500, err.Error())
+	if err := ctrl.service.UpdateCoach(c.Request.Context(), id, req); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
@@ -103,10 +148,33 @@ func (ctrl *CoachController) DeleteCoach(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.service.DeleteCoach(id); err != nil {
-		response.InternalServerError(c, "Failed to delete coach")
+	if err := ctrl.service.DeleteCoach(c.Request.Context(), id); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
 	response.SuccessWithMessage(c, "Coach deleted successfully", nil)
 }
+
+func (ctrl *CoachController) ExportCoaches(c *gin.Context) {
+	format := c.DefaultQuery("format", spreadsheet.FormatXLSX)
+	if _, err := spreadsheet.DetectFormat("export." + format); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	var status *int8
+	if statusStr := c.Query("status"); statusStr != "" {
+		s, _ := strconv.ParseInt(statusStr, 10, 8)
+		statusVal := int8(s)
+		status = &statusVal
+	}
+
+	filename := fmt.Sprintf("coaches_%s.%s", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Type", spreadsheet.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if err := ctrl.exportService.ExportCoaches(c.Request.Context(), c.Writer, format, status); err != nil {
+		logger.Error("Failed to export coaches", zap.Error(err))
+	}
+}
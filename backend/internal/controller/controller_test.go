@@ -0,0 +1,136 @@
+package controller_test
+
+import (
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Staff tokens used by the tests; the IDs need not be users
+const (
+	staffID   int64 = 9001
+	managerID int64 = 9002
+)
+
+func staffToken(t *testing.T, srv *apitest.Server) string {
+	return srv.Token(t, staffID, service.RoleStaff)
+}
+
+func managerToken(t *testing.T, srv *apitest.Server) string {
+	return srv.Token(t, managerID, service.RoleManager)
+}
+
+// createMember creates a member through the API and returns its ID
+func createMember(t *testing.T, srv *apitest.Server, phone string) int64 {
+	t.Helper()
+	resp := srv.Do(t, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":  "测试会员",
+		"phone": phone,
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create member: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var user models.User
+	resp.Decode(t, &user)
+	return user.ID
+}
+
+// createCardType adds a monthly card type to the catalogue. There is no API
+// for card types yet.
+func createCardType(t *testing.T, srv *apitest.Server, price float64) *models.CardType {
+	t.Helper()
+	cardType := &models.CardType{
+		TypeName:      "月卡",
+		TypeCode:      fmt.Sprintf("M%.0f", price),
+		DurationType:  service.CardDurationMonth,
+		DurationValue: 1,
+		Price:         price,
+		Status:        1,
+	}
+	if err := srv.DB.Create(cardType).Error; err != nil {
+		t.Fatalf("create card type: %v", err)
+	}
+	return cardType
+}
+
+// createOrder opens an order with the given items and returns it
+func createOrder(t *testing.T, srv *apitest.Server, userID int64, items ...map[string]interface{}) *models.Order {
+	t.Helper()
+	resp := srv.Do(t, http.MethodPost, "/api/v1/orders", map[string]interface{}{
+		"user_id": userID,
+		"items":   items,
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create order: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var order models.Order
+	resp.Decode(t, &order)
+	return &order
+}
+
+func cardItem(cardTypeID int64) map[string]interface{} {
+	return map[string]interface{}{"item_type": service.OrderItemCard, "item_id": cardTypeID}
+}
+
+func topUpItem(amount float64) map[string]interface{} {
+	return map[string]interface{}{"item_type": service.OrderItemTopUp, "amount": amount}
+}
+
+// payOrder pays an order with the given method
+func payOrder(t *testing.T, srv *apitest.Server, orderID int64, method int8) *apitest.Response {
+	t.Helper()
+	return srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/orders/%d/pay", orderID),
+		map[string]interface{}{"method": method}, staffToken(t, srv))
+}
+
+func getOrder(t *testing.T, srv *apitest.Server, orderID int64) *models.Order {
+	t.Helper()
+	resp := srv.Do(t, http.MethodGet, fmt.Sprintf("/api/v1/orders/%d", orderID), nil, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("get order: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var order models.Order
+	resp.Decode(t, &order)
+	return &order
+}
+
+// countRows counts the rows of a model matching the condition
+func countRows(t *testing.T, srv *apitest.Server, model interface{}, query string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := srv.DB.Model(model).Where(query, args...).Count(&n).Error; err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	return n
+}
+
+// buyCard sells a card of the given type to a member for cash and returns it
+func buyCard(t *testing.T, srv *apitest.Server, userID, cardTypeID int64) *models.MembershipCard {
+	t.Helper()
+	order := createOrder(t, srv, userID, cardItem(cardTypeID))
+	if resp := payOrder(t, srv, order.ID, payment.MethodCash); resp.Status != http.StatusOK {
+		t.Fatalf("pay order: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var card models.MembershipCard
+	if err := srv.DB.Where("user_id = ? AND card_type_id = ?", userID, cardTypeID).Last(&card).Error; err != nil {
+		t.Fatalf("find card: %v", err)
+	}
+	return &card
+}
+
+// counterValue reads the current value of a metrics counter
+func counterValue(t *testing.T, c prometheus.Counter) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
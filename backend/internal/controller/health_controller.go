@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"gym-admin/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthController serves the probes of docker-compose and load balancers.
+// They read the HTTP status, so it is set whatever server.status_compat says.
+type HealthController struct {
+	service *service.HealthService
+}
+
+func NewHealthController(healthService *service.HealthService) *HealthController {
+	return &HealthController{
+		service: healthService,
+	}
+}
+
+// Liveness answers as long as the process can serve HTTP at all
+func (ctrl *HealthController) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness answers 503 when MySQL or Redis cannot be reached or the
+// instance is shutting down
+func (ctrl *HealthController) Readiness(c *gin.Context) {
+	readiness := ctrl.service.Readiness(c.Request.Context())
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
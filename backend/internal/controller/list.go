@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// parseList reads the filter, sort, fields and pagination parameters of a
+// list request, answering the client itself when they are not allowed
+func parseList(c *gin.Context, spec listquery.Spec) (listquery.Query, bool) {
+	q, err := listquery.Parse(c.Request.URL.Query(), spec)
+	if err != nil {
+		response.Fail(c, err)
+		return q, false
+	}
+	return q, true
+}
+
+// successList answers with one page of list: the total and page number in
+// page mode, the cursor of the next page in cursor mode
+func successList(c *gin.Context, q listquery.Query, list interface{}, page listquery.Page) {
+	rows, err := q.Project(list)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	if q.Keyset {
+		response.Success(c, gin.H{
+			"list":        rows,
+			"next_cursor": page.NextCursor,
+			"page_size":   q.PageSize,
+		})
+		return
+	}
+	response.Success(c, gin.H{
+		"list":      rows,
+		"total":     page.Total,
+		"page":      q.Page,
+		"page_size": q.PageSize,
+	})
+}
@@ -0,0 +1,240 @@
+package controller
+
+import (
+	"errors"
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/payment"
+	"gym-admin/pkg/response"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrderController struct {
+	service  *service.OrderService
+	gateways *payment.Registry
+}
+
+func NewOrderController(orderService *service.OrderService, gateways *payment.Registry) *OrderController {
+	return &OrderController{
+		service:  orderService,
+		gateways: gateways,
+	}
+}
+
+type CreateOrderItemRequest struct {
+	ItemType  int8    `json:"item_type" binding:"required,oneof=1 2 3"`
+	ItemID    int64   `json:"item_id"`
+	Quantity  int     `json:"quantity"`
+	Amount    float64 `json:"amount"`                              // 储值充值金额
+	StartDate string  `json:"start_date" binding:"omitempty,date"` // YYYY-MM-DD，会员卡开卡日期
+}
+
+type CreateOrderRequest struct {
+	UserID     int64                    `json:"user_id" binding:"required"`
+	Source     int8                     `json:"source" binding:"omitempty,oneof=1 2"`
+	Items      []CreateOrderItemRequest `json:"items" binding:"required,min=1,dive"`
+	CouponCode string                   `json:"coupon_code"`
+	Remark     string                   `json:"remark"`
+}
+
+type PayOrderRequest struct {
+	Method    int8   `json:"method" binding:"required,oneof=1 2 3 4 5 9"`
+	Reference string `json:"reference"` // POS小票号
+}
+
+// CreateOrder opens an order for cards, lesson packages or wallet top-ups
+func (ctrl *OrderController) CreateOrder(c *gin.Context) {
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	items, err := orderItemInputs(req.Items)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	operatorID := c.GetInt64("user_id")
+	order, err := ctrl.service.CreateOrder(c.Request.Context(), req.UserID, req.Source, items, req.CouponCode, &operatorID, req.Remark)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, order)
+}
+
+// PreviewOrder prices an order with promotions and coupon without creating it
+func (ctrl *OrderController) PreviewOrder(c *gin.Context) {
+	var req CreateOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	items, err := orderItemInputs(req.Items)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	order, err := ctrl.service.PreviewOrder(c.Request.Context(), req.UserID, req.Source, items, req.CouponCode)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, order)
+}
+
+func orderItemInputs(reqItems []CreateOrderItemRequest) ([]service.OrderItemInput, error) {
+	items := make([]service.OrderItemInput, 0, len(reqItems))
+	for _, it := range reqItems {
+		in := service.OrderItemInput{ItemType: it.ItemType, ItemID: it.ItemID, Quantity: it.Quantity, Amount: it.Amount}
+		if it.StartDate != "" {
+			t, err := time.ParseInLocation("2006-01-02", it.StartDate, time.Local)
+			if err != nil {
+				return nil, errors.New("invalid start_date, expected YYYY-MM-DD")
+			}
+			in.StartDate = &t
+		}
+		items = append(items, in)
+	}
+	return items, nil
+}
+
+// GetOrder gets order by ID with its items
+func (ctrl *OrderController) GetOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	order, err := ctrl.service.GetOrder(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, order)
+}
+
+// ListOrders lists orders with pagination
+func (ctrl *OrderController) ListOrders(c *gin.Context) {
+	q, ok := parseList(c, repository.OrderListSpec)
+	if !ok {
+		return
+	}
+
+	orders, page, err := ctrl.service.ListOrders(c.Request.Context(), q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, orders, page)
+}
+
+// ListPayments lists the payment attempts of an order
+func (ctrl *OrderController) ListPayments(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	payments, err := ctrl.service.ListPayments(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, payments)
+}
+
+// PayOrder starts paying an order with the chosen method
+func (ctrl *OrderController) PayOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	var req PayOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+	// Cash and POS payments are recorded by the front desk once the money is
+	// in hand. Members pay online, where the gateway confirms the payment, or
+	// from their wallet.
+	if c.GetString("role") == service.RoleMember && (req.Method == payment.MethodCash || req.Method == payment.MethodPOS) {
+		response.Forbidden(c, "Offline payments are recorded by staff")
+		return
+	}
+
+	result, err := ctrl.service.PayOrder(c.Request.Context(), id, req.Method, req.Reference, c.ClientIP())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// CancelOrder cancels an unpaid order
+func (ctrl *OrderController) CancelOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	if err := ctrl.service.CancelOrder(c.Request.Context(), id); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Order cancelled successfully", nil)
+}
+
+// FulfilOrder retries delivering a paid order whose fulfilment failed
+func (ctrl *OrderController) FulfilOrder(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	order, err := ctrl.service.FulfilOrder(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, order)
+}
+
+// PaymentNotify receives asynchronous payment results. The response format is
+// dictated by each gateway, so it is written by the gateway itself.
+func (ctrl *OrderController) PaymentNotify(c *gin.Context) {
+	gateway, err := ctrl.gateways.GetByName(c.Param("gateway"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	err = ctrl.service.HandleNotify(c.Request.Context(), gateway, c.Request)
+	if errors.Is(err, payment.ErrNotifyUnsupported) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	gateway.AckNotify(c.Writer, err)
+}
@@ -0,0 +1,273 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPayAndFulfilOrder(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000001")
+	cardType := createCardType(t, srv, 300)
+	order := createOrder(t, srv, userID, cardItem(cardType.ID))
+	if order.Status != service.OrderStatusPending || order.PayAmount != 300 {
+		t.Fatalf("new order: status %d, pay amount %.2f", order.Status, order.PayAmount)
+	}
+
+	resp := payOrder(t, srv, order.ID, payment.MethodCash)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("pay: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var result service.PayOrderResult
+	resp.Decode(t, &result)
+	if result.Payment.Status != service.PaymentStatusSuccess {
+		t.Fatalf("payment status = %d, want success", result.Payment.Status)
+	}
+
+	order = getOrder(t, srv, order.ID)
+	if order.Status != service.OrderStatusPaid || order.FulfilledAt == nil {
+		t.Fatalf("paid order: status %d, fulfilled at %v", order.Status, order.FulfilledAt)
+	}
+	item := order.Items[0]
+	if item.FulfilledRefID == nil {
+		t.Fatal("card item was not linked to a card")
+	}
+	var card models.MembershipCard
+	if err := srv.DB.First(&card, *item.FulfilledRefID).Error; err != nil {
+		t.Fatalf("issued card: %v", err)
+	}
+	if card.UserID != userID || card.Status != service.CardStatusNormal || card.PurchasePrice != 300 {
+		t.Errorf("issued card: user %d, status %d, price %.2f", card.UserID, card.Status, card.PurchasePrice)
+	}
+	if n := countRows(t, srv, &models.CardOperationLog{}, "card_id = ? AND operation_type = ?", card.ID, service.CardOpOpen); n != 1 {
+		t.Errorf("opening logs = %d, want 1", n)
+	}
+
+	// Fulfilling again delivers nothing more
+	resp = srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/orders/%d/fulfil", order.ID), nil, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("fulfil: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	if n := countRows(t, srv, &models.MembershipCard{}, "order_id = ?", order.ID); n != 1 {
+		t.Errorf("cards issued = %d, want 1", n)
+	}
+
+	if resp := payOrder(t, srv, order.ID, payment.MethodCash); resp.Status != http.StatusConflict {
+		t.Errorf("paying a paid order: status %d, want 409", resp.Status)
+	}
+}
+
+func TestMembersCannotPayOffline(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000004")
+	cardType := createCardType(t, srv, 300)
+	order := createOrder(t, srv, userID, cardItem(cardType.ID))
+	member := srv.Token(t, userID, service.RoleMember)
+
+	for _, method := range []int8{payment.MethodCash, payment.MethodPOS} {
+		resp := srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/orders/%d/pay", order.ID),
+			map[string]interface{}{"method": method, "reference": "P1"}, member)
+		if resp.Status != http.StatusForbidden {
+			t.Errorf("member paying with method %d: status %d, want 403", method, resp.Status)
+		}
+	}
+	if order = getOrder(t, srv, order.ID); order.Status != service.OrderStatusPending {
+		t.Errorf("order status = %d, want still pending", order.Status)
+	}
+}
+
+func TestMockNotifyIsIdempotent(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000002")
+	cardType := createCardType(t, srv, 200)
+	order := createOrder(t, srv, userID, cardItem(cardType.ID))
+
+	resp := payOrder(t, srv, order.ID, payment.MethodMock)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("pay: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var result service.PayOrderResult
+	resp.Decode(t, &result)
+	if result.Payment.Status != service.PaymentStatusPending || result.Params["pay_url"] == "" {
+		t.Fatalf("mock payment: status %d, params %v", result.Payment.Status, result.Params)
+	}
+
+	body, err := json.Marshal(payment.MockNotification{
+		PaymentNo: result.Payment.PaymentNo,
+		TradeNo:   "MOCK-TRADE-1",
+		Amount:    result.Payment.Amount,
+		Status:    "SUCCESS",
+		PaidAt:    time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if status := notifyMock(t, srv, body, payment.NewMockGateway(apitest.MockSecret).Sign(body)); status != http.StatusOK {
+			t.Fatalf("notification %d: status %d", i+1, status)
+		}
+	}
+
+	order = getOrder(t, srv, order.ID)
+	if order.Status != service.OrderStatusPaid || order.FulfilledAt == nil {
+		t.Fatalf("notified order: status %d, fulfilled at %v", order.Status, order.FulfilledAt)
+	}
+	if n := countRows(t, srv, &models.MembershipCard{}, "order_id = ?", order.ID); n != 1 {
+		t.Errorf("cards issued = %d, want 1", n)
+	}
+	if n := countRows(t, srv, &models.Payment{}, "order_id = ? AND status = ?", order.ID, service.PaymentStatusSuccess); n != 1 {
+		t.Errorf("successful payments = %d, want 1", n)
+	}
+
+	if status := notifyMock(t, srv, body, "forged"); status != http.StatusBadRequest {
+		t.Errorf("forged notification: status %d, want 400", status)
+	}
+}
+
+func TestPayOrderFromWallet(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000003")
+	topUp := createOrder(t, srv, userID, topUpItem(500))
+	if resp := payOrder(t, srv, topUp.ID, payment.MethodCash); resp.Status != http.StatusOK {
+		t.Fatalf("pay top-up: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+
+	cardType := createCardType(t, srv, 300)
+	order := createOrder(t, srv, userID, cardItem(cardType.ID))
+	if resp := payOrder(t, srv, order.ID, payment.MethodWallet); resp.Status != http.StatusOK {
+		t.Fatalf("pay from wallet: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	if resp := payOrder(t, srv, order.ID, payment.MethodWallet); resp.Status != http.StatusConflict {
+		t.Errorf("paying twice: status %d, want 409", resp.Status)
+	}
+
+	var wallet models.Wallet
+	if err := srv.DB.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Balance != 200 {
+		t.Errorf("wallet balance = %.2f, want 200", wallet.Balance)
+	}
+	if order = getOrder(t, srv, order.ID); order.Status != service.OrderStatusPaid || order.FulfilledAt == nil {
+		t.Errorf("order: status %d, fulfilled at %v", order.Status, order.FulfilledAt)
+	}
+
+	// Not enough left: neither the wallet nor the order changes
+	second := createOrder(t, srv, userID, cardItem(cardType.ID))
+	if resp := payOrder(t, srv, second.ID, payment.MethodWallet); resp.Status != http.StatusConflict {
+		t.Errorf("insufficient balance: status %d, want 409", resp.Status)
+	}
+	if second = getOrder(t, srv, second.ID); second.Status != service.OrderStatusPending {
+		t.Errorf("unpaid order status = %d, want pending", second.Status)
+	}
+}
+
+func TestLatePaymentOfClosedOrder(t *testing.T) {
+	srv := apitest.New(t)
+	cardType := createCardType(t, srv, 300)
+	resp := srv.Do(t, http.MethodPost, "/api/v1/promotions", map[string]interface{}{
+		"name": "首单立减", "rule_type": service.PromotionAmountOff, "value": 50, "total_limit": 1,
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create promotion: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var promotion models.Promotion
+	resp.Decode(t, &promotion)
+
+	// closedOrder opens a discounted order, starts paying it online and lets
+	// it expire before the payment is confirmed
+	closedOrder := func(phone string) (*models.Order, []byte) {
+		t.Helper()
+		order := createOrder(t, srv, createMember(t, srv, phone), cardItem(cardType.ID))
+		if order.PayAmount != 250 {
+			t.Fatalf("pay amount = %.2f, want 250 with the promotion", order.PayAmount)
+		}
+		resp := payOrder(t, srv, order.ID, payment.MethodMock)
+		if resp.Status != http.StatusOK {
+			t.Fatalf("pay: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+		}
+		var result service.PayOrderResult
+		resp.Decode(t, &result)
+		if err := srv.DB.Model(&models.Order{}).Where("id = ?", order.ID).
+			Update("expire_at", time.Now().Add(-time.Minute)).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.Container.OrderService.CloseExpiredOrders(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(payment.MockNotification{
+			PaymentNo: result.Payment.PaymentNo, TradeNo: "MOCK-" + phone, Amount: result.Payment.Amount,
+			Status: "SUCCESS", PaidAt: time.Now(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order, body
+	}
+	usedCount := func() int {
+		t.Helper()
+		var p models.Promotion
+		if err := srv.DB.First(&p, promotion.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return p.UsedCount
+	}
+	sign := payment.NewMockGateway(apitest.MockSecret).Sign
+
+	// Both orders took the promotion while pending and gave it back on closing
+	first, firstBody := closedOrder("13800000031")
+	second, secondBody := closedOrder("13800000032")
+	if n := usedCount(); n != 0 {
+		t.Fatalf("used count after closing = %d, want 0", n)
+	}
+
+	// Nobody took the promotion meanwhile: the order is revived with it
+	if status := notifyMock(t, srv, firstBody, sign(firstBody)); status != http.StatusOK {
+		t.Fatalf("late notification: status %d", status)
+	}
+	if order := getOrder(t, srv, first.ID); order.Status != service.OrderStatusPaid {
+		t.Errorf("revived order status = %d, want paid", order.Status)
+	}
+	if n := usedCount(); n != 1 {
+		t.Errorf("used count after reviving = %d, want 1", n)
+	}
+
+	// The promotion is used up by the first order: the late payment of the
+	// second is recorded but does not revive it
+	if status := notifyMock(t, srv, secondBody, sign(secondBody)); status != http.StatusOK {
+		t.Fatalf("late notification: status %d", status)
+	}
+	if order := getOrder(t, srv, second.ID); order.Status != service.OrderStatusClosed {
+		t.Errorf("order status = %d, want still closed", order.Status)
+	}
+	if n := countRows(t, srv, &models.Payment{}, "order_id = ? AND status = ?", second.ID, service.PaymentStatusSuccess); n != 1 {
+		t.Errorf("successful payments = %d, want the late one recorded", n)
+	}
+	if n := usedCount(); n != 1 {
+		t.Errorf("used count = %d, want 1", n)
+	}
+}
+
+func notifyMock(t *testing.T, srv *apitest.Server, body []byte, signature string) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/v1/payments/notify/mock", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(payment.MockSignatureHeader, signature)
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PointsController struct {
+	service *service.PointsService
+}
+
+func NewPointsController(pointsService *service.PointsService) *PointsController {
+	return &PointsController{
+		service: pointsService,
+	}
+}
+
+type RedeemPointsRequest struct {
+	RewardID int64  `json:"reward_id" binding:"required"`
+	CardID   *int64 `json:"card_id"` // 兑换会员卡天数时必填
+}
+
+type SetPointsStatusRequest struct {
+	Status int8 `json:"status" binding:"required,oneof=1 2"`
+}
+
+// GetAccount gets the points balance of a member
+func (ctrl *PointsController) GetAccount(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	account, err := ctrl.service.GetAccount(c.Request.Context(), userID)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, account)
+}
+
+// ListTransactions lists the points ledger of a member with pagination
+func (ctrl *PointsController) ListTransactions(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	q, ok := parseList(c, repository.PointTransactionListSpec)
+	if !ok {
+		return
+	}
+
+	entries, page, err := ctrl.service.ListTransactions(c.Request.Context(), userID, q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, entries, page)
+}
+
+// Redeem exchanges a member's points for a reward
+func (ctrl *PointsController) Redeem(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var req RedeemPointsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	redemption, err := ctrl.service.Redeem(c.Request.Context(), userID, req.RewardID, req.CardID, c.GetInt64("user_id"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, redemption)
+}
+
+// GetLeaderboard ranks members by points earned in a month, for the mini
+// program. Members see their own rank alongside the list.
+func (ctrl *PointsController) GetLeaderboard(c *gin.Context) {
+	month := time.Now()
+	if v := c.Query("month"); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, time.Local)
+		if err != nil {
+			response.BadRequest(c, "invalid month, expected YYYY-MM")
+			return
+		}
+		month = t
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	var userID int64
+	if c.GetString("role") == "user" {
+		userID = c.GetInt64("user_id")
+	}
+
+	board, err := ctrl.service.Leaderboard(c.Request.Context(), month, limit, userID)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, board)
+}
+
+// ListRules lists the points earning rules
+func (ctrl *PointsController) ListRules(c *gin.Context) {
+	rules, err := ctrl.service.ListRules(c.Request.Context(), queryStatus(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rules)
+}
+
+// CreateRule creates a points earning rule
+func (ctrl *PointsController) CreateRule(c *gin.Context) {
+	var req service.PointRuleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	rule, err := ctrl.service.CreateRule(c.Request.Context(), req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rule)
+}
+
+// UpdateRule updates a points earning rule
+func (ctrl *PointsController) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid rule ID")
+		return
+	}
+
+	var req service.PointRuleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	rule, err := ctrl.service.UpdateRule(c.Request.Context(), id, req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rule)
+}
+
+// SetRuleStatus enables or disables a points earning rule
+func (ctrl *PointsController) SetRuleStatus(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid rule ID")
+		return
+	}
+
+	var req SetPointsStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.SetRuleStatus(c.Request.Context(), id, req.Status); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Points rule updated successfully", nil)
+}
+
+// ListRewards lists the rewards points can be redeemed for
+func (ctrl *PointsController) ListRewards(c *gin.Context) {
+	rewards, err := ctrl.service.ListRewards(c.Request.Context(), queryStatus(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rewards)
+}
+
+// CreateReward creates a points reward
+func (ctrl *PointsController) CreateReward(c *gin.Context) {
+	var req service.PointRewardInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	reward, err := ctrl.service.CreateReward(c.Request.Context(), req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, reward)
+}
+
+// UpdateReward updates a points reward
+func (ctrl *PointsController) UpdateReward(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid reward ID")
+		return
+	}
+
+	var req service.PointRewardInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	reward, err := ctrl.service.UpdateReward(c.Request.Context(), id, req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, reward)
+}
+
+// SetRewardStatus puts a reward on or off the shelf
+func (ctrl *PointsController) SetRewardStatus(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid reward ID")
+		return
+	}
+
+	var req SetPointsStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.SetRewardStatus(c.Request.Context(), id, req.Status); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Reward updated successfully", nil)
+}
+
+func queryStatus(c *gin.Context) *int8 {
+	v, err := strconv.ParseInt(c.Query("status"), 10, 8)
+	if err != nil {
+		return nil
+	}
+	status := int8(v)
+	return &status
+}
@@ -0,0 +1,55 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"net/http"
+	"testing"
+)
+
+func TestRefundTakesBackPurchasePoints(t *testing.T) {
+	srv := apitest.New(t)
+	if resp := srv.Do(t, http.MethodPost, "/api/v1/points/rules", map[string]interface{}{
+		"name": "消费积分", "rule_type": service.PointRulePurchase, "points": 1, "per_yuan": 1,
+	}, staffToken(t, srv)); resp.Status != http.StatusOK {
+		t.Fatalf("create rule: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	order := paidCardOrder(t, srv, "13800000071", 300)
+	if err := srv.Container.PointsService.AwardPoints(context.Background()); err != nil {
+		t.Fatalf("AwardPoints: %v", err)
+	}
+	balance := func() int64 {
+		t.Helper()
+		var account models.PointAccount
+		if err := srv.DB.Where("user_id = ?", order.UserID).First(&account).Error; err != nil {
+			t.Fatal(err)
+		}
+		return account.Balance
+	}
+	if got := balance(); got != 300 {
+		t.Fatalf("balance after paying = %d, want 300", got)
+	}
+
+	resp := requestRefund(t, srv, order.ID, map[string]interface{}{"amount": 100, "reason": "少上一个月"}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("request refund: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var refund models.Refund
+	resp.Decode(t, &refund)
+	if resp := srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/refunds/%d/approve", refund.ID), nil, managerToken(t, srv)); resp.Status != http.StatusOK {
+		t.Fatalf("approve: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+
+	if got := balance(); got != 200 {
+		t.Errorf("balance after refunding a third = %d, want 200", got)
+	}
+	if n := countRows(t, srv, &models.PointTransaction{}, "type = ? AND source_id = ? AND points = ?", service.PointTxRefund, refund.ID, -100); n != 1 {
+		t.Errorf("reversal entries = %d, want one of -100", n)
+	}
+	if n := countRows(t, srv, &models.PointTransaction{}, "type = ? AND source_id = ? AND remaining = ?", service.PointTxPurchase, order.ID, 200); n != 1 {
+		t.Errorf("purchase entries with 200 left = %d, want 1", n)
+	}
+}
@@ -0,0 +1,164 @@
+package controller
+
+import (
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PromotionController struct {
+	service *service.PromotionService
+}
+
+func NewPromotionController(promotionService *service.PromotionService) *PromotionController {
+	return &PromotionController{
+		service: promotionService,
+	}
+}
+
+type SetPromotionStatusRequest struct {
+	Status int8 `json:"status" binding:"required,oneof=1 2"`
+}
+
+// CreatePromotion creates a promotion rule
+func (ctrl *PromotionController) CreatePromotion(c *gin.Context) {
+	var req service.PromotionInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	promotion, err := ctrl.service.CreatePromotion(c.Request.Context(), req, c.GetInt64("user_id"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, promotion)
+}
+
+// GetPromotion gets promotion by ID
+func (ctrl *PromotionController) GetPromotion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid promotion ID")
+		return
+	}
+
+	promotion, err := ctrl.service.GetPromotion(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, promotion)
+}
+
+// ListPromotions lists promotions with pagination
+func (ctrl *PromotionController) ListPromotions(c *gin.Context) {
+	q, ok := parseList(c, repository.PromotionListSpec)
+	if !ok {
+		return
+	}
+
+	promotions, page, err := ctrl.service.ListPromotions(c.Request.Context(), q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, promotions, page)
+}
+
+// UpdatePromotion updates a promotion rule
+func (ctrl *PromotionController) UpdatePromotion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid promotion ID")
+		return
+	}
+
+	var req service.PromotionInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	promotion, err := ctrl.service.UpdatePromotion(c.Request.Context(), id, req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, promotion)
+}
+
+// SetPromotionStatus enables or disables a promotion
+func (ctrl *PromotionController) SetPromotionStatus(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid promotion ID")
+		return
+	}
+
+	var req SetPromotionStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.SetPromotionStatus(c.Request.Context(), id, req.Status); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Promotion updated successfully", nil)
+}
+
+// CreateCoupons issues coupon codes for a promotion
+func (ctrl *PromotionController) CreateCoupons(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid promotion ID")
+		return
+	}
+
+	var req service.CouponBatchInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	coupons, err := ctrl.service.CreateCoupons(c.Request.Context(), id, req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, coupons)
+}
+
+// ListCoupons lists the coupon codes of a promotion with their usage counts
+func (ctrl *PromotionController) ListCoupons(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid promotion ID")
+		return
+	}
+
+	q, ok := parseList(c, repository.CouponListSpec)
+	if !ok {
+		return
+	}
+
+	coupons, page, err := ctrl.service.ListCoupons(c.Request.Context(), id, q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, coupons, page)
+}
@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReferralController struct {
+	service *service.ReferralService
+}
+
+func NewReferralController(referralService *service.ReferralService) *ReferralController {
+	return &ReferralController{
+		service: referralService,
+	}
+}
+
+type SetReferralRuleStatusRequest struct {
+	Status int8 `json:"status" binding:"required,oneof=1 2"`
+}
+
+// GetInfo gets a member's referral code and referral totals
+func (ctrl *ReferralController) GetInfo(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	info, err := ctrl.service.GetInfo(c.Request.Context(), userID)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, info)
+}
+
+// ListReferrals lists the members a member referred with pagination
+func (ctrl *ReferralController) ListReferrals(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	q, ok := parseList(c, repository.ReferralListSpec)
+	if !ok {
+		return
+	}
+
+	referrals, page, err := ctrl.service.ListReferrals(c.Request.Context(), userID, q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, referrals, page)
+}
+
+// ListRules lists the referral reward rules
+func (ctrl *ReferralController) ListRules(c *gin.Context) {
+	rules, err := ctrl.service.ListRules(c.Request.Context(), queryStatus(c))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rules)
+}
+
+// CreateRule creates a referral reward rule
+func (ctrl *ReferralController) CreateRule(c *gin.Context) {
+	var req service.ReferralRuleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	rule, err := ctrl.service.CreateRule(c.Request.Context(), req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rule)
+}
+
+// UpdateRule updates a referral reward rule
+func (ctrl *ReferralController) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid rule ID")
+		return
+	}
+
+	var req service.ReferralRuleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	rule, err := ctrl.service.UpdateRule(c.Request.Context(), id, req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rule)
+}
+
+// SetRuleStatus enables or disables a referral reward rule
+func (ctrl *ReferralController) SetRuleStatus(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid rule ID")
+		return
+	}
+
+	var req SetReferralRuleStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.SetRuleStatus(c.Request.Context(), id, req.Status); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Referral rule updated successfully", nil)
+}
@@ -0,0 +1,95 @@
+package controller_test
+
+import (
+	"context"
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReferralRewardFollowsPaidCard(t *testing.T) {
+	srv := apitest.New(t)
+	referrerID := createMember(t, srv, "13800000061")
+	rewardCard := buyCard(t, srv, referrerID, createCardType(t, srv, 300).ID)
+	var referrer models.User
+	if err := srv.DB.First(&referrer, referrerID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if resp := srv.Do(t, http.MethodPost, "/api/v1/referrals/rules", map[string]interface{}{
+		"name": "推荐送7天", "days": 7,
+	}, staffToken(t, srv)); resp.Status != http.StatusOK {
+		t.Fatalf("create rule: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+
+	resp := srv.Do(t, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name": "被推荐人", "phone": "13800000062", "invite_code": *referrer.ReferralCode,
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create referee: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var referee models.User
+	resp.Decode(t, &referee)
+
+	referral := func() models.Referral {
+		t.Helper()
+		if err := srv.Container.ReferralService.RewardReferrals(context.Background()); err != nil {
+			t.Fatalf("RewardReferrals: %v", err)
+		}
+		var r models.Referral
+		if err := srv.DB.Where("referee_id = ?", referee.ID).First(&r).Error; err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	endDate := func() time.Time {
+		t.Helper()
+		var card models.MembershipCard
+		if err := srv.DB.First(&card, rewardCard.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return card.EndDate
+	}
+
+	// A card with a price but no paid order, as imported, does not qualify
+	imported := &models.MembershipCard{CardNo: "IMPORTED1", UserID: referee.ID, CardTypeID: rewardCard.CardTypeID,
+		StartDate: rewardCard.StartDate, EndDate: rewardCard.EndDate, Status: service.CardStatusNormal, PurchasePrice: 300}
+	if err := srv.DB.Create(imported).Error; err != nil {
+		t.Fatal(err)
+	}
+	if r := referral(); r.Status != service.ReferralStatusPending {
+		t.Fatalf("referral with an imported card: status %d, want pending", r.Status)
+	}
+
+	order := createOrder(t, srv, referee.ID, cardItem(createCardType(t, srv, 200).ID))
+	if resp := payOrder(t, srv, order.ID, payment.MethodCash); resp.Status != http.StatusOK {
+		t.Fatalf("pay: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	r := referral()
+	if r.Status != service.ReferralStatusRewarded || r.RewardDays != 7 {
+		t.Fatalf("referral after a paid card: %+v, want rewarded with 7 days", r)
+	}
+	if got, want := endDate(), rewardCard.EndDate.AddDate(0, 0, 7); !got.Equal(want) {
+		t.Errorf("rewarded card ends %s, want %s", got, want)
+	}
+
+	resp = requestRefund(t, srv, order.ID, map[string]interface{}{"amount": 200, "reason": "不想练了"}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("request refund: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var refund models.Refund
+	resp.Decode(t, &refund)
+	if resp := srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/refunds/%d/approve", refund.ID), nil, managerToken(t, srv)); resp.Status != http.StatusOK {
+		t.Fatalf("approve: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	if r := referral(); r.Status != service.ReferralStatusRevoked {
+		t.Errorf("referral after the refund: status %d, want revoked", r.Status)
+	}
+	if got := endDate(); !got.Equal(rewardCard.EndDate) {
+		t.Errorf("card after the refund ends %s, want %s", got, rewardCard.EndDate)
+	}
+}
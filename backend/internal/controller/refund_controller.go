@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RefundController struct {
+	service *service.RefundService
+}
+
+func NewRefundController(refundService *service.RefundService) *RefundController {
+	return &RefundController{
+		service: refundService,
+	}
+}
+
+type CreateRefundRequest struct {
+	Amount      float64 `json:"amount" binding:"required,gt=0"`
+	Reason      string  `json:"reason" binding:"required"`
+	OrderItemID *int64  `json:"order_item_id"`
+	Sessions    int     `json:"sessions"` // 私教课包退掉的课时数，0表示剩余全部
+}
+
+type RejectRefundRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CreateRefund requests a full or partial refund of a paid order
+func (ctrl *RefundController) CreateRefund(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	var req CreateRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	in := service.RefundInput{
+		Amount:      req.Amount,
+		Reason:      req.Reason,
+		OrderItemID: req.OrderItemID,
+		Sessions:    req.Sessions,
+	}
+	refund, err := ctrl.service.RequestRefund(c.Request.Context(), orderID, in, c.GetInt64("user_id"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, refund)
+}
+
+// ListOrderRefunds lists the refunds of an order
+func (ctrl *RefundController) ListOrderRefunds(c *gin.Context) {
+	orderID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid order ID")
+		return
+	}
+
+	refunds, err := ctrl.service.ListOrderRefunds(c.Request.Context(), orderID)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, refunds)
+}
+
+// GetRefund gets refund by ID
+func (ctrl *RefundController) GetRefund(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid refund ID")
+		return
+	}
+
+	refund, err := ctrl.service.GetRefund(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, refund)
+}
+
+// ListRefunds lists refunds, e.g. those awaiting approval with status=1
+func (ctrl *RefundController) ListRefunds(c *gin.Context) {
+	q, ok := parseList(c, repository.RefundListSpec)
+	if !ok {
+		return
+	}
+
+	refunds, page, err := ctrl.service.ListRefunds(c.Request.Context(), q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, refunds, page)
+}
+
+// ApproveRefund approves a refund above the threshold and sends it out
+func (ctrl *RefundController) ApproveRefund(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid refund ID")
+		return
+	}
+
+	refund, err := ctrl.service.ApproveRefund(c.Request.Context(), id, c.GetInt64("user_id"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, refund)
+}
+
+// RejectRefund rejects a refund request
+func (ctrl *RefundController) RejectRefund(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid refund ID")
+		return
+	}
+
+	var req RejectRefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.RejectRefund(c.Request.Context(), id, c.GetInt64("user_id"), req.Reason); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Refund rejected successfully", nil)
+}
+
+// RetryRefund sends a failed refund to the payment channel again
+func (ctrl *RefundController) RetryRefund(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid refund ID")
+		return
+	}
+
+	refund, err := ctrl.service.RetryRefund(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, refund)
+}
@@ -0,0 +1,166 @@
+package controller_test
+
+import (
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"testing"
+)
+
+// paidCardOrder opens a card order for a new member and pays it in cash
+func paidCardOrder(t *testing.T, srv *apitest.Server, phone string, price float64) *models.Order {
+	t.Helper()
+	userID := createMember(t, srv, phone)
+	cardType := createCardType(t, srv, price)
+	order := createOrder(t, srv, userID, cardItem(cardType.ID))
+	if resp := payOrder(t, srv, order.ID, payment.MethodCash); resp.Status != http.StatusOK {
+		t.Fatalf("pay: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	return getOrder(t, srv, order.ID)
+}
+
+func requestRefund(t *testing.T, srv *apitest.Server, orderID int64, body map[string]interface{}, token string) *apitest.Response {
+	t.Helper()
+	return srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/orders/%d/refunds", orderID), body, token)
+}
+
+func TestRefundRequestAndApprove(t *testing.T) {
+	srv := apitest.New(t)
+	order := paidCardOrder(t, srv, "13800000011", 300)
+	body := map[string]interface{}{"amount": 300, "reason": "会员搬家"}
+
+	member := srv.Token(t, order.UserID, service.RoleMember)
+	if resp := requestRefund(t, srv, order.ID, body, member); resp.Status != http.StatusForbidden {
+		t.Errorf("member requesting a refund: status %d, want 403", resp.Status)
+	}
+
+	// apitest leaves the approval threshold at zero, so every refund waits for a manager
+	resp := requestRefund(t, srv, order.ID, body, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("request refund: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var refund models.Refund
+	resp.Decode(t, &refund)
+	if refund.Status != service.RefundStatusPendingApproval {
+		t.Fatalf("refund status = %d, want pending approval", refund.Status)
+	}
+
+	approve := fmt.Sprintf("/api/v1/refunds/%d/approve", refund.ID)
+	if resp := srv.Do(t, http.MethodPost, approve, nil, staffToken(t, srv)); resp.Status != http.StatusForbidden {
+		t.Errorf("staff approving: status %d, want 403", resp.Status)
+	}
+	resp = srv.Do(t, http.MethodPost, approve, nil, managerToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("approve: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	resp.Decode(t, &refund)
+	if refund.Status != service.RefundStatusSuccess {
+		t.Fatalf("approved refund status = %d, want success", refund.Status)
+	}
+	if resp := srv.Do(t, http.MethodPost, approve, nil, managerToken(t, srv)); resp.Status != http.StatusConflict {
+		t.Errorf("approving twice: status %d, want 409", resp.Status)
+	}
+
+	order = getOrder(t, srv, order.ID)
+	if order.Status != service.OrderStatusRefunded || order.RefundedAmount != 300 || order.RefundingAmount != 0 {
+		t.Errorf("refunded order: status %d, refunded %.2f, refunding %.2f", order.Status, order.RefundedAmount, order.RefundingAmount)
+	}
+	var card models.MembershipCard
+	if err := srv.DB.First(&card, *order.Items[0].FulfilledRefID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if card.Status != service.CardStatusRefunded {
+		t.Errorf("card status = %d, want refunded", card.Status)
+	}
+	if n := countRows(t, srv, &models.CardOperationLog{}, "card_id = ? AND operation_type = ?", card.ID, service.CardOpRefund); n != 1 {
+		t.Errorf("refund logs = %d, want 1", n)
+	}
+}
+
+func TestRejectRefundReleasesAmount(t *testing.T) {
+	srv := apitest.New(t)
+	order := paidCardOrder(t, srv, "13800000012", 300)
+
+	resp := requestRefund(t, srv, order.ID, map[string]interface{}{"amount": 100, "reason": "价格调整"}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("request refund: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var refund models.Refund
+	resp.Decode(t, &refund)
+	if order = getOrder(t, srv, order.ID); order.RefundingAmount != 100 {
+		t.Errorf("refunding amount = %.2f, want 100", order.RefundingAmount)
+	}
+
+	resp = srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/refunds/%d/reject", refund.ID),
+		map[string]interface{}{"reason": "不符合退款条件"}, managerToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("reject: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	if order = getOrder(t, srv, order.ID); order.RefundingAmount != 0 || order.Status != service.OrderStatusPaid {
+		t.Errorf("order after rejection: status %d, refunding %.2f", order.Status, order.RefundingAmount)
+	}
+}
+
+func TestPartialItemRefunds(t *testing.T) {
+	srv := apitest.New(t)
+	order := paidCardOrder(t, srv, "13800000013", 300)
+	item := order.Items[0]
+	cardID := *item.FulfilledRefID
+
+	refundItem := func(amount float64) *apitest.Response {
+		t.Helper()
+		resp := requestRefund(t, srv, order.ID, map[string]interface{}{
+			"amount": amount, "reason": "部分退款", "order_item_id": item.ID,
+		}, staffToken(t, srv))
+		if resp.Status != http.StatusOK {
+			return resp
+		}
+		var refund models.Refund
+		resp.Decode(t, &refund)
+		return srv.Do(t, http.MethodPost, fmt.Sprintf("/api/v1/refunds/%d/approve", refund.ID), nil, managerToken(t, srv))
+	}
+	refundLogs := func() []models.CardOperationLog {
+		t.Helper()
+		var logs []models.CardOperationLog
+		if err := srv.DB.Where("card_id = ? AND operation_type = ?", cardID, service.CardOpRefund).Order("id").Find(&logs).Error; err != nil {
+			t.Fatal(err)
+		}
+		return logs
+	}
+	cardStatus := func() int8 {
+		t.Helper()
+		var card models.MembershipCard
+		if err := srv.DB.First(&card, cardID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return card.Status
+	}
+
+	if resp := refundItem(100); resp.Status != http.StatusOK {
+		t.Fatalf("first partial refund: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	if status := cardStatus(); status != service.CardStatusNormal {
+		t.Errorf("card status after a partial refund = %d, want normal", status)
+	}
+	if logs := refundLogs(); len(logs) != 1 || logs[0].Amount != 100 {
+		t.Errorf("refund logs after a partial refund = %+v, want one of 100", logs)
+	}
+
+	// 200 is left of the item
+	if resp := refundItem(250); resp.Status != http.StatusBadRequest {
+		t.Errorf("refunding more than is left of the item: status %d, want 400", resp.Status)
+	}
+
+	if resp := refundItem(200); resp.Status != http.StatusOK {
+		t.Fatalf("second partial refund: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	if status := cardStatus(); status != service.CardStatusRefunded {
+		t.Errorf("card status once the item is paid back = %d, want refunded", status)
+	}
+	if logs := refundLogs(); len(logs) != 2 || logs[1].Amount != 200 {
+		t.Errorf("refund logs once the item is paid back = %+v, want a second one of 200", logs)
+	}
+}
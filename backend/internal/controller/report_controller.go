@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"errors"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportController struct {
+	occupancyService *service.OccupancyService
+	revenueService   *service.RevenueService
+	walletService    *service.WalletService
+	referralService  *service.ReferralService
+}
+
+func NewReportController(occupancyService *service.OccupancyService, revenueService *service.RevenueService, walletService *service.WalletService, referralService *service.ReferralService) *ReportController {
+	return &ReportController{
+		occupancyService: occupancyService,
+		revenueService:   revenueService,
+		walletService:    walletService,
+		referralService:  referralService,
+	}
+}
+
+// GetOccupancy returns the weekday x hour heatmap, average dwell time and daily peaks
+func (ctrl *ReportController) GetOccupancy(c *gin.Context) {
+	from, to, err := parseDateRange(c, 30)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	report, err := ctrl.occupancyService.GetReport(c.Request.Context(), from, to, c.Query("device_id"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// RebuildOccupancy re-aggregates the hourly check-in tables for a date range
+func (ctrl *ReportController) RebuildOccupancy(c *gin.Context) {
+	from, to, err := parseDateRange(c, 1)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	err = ctrl.occupancyService.Rebuild(c.Request.Context(), from, to)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Occupancy stats rebuilt successfully", nil)
+}
+
+// GetRevenue returns monthly sales, recognized and deferred card revenue
+func (ctrl *ReportController) GetRevenue(c *gin.Context) {
+	from, to, err := parseMonthRange(c)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	report, err := ctrl.revenueService.GetReport(c.Request.Context(), from, to)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// GetWallet returns monthly wallet top-ups, bonuses, spending and the
+// outstanding balances
+func (ctrl *ReportController) GetWallet(c *gin.Context) {
+	from, to, err := parseMonthRange(c)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	report, err := ctrl.walletService.GetReport(c.Request.Context(), from, to)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// GetReferrals returns referrals made and rewards given per month, with the
+// top referrers of the period
+func (ctrl *ReportController) GetReferrals(c *gin.Context) {
+	from, to, err := parseMonthRange(c)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	report, err := ctrl.referralService.GetReport(c.Request.Context(), from, to, limit)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, report)
+}
+
+// parseMonthRange reads from/to as YYYY-MM, defaulting to the last 12 months
+func parseMonthRange(c *gin.Context) (time.Time, time.Time, error) {
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to, expected YYYY-MM")
+		}
+		to = t
+	}
+	from := to.AddDate(0, -11, 0)
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01", v, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from, expected YYYY-MM")
+		}
+		from = t
+	}
+	return from, to, nil
+}
+
+// parseDateRange reads the inclusive from/to dates (YYYY-MM-DD), defaulting to
+// the last defaultDays days up to today
+func parseDateRange(c *gin.Context, defaultDays int) (time.Time, time.Time, error) {
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to, expected YYYY-MM-DD")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -(defaultDays - 1))
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from, expected YYYY-MM-DD")
+		}
+		from = t
+	}
+	return from, to, nil
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReviewController struct {
+	service *service.ReviewService
+}
+
+func NewReviewController(reviewService *service.ReviewService) *ReviewController {
+	return &ReviewController{
+		service: reviewService,
+	}
+}
+
+type CreateReviewRequest struct {
+	BookingID    int64  `json:"booking_id" binding:"required"`
+	CourseRating int8   `json:"course_rating" binding:"required,min=1,max=5"`
+	CoachRating  int8   `json:"coach_rating" binding:"required,min=1,max=5"`
+	Comment      string `json:"comment" binding:"max=500"`
+}
+
+type HideReviewRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CreateReview rates a completed booking
+func (ctrl *ReviewController) CreateReview(c *gin.Context) {
+	var req CreateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	userID := c.GetInt64("user_id")
+	review, err := ctrl.service.CreateReview(c.Request.Context(), userID, req.BookingID, req.CourseRating, req.CoachRating, req.Comment)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, review)
+}
+
+// GetReview gets review by ID
+func (ctrl *ReviewController) GetReview(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	review, err := ctrl.service.GetReview(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, review)
+}
+
+// ListReviews lists reviews for moderation, including hidden ones
+func (ctrl *ReviewController) ListReviews(c *gin.Context) {
+	q, ok := parseList(c, repository.ReviewListSpec)
+	if !ok {
+		return
+	}
+
+	reviews, page, err := ctrl.service.ListReviews(c.Request.Context(), q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, reviews, page)
+}
+
+// ListCoachReviews lists the visible reviews of a coach
+func (ctrl *ReviewController) ListCoachReviews(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid coach ID")
+		return
+	}
+
+	q, ok := parseList(c, repository.CoachReviewSpec)
+	if !ok {
+		return
+	}
+
+	reviews, page, err := ctrl.service.ListCoachReviews(c.Request.Context(), id, q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, reviews, page)
+}
+
+// HideReview hides an abusive review
+func (ctrl *ReviewController) HideReview(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	var req HideReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.HideReview(c.Request.Context(), id, c.GetInt64("user_id"), req.Reason); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Review hidden successfully", nil)
+}
+
+// ShowReview restores a hidden review
+func (ctrl *ReviewController) ShowReview(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid review ID")
+		return
+	}
+
+	if err := ctrl.service.ShowReview(c.Request.Context(), id); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Review restored successfully", nil)
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatsController struct {
+	service *service.StatsService
+}
+
+func NewStatsController(statsService *service.StatsService) *StatsController {
+	return &StatsController{
+		service: statsService,
+	}
+}
+
+// GetDashboard returns the operations dashboard numbers
+func (ctrl *StatsController) GetDashboard(c *gin.Context) {
+	stats, err := ctrl.service.GetDashboard(c.Request.Context(), c.DefaultQuery("period", "day"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, stats)
+}
@@ -1,34 +1,87 @@
 package controller
 
 import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
 	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
 	"gym-admin/internal/service"
+	"gym-admin/pkg/logger"
 	"gym-admin/pkg/response"
+	"gym-admin/pkg/spreadsheet"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 type UserController struct {
-	service *service.UserService
+	service       *service.UserService
+	importService *service.UserImportService
+	exportService *service.ExportService
 }
 
-func NewUserController() *UserController {
+func NewUserController(userService *service.UserService, importService *service.UserImportService, exportService *service.ExportService) *UserController {
 	return &UserController{
-		service: service.NewUserService(),
+		service:       userService,
+		importService: importService,
+		exportService: exportService,
 	}
 }
 
+// CreateUserRequest is a member profile plus the referral code of the
+// member who invited them, if any. Numbers, status and referral fields are
+// assigned by the service.
+type CreateUserRequest struct {
+	Name             string `json:"name" binding:"required,max=50"`
+	Gender           int8   `json:"gender" binding:"omitempty,oneof=1 2"`
+	Birthday         string `json:"birthday" binding:"omitempty,date,notfuture"` // YYYY-MM-DD
+	IDCard           string `json:"id_card" binding:"omitempty,idcard"`
+	Phone            string `json:"phone" binding:"required,mobile"`
+	Email            string `json:"email" binding:"omitempty,email,max=100"`
+	AvatarURL        string `json:"avatar_url" binding:"max=255"`
+	Address          string `json:"address" binding:"max=255"`
+	EmergencyContact string `json:"emergency_contact" binding:"max=50"`
+	EmergencyPhone   string `json:"emergency_phone" binding:"omitempty,mobile"`
+	HealthStatus     string `json:"health_status"`
+	TrainingGoal     string `json:"training_goal"`
+	Source           int8   `json:"source" binding:"omitempty,oneof=1 2 3 4"`
+	Remark           string `json:"remark"`
+	InviteCode       string `json:"invite_code" binding:"max=16"`
+}
+
 // CreateUser creates a new user
 func (ctrl *UserController) CreateUser(c *gin.Context) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		response.BadRequest(c, "Invalid request: "+err.Error())
+	var req CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
 		return
 	}
 
-	if err := ctrl.service.CreateUser(&user); err != nil {
-		response.Error(c, 500, err.Error())
+	user := models.User{
+		Name:             req.Name,
+		Gender:           req.Gender,
+		IDCard:           req.IDCard,
+		Phone:            req.Phone,
+		Email:            req.Email,
+		AvatarURL:        req.AvatarURL,
+		Address:          req.Address,
+		EmergencyContact: req.EmergencyContact,
+		EmergencyPhone:   req.EmergencyPhone,
+		HealthStatus:     req.HealthStatus,
+		TrainingGoal:     req.TrainingGoal,
+		Source:           req.Source,
+		Remark:           req.Remark,
+	}
+	if req.Birthday != "" {
+		birthday, _ := time.ParseInLocation("2006-01-02", req.Birthday, time.Local)
+		user.Birthday = &birthday
+	}
+	if err := ctrl.service.CreateUser(c.Request.Context(), &user, req.InviteCode); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
@@ -43,9 +96,9 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 		return
 	}
 
-	user, err := ctrl.service.GetUser(id)
+	user, err := ctrl.service.GetUser(c.Request.Context(), id)
 	if err != nil {
-		response.NotFound(c, "User not found")
+		response.Fail(c, err)
 		return
 	}
 
@@ -54,28 +107,50 @@ func (ctrl *UserController) GetUser(c *gin.Context) {
 
 // ListUsers lists users with pagination
 func (ctrl *UserController) ListUsers(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	q, ok := parseList(c, repository.UserListSpec)
+	if !ok {
+		return
+	}
 
-	var status *int8
-	if statusStr := c.Query("status"); statusStr != "" {
-		s, _ := strconv.ParseInt(statusStr, 10, 8)
-		statusVal := int8(s)
-		status = &statusVal
+	users, page, err := ctrl.service.ListUsers(c.Request.Context(), q)
+	if err != nil {
+		response.Fail(c, err)
+		return
 	}
 
-	users, total, err := ctrl.service.ListUsers(page, pageSize, status)
+	successList(c, q, users, page)
+}
+
+// SearchUsers searches users by keyword with optional filters
+func (ctrl *UserController) SearchUsers(c *gin.Context) {
+	filter, err := parseUserSearchFilter(c)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+	q, ok := parseList(c, repository.UserSearchSpec)
+	if !ok {
+		return
+	}
+
+	users, page, err := ctrl.service.SearchUsers(c.Request.Context(), filter, q)
 	if err != nil {
-		response.InternalServerError(c, "Failed to get users")
+		response.Fail(c, err)
 		return
 	}
 
-	response.Success(c, gin.H{
-		"list":      users,
-		"total":     total,
-		"page":      page,
-		"page_size": pageSize,
-	})
+	successList(c, q, users, page)
+}
+
+// RebuildNamePinyin backfills pinyin search data for existing users
+func (ctrl *UserController) RebuildNamePinyin(c *gin.Context) {
+	updated, err := ctrl.service.RebuildNamePinyin(c.Request.Context())
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, gin.H{"updated": updated})
 }
 
 // UpdateUser updates user information
@@ -86,14 +161,14 @@ func (ctrl *UserController) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var updates map[string]interface{}
-	if err := c.ShouldBindJSON(&updates); err != nil {
-		response.BadRequest(c, "Invalid request: "+err.Error())
+	var req service.UserUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
 		return
 	}
 
-	if err := ctrl.service.UpdateUser(id, updates); err != nil {
-		response.Error(c, 500, err.Error())
+	if err := ctrl.service.UpdateUser(c.Request.Context(), id, req); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
@@ -108,8 +183,8 @@ func (ctrl *UserController) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := ctrl.service.DeleteUser(id); err != nil {
-		response.InternalServerError(c, "Failed to delete user")
+	if err := ctrl.service.DeleteUser(c.Request.Context(), id); err != nil {
+		response.Fail(c, err)
 		return
 	}
 
@@ -124,11 +199,243 @@ func (ctrl *UserController) GetUserStats(c *gin.Context) {
 		return
 	}
 
-	stats, err := ctrl.service.GetUserStats(id)
+	stats, err := ctrl.service.GetUserStats(c.Request.Context(), id)
 	if err != nil {
-		response.InternalServerError(c, "Failed to get user stats")
+		response.Fail(c, err)
 		return
 	}
 
 	response.Success(c, stats)
 }
+
+type ChangeUserStatusRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// FreezeUser freezes a user
+func (ctrl *UserController) FreezeUser(c *gin.Context) {
+	ctrl.changeStatus(c, ctrl.service.FreezeUser, "User frozen successfully")
+}
+
+// UnfreezeUser restores a frozen user to normal
+func (ctrl *UserController) UnfreezeUser(c *gin.Context) {
+	ctrl.changeStatus(c, ctrl.service.UnfreezeUser, "User unfrozen successfully")
+}
+
+// AddToBlacklist blacklists a user
+func (ctrl *UserController) AddToBlacklist(c *gin.Context) {
+	ctrl.changeStatus(c, ctrl.service.AddToBlacklist, "User added to blacklist")
+}
+
+// RemoveFromBlacklist restores a blacklisted user to normal
+func (ctrl *UserController) RemoveFromBlacklist(c *gin.Context) {
+	ctrl.changeStatus(c, ctrl.service.RemoveFromBlacklist, "User removed from blacklist")
+}
+
+// GetStatusHistory lists the status changes of a user
+func (ctrl *UserController) GetStatusHistory(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	changes, err := ctrl.service.GetStatusHistory(c.Request.Context(), id)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, changes)
+}
+
+type SetUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user staff manager admin"`
+}
+
+// SetUserRole makes a user a member or gives them a staff role
+func (ctrl *UserController) SetUserRole(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var req SetUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.SetRole(c.Request.Context(), id, req.Role); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "User role updated successfully", nil)
+}
+
+type SetPasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password" binding:"required,min=6,max=72"`
+}
+
+// SetPassword changes the caller's own password, which needs the current one.
+// Admins may set anyone's password without it.
+func (ctrl *UserController) SetPassword(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var req SetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	switch {
+	case c.GetString("role") == service.RoleAdmin:
+		err = ctrl.service.ResetPassword(c.Request.Context(), id, req.Password)
+	case c.GetInt64("user_id") == id:
+		err = ctrl.service.ChangePassword(c.Request.Context(), id, req.CurrentPassword, req.Password)
+	default:
+		response.Forbidden(c, "Permission denied")
+		return
+	}
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Password updated successfully", nil)
+}
+
+func (ctrl *UserController) changeStatus(c *gin.Context, change func(context.Context, int64, string, int64) error, message string) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var req ChangeUserStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := change(c.Request.Context(), id, req.Reason, c.GetInt64("user_id")); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, message, nil)
+}
+
+// ImportUsers imports users from an uploaded CSV or XLSX file.
+// dry_run defaults to true so that a report is produced before anything is written.
+func (ctrl *UserController) ImportUsers(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		response.BadRequest(c, "Missing import file")
+		return
+	}
+
+	dryRun := true
+	if v := c.PostForm("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			response.BadRequest(c, "Invalid dry_run")
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		response.BadRequest(c, "Failed to read import file")
+		return
+	}
+	defer file.Close()
+
+	result, err := ctrl.importService.ImportUsers(c.Request.Context(), file, fileHeader.Filename, dryRun, c.GetInt64("user_id"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// DownloadImportTemplate returns an empty CSV with the supported import columns
+func (ctrl *UserController) DownloadImportTemplate(c *gin.Context) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="user_import_template.csv"`)
+
+	// BOM so that Excel opens the file as UTF-8
+	c.Writer.WriteString("\xef\xbb\xbf")
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(service.ImportTemplateHeaders)
+	writer.Flush()
+}
+
+// ExportUsers downloads the users matching the search filters as CSV or XLSX
+func (ctrl *UserController) ExportUsers(c *gin.Context) {
+	format := c.DefaultQuery("format", spreadsheet.FormatXLSX)
+	if _, err := spreadsheet.DetectFormat("export." + format); err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	filter, err := parseUserSearchFilter(c)
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	filename := fmt.Sprintf("users_%s.%s", time.Now().Format("20060102150405"), format)
+	c.Header("Content-Type", spreadsheet.ContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if err := ctrl.exportService.ExportUsers(c.Request.Context(), c.Writer, format, filter); err != nil {
+		// Headers are already sent, so the client sees a truncated file
+		logger.Error("Failed to export users", zap.Error(err))
+	}
+}
+
+// parseUserSearchFilter reads the search and list filters shared by search and export
+func parseUserSearchFilter(c *gin.Context) (repository.UserSearchFilter, error) {
+	filter := repository.UserSearchFilter{Keyword: c.Query("keyword")}
+	if v, err := strconv.ParseInt(c.Query("source"), 10, 8); err == nil {
+		source := int8(v)
+		filter.Source = &source
+	}
+	if v, err := strconv.ParseInt(c.Query("gender"), 10, 8); err == nil {
+		gender := int8(v)
+		filter.Gender = &gender
+	}
+	if v, err := strconv.ParseInt(c.Query("status"), 10, 8); err == nil {
+		status := int8(v)
+		filter.Status = &status
+	}
+	if v := c.Query("registered_from"); v != "" {
+		from, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return filter, errors.New("invalid registered_from, expected YYYY-MM-DD")
+		}
+		filter.RegisteredFrom = &from
+	}
+	if v := c.Query("registered_to"); v != "" {
+		to, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return filter, errors.New("invalid registered_to, expected YYYY-MM-DD")
+		}
+		// Inclusive end date
+		to = to.AddDate(0, 0, 1)
+		filter.RegisteredTo = &to
+	}
+	if v, err := strconv.ParseBool(c.Query("has_active_card")); err == nil {
+		filter.HasActiveCard = &v
+	}
+
+	return filter, nil
+}
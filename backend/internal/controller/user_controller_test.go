@@ -0,0 +1,118 @@
+package controller_test
+
+import (
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"net/http"
+	"testing"
+)
+
+func TestCreateUser(t *testing.T) {
+	srv := apitest.New(t)
+	token := staffToken(t, srv)
+
+	resp := srv.Do(t, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":   "张三",
+		"phone":  "13900000001",
+		"gender": 1,
+	}, token)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var user models.User
+	resp.Decode(t, &user)
+	if user.ID == 0 || user.UserNo == "" || user.Phone != "13900000001" {
+		t.Errorf("created user: id %d, user no %q, phone %q", user.ID, user.UserNo, user.Phone)
+	}
+	if user.Role != service.RoleMember {
+		t.Errorf("role = %q, want %q", user.Role, service.RoleMember)
+	}
+
+	resp = srv.Do(t, http.MethodGet, fmt.Sprintf("/api/v1/users/%d", user.ID), nil, token)
+	if resp.Status != http.StatusOK {
+		t.Fatalf("get: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var got models.User
+	resp.Decode(t, &got)
+	if got.Name != "张三" {
+		t.Errorf("name = %q, want 张三", got.Name)
+	}
+}
+
+func TestCreateInvitedUserRecordsReferral(t *testing.T) {
+	srv := apitest.New(t)
+	referrerID := createMember(t, srv, "13900000011")
+	var referrer models.User
+	if err := srv.DB.First(&referrer, referrerID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp := srv.Do(t, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":        "李四",
+		"phone":       "13900000012",
+		"invite_code": *referrer.ReferralCode,
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("create: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var user models.User
+	resp.Decode(t, &user)
+	if user.ReferrerID == nil || *user.ReferrerID != referrerID {
+		t.Errorf("referrer = %v, want %d", user.ReferrerID, referrerID)
+	}
+	if n := countRows(t, srv, &models.Referral{}, "referrer_id = ? AND referee_id = ?", referrerID, user.ID); n != 1 {
+		t.Errorf("referrals = %d, want 1", n)
+	}
+}
+
+func TestCreateUserDuplicatePhone(t *testing.T) {
+	srv := apitest.New(t)
+	createMember(t, srv, "13900000002")
+
+	resp := srv.Do(t, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":  "李四",
+		"phone": "13900000002",
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusConflict || resp.Error != "PHONE_EXISTS" {
+		t.Errorf("duplicate phone: status %d, error %q", resp.Status, resp.Error)
+	}
+	if n := countRows(t, srv, &models.User{}, "phone = ?", "13900000002"); n != 1 {
+		t.Errorf("users with the phone = %d, want 1", n)
+	}
+}
+
+func TestCreateUserValidation(t *testing.T) {
+	srv := apitest.New(t)
+
+	resp := srv.Do(t, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":  "王五",
+		"phone": "12345",
+	}, staffToken(t, srv))
+	if resp.Status != http.StatusBadRequest {
+		t.Errorf("invalid phone: status %d, want 400", resp.Status)
+	}
+
+	resp = srv.Do(t, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":  "王五",
+		"phone": "13900000003",
+	}, "")
+	if resp.Status != http.StatusUnauthorized {
+		t.Errorf("without a token: status %d, want 401", resp.Status)
+	}
+}
+
+func TestUpdateUserRequiresStaff(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13900000004")
+	path := fmt.Sprintf("/api/v1/users/%d", userID)
+	body := map[string]interface{}{"name": "赵六"}
+
+	if resp := srv.Do(t, http.MethodPut, path, body, srv.Token(t, userID, service.RoleMember)); resp.Status != http.StatusForbidden {
+		t.Errorf("member updating: status %d, want 403", resp.Status)
+	}
+	if resp := srv.Do(t, http.MethodPut, path, body, staffToken(t, srv)); resp.Status != http.StatusOK {
+		t.Errorf("staff updating: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+}
@@ -0,0 +1,51 @@
+package controller
+
+import (
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+type VoucherController struct {
+	service *service.VoucherService
+}
+
+func NewVoucherController(voucherService *service.VoucherService) *VoucherController {
+	return &VoucherController{
+		service: voucherService,
+	}
+}
+
+type VerifyVoucherRequest struct {
+	Code       string  `json:"voucher_code" binding:"required,max=100"`
+	Platform   int8    `json:"platform" binding:"required,oneof=1 2"`
+	UserID     int64   `json:"user_id" binding:"required"`
+	CardTypeID int64   `json:"card_type_id" binding:"required"`
+	Amount     float64 `json:"amount" binding:"min=0"`
+	Remark     string  `json:"remark" binding:"max=200"`
+}
+
+// Verify redeems a Meituan or Douyin voucher for a membership card
+func (ctrl *VoucherController) Verify(c *gin.Context) {
+	var req VerifyVoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	card, err := ctrl.service.Verify(c.Request.Context(), service.VerifyVoucherInput{
+		Code:       req.Code,
+		Platform:   req.Platform,
+		UserID:     req.UserID,
+		CardTypeID: req.CardTypeID,
+		Amount:     req.Amount,
+		Remark:     req.Remark,
+	}, c.GetInt64("user_id"))
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, card)
+}
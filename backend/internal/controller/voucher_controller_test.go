@@ -0,0 +1,51 @@
+package controller_test
+
+import (
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/metrics"
+	"net/http"
+	"testing"
+)
+
+func TestVerifyVoucherIssuesCardOnce(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000051")
+	cardType := createCardType(t, srv, 300)
+
+	verify := func(code string) *apitest.Response {
+		t.Helper()
+		return srv.Do(t, http.MethodPost, "/api/v1/vouchers/verify", map[string]interface{}{
+			"voucher_code": code, "platform": service.VoucherPlatformMeituan,
+			"user_id": userID, "card_type_id": cardType.ID, "amount": 199,
+		}, staffToken(t, srv))
+	}
+
+	rejected := counterValue(t, metrics.VoucherVerifications.WithLabelValues("meituan", "rejected"))
+	resp := verify("MT0001")
+	if resp.Status != http.StatusOK {
+		t.Fatalf("verify: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	var card models.MembershipCard
+	resp.Decode(t, &card)
+	if card.UserID != userID || card.Source != 3 || card.PurchasePrice != 199 {
+		t.Errorf("card = %+v, want a Meituan card of 199 for member %d", card, userID)
+	}
+	if n := countRows(t, srv, &models.CardOperationLog{}, "card_id = ? AND operation_type = ?", card.ID, service.CardOpOpen); n != 1 {
+		t.Errorf("opening logs = %d, want 1", n)
+	}
+
+	if resp := verify("MT0001"); resp.Status != http.StatusConflict || resp.Error != "VOUCHER_ALREADY_VERIFIED" {
+		t.Errorf("verifying again: status %d, error %q", resp.Status, resp.Error)
+	}
+	if got := counterValue(t, metrics.VoucherVerifications.WithLabelValues("meituan", "rejected")) - rejected; got != 1 {
+		t.Errorf("rejected verifications counted = %.0f, want 1", got)
+	}
+	if n := countRows(t, srv, &models.MembershipCard{}, "user_id = ?", userID); n != 1 {
+		t.Errorf("cards = %d, want 1", n)
+	}
+	if n := countRows(t, srv, &models.VoucherRecord{}, "voucher_code = ? AND status = ?", "MT0001", service.VoucherStatusVerified); n != 1 {
+		t.Errorf("verified vouchers = %d, want 1", n)
+	}
+}
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"gym-admin/internal/repository"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/response"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WalletController struct {
+	service *service.WalletService
+}
+
+func NewWalletController(walletService *service.WalletService) *WalletController {
+	return &WalletController{
+		service: walletService,
+	}
+}
+
+type WalletSpendRequest struct {
+	Amount float64 `json:"amount" binding:"required,gt=0"`
+	Remark string  `json:"remark" binding:"required"` // 消费内容，如饮料、储物柜
+	// IdempotencyKey is generated by the client once per purchase and sent
+	// again on retries, which then return the first charge
+	IdempotencyKey string `json:"idempotency_key" binding:"required,max=48"`
+}
+
+type SetTopUpRuleStatusRequest struct {
+	Status int8 `json:"status" binding:"required,oneof=1 2"`
+}
+
+// GetWallet gets the wallet balances of a member
+func (ctrl *WalletController) GetWallet(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	wallet, err := ctrl.service.GetWallet(c.Request.Context(), userID)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, wallet)
+}
+
+// ListTransactions lists the wallet ledger of a member with pagination
+func (ctrl *WalletController) ListTransactions(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	q, ok := parseList(c, repository.WalletTransactionListSpec)
+	if !ok {
+		return
+	}
+
+	entries, page, err := ctrl.service.ListTransactions(c.Request.Context(), userID, q)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	successList(c, q, entries, page)
+}
+
+// Spend charges a front desk purchase to a member's wallet
+func (ctrl *WalletController) Spend(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var req WalletSpendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	entry, err := ctrl.service.Spend(c.Request.Context(), userID, req.Amount, req.IdempotencyKey, c.GetInt64("user_id"), req.Remark)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, entry)
+}
+
+// ListTopUpRules lists the top-up bonus rules
+func (ctrl *WalletController) ListTopUpRules(c *gin.Context) {
+	var status *int8
+	if v, err := strconv.ParseInt(c.Query("status"), 10, 8); err == nil {
+		s := int8(v)
+		status = &s
+	}
+
+	rules, err := ctrl.service.ListTopUpRules(c.Request.Context(), status)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rules)
+}
+
+// CreateTopUpRule creates a top-up bonus rule
+func (ctrl *WalletController) CreateTopUpRule(c *gin.Context) {
+	var req service.TopUpRuleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	rule, err := ctrl.service.CreateTopUpRule(c.Request.Context(), req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rule)
+}
+
+// UpdateTopUpRule updates a top-up bonus rule
+func (ctrl *WalletController) UpdateTopUpRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid rule ID")
+		return
+	}
+
+	var req service.TopUpRuleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	rule, err := ctrl.service.UpdateTopUpRule(c.Request.Context(), id, req)
+	if err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.Success(c, rule)
+}
+
+// SetTopUpRuleStatus enables or disables a top-up bonus rule
+func (ctrl *WalletController) SetTopUpRuleStatus(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		response.BadRequest(c, "Invalid rule ID")
+		return
+	}
+
+	var req SetTopUpRuleStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.BindError(c, err)
+		return
+	}
+
+	if err := ctrl.service.SetTopUpRuleStatus(c.Request.Context(), id, req.Status); err != nil {
+		response.Fail(c, err)
+		return
+	}
+
+	response.SuccessWithMessage(c, "Top-up rule updated successfully", nil)
+}
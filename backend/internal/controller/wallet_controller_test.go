@@ -0,0 +1,62 @@
+package controller_test
+
+import (
+	"fmt"
+	"gym-admin/internal/apitest"
+	"gym-admin/internal/models"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"testing"
+)
+
+func TestWalletSpendIsIdempotent(t *testing.T) {
+	srv := apitest.New(t)
+	userID := createMember(t, srv, "13800000021")
+	topUp := createOrder(t, srv, userID, topUpItem(100))
+	if resp := payOrder(t, srv, topUp.ID, payment.MethodCash); resp.Status != http.StatusOK {
+		t.Fatalf("pay top-up: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+
+	path := fmt.Sprintf("/api/v1/wallets/%d/spend", userID)
+	spend := func(amount float64, key, token string) *apitest.Response {
+		t.Helper()
+		return srv.Do(t, http.MethodPost, path, map[string]interface{}{
+			"amount": amount, "remark": "饮料", "idempotency_key": key,
+		}, token)
+	}
+
+	if resp := spend(10, "K1", srv.Token(t, userID, service.RoleMember)); resp.Status != http.StatusForbidden {
+		t.Errorf("member spending: status %d, want 403", resp.Status)
+	}
+
+	var first, retried models.WalletTransaction
+	resp := spend(10, "K1", staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("spend: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	resp.Decode(t, &first)
+	resp = spend(10, "K1", staffToken(t, srv))
+	if resp.Status != http.StatusOK {
+		t.Fatalf("retried spend: status %d, %s %s", resp.Status, resp.Error, resp.Message)
+	}
+	resp.Decode(t, &retried)
+	if retried.ID != first.ID {
+		t.Errorf("retry recorded entry %d, want the first entry %d", retried.ID, first.ID)
+	}
+
+	if resp := spend(20, "K1", staffToken(t, srv)); resp.Status != http.StatusConflict || resp.Error != "IDEMPOTENCY_KEY_REUSED" {
+		t.Errorf("reusing the key for another amount: status %d, error %q", resp.Status, resp.Error)
+	}
+	if resp := spend(10, "", staffToken(t, srv)); resp.Status != http.StatusBadRequest {
+		t.Errorf("without a key: status %d, want 400", resp.Status)
+	}
+
+	var wallet models.Wallet
+	if err := srv.DB.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		t.Fatal(err)
+	}
+	if wallet.Balance != 90 {
+		t.Errorf("wallet balance = %.2f, want 90", wallet.Balance)
+	}
+}
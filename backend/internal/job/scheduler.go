@@ -0,0 +1,177 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"gym-admin/pkg/cache"
+	"gym-admin/pkg/logger"
+	"gym-admin/pkg/metrics"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Func is the body of a background job. It should return promptly once ctx is cancelled.
+type Func func(ctx context.Context) error
+
+type dailyJob struct {
+	name   string
+	hour   int
+	minute int
+	run    Func
+}
+
+type intervalJob struct {
+	name     string
+	interval time.Duration
+	run      Func
+}
+
+// Scheduler runs jobs once a day at a fixed local time. When several replicas
+// run, a Redis lock makes sure each run happens on one instance only.
+type Scheduler struct {
+	jobs   []dailyJob
+	every  []intervalJob
+	ctx    context.Context // cancelled to abort running jobs
+	cancel context.CancelFunc
+	stop   chan struct{} // closed to stop scheduling new runs
+	wg     sync.WaitGroup
+	locks  cache.Cache
+
+	stopOnce sync.Once
+}
+
+// NewScheduler creates a scheduler that takes its run locks in locks, so
+// that each run happens on one instance only
+func NewScheduler(locks cache.Cache) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel, stop: make(chan struct{}), locks: locks}
+}
+
+// Daily registers a job to run every day at hh:mm local time
+func (s *Scheduler) Daily(name string, at string, run Func) error {
+	t, err := time.Parse("15:04", at)
+	if err != nil {
+		return fmt.Errorf("invalid time %q for job %s: %w", at, name, err)
+	}
+	s.jobs = append(s.jobs, dailyJob{name: name, hour: t.Hour(), minute: t.Minute(), run: run})
+	return nil
+}
+
+// Every registers a job to run at each multiple of interval, e.g. on the hour
+func (s *Scheduler) Every(name string, interval time.Duration, run Func) error {
+	if interval < time.Minute {
+		return fmt.Errorf("interval of job %s must be at least a minute", name)
+	}
+	s.every = append(s.every, intervalJob{name: name, interval: interval, run: run})
+	return nil
+}
+
+// Start launches one goroutine per registered job
+func (s *Scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+	for _, j := range s.every {
+		s.wg.Add(1)
+		go s.loopEvery(j)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Shutdown stops scheduling new runs and waits for the running ones to
+// finish. Runs still going when ctx is done are cancelled, and Shutdown
+// returns ctx's error once they have returned.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	s.stopOnce.Do(func() { close(s.stop) })
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.cancel()
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) loop(j dailyJob) {
+	defer s.wg.Done()
+	for {
+		timer := time.NewTimer(time.Until(nextRun(time.Now(), j.hour, j.minute)))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.runOnce(j.name, j.name+":"+time.Now().Format("20060102"), 23*time.Hour, j.run)
+		}
+	}
+}
+
+func (s *Scheduler) loopEvery(j intervalJob) {
+	defer s.wg.Done()
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(j.interval).Add(j.interval).Sub(now))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+			runAt := time.Now().Truncate(j.interval)
+			s.runOnce(j.name, j.name+":"+runAt.Format("200601021504"), j.interval-time.Second, j.run)
+		}
+	}
+}
+
+// runOnce runs a job unless another instance already holds lockKey
+func (s *Scheduler) runOnce(name, lockKey string, lockTTL time.Duration, run Func) {
+	acquired, err := s.locks.SetNX(s.ctx, "job:lock:"+lockKey, 1, lockTTL)
+	if err != nil {
+		logger.Warn("Failed to acquire job lock, running anyway", zap.String("job", name), zap.Error(err))
+	} else if !acquired {
+		logger.Info("Job already ran on another instance", zap.String("job", name))
+		metrics.JobRuns.WithLabelValues(name, "skipped").Inc()
+		return
+	}
+
+	start := time.Now()
+	defer func() {
+		metrics.JobDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if r := recover(); r != nil {
+			logger.Error("Job panicked", zap.String("job", name), zap.Any("panic", r))
+			metrics.JobRuns.WithLabelValues(name, "panic").Inc()
+		}
+	}()
+
+	if err := run(s.ctx); err != nil {
+		logger.Error("Job failed", zap.String("job", name), zap.Duration("cost", time.Since(start)), zap.Error(err))
+		metrics.JobRuns.WithLabelValues(name, "failed").Inc()
+		return
+	}
+	logger.Info("Job finished", zap.String("job", name), zap.Duration("cost", time.Since(start)))
+	metrics.JobRuns.WithLabelValues(name, "success").Inc()
+}
+
+func nextRun(now time.Time, hour, minute int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -31,7 +31,7 @@ func Auth() gin.HandlerFunc {
 		// Validate JWT token
 		claims, err := jwt.ParseToken(token)
 		if err != nil {
-			response.Unauthorized(c, "Invalid token: "+err.Error())
+			response.Unauthorized(c, "Invalid token")
 			c.Abort()
 			return
 		}
@@ -43,3 +43,19 @@ func Auth() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireRole only lets through tokens carrying one of the given roles. It
+// must run after Auth.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		response.Forbidden(c, "Permission denied")
+		c.Abort()
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"gym-admin/pkg/deadline"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout cancels the request context after d so that the database and
+// cache calls made for it give up. Routes in long, keyed by method and route
+// path as in "GET /api/v1/users/export", get their own limit. A zero limit
+// means none.
+func Timeout(d time.Duration, long map[string]time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := d
+		if l, ok := long[c.Request.Method+" "+c.FullPath()]; ok {
+			limit = l
+		}
+
+		ctx := deadline.Track(c.Request.Context())
+		if limit > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, limit)
+			defer cancel()
+		}
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// CohortRetention is how many members of a registration month checked in
+// during each following month. Rebuilt nightly.
+type CohortRetention struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	CohortMonth time.Time `gorm:"type:date;not null;uniqueIndex:uk_cohort_offset" json:"cohort_month"` // 注册月份（当月1日）
+	MonthOffset int       `gorm:"not null;uniqueIndex:uk_cohort_offset" json:"month_offset"`           // 注册后第N个月，0为注册当月
+	CohortSize  int       `gorm:"not null" json:"cohort_size"`
+	ActiveUsers int       `gorm:"not null" json:"active_users"`
+	Rate        float64   `gorm:"type:decimal(5,4);not null" json:"rate"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (CohortRetention) TableName() string {
+	return "cohort_retentions"
+}
+
+// RenewalStat is the renewal rate of the cards that ended in a month. Rebuilt nightly.
+type RenewalStat struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Month        time.Time `gorm:"type:date;uniqueIndex;not null" json:"month"` // 到期月份（当月1日）
+	DueCards     int       `gorm:"not null" json:"due_cards"`                   // 当月到期卡数
+	RenewedCards int       `gorm:"not null" json:"renewed_cards"`               // 到期前后续卡数
+	Rate         float64   `gorm:"type:decimal(5,4);not null" json:"rate"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (RenewalStat) TableName() string {
+	return "renewal_stats"
+}
+
+// MemberActivity is a nightly snapshot of the visit pattern of each member
+// holding an active card, used to find members about to churn
+type MemberActivity struct {
+	ID               int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID           int64      `gorm:"uniqueIndex;not null" json:"user_id"`
+	CardID           int64      `gorm:"not null" json:"card_id"`
+	CardEndDate      time.Time  `gorm:"type:date;not null" json:"card_end_date"`
+	LastCheckInAt    *time.Time `json:"last_check_in_at"`
+	DaysSinceCheckIn int        `gorm:"not null;index" json:"days_since_check_in"` // 从未签到时为开卡至今天数
+	VisitsLast30Days int        `gorm:"column:visits_last_30_days;not null" json:"visits_last_30_days"`
+	VisitsPrev30Days int        `gorm:"column:visits_prev_30_days;not null" json:"visits_prev_30_days"` // 31-60天前的到店次数
+	SnapshotDate     time.Time  `gorm:"type:date;not null" json:"snapshot_date"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+func (MemberActivity) TableName() string {
+	return "member_activities"
+}
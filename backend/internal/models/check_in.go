@@ -6,12 +6,19 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	CheckInFace   int8 = 1
+	CheckInCard   int8 = 2
+	CheckInManual int8 = 3
+)
+
 type CheckIn struct {
 	ID          int64          `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID      int64          `gorm:"index;not null" json:"user_id"`
 	CardID      *int64         `gorm:"index" json:"card_id"`
 	CheckInType int8           `gorm:"type:tinyint;not null" json:"check_in_type"` // 1-人脸识别，2-刷卡，3-手动签到
 	CheckInTime time.Time      `gorm:"not null;index" json:"check_in_time"`
+	CheckOutAt  *time.Time     `json:"check_out_at"` // 离场时间，闸机记录出场后填写
 	DeviceID    string         `gorm:"type:varchar(50)" json:"device_id"`
 	Remark      string         `gorm:"type:text" json:"remark"`
 	CreatedAt   time.Time      `json:"created_at"`
@@ -36,3 +43,23 @@ type FaceRecord struct {
 func (FaceRecord) TableName() string {
 	return "face_records"
 }
+
+// CheckInHourlyStat is the pre-aggregated traffic of one device in one hour.
+// Rows with DeviceID "_all" hold the totals across all devices.
+type CheckInHourlyStat struct {
+	ID            int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	StatDate      time.Time `gorm:"type:date;not null;uniqueIndex:uk_date_hour_device" json:"stat_date"`
+	Hour          int8      `gorm:"type:tinyint;not null;uniqueIndex:uk_date_hour_device" json:"hour"`
+	DeviceID      string    `gorm:"type:varchar(50);not null;uniqueIndex:uk_date_hour_device" json:"device_id"`
+	Weekday       int8      `gorm:"type:tinyint;not null" json:"weekday"` // 1-周一 ... 7-周日
+	CheckIns      int       `gorm:"not null" json:"check_ins"`
+	UniqueUsers   int       `gorm:"not null" json:"unique_users"`
+	CheckOuts     int       `gorm:"not null" json:"check_outs"`     // 有离场记录的入场次数
+	DwellMinutes  int64     `gorm:"not null" json:"dwell_minutes"`  // 有离场记录的入场总停留分钟
+	PeakOccupancy int       `gorm:"not null" json:"peak_occupancy"` // 该小时内最高在场人数
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+func (CheckInHourlyStat) TableName() string {
+	return "check_in_hourly_stats"
+}
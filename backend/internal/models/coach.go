@@ -19,6 +19,8 @@ type Coach struct {
 	Experience     int            `gorm:"default:0" json:"experience"`     // 工作年限
 	Introduction   string         `gorm:"type:text" json:"introduction"`
 	HourlyRate     float64        `gorm:"type:decimal(10,2)" json:"hourly_rate"`
+	Rating         float64        `gorm:"type:decimal(3,2);default:0;index" json:"rating"` // 教练评分均值（1-5），由评价汇总
+	TotalRatings   int            `gorm:"default:0" json:"total_ratings"`
 	Status         int8           `gorm:"type:tinyint;default:1;index" json:"status"` // 1-在职，2-离职
 	HireDate       *time.Time     `gorm:"type:date" json:"hire_date"`
 	Remark         string         `gorm:"type:text" json:"remark"`
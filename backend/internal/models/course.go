@@ -45,3 +45,27 @@ type Booking struct {
 func (Booking) TableName() string {
 	return "bookings"
 }
+
+const (
+	LessonPackageActive   int8 = 1
+	LessonPackageUsedUp   int8 = 2
+	LessonPackageRefunded int8 = 3
+)
+
+type LessonPackage struct {
+	ID                int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID            int64          `gorm:"index;not null" json:"user_id"`
+	CoachID           int64          `gorm:"index;not null" json:"coach_id"`
+	OrderID           *int64         `gorm:"index" json:"order_id"`
+	TotalSessions     int            `gorm:"not null" json:"total_sessions"`
+	RemainingSessions int            `gorm:"not null" json:"remaining_sessions"`
+	UnitPrice         float64        `gorm:"type:decimal(10,2)" json:"unit_price"`       // 优惠后单节课价格
+	Status            int8           `gorm:"type:tinyint;default:1;index" json:"status"` // 1-正常，2-已用完，3-已退款
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (LessonPackage) TableName() string {
+	return "lesson_packages"
+}
@@ -6,6 +6,25 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	CardStatusNormal      int8 = 1
+	CardStatusExpired     int8 = 2
+	CardStatusFrozen      int8 = 3
+	CardStatusTransferred int8 = 4
+	CardStatusRefunded    int8 = 5
+)
+
+const (
+	CardOpOpen     int8 = 1
+	CardOpRenew    int8 = 2
+	CardOpFreeze   int8 = 3
+	CardOpUnfreeze int8 = 4
+	CardOpTransfer int8 = 5
+	CardOpRefund   int8 = 6
+	CardOpRedeem   int8 = 7
+	CardOpReferral int8 = 8
+)
+
 type CardType struct {
 	ID             int64     `gorm:"primaryKey;autoIncrement" json:"id"`
 	TypeName       string    `gorm:"type:varchar(50);not null" json:"type_name"`
@@ -47,6 +66,8 @@ type MembershipCard struct {
 	FrozenAt       *time.Time     `json:"frozen_at"`
 	Source         int8           `gorm:"type:tinyint;default:1" json:"source"` // 1-前台办理，2-小程序购买，3-美团，4-抖音
 	PurchasePrice  float64        `gorm:"type:decimal(10,2);not null" json:"purchase_price"`
+	OrderID        *int64         `gorm:"index" json:"order_id"`
+	PromotionID    *int64         `json:"promotion_id"` // 决定购卡价格的促销活动
 	OperatorID     *int64         `json:"operator_id"`
 	Remark         string         `gorm:"type:text" json:"remark"`
 	CreatedAt      time.Time      `json:"created_at"`
@@ -57,3 +78,36 @@ type MembershipCard struct {
 func (MembershipCard) TableName() string {
 	return "membership_cards"
 }
+
+type CardOperationLog struct {
+	ID            int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CardID        int64      `gorm:"index;not null" json:"card_id"`
+	OperationType int8       `gorm:"type:tinyint;not null;index" json:"operation_type"` // 1-开卡，2-续费，3-冻结，4-解冻，5-转卡，6-退卡，7-积分兑换天数，8-推荐奖励天数
+	OldEndDate    *time.Time `gorm:"type:date" json:"old_end_date"`
+	NewEndDate    *time.Time `gorm:"type:date" json:"new_end_date"`
+	Amount        float64    `gorm:"type:decimal(10,2)" json:"amount"` // 续费/转卡手续费/退款金额
+	OperatorID    int64      `gorm:"not null" json:"operator_id"`
+	Remark        string     `gorm:"type:varchar(255)" json:"remark"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (CardOperationLog) TableName() string {
+	return "card_operation_logs"
+}
+
+type CardFreezeRecord struct {
+	ID              int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CardID          int64      `gorm:"index;not null" json:"card_id"`
+	FreezeStartDate time.Time  `gorm:"type:date;not null" json:"freeze_start_date"`
+	FreezeEndDate   *time.Time `gorm:"type:date" json:"freeze_end_date"` // 冻结中为空
+	FreezeDays      int        `gorm:"not null" json:"freeze_days"`
+	Reason          string     `gorm:"type:varchar(255)" json:"reason"`
+	OperatorID      int64      `gorm:"not null" json:"operator_id"`
+	Status          int8       `gorm:"type:tinyint;default:1;index" json:"status"` // 1-冻结中，2-已解冻
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (CardFreezeRecord) TableName() string {
+	return "card_freeze_records"
+}
@@ -0,0 +1,127 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	OrderStatusPending   int8 = 1
+	OrderStatusPaid      int8 = 2
+	OrderStatusCancelled int8 = 3
+	OrderStatusClosed    int8 = 4
+	OrderStatusRefunded  int8 = 5
+)
+
+const (
+	PaymentStatusPending int8 = 1
+	PaymentStatusSuccess int8 = 2
+	PaymentStatusFailed  int8 = 3
+	PaymentStatusClosed  int8 = 4
+)
+
+const (
+	OrderItemCard          int8 = 1
+	OrderItemLessonPackage int8 = 2
+	OrderItemTopUp         int8 = 3
+)
+
+const (
+	RefundStatusPendingApproval int8 = 1
+	RefundStatusProcessing      int8 = 2
+	RefundStatusSuccess         int8 = 3
+	RefundStatusFailed          int8 = 4
+	RefundStatusRejected        int8 = 5
+)
+
+type Order struct {
+	ID              int64       `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderNo         string      `gorm:"type:varchar(32);uniqueIndex;not null" json:"order_no"`
+	UserID          int64       `gorm:"index;not null" json:"user_id"`
+	Source          int8        `gorm:"type:tinyint;default:1" json:"source"`                 // 1-前台办理，2-小程序购买
+	TotalAmount     float64     `gorm:"type:decimal(10,2);not null" json:"total_amount"`      // 商品原价合计
+	DiscountAmount  float64     `gorm:"type:decimal(10,2);default:0" json:"discount_amount"`  // 优惠金额
+	PayAmount       float64     `gorm:"type:decimal(10,2);not null" json:"pay_amount"`        // 应付金额
+	RefundedAmount  float64     `gorm:"type:decimal(10,2);default:0" json:"refunded_amount"`  // 已退款金额
+	RefundingAmount float64     `gorm:"type:decimal(10,2);default:0" json:"refunding_amount"` // 审批中/退款中的金额
+	PaymentMethod   int8        `gorm:"type:tinyint;default:0" json:"payment_method"`         // 1-现金，2-POS刷卡，3-微信支付，4-支付宝，5-储值余额，9-模拟支付
+	Status          int8        `gorm:"type:tinyint;default:1;index" json:"status"`           // 1-待支付，2-已支付，3-已取消，4-已关闭，5-已退款
+	PaidAt          *time.Time  `json:"paid_at"`
+	FulfilledAt     *time.Time  `json:"fulfilled_at"` // 履约完成时间（已开卡/发放课包）
+	ExpireAt        time.Time   `gorm:"index" json:"expire_at"`
+	OperatorID      *int64      `json:"operator_id"`
+	Remark          string      `gorm:"type:text" json:"remark"`
+	Items           []OrderItem `gorm:"foreignKey:OrderID" json:"items,omitempty"`
+	CreatedAt       time.Time   `json:"created_at"`
+	UpdatedAt       time.Time   `json:"updated_at"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+type OrderItem struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	OrderID        int64      `gorm:"index;not null" json:"order_id"`
+	ItemType       int8       `gorm:"type:tinyint;not null" json:"item_type"` // 1-会员卡，2-私教课包，3-储值充值
+	ItemID         int64      `gorm:"not null" json:"item_id"`                // 卡类型ID / 教练ID，储值充值为0
+	ItemName       string     `gorm:"type:varchar(100)" json:"item_name"`
+	Quantity       int        `gorm:"default:1" json:"quantity"` // 私教课包为课时数
+	UnitPrice      float64    `gorm:"type:decimal(10,2);not null" json:"unit_price"`
+	DiscountAmount float64    `gorm:"type:decimal(10,2);default:0" json:"discount_amount"`
+	Amount         float64    `gorm:"type:decimal(10,2);not null" json:"amount"` // 优惠后金额
+	StartDate      *time.Time `gorm:"type:date" json:"start_date"`               // 会员卡开卡日期
+	PromotionID    *int64     `json:"promotion_id"`                              // 决定成交价的促销活动
+	CouponID       *int64     `json:"coupon_id"`
+	BonusDays      int        `gorm:"default:0" json:"bonus_days"`                      // 促销赠送的会员卡天数
+	BonusAmount    float64    `gorm:"type:decimal(10,2);default:0" json:"bonus_amount"` // 储值充值赠送金额
+	FulfilledRefID *int64     `json:"fulfilled_ref_id"`                                 // 履约生成的会员卡ID / 课包ID / 钱包流水ID
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (OrderItem) TableName() string {
+	return "order_items"
+}
+
+type Payment struct {
+	ID         int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	PaymentNo  string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"payment_no"`
+	OrderID    int64      `gorm:"index;not null" json:"order_id"`
+	Method     int8       `gorm:"type:tinyint;not null" json:"method"` // 1-现金，2-POS刷卡，3-微信支付，4-支付宝，5-储值余额，9-模拟支付
+	Amount     float64    `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Status     int8       `gorm:"type:tinyint;default:1;index" json:"status"` // 1-待支付，2-支付成功，3-支付失败，4-已关闭
+	TradeNo    string     `gorm:"type:varchar(64);index" json:"trade_no"`     // 支付渠道交易号 / POS凭证号
+	PaidAt     *time.Time `json:"paid_at"`
+	NotifyData string     `gorm:"type:text" json:"-"` // 支付回调原文
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (Payment) TableName() string {
+	return "payments"
+}
+
+type Refund struct {
+	ID              int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	RefundNo        string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"refund_no"`
+	OrderID         int64      `gorm:"index;not null" json:"order_id"`
+	PaymentID       int64      `gorm:"not null" json:"payment_id"`
+	OrderItemID     *int64     `json:"order_item_id"`             // 退订的订单项，为空时整单退款才撤销履约
+	Sessions        int        `gorm:"default:0" json:"sessions"` // 私教课包退掉的课时数
+	Amount          float64    `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Reason          string     `gorm:"type:varchar(255);not null" json:"reason"`
+	Status          int8       `gorm:"type:tinyint;default:1;index" json:"status"` // 1-待审批，2-退款中，3-退款成功，4-退款失败，5-已驳回
+	RequestedBy     int64      `gorm:"not null" json:"requested_by"`
+	ApprovedBy      *int64     `json:"approved_by"`
+	ApprovedAt      *time.Time `json:"approved_at"`
+	RejectReason    string     `gorm:"type:varchar(255)" json:"reject_reason"`
+	GatewayRefundNo string     `gorm:"type:varchar(64)" json:"gateway_refund_no"` // 支付渠道退款单号
+	FailReason      string     `gorm:"type:varchar(255)" json:"fail_reason"`
+	RefundedAt      *time.Time `json:"refunded_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func (Refund) TableName() string {
+	return "refunds"
+}
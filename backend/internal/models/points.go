@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	PointTxCheckIn  int8 = 1
+	PointTxStreak   int8 = 2
+	PointTxPurchase int8 = 3
+	PointTxRedeem   int8 = 4
+	PointTxExpire   int8 = 5
+	PointTxRefund   int8 = 6
+)
+
+type PointAccount struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int64     `gorm:"uniqueIndex;not null" json:"user_id"`
+	Balance     int64     `gorm:"default:0" json:"balance"`
+	TotalEarned int64     `gorm:"default:0" json:"total_earned"`
+	Version     int64     `gorm:"default:0" json:"-"` // 每次记账递增，用于锁定账户行
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (PointAccount) TableName() string {
+	return "point_accounts"
+}
+
+// PointTransaction is an append-only points ledger entry. Earned entries keep
+// in Remaining what has been neither redeemed nor expired yet.
+type PointTransaction struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       int64      `gorm:"index;not null" json:"user_id"`
+	Type         int8       `gorm:"type:tinyint;not null;uniqueIndex:uk_type_source" json:"type"` // 1-签到，2-连续签到奖励，3-消费，4-兑换，5-过期，6-退款扣回
+	SourceID     int64      `gorm:"not null;uniqueIndex:uk_type_source" json:"source_id"`         // 签到ID / 订单ID / 兑换记录ID / 过期的积分流水ID / 退款ID
+	Points       int64      `gorm:"not null" json:"points"`                                       // 获得为正，使用/过期为负
+	Remaining    int64      `gorm:"default:0" json:"remaining"`
+	BalanceAfter int64      `json:"balance_after"`
+	ExpireAt     *time.Time `gorm:"index" json:"expire_at"`
+	OccurredAt   time.Time  `gorm:"index" json:"occurred_at"` // 签到/支付/兑换时间
+	Remark       string     `gorm:"type:varchar(255)" json:"remark"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (PointTransaction) TableName() string {
+	return "point_transactions"
+}
+
+type PointRule struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
+	RuleType    int8      `gorm:"type:tinyint;not null;index" json:"rule_type"` // 1-每次签到，2-连续签到奖励，3-消费
+	Points      int64     `gorm:"not null" json:"points"`
+	StreakDays  int       `gorm:"default:0" json:"streak_days"`                 // 连续签到每满该天数奖励一次
+	PerYuan     float64   `gorm:"type:decimal(10,2);default:0" json:"per_yuan"` // 消费每满该金额奖励一次
+	ValidMonths int       `gorm:"default:0" json:"valid_months"`                // 积分有效月数，0为永久有效
+	Status      int8      `gorm:"type:tinyint;default:1;index" json:"status"`   // 1-启用，2-停用
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (PointRule) TableName() string {
+	return "point_rules"
+}
+
+type PointReward struct {
+	ID              int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name            string    `gorm:"type:varchar(100);not null" json:"name"`
+	RewardType      int8      `gorm:"type:tinyint;not null" json:"reward_type"` // 1-优惠券，2-会员卡天数
+	Points          int64     `gorm:"not null" json:"points"`                   // 兑换所需积分
+	PromotionID     *int64    `json:"promotion_id"`                             // 发放优惠券所属的促销活动
+	CouponValidDays int       `gorm:"default:0" json:"coupon_valid_days"`       // 优惠券有效天数，0为不限
+	Days            int       `gorm:"default:0" json:"days"`                    // 赠送的会员卡天数
+	Stock           int       `gorm:"default:0" json:"stock"`                   // 0为不限量
+	RedeemedCount   int       `gorm:"default:0" json:"redeemed_count"`
+	Status          int8      `gorm:"type:tinyint;default:1;index" json:"status"` // 1-上架，2-下架
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (PointReward) TableName() string {
+	return "point_rewards"
+}
+
+type PointRedemption struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int64     `gorm:"index;not null" json:"user_id"`
+	RewardID  int64     `gorm:"index;not null" json:"reward_id"`
+	Points    int64     `gorm:"not null" json:"points"`
+	CouponID  *int64    `json:"coupon_id"`
+	CardID    *int64    `json:"card_id"`
+	Days      int       `gorm:"default:0" json:"days"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (PointRedemption) TableName() string {
+	return "point_redemptions"
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+)
+
+type Promotion struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name           string     `gorm:"type:varchar(100);not null" json:"name"`
+	RuleType       int8       `gorm:"type:tinyint;not null" json:"rule_type"`   // 1-折扣，2-立减，3-一口价，4-买赠天数
+	Value          float64    `gorm:"type:decimal(10,2);not null" json:"value"` // 折扣百分比(20即减20%)/立减金额/一口价/赠送天数
+	BuyDays        int        `gorm:"default:0" json:"buy_days"`                // 买赠天数：卡时长达到该天数才赠送
+	ItemType       int8       `gorm:"type:tinyint;default:0" json:"item_type"`  // 0-不限，1-会员卡，2-私教课包
+	CardTypeIDs    string     `gorm:"type:varchar(255)" json:"card_type_ids"`   // 适用卡类型ID，逗号分隔，空为不限
+	NewMembersOnly int8       `gorm:"type:tinyint;default:0" json:"new_members_only"`
+	RequiresCoupon int8       `gorm:"type:tinyint;default:0" json:"requires_coupon"` // 1-仅凭优惠码使用，0-自动参与
+	StartAt        *time.Time `json:"start_at"`
+	EndAt          *time.Time `json:"end_at"`
+	PerUserLimit   int        `gorm:"default:0" json:"per_user_limit"` // 每人限用次数，0为不限
+	TotalLimit     int        `gorm:"default:0" json:"total_limit"`    // 总名额，0为不限
+	UsedCount      int        `gorm:"default:0" json:"used_count"`
+	Status         int8       `gorm:"type:tinyint;default:1;index" json:"status"` // 1-启用，2-停用
+	Description    string     `gorm:"type:text" json:"description"`
+	CreatedBy      int64      `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (Promotion) TableName() string {
+	return "promotions"
+}
+
+const (
+	CouponStatusActive   int8 = 1
+	CouponStatusDisabled int8 = 2
+)
+
+type Coupon struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code        string     `gorm:"type:varchar(32);uniqueIndex;not null" json:"code"`
+	PromotionID int64      `gorm:"index;not null" json:"promotion_id"`
+	UserID      *int64     `gorm:"index" json:"user_id"` // 指定会员，为空时任何人可用
+	MaxUses     int        `gorm:"default:1" json:"max_uses"`
+	UsedCount   int        `gorm:"default:0" json:"used_count"`
+	Status      int8       `gorm:"type:tinyint;default:1;index" json:"status"` // 1-可用，2-已作废
+	ExpireAt    *time.Time `json:"expire_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// PromotionUsage is one application of a promotion to an order item. It holds
+// a place against the usage limits until the order is cancelled or closed.
+type PromotionUsage struct {
+	ID             int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	PromotionID    int64     `gorm:"index:idx_promotion_user;not null" json:"promotion_id"`
+	CouponID       *int64    `gorm:"index" json:"coupon_id"`
+	UserID         int64     `gorm:"index:idx_promotion_user;not null" json:"user_id"`
+	OrderID        int64     `gorm:"index;not null" json:"order_id"`
+	OrderItemID    int64     `json:"order_item_id"`
+	DiscountAmount float64   `gorm:"type:decimal(10,2);default:0" json:"discount_amount"`
+	BonusDays      int       `gorm:"default:0" json:"bonus_days"`
+	Status         int8      `gorm:"type:tinyint;default:1" json:"status"` // 1-已使用，2-已释放
+	ItemIndex      int       `gorm:"-" json:"-"`                           // 下单时对应的订单项序号
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (PromotionUsage) TableName() string {
+	return "promotion_usages"
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ReferralStatusPending  int8 = 1
+	ReferralStatusRewarded int8 = 2
+	ReferralStatusRevoked  int8 = 3
+)
+
+const (
+	ReferralRewardCardDays int8 = 1
+	ReferralRewardCoupon   int8 = 2
+)
+
+const (
+	ReferralRuleEnabled  int8 = 1
+	ReferralRuleDisabled int8 = 2
+)
+
+// Referral records who brought a new member in. The referrer is rewarded once
+// the new member's first paid card has started, and loses the reward again if
+// that card is refunded.
+type Referral struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	ReferrerID   int64      `gorm:"index;not null" json:"referrer_id"`
+	RefereeID    int64      `gorm:"uniqueIndex;not null" json:"referee_id"`
+	Code         string     `gorm:"type:varchar(16);not null" json:"code"`      // 注册时使用的推荐码
+	Status       int8       `gorm:"type:tinyint;default:1;index" json:"status"` // 1-待奖励，2-已奖励，3-已撤回
+	CardID       *int64     `json:"card_id"`                                    // 被推荐人的首张付费卡
+	RuleID       *int64     `json:"rule_id"`
+	RewardType   int8       `gorm:"type:tinyint;default:0" json:"reward_type"` // 1-会员卡天数，2-优惠券
+	RewardDays   int        `gorm:"default:0" json:"reward_days"`
+	RewardCardID *int64     `json:"reward_card_id"` // 延期的推荐人会员卡
+	CouponID     *int64     `json:"coupon_id"`
+	RewardedAt   *time.Time `gorm:"index" json:"rewarded_at"`
+	CreatedAt    time.Time  `gorm:"index" json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (Referral) TableName() string {
+	return "referrals"
+}
+
+// ReferralRule sets the referrer's reward. Days go onto the referrer's active
+// time card; referrers without one get a coupon of the promotion instead.
+type ReferralRule struct {
+	ID              int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name            string    `gorm:"type:varchar(100);not null" json:"name"`
+	Days            int       `gorm:"default:0" json:"days"`                      // 赠送推荐人的会员卡天数
+	PromotionID     *int64    `json:"promotion_id"`                               // 无有效会员卡时发放该促销活动的优惠券
+	CouponValidDays int       `gorm:"default:0" json:"coupon_valid_days"`         // 优惠券有效天数，0为不限
+	Status          int8      `gorm:"type:tinyint;default:1;index" json:"status"` // 1-启用，2-停用
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+func (ReferralRule) TableName() string {
+	return "referral_rules"
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CourseReview struct {
+	ID           int64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	BookingID    int64          `gorm:"uniqueIndex;not null" json:"booking_id"` // 每个预约只能评价一次
+	UserID       int64          `gorm:"index;not null" json:"user_id"`
+	CourseID     int64          `gorm:"index;not null" json:"course_id"`
+	CoachID      int64          `gorm:"index;not null" json:"coach_id"`
+	CourseRating int8           `gorm:"type:tinyint;not null" json:"course_rating"` // 1-5
+	CoachRating  int8           `gorm:"type:tinyint;not null" json:"coach_rating"`  // 1-5
+	Comment      string         `gorm:"type:text" json:"comment"`
+	Status       int8           `gorm:"type:tinyint;default:1;index" json:"status"` // 1-显示，2-已隐藏
+	HiddenBy     *int64         `json:"hidden_by"`                                  // 隐藏操作员ID
+	HiddenAt     *time.Time     `json:"hidden_at"`
+	HiddenReason string         `gorm:"type:varchar(255)" json:"hidden_reason"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+func (CourseReview) TableName() string {
+	return "course_reviews"
+}
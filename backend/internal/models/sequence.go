@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Sequence is a named counter used to allocate business numbers such as UserNo
+type Sequence struct {
+	Name      string    `gorm:"type:varchar(64);primaryKey" json:"name"`
+	Value     int64     `gorm:"not null;default:0" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (Sequence) TableName() string {
+	return "sequences"
+}
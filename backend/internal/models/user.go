@@ -10,7 +10,9 @@ type User struct {
 	ID               int64          `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserNo           string         `gorm:"type:varchar(32);uniqueIndex;not null" json:"user_no"`
 	Name             string         `gorm:"type:varchar(50);not null" json:"name"`
-	Gender           int8           `gorm:"type:tinyint" json:"gender"` // 1-男，2-女
+	NamePinyin       string         `gorm:"type:varchar(255);index" json:"-"` // 姓名全拼，用于搜索
+	NameInitials     string         `gorm:"type:varchar(50);index" json:"-"`  // 姓名拼音首字母，用于搜索
+	Gender           int8           `gorm:"type:tinyint" json:"gender"`       // 1-男，2-女
 	Birthday         *time.Time     `gorm:"type:date" json:"birthday"`
 	IDCard           string         `gorm:"type:varchar(18)" json:"id_card"`
 	Phone            string         `gorm:"type:varchar(11);uniqueIndex;not null" json:"phone"`
@@ -21,8 +23,12 @@ type User struct {
 	EmergencyPhone   string         `gorm:"type:varchar(11)" json:"emergency_phone"`
 	HealthStatus     string         `gorm:"type:text" json:"health_status"`
 	TrainingGoal     string         `gorm:"type:text" json:"training_goal"`
-	Source           int8           `gorm:"type:tinyint;default:1" json:"source"`       // 1-前台录入，2-小程序注册，3-美团，4-抖音
-	Status           int8           `gorm:"type:tinyint;default:1;index" json:"status"` // 1-正常，2-冻结，3-黑名单
+	Source           int8           `gorm:"type:tinyint;default:1" json:"source"`              // 1-前台录入，2-小程序注册，3-美团，4-抖音，5-会员推荐
+	ReferralCode     *string        `gorm:"type:varchar(16);uniqueIndex" json:"referral_code"` // 本人的推荐码
+	ReferrerID       *int64         `gorm:"index" json:"referrer_id"`                          // 推荐人
+	Status           int8           `gorm:"type:tinyint;default:1;index" json:"status"`        // 1-正常，2-冻结，3-黑名单
+	Role             string         `gorm:"type:varchar(20);default:user" json:"role"`         // user-会员，staff-前台，manager-店长，admin-管理员
+	PasswordHash     string         `gorm:"type:varchar(100);not null;default:''" json:"-"`    // bcrypt 哈希，为空则不能登录
 	Remark           string         `gorm:"type:text" json:"remark"`
 	CreatedAt        time.Time      `json:"created_at"`
 	UpdatedAt        time.Time      `json:"updated_at"`
@@ -49,3 +55,17 @@ type UserTrainingStats struct {
 func (UserTrainingStats) TableName() string {
 	return "user_training_stats"
 }
+
+type UserStatusChange struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int64     `gorm:"index;not null" json:"user_id"`
+	OldStatus  int8      `gorm:"type:tinyint;not null" json:"old_status"`
+	NewStatus  int8      `gorm:"type:tinyint;not null" json:"new_status"`
+	Reason     string    `gorm:"type:varchar(255)" json:"reason"`
+	OperatorID int64     `gorm:"not null" json:"operator_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (UserStatusChange) TableName() string {
+	return "user_status_changes"
+}
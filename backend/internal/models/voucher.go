@@ -6,6 +6,17 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	VoucherPlatformMeituan int8 = 1
+	VoucherPlatformDouyin  int8 = 2
+)
+
+const (
+	VoucherStatusUnverified int8 = 1
+	VoucherStatusVerified   int8 = 2
+	VoucherStatusExpired    int8 = 3
+)
+
 type VoucherRecord struct {
 	ID           int64          `gorm:"primaryKey;autoIncrement" json:"id"`
 	VoucherCode  string         `gorm:"type:varchar(100);uniqueIndex;not null" json:"voucher_code"`
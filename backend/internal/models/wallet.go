@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	WalletTxTopUp       int8 = 1
+	WalletTxBonus       int8 = 2
+	WalletTxSpend       int8 = 3
+	WalletTxRefund      int8 = 4
+	WalletTxTopUpRefund int8 = 5
+)
+
+const (
+	TopUpRuleEnabled  int8 = 1
+	TopUpRuleDisabled int8 = 2
+)
+
+type Wallet struct {
+	ID           int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       int64     `gorm:"uniqueIndex;not null" json:"user_id"`
+	Balance      float64   `gorm:"type:decimal(10,2);default:0" json:"balance"`       // 本金余额
+	BonusBalance float64   `gorm:"type:decimal(10,2);default:0" json:"bonus_balance"` // 赠送余额
+	Version      int64     `gorm:"default:0" json:"-"`                                // 乐观锁版本号
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+func (Wallet) TableName() string {
+	return "wallets"
+}
+
+// WalletTransaction is an append-only ledger entry. Amounts are signed:
+// credits are positive, debits negative.
+type WalletTransaction struct {
+	ID                int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	WalletID          int64     `gorm:"index;not null" json:"wallet_id"`
+	UserID            int64     `gorm:"index;not null" json:"user_id"`
+	Type              int8      `gorm:"type:tinyint;not null;uniqueIndex:uk_type_reference" json:"type"`          // 1-充值，2-充值赠送，3-消费，4-退款退回，5-充值退款扣回
+	Reference         string    `gorm:"type:varchar(64);not null;uniqueIndex:uk_type_reference" json:"reference"` // 支付单号/退款单号等业务单号
+	PrincipalAmount   float64   `gorm:"type:decimal(10,2);default:0" json:"principal_amount"`
+	BonusAmount       float64   `gorm:"type:decimal(10,2);default:0" json:"bonus_amount"`
+	BalanceAfter      float64   `gorm:"type:decimal(10,2)" json:"balance_after"`
+	BonusBalanceAfter float64   `gorm:"type:decimal(10,2)" json:"bonus_balance_after"`
+	OrderID           *int64    `gorm:"index" json:"order_id"`
+	OperatorID        *int64    `json:"operator_id"`
+	Remark            string    `gorm:"type:varchar(255)" json:"remark"`
+	CreatedAt         time.Time `gorm:"index" json:"created_at"`
+}
+
+func (WalletTransaction) TableName() string {
+	return "wallet_transactions"
+}
+
+type TopUpRule struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name        string     `gorm:"type:varchar(100);not null" json:"name"`
+	MinAmount   float64    `gorm:"type:decimal(10,2);not null" json:"min_amount"`   // 单次充值满该金额
+	BonusAmount float64    `gorm:"type:decimal(10,2);not null" json:"bonus_amount"` // 赠送金额
+	StartAt     *time.Time `json:"start_at"`
+	EndAt       *time.Time `json:"end_at"`
+	Status      int8       `gorm:"type:tinyint;default:1;index" json:"status"` // 1-启用，2-停用
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (TopUpRule) TableName() string {
+	return "top_up_rules"
+}
@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/listquery"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AnalyticsRepository interface {
+	CountUsersRegistered(ctx context.Context, from, to time.Time) (int64, error)
+	CountCohortActive(ctx context.Context, cohortFrom, cohortTo, from, to time.Time) (int64, error)
+	CardsEndingBetween(ctx context.Context, from, to time.Time) ([]models.MembershipCard, error)
+	CardsStartingBetween(ctx context.Context, userIDs []int64, from, to time.Time) ([]models.MembershipCard, error)
+	ActiveCardActivities(ctx context.Context, today, recentFrom, prevFrom time.Time) ([]ActiveCardActivity, error)
+	ReplaceCohorts(ctx context.Context, rows []models.CohortRetention) error
+	ReplaceRenewals(ctx context.Context, rows []models.RenewalStat) error
+	ReplaceMemberActivities(ctx context.Context, rows []models.MemberActivity) error
+	ListCohorts(ctx context.Context, from time.Time) ([]models.CohortRetention, error)
+	ListRenewals(ctx context.Context, from time.Time) ([]models.RenewalStat, error)
+	ListAtRisk(ctx context.Context, filter AtRiskFilter, q listquery.Query) ([]models.MemberActivity, listquery.Page, error)
+}
+
+type analyticsRepository struct {
+	db *gorm.DB
+}
+
+func NewAnalyticsRepository(db *gorm.DB) AnalyticsRepository {
+	return &analyticsRepository{db: db}
+}
+
+// CountUsersRegistered counts users created in [from, to)
+func (r *analyticsRepository) CountUsersRegistered(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&count).Error
+	return count, err
+}
+
+// CountCohortActive counts members registered in [cohortFrom, cohortTo) who checked in during [from, to)
+func (r *analyticsRepository) CountCohortActive(ctx context.Context, cohortFrom, cohortTo, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.CheckIn{}).
+		Joins("JOIN users ON users.id = check_ins.user_id AND users.deleted_at IS NULL").
+		Where("users.created_at >= ? AND users.created_at < ?", cohortFrom, cohortTo).
+		Where("check_ins.check_in_time >= ? AND check_ins.check_in_time < ?", from, to).
+		Distinct("check_ins.user_id").
+		Count(&count).Error
+	return count, err
+}
+
+// CardsEndingBetween returns the cards that ended in [from, to), excluding
+// transferred and refunded cards which cannot be renewed
+func (r *analyticsRepository) CardsEndingBetween(ctx context.Context, from, to time.Time) ([]models.MembershipCard, error) {
+	var cards []models.MembershipCard
+	err := r.db.WithContext(ctx).Select("id", "user_id", "end_date").
+		Where("end_date >= ? AND end_date < ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Where("status NOT IN ?", []int8{4, 5}).
+		Find(&cards).Error
+	return cards, err
+}
+
+// CardsStartingBetween returns the cards of the given users that started in [from, to)
+func (r *analyticsRepository) CardsStartingBetween(ctx context.Context, userIDs []int64, from, to time.Time) ([]models.MembershipCard, error) {
+	var cards []models.MembershipCard
+	if len(userIDs) == 0 {
+		return cards, nil
+	}
+	err := r.db.WithContext(ctx).Select("id", "user_id", "start_date").
+		Where("user_id IN ?", userIDs).
+		Where("start_date >= ? AND start_date < ?", from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Find(&cards).Error
+	return cards, err
+}
+
+// ActiveCardActivity is the visit pattern of one member with an active card
+type ActiveCardActivity struct {
+	UserID        int64
+	CardID        int64
+	CardStartDate time.Time
+	CardEndDate   time.Time
+	LastCheckInAt *time.Time
+	VisitsLast30  int
+	VisitsPrev30  int
+}
+
+// ActiveCardActivities aggregates check-ins of every member holding a normal,
+// unexpired card. recentFrom and prevFrom delimit the two 30-day windows.
+func (r *analyticsRepository) ActiveCardActivities(ctx context.Context, today, recentFrom, prevFrom time.Time) ([]ActiveCardActivity, error) {
+	var scanned []struct {
+		UserID        int64
+		CardID        int64
+		CardStartDate aggregateTime
+		CardEndDate   aggregateTime
+		LastCheckInAt aggregateTime
+		VisitsLast30  int
+		VisitsPrev30  int
+	}
+	err := r.db.WithContext(ctx).Table("membership_cards AS mc").
+		Select(`mc.user_id AS user_id, MAX(mc.id) AS card_id,
+			MIN(mc.start_date) AS card_start_date, MAX(mc.end_date) AS card_end_date,
+			MAX(ci.check_in_time) AS last_check_in_at,
+			COUNT(DISTINCT CASE WHEN ci.check_in_time >= ? THEN ci.id END) AS visits_last30,
+			COUNT(DISTINCT CASE WHEN ci.check_in_time >= ? AND ci.check_in_time < ? THEN ci.id END) AS visits_prev30`,
+			recentFrom, prevFrom, recentFrom).
+		Joins("LEFT JOIN check_ins ci ON ci.user_id = mc.user_id AND ci.deleted_at IS NULL").
+		Where("mc.status = ? AND mc.end_date >= ? AND mc.deleted_at IS NULL", 1, today.Format("2006-01-02")).
+		Group("mc.user_id").
+		Scan(&scanned).Error
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]ActiveCardActivity, len(scanned))
+	for i, row := range scanned {
+		rows[i] = ActiveCardActivity{
+			UserID:        row.UserID,
+			CardID:        row.CardID,
+			CardStartDate: row.CardStartDate.Time,
+			CardEndDate:   row.CardEndDate.Time,
+			VisitsLast30:  row.VisitsLast30,
+			VisitsPrev30:  row.VisitsPrev30,
+		}
+		if row.LastCheckInAt.Valid {
+			lastCheckInAt := row.LastCheckInAt.Time
+			rows[i].LastCheckInAt = &lastCheckInAt
+		}
+	}
+	return rows, nil
+}
+
+// aggregateTime scans MIN/MAX of a date column, which MySQL returns as a
+// time and SQLite as text
+type aggregateTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+var aggregateTimeLayouts = []string{
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02T15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func (t *aggregateTime) Scan(value interface{}) error {
+	var text string
+	switch v := value.(type) {
+	case nil:
+		t.Time, t.Valid = time.Time{}, false
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into a time", value)
+	}
+
+	for _, layout := range aggregateTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse %q as a time", text)
+}
+
+func (t aggregateTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+// ReplaceCohorts swaps the cohort table contents in one transaction
+func (r *analyticsRepository) ReplaceCohorts(ctx context.Context, rows []models.CohortRetention) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.CohortRetention{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+// ReplaceRenewals swaps the renewal table contents in one transaction
+func (r *analyticsRepository) ReplaceRenewals(ctx context.Context, rows []models.RenewalStat) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.RenewalStat{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+// ReplaceMemberActivities swaps the activity snapshot in one transaction
+func (r *analyticsRepository) ReplaceMemberActivities(ctx context.Context, rows []models.MemberActivity) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.MemberActivity{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+func (r *analyticsRepository) ListCohorts(ctx context.Context, from time.Time) ([]models.CohortRetention, error) {
+	var rows []models.CohortRetention
+	err := r.db.WithContext(ctx).Where("cohort_month >= ?", from.Format("2006-01-02")).
+		Order("cohort_month, month_offset").Find(&rows).Error
+	return rows, err
+}
+
+func (r *analyticsRepository) ListRenewals(ctx context.Context, from time.Time) ([]models.RenewalStat, error) {
+	var rows []models.RenewalStat
+	err := r.db.WithContext(ctx).Where("month >= ?", from.Format("2006-01-02")).Order("month").Find(&rows).Error
+	return rows, err
+}
+
+// AtRiskFilter selects members who stopped coming or whose visits dropped
+type AtRiskFilter struct {
+	InactiveDays int     // no check-in for at least this many days
+	DropRate     float64 // visits in the last 30 days fell by at least this fraction
+	MinPrevVisit int     // only consider a drop when the previous window had this many visits
+}
+
+// AtRiskListSpec is what the at-risk list can be filtered, sorted and selected by
+var AtRiskListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"card_end_date": {Column: "card_end_date", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+	},
+	Sorts: map[string]string{
+		"days_since_check_in": "days_since_check_in",
+		"card_end_date":       "card_end_date",
+		"visits_last_30_days": "visits_last_30_days",
+	},
+	Fields: []string{"user_id", "card_id", "card_end_date", "last_check_in_at", "days_since_check_in",
+		"visits_last_30_days", "visits_prev_30_days", "snapshot_date"},
+	DefaultSort: "-days_since_check_in,card_end_date",
+}
+
+func (r *analyticsRepository) ListAtRisk(ctx context.Context, filter AtRiskFilter, q listquery.Query) ([]models.MemberActivity, listquery.Page, error) {
+	var rows []models.MemberActivity
+	query := r.db.WithContext(ctx).Model(&models.MemberActivity{}).Where(
+		r.db.WithContext(ctx).Where("days_since_check_in >= ?", filter.InactiveDays).
+			Or("visits_prev_30_days >= ? AND visits_last_30_days <= visits_prev_30_days * ?",
+				filter.MinPrevVisit, 1-filter.DropRate),
+	)
+	page, err := listquery.Find(query, q, &rows)
+	return rows, page, err
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CardRepository interface {
+	GetCardTypeByID(ctx context.Context, id int64) (*models.CardType, error)
+	GetCardTypeByCode(ctx context.Context, code string) (*models.CardType, error)
+	GetByID(ctx context.Context, id int64) (*models.MembershipCard, error)
+	Create(ctx context.Context, card *models.MembershipCard) error
+	CreateLog(ctx context.Context, log *models.CardOperationLog) error
+	MarkRefunded(ctx context.Context, id int64) (bool, error)
+	MarkTransferred(ctx context.Context, card *models.MembershipCard) (bool, error)
+	Extend(ctx context.Context, card *models.MembershipCard, newEndDate time.Time) (bool, error)
+	ListUsable(ctx context.Context, userID int64, day time.Time) ([]models.MembershipCard, error)
+	UseVisit(ctx context.Context, id int64) (bool, error)
+}
+
+type cardRepository struct {
+	db *gorm.DB
+}
+
+func NewCardRepository(db *gorm.DB) CardRepository {
+	return &cardRepository{db: db}
+}
+
+func (r *cardRepository) GetCardTypeByID(ctx context.Context, id int64) (*models.CardType, error) {
+	var cardType models.CardType
+	err := r.db.WithContext(ctx).First(&cardType, id).Error
+	return &cardType, err
+}
+
+func (r *cardRepository) GetCardTypeByCode(ctx context.Context, code string) (*models.CardType, error) {
+	var cardType models.CardType
+	err := r.db.WithContext(ctx).Where("type_code = ?", code).First(&cardType).Error
+	return &cardType, err
+}
+
+func (r *cardRepository) GetByID(ctx context.Context, id int64) (*models.MembershipCard, error) {
+	var card models.MembershipCard
+	err := r.db.WithContext(ctx).First(&card, id).Error
+	return &card, err
+}
+
+func (r *cardRepository) Create(ctx context.Context, card *models.MembershipCard) error {
+	return r.db.WithContext(ctx).Create(card).Error
+}
+
+func (r *cardRepository) CreateLog(ctx context.Context, log *models.CardOperationLog) error {
+	return r.db.WithContext(ctx).Create(log).Error
+}
+
+// MarkRefunded closes a card that has been paid back, returning false when it
+// was already transferred or refunded
+func (r *cardRepository) MarkRefunded(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Where("id = ? AND status IN ?", id, []int8{models.CardStatusNormal, models.CardStatusExpired, models.CardStatusFrozen}).
+		Update("status", models.CardStatusRefunded)
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkTransferred closes a card handed over to another member, returning
+// false when it is no longer the running card it was read as
+func (r *cardRepository) MarkTransferred(ctx context.Context, card *models.MembershipCard) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Where("id = ? AND user_id = ? AND status = ? AND is_frozen = 0 AND end_date = ?", card.ID, card.UserID, models.CardStatusNormal, card.EndDate).
+		Update("status", models.CardStatusTransferred)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Extend moves the end date of a running card, returning false when the card
+// has changed since the new end date was worked out from it
+func (r *cardRepository) Extend(ctx context.Context, card *models.MembershipCard, newEndDate time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Where("id = ? AND user_id = ? AND status = ? AND end_date = ?", card.ID, card.UserID, models.CardStatusNormal, card.EndDate).
+		Update("end_date", newEndDate)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListUsable returns the member's cards that admit them on day: running, not
+// frozen and within their dates, those ending first first
+func (r *cardRepository) ListUsable(ctx context.Context, userID int64, day time.Time) ([]models.MembershipCard, error) {
+	var cards []models.MembershipCard
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status = ? AND is_frozen = 0", userID, models.CardStatusNormal).
+		Where("start_date <= ? AND end_date >= ?", day, day).
+		Order("end_date, id").
+		Find(&cards).Error
+	return cards, err
+}
+
+// UseVisit takes one visit off a visit card, returning false when it has none
+// left or can no longer be used
+func (r *cardRepository) UseVisit(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Where("id = ? AND status = ? AND is_frozen = 0 AND remaining_times > 0", id, models.CardStatusNormal).
+		Update("remaining_times", gorm.Expr("remaining_times - 1"))
+	return result.RowsAffected > 0, result.Error
+}
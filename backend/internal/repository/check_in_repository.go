@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type CheckInRepository interface {
+	Create(ctx context.Context, checkIn *models.CheckIn) error
+}
+
+type checkInRepository struct {
+	db *gorm.DB
+}
+
+func NewCheckInRepository(db *gorm.DB) CheckInRepository {
+	return &checkInRepository{db: db}
+}
+
+func (r *checkInRepository) Create(ctx context.Context, checkIn *models.CheckIn) error {
+	return r.db.WithContext(ctx).Create(checkIn).Error
+}
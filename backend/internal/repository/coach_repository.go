@@ -1,52 +1,92 @@
 package repository
 
 import (
+	"context"
 	"gym-admin/internal/models"
-	"gym-admin/pkg/database"
+	"gym-admin/pkg/listquery"
 
 	"gorm.io/gorm"
 )
 
-type CoachRepository struct {
+type CoachRepository interface {
+	Create(ctx context.Context, coach *models.Coach) error
+	GetByID(ctx context.Context, id int64) (*models.Coach, error)
+	List(ctx context.Context, q listquery.Query) ([]models.Coach, listquery.Page, error)
+	Update(ctx context.Context, coach *models.Coach) error
+	Delete(ctx context.Context, id int64) error
+	UpdateRating(ctx context.Context, id int64, rating float64, totalRatings int) error
+	EachBatch(ctx context.Context, status *int8, batchSize int, fn func([]models.Coach) error) error
+}
+
+type coachRepository struct {
 	db *gorm.DB
 }
 
-func NewCoachRepository() *CoachRepository {
-	return &CoachRepository{db: database.GetDB()}
+// CoachListSpec is what the coach list can be filtered, sorted and selected by
+var CoachListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":      {Column: "status", Kind: listquery.Int},
+		"gender":      {Column: "gender", Kind: listquery.Int},
+		"rating":      {Column: "rating", Kind: listquery.Float, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"hourly_rate": {Column: "hourly_rate", Kind: listquery.Float, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"name":        {Column: "name", Kind: listquery.String, Ops: []listquery.Op{listquery.Like}},
+	},
+	Sorts: map[string]string{
+		"created_at":    "created_at",
+		"rating":        "rating",
+		"total_ratings": "total_ratings",
+		"hourly_rate":   "hourly_rate",
+		"experience":    "experience",
+	},
+	Fields: []string{"coach_no", "name", "gender", "phone", "email", "avatar_url", "specialties",
+		"certifications", "experience", "introduction", "hourly_rate", "rating", "total_ratings",
+		"status", "hire_date", "remark", "created_at", "updated_at"},
+	DefaultSort: "-created_at",
+}
+
+func NewCoachRepository(db *gorm.DB) CoachRepository {
+	return &coachRepository{db: db}
 }
 
-func (r *CoachRepository) Create(coach *models.Coach) error {
-	return r.db.Create(coach).Error
+func (r *coachRepository) Create(ctx context.Context, coach *models.Coach) error {
+	return r.db.WithContext(ctx).Create(coach).Error
 }
 
-func (r *CoachRepository) GetByID(id int64) (*models.Coach, error) {
+func (r *coachRepository) GetByID(ctx context.Context, id int64) (*models.Coach, error) {
 	var coach models.Coach
-	err := r.db.First(&coach, id).Error
+	err := r.db.WithContext(ctx).First(&coach, id).Error
 	return &coach, err
 }
 
-func (r *CoachRepository) List(page, pageSize int, status *int8) ([]models.Coach, int64, error) {
+func (r *coachRepository) List(ctx context.Context, q listquery.Query) ([]models.Coach, listquery.Page, error) {
 	var coaches []models.Coach
-	var total int64
-
-	query := r.db.Model(&models.Coach{})
-	if status != nil {
-		query = query.Where("status = ?", *status)
-	}
+	page, err := listquery.Find(r.db.WithContext(ctx).Model(&models.Coach{}), q, &coaches)
+	return coaches, page, err
+}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
+func (r *coachRepository) Update(ctx context.Context, coach *models.Coach) error {
+	return r.db.WithContext(ctx).Save(coach).Error
+}
 
-	offset := (page - 1) * pageSize
-	err := query.Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&coaches).Error
-	return coaches, total, err
+func (r *coachRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Delete(&models.Coach{}, id).Error
 }
 
-func (r *CoachRepository) Update(coach *models.Coach) error {
-	return r.db.Save(coach).Error
+func (r *coachRepository) UpdateRating(ctx context.Context, id int64, rating float64, totalRatings int) error {
+	return r.db.WithContext(ctx).Model(&models.Coach{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"rating":        rating,
+		"total_ratings": totalRatings,
+	}).Error
 }
 
-func (r *CoachRepository) Delete(id int64) error {
-	return r.db.Delete(&models.Coach{}, id).Error
+// EachBatch streams the coaches with the given status in primary key order, batchSize at a time
+func (r *coachRepository) EachBatch(ctx context.Context, status *int8, batchSize int, fn func([]models.Coach) error) error {
+	var batch []models.Coach
+	query := r.db.WithContext(ctx).Model(&models.Coach{})
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	return query.FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		return fn(batch)
+	}).Error
 }
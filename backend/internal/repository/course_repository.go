@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type CourseRepository interface {
+	GetByID(ctx context.Context, id int64) (*models.Course, error)
+	GetBookingByID(ctx context.Context, id int64) (*models.Booking, error)
+}
+
+type courseRepository struct {
+	db *gorm.DB
+}
+
+func NewCourseRepository(db *gorm.DB) CourseRepository {
+	return &courseRepository{db: db}
+}
+
+func (r *courseRepository) GetByID(ctx context.Context, id int64) (*models.Course, error) {
+	var course models.Course
+	err := r.db.WithContext(ctx).First(&course, id).Error
+	return &course, err
+}
+
+func (r *courseRepository) GetBookingByID(ctx context.Context, id int64) (*models.Booking, error) {
+	var booking models.Booking
+	err := r.db.WithContext(ctx).First(&booking, id).Error
+	return &booking, err
+}
@@ -0,0 +1,102 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"time"
+)
+
+var _ repository.AnalyticsRepository = (*AnalyticsRepository)(nil)
+
+type AnalyticsRepository struct {
+	CountUsersRegisteredFunc    func(ctx context.Context, from, to time.Time) (int64, error)
+	CountCohortActiveFunc       func(ctx context.Context, cohortFrom, cohortTo, from, to time.Time) (int64, error)
+	CardsEndingBetweenFunc      func(ctx context.Context, from, to time.Time) ([]models.MembershipCard, error)
+	CardsStartingBetweenFunc    func(ctx context.Context, userIDs []int64, from, to time.Time) ([]models.MembershipCard, error)
+	ActiveCardActivitiesFunc    func(ctx context.Context, today, recentFrom, prevFrom time.Time) ([]repository.ActiveCardActivity, error)
+	ReplaceCohortsFunc          func(ctx context.Context, rows []models.CohortRetention) error
+	ReplaceRenewalsFunc         func(ctx context.Context, rows []models.RenewalStat) error
+	ReplaceMemberActivitiesFunc func(ctx context.Context, rows []models.MemberActivity) error
+	ListCohortsFunc             func(ctx context.Context, from time.Time) ([]models.CohortRetention, error)
+	ListRenewalsFunc            func(ctx context.Context, from time.Time) ([]models.RenewalStat, error)
+	ListAtRiskFunc              func(ctx context.Context, filter repository.AtRiskFilter, q listquery.Query) ([]models.MemberActivity, listquery.Page, error)
+}
+
+func (f *AnalyticsRepository) CountUsersRegistered(ctx context.Context, from, to time.Time) (int64, error) {
+	if f.CountUsersRegisteredFunc == nil {
+		panic("fake: AnalyticsRepository.CountUsersRegistered not stubbed")
+	}
+	return f.CountUsersRegisteredFunc(ctx, from, to)
+}
+
+func (f *AnalyticsRepository) CountCohortActive(ctx context.Context, cohortFrom, cohortTo, from, to time.Time) (int64, error) {
+	if f.CountCohortActiveFunc == nil {
+		panic("fake: AnalyticsRepository.CountCohortActive not stubbed")
+	}
+	return f.CountCohortActiveFunc(ctx, cohortFrom, cohortTo, from, to)
+}
+
+func (f *AnalyticsRepository) CardsEndingBetween(ctx context.Context, from, to time.Time) ([]models.MembershipCard, error) {
+	if f.CardsEndingBetweenFunc == nil {
+		panic("fake: AnalyticsRepository.CardsEndingBetween not stubbed")
+	}
+	return f.CardsEndingBetweenFunc(ctx, from, to)
+}
+
+func (f *AnalyticsRepository) CardsStartingBetween(ctx context.Context, userIDs []int64, from, to time.Time) ([]models.MembershipCard, error) {
+	if f.CardsStartingBetweenFunc == nil {
+		panic("fake: AnalyticsRepository.CardsStartingBetween not stubbed")
+	}
+	return f.CardsStartingBetweenFunc(ctx, userIDs, from, to)
+}
+
+func (f *AnalyticsRepository) ActiveCardActivities(ctx context.Context, today, recentFrom, prevFrom time.Time) ([]repository.ActiveCardActivity, error) {
+	if f.ActiveCardActivitiesFunc == nil {
+		panic("fake: AnalyticsRepository.ActiveCardActivities not stubbed")
+	}
+	return f.ActiveCardActivitiesFunc(ctx, today, recentFrom, prevFrom)
+}
+
+func (f *AnalyticsRepository) ReplaceCohorts(ctx context.Context, rows []models.CohortRetention) error {
+	if f.ReplaceCohortsFunc == nil {
+		panic("fake: AnalyticsRepository.ReplaceCohorts not stubbed")
+	}
+	return f.ReplaceCohortsFunc(ctx, rows)
+}
+
+func (f *AnalyticsRepository) ReplaceRenewals(ctx context.Context, rows []models.RenewalStat) error {
+	if f.ReplaceRenewalsFunc == nil {
+		panic("fake: AnalyticsRepository.ReplaceRenewals not stubbed")
+	}
+	return f.ReplaceRenewalsFunc(ctx, rows)
+}
+
+func (f *AnalyticsRepository) ReplaceMemberActivities(ctx context.Context, rows []models.MemberActivity) error {
+	if f.ReplaceMemberActivitiesFunc == nil {
+		panic("fake: AnalyticsRepository.ReplaceMemberActivities not stubbed")
+	}
+	return f.ReplaceMemberActivitiesFunc(ctx, rows)
+}
+
+func (f *AnalyticsRepository) ListCohorts(ctx context.Context, from time.Time) ([]models.CohortRetention, error) {
+	if f.ListCohortsFunc == nil {
+		panic("fake: AnalyticsRepository.ListCohorts not stubbed")
+	}
+	return f.ListCohortsFunc(ctx, from)
+}
+
+func (f *AnalyticsRepository) ListRenewals(ctx context.Context, from time.Time) ([]models.RenewalStat, error) {
+	if f.ListRenewalsFunc == nil {
+		panic("fake: AnalyticsRepository.ListRenewals not stubbed")
+	}
+	return f.ListRenewalsFunc(ctx, from)
+}
+
+func (f *AnalyticsRepository) ListAtRisk(ctx context.Context, filter repository.AtRiskFilter, q listquery.Query) ([]models.MemberActivity, listquery.Page, error) {
+	if f.ListAtRiskFunc == nil {
+		panic("fake: AnalyticsRepository.ListAtRisk not stubbed")
+	}
+	return f.ListAtRiskFunc(ctx, filter, q)
+}
@@ -0,0 +1,93 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"time"
+)
+
+var _ repository.CardRepository = (*CardRepository)(nil)
+
+type CardRepository struct {
+	GetCardTypeByIDFunc   func(ctx context.Context, id int64) (*models.CardType, error)
+	GetCardTypeByCodeFunc func(ctx context.Context, code string) (*models.CardType, error)
+	GetByIDFunc           func(ctx context.Context, id int64) (*models.MembershipCard, error)
+	CreateFunc            func(ctx context.Context, card *models.MembershipCard) error
+	CreateLogFunc         func(ctx context.Context, log *models.CardOperationLog) error
+	MarkRefundedFunc      func(ctx context.Context, id int64) (bool, error)
+	MarkTransferredFunc   func(ctx context.Context, card *models.MembershipCard) (bool, error)
+	ExtendFunc            func(ctx context.Context, card *models.MembershipCard, newEndDate time.Time) (bool, error)
+	ListUsableFunc        func(ctx context.Context, userID int64, day time.Time) ([]models.MembershipCard, error)
+	UseVisitFunc          func(ctx context.Context, id int64) (bool, error)
+}
+
+func (f *CardRepository) GetCardTypeByID(ctx context.Context, id int64) (*models.CardType, error) {
+	if f.GetCardTypeByIDFunc == nil {
+		panic("fake: CardRepository.GetCardTypeByID not stubbed")
+	}
+	return f.GetCardTypeByIDFunc(ctx, id)
+}
+
+func (f *CardRepository) GetCardTypeByCode(ctx context.Context, code string) (*models.CardType, error) {
+	if f.GetCardTypeByCodeFunc == nil {
+		panic("fake: CardRepository.GetCardTypeByCode not stubbed")
+	}
+	return f.GetCardTypeByCodeFunc(ctx, code)
+}
+
+func (f *CardRepository) GetByID(ctx context.Context, id int64) (*models.MembershipCard, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: CardRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *CardRepository) Create(ctx context.Context, card *models.MembershipCard) error {
+	if f.CreateFunc == nil {
+		panic("fake: CardRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, card)
+}
+
+func (f *CardRepository) CreateLog(ctx context.Context, log *models.CardOperationLog) error {
+	if f.CreateLogFunc == nil {
+		panic("fake: CardRepository.CreateLog not stubbed")
+	}
+	return f.CreateLogFunc(ctx, log)
+}
+
+func (f *CardRepository) MarkRefunded(ctx context.Context, id int64) (bool, error) {
+	if f.MarkRefundedFunc == nil {
+		panic("fake: CardRepository.MarkRefunded not stubbed")
+	}
+	return f.MarkRefundedFunc(ctx, id)
+}
+
+func (f *CardRepository) MarkTransferred(ctx context.Context, card *models.MembershipCard) (bool, error) {
+	if f.MarkTransferredFunc == nil {
+		panic("fake: CardRepository.MarkTransferred not stubbed")
+	}
+	return f.MarkTransferredFunc(ctx, card)
+}
+
+func (f *CardRepository) Extend(ctx context.Context, card *models.MembershipCard, newEndDate time.Time) (bool, error) {
+	if f.ExtendFunc == nil {
+		panic("fake: CardRepository.Extend not stubbed")
+	}
+	return f.ExtendFunc(ctx, card, newEndDate)
+}
+
+func (f *CardRepository) ListUsable(ctx context.Context, userID int64, day time.Time) ([]models.MembershipCard, error) {
+	if f.ListUsableFunc == nil {
+		panic("fake: CardRepository.ListUsable not stubbed")
+	}
+	return f.ListUsableFunc(ctx, userID, day)
+}
+
+func (f *CardRepository) UseVisit(ctx context.Context, id int64) (bool, error) {
+	if f.UseVisitFunc == nil {
+		panic("fake: CardRepository.UseVisit not stubbed")
+	}
+	return f.UseVisitFunc(ctx, id)
+}
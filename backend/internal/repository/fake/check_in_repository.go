@@ -0,0 +1,20 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+)
+
+var _ repository.CheckInRepository = (*CheckInRepository)(nil)
+
+type CheckInRepository struct {
+	CreateFunc func(ctx context.Context, checkIn *models.CheckIn) error
+}
+
+func (f *CheckInRepository) Create(ctx context.Context, checkIn *models.CheckIn) error {
+	if f.CreateFunc == nil {
+		panic("fake: CheckInRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, checkIn)
+}
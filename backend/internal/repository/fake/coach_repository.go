@@ -0,0 +1,69 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+)
+
+var _ repository.CoachRepository = (*CoachRepository)(nil)
+
+type CoachRepository struct {
+	CreateFunc       func(ctx context.Context, coach *models.Coach) error
+	GetByIDFunc      func(ctx context.Context, id int64) (*models.Coach, error)
+	ListFunc         func(ctx context.Context, q listquery.Query) ([]models.Coach, listquery.Page, error)
+	UpdateFunc       func(ctx context.Context, coach *models.Coach) error
+	DeleteFunc       func(ctx context.Context, id int64) error
+	UpdateRatingFunc func(ctx context.Context, id int64, rating float64, totalRatings int) error
+	EachBatchFunc    func(ctx context.Context, status *int8, batchSize int, fn func([]models.Coach) error) error
+}
+
+func (f *CoachRepository) Create(ctx context.Context, coach *models.Coach) error {
+	if f.CreateFunc == nil {
+		panic("fake: CoachRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, coach)
+}
+
+func (f *CoachRepository) GetByID(ctx context.Context, id int64) (*models.Coach, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: CoachRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *CoachRepository) List(ctx context.Context, q listquery.Query) ([]models.Coach, listquery.Page, error) {
+	if f.ListFunc == nil {
+		panic("fake: CoachRepository.List not stubbed")
+	}
+	return f.ListFunc(ctx, q)
+}
+
+func (f *CoachRepository) Update(ctx context.Context, coach *models.Coach) error {
+	if f.UpdateFunc == nil {
+		panic("fake: CoachRepository.Update not stubbed")
+	}
+	return f.UpdateFunc(ctx, coach)
+}
+
+func (f *CoachRepository) Delete(ctx context.Context, id int64) error {
+	if f.DeleteFunc == nil {
+		panic("fake: CoachRepository.Delete not stubbed")
+	}
+	return f.DeleteFunc(ctx, id)
+}
+
+func (f *CoachRepository) UpdateRating(ctx context.Context, id int64, rating float64, totalRatings int) error {
+	if f.UpdateRatingFunc == nil {
+		panic("fake: CoachRepository.UpdateRating not stubbed")
+	}
+	return f.UpdateRatingFunc(ctx, id, rating, totalRatings)
+}
+
+func (f *CoachRepository) EachBatch(ctx context.Context, status *int8, batchSize int, fn func([]models.Coach) error) error {
+	if f.EachBatchFunc == nil {
+		panic("fake: CoachRepository.EachBatch not stubbed")
+	}
+	return f.EachBatchFunc(ctx, status, batchSize, fn)
+}
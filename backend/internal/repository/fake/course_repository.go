@@ -0,0 +1,28 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+)
+
+var _ repository.CourseRepository = (*CourseRepository)(nil)
+
+type CourseRepository struct {
+	GetByIDFunc        func(ctx context.Context, id int64) (*models.Course, error)
+	GetBookingByIDFunc func(ctx context.Context, id int64) (*models.Booking, error)
+}
+
+func (f *CourseRepository) GetByID(ctx context.Context, id int64) (*models.Course, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: CourseRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *CourseRepository) GetBookingByID(ctx context.Context, id int64) (*models.Booking, error) {
+	if f.GetBookingByIDFunc == nil {
+		panic("fake: CourseRepository.GetBookingByID not stubbed")
+	}
+	return f.GetBookingByIDFunc(ctx, id)
+}
@@ -0,0 +1,14 @@
+// Package fake provides stub implementations of the repository interfaces
+// for testing services without a database. Each method calls the matching
+// Func field and panics if it is not set, so a test stubs exactly the calls
+// the code under test should make:
+//
+//	cards := &fake.CardRepository{
+//		GetByIDFunc: func(ctx context.Context, id int64) (*models.MembershipCard, error) {
+//			return &models.MembershipCard{ID: id, Status: 1}, nil
+//		},
+//	}
+//	svc := service.NewCardService(cards, &fake.SequenceRepository{}, &fake.TxManager{
+//		Repos: &repository.Repositories{Cards: cards},
+//	})
+package fake
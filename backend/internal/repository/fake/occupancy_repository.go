@@ -0,0 +1,37 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"time"
+)
+
+var _ repository.OccupancyRepository = (*OccupancyRepository)(nil)
+
+type OccupancyRepository struct {
+	CheckInsBetweenFunc func(ctx context.Context, from, to time.Time) ([]models.CheckIn, error)
+	ReplaceDayFunc      func(ctx context.Context, day time.Time, rows []models.CheckInHourlyStat) error
+	HourlyStatsFunc     func(ctx context.Context, from, to time.Time, deviceID string) ([]models.CheckInHourlyStat, error)
+}
+
+func (f *OccupancyRepository) CheckInsBetween(ctx context.Context, from, to time.Time) ([]models.CheckIn, error) {
+	if f.CheckInsBetweenFunc == nil {
+		panic("fake: OccupancyRepository.CheckInsBetween not stubbed")
+	}
+	return f.CheckInsBetweenFunc(ctx, from, to)
+}
+
+func (f *OccupancyRepository) ReplaceDay(ctx context.Context, day time.Time, rows []models.CheckInHourlyStat) error {
+	if f.ReplaceDayFunc == nil {
+		panic("fake: OccupancyRepository.ReplaceDay not stubbed")
+	}
+	return f.ReplaceDayFunc(ctx, day, rows)
+}
+
+func (f *OccupancyRepository) HourlyStats(ctx context.Context, from, to time.Time, deviceID string) ([]models.CheckInHourlyStat, error) {
+	if f.HourlyStatsFunc == nil {
+		panic("fake: OccupancyRepository.HourlyStats not stubbed")
+	}
+	return f.HourlyStatsFunc(ctx, from, to, deviceID)
+}
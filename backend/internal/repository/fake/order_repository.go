@@ -0,0 +1,150 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"time"
+)
+
+var _ repository.OrderRepository = (*OrderRepository)(nil)
+
+type OrderRepository struct {
+	CreateFunc              func(ctx context.Context, order *models.Order, usages []models.PromotionUsage) error
+	GetByIDFunc             func(ctx context.Context, id int64) (*models.Order, error)
+	ListFunc                func(ctx context.Context, q listquery.Query) ([]models.Order, listquery.Page, error)
+	CloseUnpaidFunc         func(ctx context.Context, id int64, status int8) (bool, error)
+	CloseExpiredFunc        func(ctx context.Context, now time.Time) (int64, error)
+	CreatePaymentFunc       func(ctx context.Context, payment *models.Payment) error
+	GetPaymentByNoFunc      func(ctx context.Context, paymentNo string) (*models.Payment, error)
+	ListPaymentsFunc        func(ctx context.Context, orderID int64) ([]models.Payment, error)
+	UpdatePaymentStatusFunc func(ctx context.Context, paymentNo string, from, status int8) error
+	ClaimPendingFunc        func(ctx context.Context, id int64) (bool, error)
+	MarkPaidFunc            func(ctx context.Context, paymentNo, tradeNo string, paidAt time.Time, raw string) (*repository.MarkPaidResult, error)
+	MarkFulfilledFunc       func(ctx context.Context, orderID int64) (bool, error)
+	SetItemFulfilmentFunc   func(ctx context.Context, itemID, refID int64) error
+	CreateLessonPackageFunc func(ctx context.Context, pkg *models.LessonPackage) error
+	ReserveRefundFunc       func(ctx context.Context, orderID int64, amount float64) error
+	ReleaseRefundFunc       func(ctx context.Context, orderID int64, amount float64) error
+	SettleRefundFunc        func(ctx context.Context, orderID int64, amount float64) error
+}
+
+func (f *OrderRepository) Create(ctx context.Context, order *models.Order, usages []models.PromotionUsage) error {
+	if f.CreateFunc == nil {
+		panic("fake: OrderRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, order, usages)
+}
+
+func (f *OrderRepository) GetByID(ctx context.Context, id int64) (*models.Order, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: OrderRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *OrderRepository) List(ctx context.Context, q listquery.Query) ([]models.Order, listquery.Page, error) {
+	if f.ListFunc == nil {
+		panic("fake: OrderRepository.List not stubbed")
+	}
+	return f.ListFunc(ctx, q)
+}
+
+func (f *OrderRepository) CloseUnpaid(ctx context.Context, id int64, status int8) (bool, error) {
+	if f.CloseUnpaidFunc == nil {
+		panic("fake: OrderRepository.CloseUnpaid not stubbed")
+	}
+	return f.CloseUnpaidFunc(ctx, id, status)
+}
+
+func (f *OrderRepository) CloseExpired(ctx context.Context, now time.Time) (int64, error) {
+	if f.CloseExpiredFunc == nil {
+		panic("fake: OrderRepository.CloseExpired not stubbed")
+	}
+	return f.CloseExpiredFunc(ctx, now)
+}
+
+func (f *OrderRepository) CreatePayment(ctx context.Context, payment *models.Payment) error {
+	if f.CreatePaymentFunc == nil {
+		panic("fake: OrderRepository.CreatePayment not stubbed")
+	}
+	return f.CreatePaymentFunc(ctx, payment)
+}
+
+func (f *OrderRepository) GetPaymentByNo(ctx context.Context, paymentNo string) (*models.Payment, error) {
+	if f.GetPaymentByNoFunc == nil {
+		panic("fake: OrderRepository.GetPaymentByNo not stubbed")
+	}
+	return f.GetPaymentByNoFunc(ctx, paymentNo)
+}
+
+func (f *OrderRepository) ListPayments(ctx context.Context, orderID int64) ([]models.Payment, error) {
+	if f.ListPaymentsFunc == nil {
+		panic("fake: OrderRepository.ListPayments not stubbed")
+	}
+	return f.ListPaymentsFunc(ctx, orderID)
+}
+
+func (f *OrderRepository) UpdatePaymentStatus(ctx context.Context, paymentNo string, from, status int8) error {
+	if f.UpdatePaymentStatusFunc == nil {
+		panic("fake: OrderRepository.UpdatePaymentStatus not stubbed")
+	}
+	return f.UpdatePaymentStatusFunc(ctx, paymentNo, from, status)
+}
+
+func (f *OrderRepository) ClaimPending(ctx context.Context, id int64) (bool, error) {
+	if f.ClaimPendingFunc == nil {
+		panic("fake: OrderRepository.ClaimPending not stubbed")
+	}
+	return f.ClaimPendingFunc(ctx, id)
+}
+
+func (f *OrderRepository) MarkPaid(ctx context.Context, paymentNo, tradeNo string, paidAt time.Time, raw string) (*repository.MarkPaidResult, error) {
+	if f.MarkPaidFunc == nil {
+		panic("fake: OrderRepository.MarkPaid not stubbed")
+	}
+	return f.MarkPaidFunc(ctx, paymentNo, tradeNo, paidAt, raw)
+}
+
+func (f *OrderRepository) MarkFulfilled(ctx context.Context, orderID int64) (bool, error) {
+	if f.MarkFulfilledFunc == nil {
+		panic("fake: OrderRepository.MarkFulfilled not stubbed")
+	}
+	return f.MarkFulfilledFunc(ctx, orderID)
+}
+
+func (f *OrderRepository) SetItemFulfilment(ctx context.Context, itemID, refID int64) error {
+	if f.SetItemFulfilmentFunc == nil {
+		panic("fake: OrderRepository.SetItemFulfilment not stubbed")
+	}
+	return f.SetItemFulfilmentFunc(ctx, itemID, refID)
+}
+
+func (f *OrderRepository) CreateLessonPackage(ctx context.Context, pkg *models.LessonPackage) error {
+	if f.CreateLessonPackageFunc == nil {
+		panic("fake: OrderRepository.CreateLessonPackage not stubbed")
+	}
+	return f.CreateLessonPackageFunc(ctx, pkg)
+}
+
+func (f *OrderRepository) ReserveRefund(ctx context.Context, orderID int64, amount float64) error {
+	if f.ReserveRefundFunc == nil {
+		panic("fake: OrderRepository.ReserveRefund not stubbed")
+	}
+	return f.ReserveRefundFunc(ctx, orderID, amount)
+}
+
+func (f *OrderRepository) ReleaseRefund(ctx context.Context, orderID int64, amount float64) error {
+	if f.ReleaseRefundFunc == nil {
+		panic("fake: OrderRepository.ReleaseRefund not stubbed")
+	}
+	return f.ReleaseRefundFunc(ctx, orderID, amount)
+}
+
+func (f *OrderRepository) SettleRefund(ctx context.Context, orderID int64, amount float64) error {
+	if f.SettleRefundFunc == nil {
+		panic("fake: OrderRepository.SettleRefund not stubbed")
+	}
+	return f.SettleRefundFunc(ctx, orderID, amount)
+}
@@ -0,0 +1,206 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"time"
+)
+
+var _ repository.PointsRepository = (*PointsRepository)(nil)
+
+type PointsRepository struct {
+	GetAccountFunc         func(ctx context.Context, userID int64) (*models.PointAccount, error)
+	ListTransactionsFunc   func(ctx context.Context, userID int64, q listquery.Query) ([]models.PointTransaction, listquery.Page, error)
+	EarnFunc               func(ctx context.Context, userID int64, entries []models.PointTransaction) (int64, error)
+	SpendFunc              func(ctx context.Context, userID int64, entry models.PointTransaction) error
+	GetEntryFunc           func(ctx context.Context, txType int8, sourceID int64) (*models.PointTransaction, error)
+	TakeBackFunc           func(ctx context.Context, earned *models.PointTransaction, entry models.PointTransaction) (int64, error)
+	TakeRewardFunc         func(ctx context.Context, rewardID int64) error
+	CreateRedemptionFunc   func(ctx context.Context, redemption *models.PointRedemption) error
+	ListExpiredFunc        func(ctx context.Context, now time.Time, limit int) ([]models.PointTransaction, error)
+	ExpireFunc             func(ctx context.Context, entryID, userID int64) (int64, error)
+	CheckInsSinceFunc      func(ctx context.Context, since time.Time) ([]models.CheckIn, error)
+	CheckInPointsSinceFunc func(ctx context.Context, since time.Time) ([]models.PointTransaction, error)
+	PaidOrdersSinceFunc    func(ctx context.Context, since time.Time) ([]models.Order, error)
+	LeaderboardFunc        func(ctx context.Context, from, to time.Time, limit int) ([]repository.PointsRank, error)
+	EarnedBetweenFunc      func(ctx context.Context, userID int64, from, to time.Time) (int64, error)
+	CountEarnedMoreFunc    func(ctx context.Context, points int64, from, to time.Time) (int64, error)
+	CreateRuleFunc         func(ctx context.Context, rule *models.PointRule) error
+	GetRuleFunc            func(ctx context.Context, id int64) (*models.PointRule, error)
+	UpdateRuleFunc         func(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListRulesFunc          func(ctx context.Context, status *int8) ([]models.PointRule, error)
+	CreateRewardFunc       func(ctx context.Context, reward *models.PointReward) error
+	GetRewardFunc          func(ctx context.Context, id int64) (*models.PointReward, error)
+	UpdateRewardFunc       func(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListRewardsFunc        func(ctx context.Context, status *int8) ([]models.PointReward, error)
+}
+
+func (f *PointsRepository) GetAccount(ctx context.Context, userID int64) (*models.PointAccount, error) {
+	if f.GetAccountFunc == nil {
+		panic("fake: PointsRepository.GetAccount not stubbed")
+	}
+	return f.GetAccountFunc(ctx, userID)
+}
+
+func (f *PointsRepository) ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.PointTransaction, listquery.Page, error) {
+	if f.ListTransactionsFunc == nil {
+		panic("fake: PointsRepository.ListTransactions not stubbed")
+	}
+	return f.ListTransactionsFunc(ctx, userID, q)
+}
+
+func (f *PointsRepository) Earn(ctx context.Context, userID int64, entries []models.PointTransaction) (int64, error) {
+	if f.EarnFunc == nil {
+		panic("fake: PointsRepository.Earn not stubbed")
+	}
+	return f.EarnFunc(ctx, userID, entries)
+}
+
+func (f *PointsRepository) Spend(ctx context.Context, userID int64, entry models.PointTransaction) error {
+	if f.SpendFunc == nil {
+		panic("fake: PointsRepository.Spend not stubbed")
+	}
+	return f.SpendFunc(ctx, userID, entry)
+}
+
+func (f *PointsRepository) GetEntry(ctx context.Context, txType int8, sourceID int64) (*models.PointTransaction, error) {
+	if f.GetEntryFunc == nil {
+		panic("fake: PointsRepository.GetEntry not stubbed")
+	}
+	return f.GetEntryFunc(ctx, txType, sourceID)
+}
+
+func (f *PointsRepository) TakeBack(ctx context.Context, earned *models.PointTransaction, entry models.PointTransaction) (int64, error) {
+	if f.TakeBackFunc == nil {
+		panic("fake: PointsRepository.TakeBack not stubbed")
+	}
+	return f.TakeBackFunc(ctx, earned, entry)
+}
+
+func (f *PointsRepository) TakeReward(ctx context.Context, rewardID int64) error {
+	if f.TakeRewardFunc == nil {
+		panic("fake: PointsRepository.TakeReward not stubbed")
+	}
+	return f.TakeRewardFunc(ctx, rewardID)
+}
+
+func (f *PointsRepository) CreateRedemption(ctx context.Context, redemption *models.PointRedemption) error {
+	if f.CreateRedemptionFunc == nil {
+		panic("fake: PointsRepository.CreateRedemption not stubbed")
+	}
+	return f.CreateRedemptionFunc(ctx, redemption)
+}
+
+func (f *PointsRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]models.PointTransaction, error) {
+	if f.ListExpiredFunc == nil {
+		panic("fake: PointsRepository.ListExpired not stubbed")
+	}
+	return f.ListExpiredFunc(ctx, now, limit)
+}
+
+func (f *PointsRepository) Expire(ctx context.Context, entryID, userID int64) (int64, error) {
+	if f.ExpireFunc == nil {
+		panic("fake: PointsRepository.Expire not stubbed")
+	}
+	return f.ExpireFunc(ctx, entryID, userID)
+}
+
+func (f *PointsRepository) CheckInsSince(ctx context.Context, since time.Time) ([]models.CheckIn, error) {
+	if f.CheckInsSinceFunc == nil {
+		panic("fake: PointsRepository.CheckInsSince not stubbed")
+	}
+	return f.CheckInsSinceFunc(ctx, since)
+}
+
+func (f *PointsRepository) CheckInPointsSince(ctx context.Context, since time.Time) ([]models.PointTransaction, error) {
+	if f.CheckInPointsSinceFunc == nil {
+		panic("fake: PointsRepository.CheckInPointsSince not stubbed")
+	}
+	return f.CheckInPointsSinceFunc(ctx, since)
+}
+
+func (f *PointsRepository) PaidOrdersSince(ctx context.Context, since time.Time) ([]models.Order, error) {
+	if f.PaidOrdersSinceFunc == nil {
+		panic("fake: PointsRepository.PaidOrdersSince not stubbed")
+	}
+	return f.PaidOrdersSinceFunc(ctx, since)
+}
+
+func (f *PointsRepository) Leaderboard(ctx context.Context, from, to time.Time, limit int) ([]repository.PointsRank, error) {
+	if f.LeaderboardFunc == nil {
+		panic("fake: PointsRepository.Leaderboard not stubbed")
+	}
+	return f.LeaderboardFunc(ctx, from, to, limit)
+}
+
+func (f *PointsRepository) EarnedBetween(ctx context.Context, userID int64, from, to time.Time) (int64, error) {
+	if f.EarnedBetweenFunc == nil {
+		panic("fake: PointsRepository.EarnedBetween not stubbed")
+	}
+	return f.EarnedBetweenFunc(ctx, userID, from, to)
+}
+
+func (f *PointsRepository) CountEarnedMore(ctx context.Context, points int64, from, to time.Time) (int64, error) {
+	if f.CountEarnedMoreFunc == nil {
+		panic("fake: PointsRepository.CountEarnedMore not stubbed")
+	}
+	return f.CountEarnedMoreFunc(ctx, points, from, to)
+}
+
+func (f *PointsRepository) CreateRule(ctx context.Context, rule *models.PointRule) error {
+	if f.CreateRuleFunc == nil {
+		panic("fake: PointsRepository.CreateRule not stubbed")
+	}
+	return f.CreateRuleFunc(ctx, rule)
+}
+
+func (f *PointsRepository) GetRule(ctx context.Context, id int64) (*models.PointRule, error) {
+	if f.GetRuleFunc == nil {
+		panic("fake: PointsRepository.GetRule not stubbed")
+	}
+	return f.GetRuleFunc(ctx, id)
+}
+
+func (f *PointsRepository) UpdateRule(ctx context.Context, id int64, updates map[string]interface{}) error {
+	if f.UpdateRuleFunc == nil {
+		panic("fake: PointsRepository.UpdateRule not stubbed")
+	}
+	return f.UpdateRuleFunc(ctx, id, updates)
+}
+
+func (f *PointsRepository) ListRules(ctx context.Context, status *int8) ([]models.PointRule, error) {
+	if f.ListRulesFunc == nil {
+		panic("fake: PointsRepository.ListRules not stubbed")
+	}
+	return f.ListRulesFunc(ctx, status)
+}
+
+func (f *PointsRepository) CreateReward(ctx context.Context, reward *models.PointReward) error {
+	if f.CreateRewardFunc == nil {
+		panic("fake: PointsRepository.CreateReward not stubbed")
+	}
+	return f.CreateRewardFunc(ctx, reward)
+}
+
+func (f *PointsRepository) GetReward(ctx context.Context, id int64) (*models.PointReward, error) {
+	if f.GetRewardFunc == nil {
+		panic("fake: PointsRepository.GetReward not stubbed")
+	}
+	return f.GetRewardFunc(ctx, id)
+}
+
+func (f *PointsRepository) UpdateReward(ctx context.Context, id int64, updates map[string]interface{}) error {
+	if f.UpdateRewardFunc == nil {
+		panic("fake: PointsRepository.UpdateReward not stubbed")
+	}
+	return f.UpdateRewardFunc(ctx, id, updates)
+}
+
+func (f *PointsRepository) ListRewards(ctx context.Context, status *int8) ([]models.PointReward, error) {
+	if f.ListRewardsFunc == nil {
+		panic("fake: PointsRepository.ListRewards not stubbed")
+	}
+	return f.ListRewardsFunc(ctx, status)
+}
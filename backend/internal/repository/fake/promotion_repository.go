@@ -0,0 +1,110 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"time"
+)
+
+var _ repository.PromotionRepository = (*PromotionRepository)(nil)
+
+type PromotionRepository struct {
+	CreateFunc           func(ctx context.Context, promotion *models.Promotion) error
+	GetByIDFunc          func(ctx context.Context, id int64) (*models.Promotion, error)
+	UpdateFunc           func(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListFunc             func(ctx context.Context, q listquery.Query) ([]models.Promotion, listquery.Page, error)
+	ListAutomaticFunc    func(ctx context.Context, now time.Time) ([]models.Promotion, error)
+	CreateCouponsFunc    func(ctx context.Context, coupons []models.Coupon) error
+	ListCouponsFunc      func(ctx context.Context, promotionID int64, q listquery.Query) ([]models.Coupon, listquery.Page, error)
+	GetCouponByCodeFunc  func(ctx context.Context, code string) (*models.Coupon, error)
+	CouponCodeExistsFunc func(ctx context.Context, code string) (bool, error)
+	VoidCouponFunc       func(ctx context.Context, id int64) (bool, error)
+	CountUserUsagesFunc  func(ctx context.Context, promotionID, userID int64) (int64, error)
+	IsNewMemberFunc      func(ctx context.Context, userID int64) (bool, error)
+}
+
+func (f *PromotionRepository) Create(ctx context.Context, promotion *models.Promotion) error {
+	if f.CreateFunc == nil {
+		panic("fake: PromotionRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, promotion)
+}
+
+func (f *PromotionRepository) GetByID(ctx context.Context, id int64) (*models.Promotion, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: PromotionRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *PromotionRepository) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	if f.UpdateFunc == nil {
+		panic("fake: PromotionRepository.Update not stubbed")
+	}
+	return f.UpdateFunc(ctx, id, updates)
+}
+
+func (f *PromotionRepository) List(ctx context.Context, q listquery.Query) ([]models.Promotion, listquery.Page, error) {
+	if f.ListFunc == nil {
+		panic("fake: PromotionRepository.List not stubbed")
+	}
+	return f.ListFunc(ctx, q)
+}
+
+func (f *PromotionRepository) ListAutomatic(ctx context.Context, now time.Time) ([]models.Promotion, error) {
+	if f.ListAutomaticFunc == nil {
+		panic("fake: PromotionRepository.ListAutomatic not stubbed")
+	}
+	return f.ListAutomaticFunc(ctx, now)
+}
+
+func (f *PromotionRepository) CreateCoupons(ctx context.Context, coupons []models.Coupon) error {
+	if f.CreateCouponsFunc == nil {
+		panic("fake: PromotionRepository.CreateCoupons not stubbed")
+	}
+	return f.CreateCouponsFunc(ctx, coupons)
+}
+
+func (f *PromotionRepository) ListCoupons(ctx context.Context, promotionID int64, q listquery.Query) ([]models.Coupon, listquery.Page, error) {
+	if f.ListCouponsFunc == nil {
+		panic("fake: PromotionRepository.ListCoupons not stubbed")
+	}
+	return f.ListCouponsFunc(ctx, promotionID, q)
+}
+
+func (f *PromotionRepository) GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	if f.GetCouponByCodeFunc == nil {
+		panic("fake: PromotionRepository.GetCouponByCode not stubbed")
+	}
+	return f.GetCouponByCodeFunc(ctx, code)
+}
+
+func (f *PromotionRepository) CouponCodeExists(ctx context.Context, code string) (bool, error) {
+	if f.CouponCodeExistsFunc == nil {
+		panic("fake: PromotionRepository.CouponCodeExists not stubbed")
+	}
+	return f.CouponCodeExistsFunc(ctx, code)
+}
+
+func (f *PromotionRepository) VoidCoupon(ctx context.Context, id int64) (bool, error) {
+	if f.VoidCouponFunc == nil {
+		panic("fake: PromotionRepository.VoidCoupon not stubbed")
+	}
+	return f.VoidCouponFunc(ctx, id)
+}
+
+func (f *PromotionRepository) CountUserUsages(ctx context.Context, promotionID, userID int64) (int64, error) {
+	if f.CountUserUsagesFunc == nil {
+		panic("fake: PromotionRepository.CountUserUsages not stubbed")
+	}
+	return f.CountUserUsagesFunc(ctx, promotionID, userID)
+}
+
+func (f *PromotionRepository) IsNewMember(ctx context.Context, userID int64) (bool, error) {
+	if f.IsNewMemberFunc == nil {
+		panic("fake: PromotionRepository.IsNewMember not stubbed")
+	}
+	return f.IsNewMemberFunc(ctx, userID)
+}
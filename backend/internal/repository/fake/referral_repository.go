@@ -0,0 +1,158 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"time"
+)
+
+var _ repository.ReferralRepository = (*ReferralRepository)(nil)
+
+type ReferralRepository struct {
+	CreateFunc            func(ctx context.Context, referral *models.Referral) error
+	GetByRefereeFunc      func(ctx context.Context, refereeID int64) (*models.Referral, error)
+	ListByReferrerFunc    func(ctx context.Context, referrerID int64, q listquery.Query) ([]models.Referral, listquery.Page, error)
+	ListPendingFunc       func(ctx context.Context, afterID int64, limit int) ([]models.Referral, error)
+	FirstPaidCardFunc     func(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error)
+	ActiveTimeCardFunc    func(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error)
+	MarkRewardedFunc      func(ctx context.Context, referral *models.Referral) error
+	GetRewardedByCardFunc func(ctx context.Context, cardID int64) (*models.Referral, error)
+	MarkRevokedFunc       func(ctx context.Context, id int64) (bool, error)
+	CountBetweenFunc      func(ctx context.Context, from, to time.Time) (int64, int64, error)
+	RewardsBetweenFunc    func(ctx context.Context, from, to time.Time) (int64, int64, error)
+	TopReferrersFunc      func(ctx context.Context, from, to time.Time, limit int) ([]repository.ReferrerStats, error)
+	ReferrerSummaryFunc   func(ctx context.Context, referrerID int64) (*repository.ReferrerStats, error)
+	CreateRuleFunc        func(ctx context.Context, rule *models.ReferralRule) error
+	GetRuleFunc           func(ctx context.Context, id int64) (*models.ReferralRule, error)
+	UpdateRuleFunc        func(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListRulesFunc         func(ctx context.Context, status *int8) ([]models.ReferralRule, error)
+	CurrentRuleFunc       func(ctx context.Context) (*models.ReferralRule, error)
+}
+
+func (f *ReferralRepository) Create(ctx context.Context, referral *models.Referral) error {
+	if f.CreateFunc == nil {
+		panic("fake: ReferralRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, referral)
+}
+
+func (f *ReferralRepository) GetByReferee(ctx context.Context, refereeID int64) (*models.Referral, error) {
+	if f.GetByRefereeFunc == nil {
+		panic("fake: ReferralRepository.GetByReferee not stubbed")
+	}
+	return f.GetByRefereeFunc(ctx, refereeID)
+}
+
+func (f *ReferralRepository) ListByReferrer(ctx context.Context, referrerID int64, q listquery.Query) ([]models.Referral, listquery.Page, error) {
+	if f.ListByReferrerFunc == nil {
+		panic("fake: ReferralRepository.ListByReferrer not stubbed")
+	}
+	return f.ListByReferrerFunc(ctx, referrerID, q)
+}
+
+func (f *ReferralRepository) ListPending(ctx context.Context, afterID int64, limit int) ([]models.Referral, error) {
+	if f.ListPendingFunc == nil {
+		panic("fake: ReferralRepository.ListPending not stubbed")
+	}
+	return f.ListPendingFunc(ctx, afterID, limit)
+}
+
+func (f *ReferralRepository) FirstPaidCard(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error) {
+	if f.FirstPaidCardFunc == nil {
+		panic("fake: ReferralRepository.FirstPaidCard not stubbed")
+	}
+	return f.FirstPaidCardFunc(ctx, userID, day)
+}
+
+func (f *ReferralRepository) ActiveTimeCard(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error) {
+	if f.ActiveTimeCardFunc == nil {
+		panic("fake: ReferralRepository.ActiveTimeCard not stubbed")
+	}
+	return f.ActiveTimeCardFunc(ctx, userID, day)
+}
+
+func (f *ReferralRepository) MarkRewarded(ctx context.Context, referral *models.Referral) error {
+	if f.MarkRewardedFunc == nil {
+		panic("fake: ReferralRepository.MarkRewarded not stubbed")
+	}
+	return f.MarkRewardedFunc(ctx, referral)
+}
+
+func (f *ReferralRepository) GetRewardedByCard(ctx context.Context, cardID int64) (*models.Referral, error) {
+	if f.GetRewardedByCardFunc == nil {
+		panic("fake: ReferralRepository.GetRewardedByCard not stubbed")
+	}
+	return f.GetRewardedByCardFunc(ctx, cardID)
+}
+
+func (f *ReferralRepository) MarkRevoked(ctx context.Context, id int64) (bool, error) {
+	if f.MarkRevokedFunc == nil {
+		panic("fake: ReferralRepository.MarkRevoked not stubbed")
+	}
+	return f.MarkRevokedFunc(ctx, id)
+}
+
+func (f *ReferralRepository) CountBetween(ctx context.Context, from, to time.Time) (int64, int64, error) {
+	if f.CountBetweenFunc == nil {
+		panic("fake: ReferralRepository.CountBetween not stubbed")
+	}
+	return f.CountBetweenFunc(ctx, from, to)
+}
+
+func (f *ReferralRepository) RewardsBetween(ctx context.Context, from, to time.Time) (int64, int64, error) {
+	if f.RewardsBetweenFunc == nil {
+		panic("fake: ReferralRepository.RewardsBetween not stubbed")
+	}
+	return f.RewardsBetweenFunc(ctx, from, to)
+}
+
+func (f *ReferralRepository) TopReferrers(ctx context.Context, from, to time.Time, limit int) ([]repository.ReferrerStats, error) {
+	if f.TopReferrersFunc == nil {
+		panic("fake: ReferralRepository.TopReferrers not stubbed")
+	}
+	return f.TopReferrersFunc(ctx, from, to, limit)
+}
+
+func (f *ReferralRepository) ReferrerSummary(ctx context.Context, referrerID int64) (*repository.ReferrerStats, error) {
+	if f.ReferrerSummaryFunc == nil {
+		panic("fake: ReferralRepository.ReferrerSummary not stubbed")
+	}
+	return f.ReferrerSummaryFunc(ctx, referrerID)
+}
+
+func (f *ReferralRepository) CreateRule(ctx context.Context, rule *models.ReferralRule) error {
+	if f.CreateRuleFunc == nil {
+		panic("fake: ReferralRepository.CreateRule not stubbed")
+	}
+	return f.CreateRuleFunc(ctx, rule)
+}
+
+func (f *ReferralRepository) GetRule(ctx context.Context, id int64) (*models.ReferralRule, error) {
+	if f.GetRuleFunc == nil {
+		panic("fake: ReferralRepository.GetRule not stubbed")
+	}
+	return f.GetRuleFunc(ctx, id)
+}
+
+func (f *ReferralRepository) UpdateRule(ctx context.Context, id int64, updates map[string]interface{}) error {
+	if f.UpdateRuleFunc == nil {
+		panic("fake: ReferralRepository.UpdateRule not stubbed")
+	}
+	return f.UpdateRuleFunc(ctx, id, updates)
+}
+
+func (f *ReferralRepository) ListRules(ctx context.Context, status *int8) ([]models.ReferralRule, error) {
+	if f.ListRulesFunc == nil {
+		panic("fake: ReferralRepository.ListRules not stubbed")
+	}
+	return f.ListRulesFunc(ctx, status)
+}
+
+func (f *ReferralRepository) CurrentRule(ctx context.Context) (*models.ReferralRule, error) {
+	if f.CurrentRuleFunc == nil {
+		panic("fake: ReferralRepository.CurrentRule not stubbed")
+	}
+	return f.CurrentRuleFunc(ctx)
+}
@@ -0,0 +1,134 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"time"
+)
+
+var _ repository.RefundRepository = (*RefundRepository)(nil)
+
+type RefundRepository struct {
+	CreateFunc               func(ctx context.Context, refund *models.Refund) error
+	GetByIDFunc              func(ctx context.Context, id int64) (*models.Refund, error)
+	ListByOrderFunc          func(ctx context.Context, orderID int64) ([]models.Refund, error)
+	ListFunc                 func(ctx context.Context, q listquery.Query) ([]models.Refund, listquery.Page, error)
+	ListProcessingFunc       func(ctx context.Context, limit int) ([]models.Refund, error)
+	GetPaymentByIDFunc       func(ctx context.Context, id int64) (*models.Payment, error)
+	GetSuccessfulPaymentFunc func(ctx context.Context, orderID int64) (*models.Payment, error)
+	GetLessonPackageFunc     func(ctx context.Context, id int64) (*models.LessonPackage, error)
+	ApproveFunc              func(ctx context.Context, id, approverID int64) (bool, error)
+	RejectFunc               func(ctx context.Context, id, approverID int64, reason string) (bool, error)
+	MarkFailedFunc           func(ctx context.Context, id int64, reason string) (bool, error)
+	RetryFunc                func(ctx context.Context, id int64) (bool, error)
+	SetGatewayRefundNoFunc   func(ctx context.Context, id int64, gatewayRefundNo string) error
+	CompleteFunc             func(ctx context.Context, id int64, gatewayRefundNo string, refundedAt time.Time) (bool, error)
+	RemoveSessionsFunc       func(ctx context.Context, packageID int64, sessions int) error
+}
+
+func (f *RefundRepository) Create(ctx context.Context, refund *models.Refund) error {
+	if f.CreateFunc == nil {
+		panic("fake: RefundRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, refund)
+}
+
+func (f *RefundRepository) GetByID(ctx context.Context, id int64) (*models.Refund, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: RefundRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *RefundRepository) ListByOrder(ctx context.Context, orderID int64) ([]models.Refund, error) {
+	if f.ListByOrderFunc == nil {
+		panic("fake: RefundRepository.ListByOrder not stubbed")
+	}
+	return f.ListByOrderFunc(ctx, orderID)
+}
+
+func (f *RefundRepository) List(ctx context.Context, q listquery.Query) ([]models.Refund, listquery.Page, error) {
+	if f.ListFunc == nil {
+		panic("fake: RefundRepository.List not stubbed")
+	}
+	return f.ListFunc(ctx, q)
+}
+
+func (f *RefundRepository) ListProcessing(ctx context.Context, limit int) ([]models.Refund, error) {
+	if f.ListProcessingFunc == nil {
+		panic("fake: RefundRepository.ListProcessing not stubbed")
+	}
+	return f.ListProcessingFunc(ctx, limit)
+}
+
+func (f *RefundRepository) GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error) {
+	if f.GetPaymentByIDFunc == nil {
+		panic("fake: RefundRepository.GetPaymentByID not stubbed")
+	}
+	return f.GetPaymentByIDFunc(ctx, id)
+}
+
+func (f *RefundRepository) GetSuccessfulPayment(ctx context.Context, orderID int64) (*models.Payment, error) {
+	if f.GetSuccessfulPaymentFunc == nil {
+		panic("fake: RefundRepository.GetSuccessfulPayment not stubbed")
+	}
+	return f.GetSuccessfulPaymentFunc(ctx, orderID)
+}
+
+func (f *RefundRepository) GetLessonPackage(ctx context.Context, id int64) (*models.LessonPackage, error) {
+	if f.GetLessonPackageFunc == nil {
+		panic("fake: RefundRepository.GetLessonPackage not stubbed")
+	}
+	return f.GetLessonPackageFunc(ctx, id)
+}
+
+func (f *RefundRepository) Approve(ctx context.Context, id, approverID int64) (bool, error) {
+	if f.ApproveFunc == nil {
+		panic("fake: RefundRepository.Approve not stubbed")
+	}
+	return f.ApproveFunc(ctx, id, approverID)
+}
+
+func (f *RefundRepository) Reject(ctx context.Context, id, approverID int64, reason string) (bool, error) {
+	if f.RejectFunc == nil {
+		panic("fake: RefundRepository.Reject not stubbed")
+	}
+	return f.RejectFunc(ctx, id, approverID, reason)
+}
+
+func (f *RefundRepository) MarkFailed(ctx context.Context, id int64, reason string) (bool, error) {
+	if f.MarkFailedFunc == nil {
+		panic("fake: RefundRepository.MarkFailed not stubbed")
+	}
+	return f.MarkFailedFunc(ctx, id, reason)
+}
+
+func (f *RefundRepository) Retry(ctx context.Context, id int64) (bool, error) {
+	if f.RetryFunc == nil {
+		panic("fake: RefundRepository.Retry not stubbed")
+	}
+	return f.RetryFunc(ctx, id)
+}
+
+func (f *RefundRepository) SetGatewayRefundNo(ctx context.Context, id int64, gatewayRefundNo string) error {
+	if f.SetGatewayRefundNoFunc == nil {
+		panic("fake: RefundRepository.SetGatewayRefundNo not stubbed")
+	}
+	return f.SetGatewayRefundNoFunc(ctx, id, gatewayRefundNo)
+}
+
+func (f *RefundRepository) Complete(ctx context.Context, id int64, gatewayRefundNo string, refundedAt time.Time) (bool, error) {
+	if f.CompleteFunc == nil {
+		panic("fake: RefundRepository.Complete not stubbed")
+	}
+	return f.CompleteFunc(ctx, id, gatewayRefundNo, refundedAt)
+}
+
+func (f *RefundRepository) RemoveSessions(ctx context.Context, packageID int64, sessions int) error {
+	if f.RemoveSessionsFunc == nil {
+		panic("fake: RefundRepository.RemoveSessions not stubbed")
+	}
+	return f.RemoveSessionsFunc(ctx, packageID, sessions)
+}
@@ -0,0 +1,53 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"time"
+)
+
+var _ repository.RevenueRepository = (*RevenueRepository)(nil)
+
+type RevenueRepository struct {
+	CardTypesFunc          func(ctx context.Context) ([]models.CardType, error)
+	EachCardSoldBeforeFunc func(ctx context.Context, before time.Time, batchSize int, fn func([]models.MembershipCard) error) error
+	FreezeRecordsFunc      func(ctx context.Context, cardIDs []int64) ([]models.CardFreezeRecord, error)
+	OperationLogsFunc      func(ctx context.Context, cardIDs []int64, operationTypes []int8) ([]models.CardOperationLog, error)
+	CountVisitsFunc        func(ctx context.Context, cardIDs []int64, from, to time.Time) ([]repository.CardVisitCount, error)
+}
+
+func (f *RevenueRepository) CardTypes(ctx context.Context) ([]models.CardType, error) {
+	if f.CardTypesFunc == nil {
+		panic("fake: RevenueRepository.CardTypes not stubbed")
+	}
+	return f.CardTypesFunc(ctx)
+}
+
+func (f *RevenueRepository) EachCardSoldBefore(ctx context.Context, before time.Time, batchSize int, fn func([]models.MembershipCard) error) error {
+	if f.EachCardSoldBeforeFunc == nil {
+		panic("fake: RevenueRepository.EachCardSoldBefore not stubbed")
+	}
+	return f.EachCardSoldBeforeFunc(ctx, before, batchSize, fn)
+}
+
+func (f *RevenueRepository) FreezeRecords(ctx context.Context, cardIDs []int64) ([]models.CardFreezeRecord, error) {
+	if f.FreezeRecordsFunc == nil {
+		panic("fake: RevenueRepository.FreezeRecords not stubbed")
+	}
+	return f.FreezeRecordsFunc(ctx, cardIDs)
+}
+
+func (f *RevenueRepository) OperationLogs(ctx context.Context, cardIDs []int64, operationTypes []int8) ([]models.CardOperationLog, error) {
+	if f.OperationLogsFunc == nil {
+		panic("fake: RevenueRepository.OperationLogs not stubbed")
+	}
+	return f.OperationLogsFunc(ctx, cardIDs, operationTypes)
+}
+
+func (f *RevenueRepository) CountVisits(ctx context.Context, cardIDs []int64, from, to time.Time) ([]repository.CardVisitCount, error) {
+	if f.CountVisitsFunc == nil {
+		panic("fake: RevenueRepository.CountVisits not stubbed")
+	}
+	return f.CountVisitsFunc(ctx, cardIDs, from, to)
+}
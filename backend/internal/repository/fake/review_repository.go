@@ -0,0 +1,69 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+)
+
+var _ repository.ReviewRepository = (*ReviewRepository)(nil)
+
+type ReviewRepository struct {
+	CreateFunc             func(ctx context.Context, review *models.CourseReview) error
+	GetByIDFunc            func(ctx context.Context, id int64) (*models.CourseReview, error)
+	ExistsByBookingIDFunc  func(ctx context.Context, bookingID int64) (bool, error)
+	ListFunc               func(ctx context.Context, q listquery.Query) ([]models.CourseReview, listquery.Page, error)
+	ListVisibleByCoachFunc func(ctx context.Context, coachID int64, q listquery.Query) ([]models.CourseReview, listquery.Page, error)
+	UpdateFunc             func(ctx context.Context, review *models.CourseReview) error
+	CoachRatingSummaryFunc func(ctx context.Context, coachID int64) (*repository.RatingSummary, error)
+}
+
+func (f *ReviewRepository) Create(ctx context.Context, review *models.CourseReview) error {
+	if f.CreateFunc == nil {
+		panic("fake: ReviewRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, review)
+}
+
+func (f *ReviewRepository) GetByID(ctx context.Context, id int64) (*models.CourseReview, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: ReviewRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *ReviewRepository) ExistsByBookingID(ctx context.Context, bookingID int64) (bool, error) {
+	if f.ExistsByBookingIDFunc == nil {
+		panic("fake: ReviewRepository.ExistsByBookingID not stubbed")
+	}
+	return f.ExistsByBookingIDFunc(ctx, bookingID)
+}
+
+func (f *ReviewRepository) List(ctx context.Context, q listquery.Query) ([]models.CourseReview, listquery.Page, error) {
+	if f.ListFunc == nil {
+		panic("fake: ReviewRepository.List not stubbed")
+	}
+	return f.ListFunc(ctx, q)
+}
+
+func (f *ReviewRepository) ListVisibleByCoach(ctx context.Context, coachID int64, q listquery.Query) ([]models.CourseReview, listquery.Page, error) {
+	if f.ListVisibleByCoachFunc == nil {
+		panic("fake: ReviewRepository.ListVisibleByCoach not stubbed")
+	}
+	return f.ListVisibleByCoachFunc(ctx, coachID, q)
+}
+
+func (f *ReviewRepository) Update(ctx context.Context, review *models.CourseReview) error {
+	if f.UpdateFunc == nil {
+		panic("fake: ReviewRepository.Update not stubbed")
+	}
+	return f.UpdateFunc(ctx, review)
+}
+
+func (f *ReviewRepository) CoachRatingSummary(ctx context.Context, coachID int64) (*repository.RatingSummary, error) {
+	if f.CoachRatingSummaryFunc == nil {
+		panic("fake: ReviewRepository.CoachRatingSummary not stubbed")
+	}
+	return f.CoachRatingSummaryFunc(ctx, coachID)
+}
@@ -0,0 +1,19 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/repository"
+)
+
+var _ repository.SequenceRepository = (*SequenceRepository)(nil)
+
+type SequenceRepository struct {
+	NextFunc func(ctx context.Context, name string) (int64, error)
+}
+
+func (f *SequenceRepository) Next(ctx context.Context, name string) (int64, error) {
+	if f.NextFunc == nil {
+		panic("fake: SequenceRepository.Next not stubbed")
+	}
+	return f.NextFunc(ctx, name)
+}
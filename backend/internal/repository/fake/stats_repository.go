@@ -0,0 +1,68 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/repository"
+	"time"
+)
+
+var _ repository.StatsRepository = (*StatsRepository)(nil)
+
+type StatsRepository struct {
+	CountCheckInsFunc             func(ctx context.Context, from, to time.Time) (int64, error)
+	CountDistinctCheckInUsersFunc func(ctx context.Context, from, to time.Time) (int64, error)
+	CountNewUsersFunc             func(ctx context.Context, from, to time.Time) (int64, error)
+	CardSalesFunc                 func(ctx context.Context, from, to time.Time) (*repository.CardSales, error)
+	CountBookingsFunc             func(ctx context.Context, from, to time.Time) (int64, error)
+	CountVoucherRedemptionsFunc   func(ctx context.Context, from, to time.Time) (int64, error)
+	CountCardsEndingFunc          func(ctx context.Context, from, to time.Time) (int64, error)
+}
+
+func (f *StatsRepository) CountCheckIns(ctx context.Context, from, to time.Time) (int64, error) {
+	if f.CountCheckInsFunc == nil {
+		panic("fake: StatsRepository.CountCheckIns not stubbed")
+	}
+	return f.CountCheckInsFunc(ctx, from, to)
+}
+
+func (f *StatsRepository) CountDistinctCheckInUsers(ctx context.Context, from, to time.Time) (int64, error) {
+	if f.CountDistinctCheckInUsersFunc == nil {
+		panic("fake: StatsRepository.CountDistinctCheckInUsers not stubbed")
+	}
+	return f.CountDistinctCheckInUsersFunc(ctx, from, to)
+}
+
+func (f *StatsRepository) CountNewUsers(ctx context.Context, from, to time.Time) (int64, error) {
+	if f.CountNewUsersFunc == nil {
+		panic("fake: StatsRepository.CountNewUsers not stubbed")
+	}
+	return f.CountNewUsersFunc(ctx, from, to)
+}
+
+func (f *StatsRepository) CardSales(ctx context.Context, from, to time.Time) (*repository.CardSales, error) {
+	if f.CardSalesFunc == nil {
+		panic("fake: StatsRepository.CardSales not stubbed")
+	}
+	return f.CardSalesFunc(ctx, from, to)
+}
+
+func (f *StatsRepository) CountBookings(ctx context.Context, from, to time.Time) (int64, error) {
+	if f.CountBookingsFunc == nil {
+		panic("fake: StatsRepository.CountBookings not stubbed")
+	}
+	return f.CountBookingsFunc(ctx, from, to)
+}
+
+func (f *StatsRepository) CountVoucherRedemptions(ctx context.Context, from, to time.Time) (int64, error) {
+	if f.CountVoucherRedemptionsFunc == nil {
+		panic("fake: StatsRepository.CountVoucherRedemptions not stubbed")
+	}
+	return f.CountVoucherRedemptionsFunc(ctx, from, to)
+}
+
+func (f *StatsRepository) CountCardsEnding(ctx context.Context, from, to time.Time) (int64, error) {
+	if f.CountCardsEndingFunc == nil {
+		panic("fake: StatsRepository.CountCardsEnding not stubbed")
+	}
+	return f.CountCardsEndingFunc(ctx, from, to)
+}
@@ -0,0 +1,26 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/repository"
+)
+
+var _ repository.TxManager = (*TxManager)(nil)
+
+// TxManager runs units of work directly against Repos, usually a set of
+// fakes. Calls counts the units of work run; like the real manager, a unit
+// of work started inside another joins it and is not counted.
+type TxManager struct {
+	Repos *repository.Repositories
+	Calls int
+}
+
+type txKey struct{}
+
+func (f *TxManager) Do(ctx context.Context, fn func(ctx context.Context, repos *repository.Repositories) error) error {
+	if ctx.Value(txKey{}) == f {
+		return fn(ctx, f.Repos)
+	}
+	f.Calls++
+	return fn(context.WithValue(ctx, txKey{}, f), f.Repos)
+}
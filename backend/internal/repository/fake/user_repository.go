@@ -0,0 +1,173 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+)
+
+var _ repository.UserRepository = (*UserRepository)(nil)
+
+type UserRepository struct {
+	CreateFunc             func(ctx context.Context, user *models.User) error
+	GetByIDFunc            func(ctx context.Context, id int64) (*models.User, error)
+	GetByIDsFunc           func(ctx context.Context, ids []int64) ([]models.User, error)
+	GetByPhoneFunc         func(ctx context.Context, phone string) (*models.User, error)
+	GetByReferralCodeFunc  func(ctx context.Context, code string) (*models.User, error)
+	ReferralCodeExistsFunc func(ctx context.Context, code string) (bool, error)
+	SetReferralCodeFunc    func(ctx context.Context, id int64, code string) (bool, error)
+	ListFunc               func(ctx context.Context, q listquery.Query) ([]models.User, listquery.Page, error)
+	UpdateFunc             func(ctx context.Context, user *models.User) error
+	DeleteFunc             func(ctx context.Context, id int64) error
+	GetStatsFunc           func(ctx context.Context, userID int64) (*models.UserTrainingStats, error)
+	UpdateStatusFunc       func(ctx context.Context, userID int64, change *models.UserStatusChange) error
+	UpdateRoleFunc         func(ctx context.Context, userID int64, role string) error
+	UpdatePasswordFunc     func(ctx context.Context, userID int64, passwordHash string) error
+	ListStatusChangesFunc  func(ctx context.Context, userID int64) ([]models.UserStatusChange, error)
+	SearchFunc             func(ctx context.Context, filter repository.UserSearchFilter, q listquery.Query) ([]models.User, listquery.Page, error)
+	UpdateNamePinyinFunc   func(ctx context.Context, id int64, namePinyin, nameInitials string) error
+	FindWithoutPinyinFunc  func(ctx context.Context, afterID int64, limit int) ([]models.User, error)
+	ExistingPhonesFunc     func(ctx context.Context, phones []string) (map[string]bool, error)
+	EachBatchFunc          func(ctx context.Context, filter repository.UserSearchFilter, batchSize int, fn func([]models.User) error) error
+}
+
+func (f *UserRepository) Create(ctx context.Context, user *models.User) error {
+	if f.CreateFunc == nil {
+		panic("fake: UserRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, user)
+}
+
+func (f *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	if f.GetByIDFunc == nil {
+		panic("fake: UserRepository.GetByID not stubbed")
+	}
+	return f.GetByIDFunc(ctx, id)
+}
+
+func (f *UserRepository) GetByIDs(ctx context.Context, ids []int64) ([]models.User, error) {
+	if f.GetByIDsFunc == nil {
+		panic("fake: UserRepository.GetByIDs not stubbed")
+	}
+	return f.GetByIDsFunc(ctx, ids)
+}
+
+func (f *UserRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
+	if f.GetByPhoneFunc == nil {
+		panic("fake: UserRepository.GetByPhone not stubbed")
+	}
+	return f.GetByPhoneFunc(ctx, phone)
+}
+
+func (f *UserRepository) GetByReferralCode(ctx context.Context, code string) (*models.User, error) {
+	if f.GetByReferralCodeFunc == nil {
+		panic("fake: UserRepository.GetByReferralCode not stubbed")
+	}
+	return f.GetByReferralCodeFunc(ctx, code)
+}
+
+func (f *UserRepository) ReferralCodeExists(ctx context.Context, code string) (bool, error) {
+	if f.ReferralCodeExistsFunc == nil {
+		panic("fake: UserRepository.ReferralCodeExists not stubbed")
+	}
+	return f.ReferralCodeExistsFunc(ctx, code)
+}
+
+func (f *UserRepository) SetReferralCode(ctx context.Context, id int64, code string) (bool, error) {
+	if f.SetReferralCodeFunc == nil {
+		panic("fake: UserRepository.SetReferralCode not stubbed")
+	}
+	return f.SetReferralCodeFunc(ctx, id, code)
+}
+
+func (f *UserRepository) List(ctx context.Context, q listquery.Query) ([]models.User, listquery.Page, error) {
+	if f.ListFunc == nil {
+		panic("fake: UserRepository.List not stubbed")
+	}
+	return f.ListFunc(ctx, q)
+}
+
+func (f *UserRepository) Update(ctx context.Context, user *models.User) error {
+	if f.UpdateFunc == nil {
+		panic("fake: UserRepository.Update not stubbed")
+	}
+	return f.UpdateFunc(ctx, user)
+}
+
+func (f *UserRepository) Delete(ctx context.Context, id int64) error {
+	if f.DeleteFunc == nil {
+		panic("fake: UserRepository.Delete not stubbed")
+	}
+	return f.DeleteFunc(ctx, id)
+}
+
+func (f *UserRepository) GetStats(ctx context.Context, userID int64) (*models.UserTrainingStats, error) {
+	if f.GetStatsFunc == nil {
+		panic("fake: UserRepository.GetStats not stubbed")
+	}
+	return f.GetStatsFunc(ctx, userID)
+}
+
+func (f *UserRepository) UpdateStatus(ctx context.Context, userID int64, change *models.UserStatusChange) error {
+	if f.UpdateStatusFunc == nil {
+		panic("fake: UserRepository.UpdateStatus not stubbed")
+	}
+	return f.UpdateStatusFunc(ctx, userID, change)
+}
+
+func (f *UserRepository) UpdateRole(ctx context.Context, userID int64, role string) error {
+	if f.UpdateRoleFunc == nil {
+		panic("fake: UserRepository.UpdateRole not stubbed")
+	}
+	return f.UpdateRoleFunc(ctx, userID, role)
+}
+
+func (f *UserRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	if f.UpdatePasswordFunc == nil {
+		panic("fake: UserRepository.UpdatePassword not stubbed")
+	}
+	return f.UpdatePasswordFunc(ctx, userID, passwordHash)
+}
+
+func (f *UserRepository) ListStatusChanges(ctx context.Context, userID int64) ([]models.UserStatusChange, error) {
+	if f.ListStatusChangesFunc == nil {
+		panic("fake: UserRepository.ListStatusChanges not stubbed")
+	}
+	return f.ListStatusChangesFunc(ctx, userID)
+}
+
+func (f *UserRepository) Search(ctx context.Context, filter repository.UserSearchFilter, q listquery.Query) ([]models.User, listquery.Page, error) {
+	if f.SearchFunc == nil {
+		panic("fake: UserRepository.Search not stubbed")
+	}
+	return f.SearchFunc(ctx, filter, q)
+}
+
+func (f *UserRepository) UpdateNamePinyin(ctx context.Context, id int64, namePinyin, nameInitials string) error {
+	if f.UpdateNamePinyinFunc == nil {
+		panic("fake: UserRepository.UpdateNamePinyin not stubbed")
+	}
+	return f.UpdateNamePinyinFunc(ctx, id, namePinyin, nameInitials)
+}
+
+func (f *UserRepository) FindWithoutPinyin(ctx context.Context, afterID int64, limit int) ([]models.User, error) {
+	if f.FindWithoutPinyinFunc == nil {
+		panic("fake: UserRepository.FindWithoutPinyin not stubbed")
+	}
+	return f.FindWithoutPinyinFunc(ctx, afterID, limit)
+}
+
+func (f *UserRepository) ExistingPhones(ctx context.Context, phones []string) (map[string]bool, error) {
+	if f.ExistingPhonesFunc == nil {
+		panic("fake: UserRepository.ExistingPhones not stubbed")
+	}
+	return f.ExistingPhonesFunc(ctx, phones)
+}
+
+func (f *UserRepository) EachBatch(ctx context.Context, filter repository.UserSearchFilter, batchSize int, fn func([]models.User) error) error {
+	if f.EachBatchFunc == nil {
+		panic("fake: UserRepository.EachBatch not stubbed")
+	}
+	return f.EachBatchFunc(ctx, filter, batchSize, fn)
+}
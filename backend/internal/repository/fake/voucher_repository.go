@@ -0,0 +1,36 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+)
+
+var _ repository.VoucherRepository = (*VoucherRepository)(nil)
+
+type VoucherRepository struct {
+	GetByCodeFunc    func(ctx context.Context, code string) (*models.VoucherRecord, error)
+	CreateFunc       func(ctx context.Context, voucher *models.VoucherRecord) error
+	MarkVerifiedFunc func(ctx context.Context, voucher *models.VoucherRecord) (bool, error)
+}
+
+func (f *VoucherRepository) GetByCode(ctx context.Context, code string) (*models.VoucherRecord, error) {
+	if f.GetByCodeFunc == nil {
+		panic("fake: VoucherRepository.GetByCode not stubbed")
+	}
+	return f.GetByCodeFunc(ctx, code)
+}
+
+func (f *VoucherRepository) Create(ctx context.Context, voucher *models.VoucherRecord) error {
+	if f.CreateFunc == nil {
+		panic("fake: VoucherRepository.Create not stubbed")
+	}
+	return f.CreateFunc(ctx, voucher)
+}
+
+func (f *VoucherRepository) MarkVerified(ctx context.Context, voucher *models.VoucherRecord) (bool, error) {
+	if f.MarkVerifiedFunc == nil {
+		panic("fake: VoucherRepository.MarkVerified not stubbed")
+	}
+	return f.MarkVerifiedFunc(ctx, voucher)
+}
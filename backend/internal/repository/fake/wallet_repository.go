@@ -0,0 +1,126 @@
+package fake
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"time"
+)
+
+var _ repository.WalletRepository = (*WalletRepository)(nil)
+
+type WalletRepository struct {
+	GetByUserIDFunc      func(ctx context.Context, userID int64) (*models.Wallet, error)
+	GetTransactionFunc   func(ctx context.Context, txType int8, reference string) (*models.WalletTransaction, error)
+	ListTransactionsFunc func(ctx context.Context, userID int64, q listquery.Query) ([]models.WalletTransaction, listquery.Page, error)
+	SpendFunc            func(ctx context.Context, userID int64, amount float64, reference string, orderID, operatorID *int64, remark string) (*models.WalletTransaction, error)
+	RefundSpendFunc      func(ctx context.Context, spendReference, reference string, amount float64) (*models.WalletTransaction, error)
+	TopUpFunc            func(ctx context.Context, t *repository.WalletTopUp) (int64, error)
+	DebitTopUpFunc       func(ctx context.Context, t *repository.TopUpRefund) error
+	SummarizeFunc        func(ctx context.Context, from, to time.Time) ([]repository.WalletTypeSum, error)
+	BalancesAtFunc       func(ctx context.Context, t time.Time) (float64, float64, error)
+	CreateTopUpRuleFunc  func(ctx context.Context, rule *models.TopUpRule) error
+	GetTopUpRuleFunc     func(ctx context.Context, id int64) (*models.TopUpRule, error)
+	UpdateTopUpRuleFunc  func(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListTopUpRulesFunc   func(ctx context.Context, status *int8) ([]models.TopUpRule, error)
+	BestTopUpRuleFunc    func(ctx context.Context, amount float64, now time.Time) (*models.TopUpRule, error)
+}
+
+func (f *WalletRepository) GetByUserID(ctx context.Context, userID int64) (*models.Wallet, error) {
+	if f.GetByUserIDFunc == nil {
+		panic("fake: WalletRepository.GetByUserID not stubbed")
+	}
+	return f.GetByUserIDFunc(ctx, userID)
+}
+
+func (f *WalletRepository) GetTransaction(ctx context.Context, txType int8, reference string) (*models.WalletTransaction, error) {
+	if f.GetTransactionFunc == nil {
+		panic("fake: WalletRepository.GetTransaction not stubbed")
+	}
+	return f.GetTransactionFunc(ctx, txType, reference)
+}
+
+func (f *WalletRepository) ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.WalletTransaction, listquery.Page, error) {
+	if f.ListTransactionsFunc == nil {
+		panic("fake: WalletRepository.ListTransactions not stubbed")
+	}
+	return f.ListTransactionsFunc(ctx, userID, q)
+}
+
+func (f *WalletRepository) Spend(ctx context.Context, userID int64, amount float64, reference string, orderID, operatorID *int64, remark string) (*models.WalletTransaction, error) {
+	if f.SpendFunc == nil {
+		panic("fake: WalletRepository.Spend not stubbed")
+	}
+	return f.SpendFunc(ctx, userID, amount, reference, orderID, operatorID, remark)
+}
+
+func (f *WalletRepository) RefundSpend(ctx context.Context, spendReference, reference string, amount float64) (*models.WalletTransaction, error) {
+	if f.RefundSpendFunc == nil {
+		panic("fake: WalletRepository.RefundSpend not stubbed")
+	}
+	return f.RefundSpendFunc(ctx, spendReference, reference, amount)
+}
+
+func (f *WalletRepository) TopUp(ctx context.Context, t *repository.WalletTopUp) (int64, error) {
+	if f.TopUpFunc == nil {
+		panic("fake: WalletRepository.TopUp not stubbed")
+	}
+	return f.TopUpFunc(ctx, t)
+}
+
+func (f *WalletRepository) DebitTopUp(ctx context.Context, t *repository.TopUpRefund) error {
+	if f.DebitTopUpFunc == nil {
+		panic("fake: WalletRepository.DebitTopUp not stubbed")
+	}
+	return f.DebitTopUpFunc(ctx, t)
+}
+
+func (f *WalletRepository) Summarize(ctx context.Context, from, to time.Time) ([]repository.WalletTypeSum, error) {
+	if f.SummarizeFunc == nil {
+		panic("fake: WalletRepository.Summarize not stubbed")
+	}
+	return f.SummarizeFunc(ctx, from, to)
+}
+
+func (f *WalletRepository) BalancesAt(ctx context.Context, t time.Time) (float64, float64, error) {
+	if f.BalancesAtFunc == nil {
+		panic("fake: WalletRepository.BalancesAt not stubbed")
+	}
+	return f.BalancesAtFunc(ctx, t)
+}
+
+func (f *WalletRepository) CreateTopUpRule(ctx context.Context, rule *models.TopUpRule) error {
+	if f.CreateTopUpRuleFunc == nil {
+		panic("fake: WalletRepository.CreateTopUpRule not stubbed")
+	}
+	return f.CreateTopUpRuleFunc(ctx, rule)
+}
+
+func (f *WalletRepository) GetTopUpRule(ctx context.Context, id int64) (*models.TopUpRule, error) {
+	if f.GetTopUpRuleFunc == nil {
+		panic("fake: WalletRepository.GetTopUpRule not stubbed")
+	}
+	return f.GetTopUpRuleFunc(ctx, id)
+}
+
+func (f *WalletRepository) UpdateTopUpRule(ctx context.Context, id int64, updates map[string]interface{}) error {
+	if f.UpdateTopUpRuleFunc == nil {
+		panic("fake: WalletRepository.UpdateTopUpRule not stubbed")
+	}
+	return f.UpdateTopUpRuleFunc(ctx, id, updates)
+}
+
+func (f *WalletRepository) ListTopUpRules(ctx context.Context, status *int8) ([]models.TopUpRule, error) {
+	if f.ListTopUpRulesFunc == nil {
+		panic("fake: WalletRepository.ListTopUpRules not stubbed")
+	}
+	return f.ListTopUpRulesFunc(ctx, status)
+}
+
+func (f *WalletRepository) BestTopUpRule(ctx context.Context, amount float64, now time.Time) (*models.TopUpRule, error) {
+	if f.BestTopUpRuleFunc == nil {
+		panic("fake: WalletRepository.BestTopUpRule not stubbed")
+	}
+	return f.BestTopUpRuleFunc(ctx, amount, now)
+}
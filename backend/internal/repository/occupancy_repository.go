@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type OccupancyRepository interface {
+	CheckInsBetween(ctx context.Context, from, to time.Time) ([]models.CheckIn, error)
+	ReplaceDay(ctx context.Context, day time.Time, rows []models.CheckInHourlyStat) error
+	HourlyStats(ctx context.Context, from, to time.Time, deviceID string) ([]models.CheckInHourlyStat, error)
+}
+
+type occupancyRepository struct {
+	db *gorm.DB
+}
+
+func NewOccupancyRepository(db *gorm.DB) OccupancyRepository {
+	return &occupancyRepository{db: db}
+}
+
+// CheckInsBetween returns the check-ins that started in [from, to), oldest first
+func (r *occupancyRepository) CheckInsBetween(ctx context.Context, from, to time.Time) ([]models.CheckIn, error) {
+	var checkIns []models.CheckIn
+	err := r.db.WithContext(ctx).Select("id", "user_id", "device_id", "check_in_time", "check_out_at").
+		Where("check_in_time >= ? AND check_in_time < ?", from, to).
+		Order("check_in_time").
+		Find(&checkIns).Error
+	return checkIns, err
+}
+
+// ReplaceDay swaps the hourly rows of one day in a single transaction
+func (r *occupancyRepository) ReplaceDay(ctx context.Context, day time.Time, rows []models.CheckInHourlyStat) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("stat_date >= ? AND stat_date < ?", day, day.AddDate(0, 0, 1)).
+			Delete(&models.CheckInHourlyStat{}).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rows, 500).Error
+	})
+}
+
+// HourlyStats returns the pre-aggregated rows of a device for the dates in [from, to]
+func (r *occupancyRepository) HourlyStats(ctx context.Context, from, to time.Time, deviceID string) ([]models.CheckInHourlyStat, error) {
+	var rows []models.CheckInHourlyStat
+	err := r.db.WithContext(ctx).Where("stat_date >= ? AND stat_date < ? AND device_id = ?", from, to.AddDate(0, 0, 1), deviceID).
+		Order("stat_date, hour").
+		Find(&rows).Error
+	return rows, err
+}
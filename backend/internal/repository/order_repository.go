@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPaymentClosed     = apperr.Conflict("PAYMENT_CLOSED", "payment is no longer payable")
+	ErrRefundExceedsPaid = apperr.Conflict("REFUND_EXCEEDS_PAID", "refund amount exceeds the refundable amount")
+)
+
+type OrderRepository interface {
+	Create(ctx context.Context, order *models.Order, usages []models.PromotionUsage) error
+	GetByID(ctx context.Context, id int64) (*models.Order, error)
+	List(ctx context.Context, q listquery.Query) ([]models.Order, listquery.Page, error)
+	CloseUnpaid(ctx context.Context, id int64, status int8) (bool, error)
+	CloseExpired(ctx context.Context, now time.Time) (int64, error)
+	CreatePayment(ctx context.Context, payment *models.Payment) error
+	GetPaymentByNo(ctx context.Context, paymentNo string) (*models.Payment, error)
+	ListPayments(ctx context.Context, orderID int64) ([]models.Payment, error)
+	UpdatePaymentStatus(ctx context.Context, paymentNo string, from, status int8) error
+	ClaimPending(ctx context.Context, id int64) (bool, error)
+	MarkPaid(ctx context.Context, paymentNo, tradeNo string, paidAt time.Time, raw string) (*MarkPaidResult, error)
+	MarkFulfilled(ctx context.Context, orderID int64) (bool, error)
+	SetItemFulfilment(ctx context.Context, itemID, refID int64) error
+	CreateLessonPackage(ctx context.Context, pkg *models.LessonPackage) error
+	ReserveRefund(ctx context.Context, orderID int64, amount float64) error
+	ReleaseRefund(ctx context.Context, orderID int64, amount float64) error
+	SettleRefund(ctx context.Context, orderID int64, amount float64) error
+}
+
+type orderRepository struct {
+	db *gorm.DB
+}
+
+func NewOrderRepository(db *gorm.DB) OrderRepository {
+	return &orderRepository{db: db}
+}
+
+// OrderListSpec is what the order list can be filtered, sorted and selected by
+var OrderListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"user_id":        {Column: "user_id", Kind: listquery.Int},
+		"status":         {Column: "status", Kind: listquery.Int, Ops: []listquery.Op{listquery.Ne, listquery.In}},
+		"source":         {Column: "source", Kind: listquery.Int},
+		"payment_method": {Column: "payment_method", Kind: listquery.Int, Ops: []listquery.Op{listquery.In}},
+		"pay_amount":     {Column: "pay_amount", Kind: listquery.Float, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"created_at":     {Column: "created_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "pay_amount": "pay_amount"},
+	Fields: []string{"order_no", "user_id", "source", "total_amount", "discount_amount", "pay_amount",
+		"refunded_amount", "refunding_amount", "payment_method", "status", "paid_at", "fulfilled_at",
+		"expire_at", "operator_id", "remark", "created_at", "updated_at"},
+	DefaultSort: "-created_at",
+}
+
+// Create saves an order together with its items and the promotions applied to them
+func (r *orderRepository) Create(ctx context.Context, order *models.Order, usages []models.PromotionUsage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		return reservePromotions(tx, order, usages)
+	})
+}
+
+func (r *orderRepository) GetByID(ctx context.Context, id int64) (*models.Order, error) {
+	var order models.Order
+	err := r.db.WithContext(ctx).Preload("Items").First(&order, id).Error
+	return &order, err
+}
+
+// List lists orders with their items
+func (r *orderRepository) List(ctx context.Context, q listquery.Query) ([]models.Order, listquery.Page, error) {
+	var orders []models.Order
+	page, err := listquery.Find(r.db.WithContext(ctx).Model(&models.Order{}), q, &orders, func(db *gorm.DB) *gorm.DB {
+		return db.Preload("Items")
+	})
+	return orders, page, err
+}
+
+// CloseUnpaid cancels or closes an order still awaiting payment and releases
+// its promotions, returning whether it was awaiting payment
+func (r *orderRepository) CloseUnpaid(ctx context.Context, id int64, status int8) (bool, error) {
+	ok := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", id, models.OrderStatusPending).
+			Update("status", status)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		ok = true
+		return releasePromotions(tx, []int64{id})
+	})
+	return ok, err
+}
+
+// CloseExpired closes the unpaid orders whose payment deadline has passed
+func (r *orderRepository) CloseExpired(ctx context.Context, now time.Time) (int64, error) {
+	var closed int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var ids []int64
+		if err := tx.Model(&models.Order{}).
+			Where("status = ? AND expire_at < ?", models.OrderStatusPending, now).
+			Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+			return err
+		}
+		result := tx.Model(&models.Order{}).
+			Where("id IN ? AND status = ?", ids, models.OrderStatusPending).
+			Update("status", models.OrderStatusClosed)
+		if result.Error != nil {
+			return result.Error
+		}
+		closed = result.RowsAffected
+		// Orders paid in the meantime keep their promotions
+		if err := tx.Model(&models.Order{}).
+			Where("id IN ? AND status = ?", ids, models.OrderStatusClosed).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		return releasePromotions(tx, ids)
+	})
+	return closed, err
+}
+
+// CreatePayment starts a new payment attempt, closing the attempts still pending
+func (r *orderRepository) CreatePayment(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status = ?", payment.OrderID, models.PaymentStatusPending).
+			Update("status", models.PaymentStatusClosed).Error; err != nil {
+			return err
+		}
+		return tx.Create(payment).Error
+	})
+}
+
+func (r *orderRepository) GetPaymentByNo(ctx context.Context, paymentNo string) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).Where("payment_no = ?", paymentNo).First(&payment).Error
+	return &payment, err
+}
+
+func (r *orderRepository) ListPayments(ctx context.Context, orderID int64) ([]models.Payment, error) {
+	var payments []models.Payment
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at").Find(&payments).Error
+	return payments, err
+}
+
+func (r *orderRepository) UpdatePaymentStatus(ctx context.Context, paymentNo string, from, status int8) error {
+	return r.db.WithContext(ctx).Model(&models.Payment{}).
+		Where("payment_no = ? AND status = ?", paymentNo, from).
+		Update("status", status).Error
+}
+
+// ClaimPending locks an order awaiting payment until the transaction ends,
+// returning false when it is no longer awaiting payment. A concurrent claim
+// waits for the lock and then finds the order paid.
+func (r *orderRepository) ClaimPending(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("id = ? AND status = ?", id, models.OrderStatusPending).
+		Update("updated_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkPaidResult tells whether MarkPaid changed anything. Duplicate is set
+// when the payment succeeded for an order that another payment had already paid.
+type MarkPaidResult struct {
+	Order     *models.Order
+	Changed   bool
+	Duplicate bool
+}
+
+// MarkPaid records a successful payment and marks its order paid in one
+// transaction. The conditional updates make it idempotent: a repeated
+// notification finds the payment already successful and reports Changed=false.
+// A payment confirmed after its attempt was closed is still accepted, since
+// the money has been taken; whether it settles the order is up to markOrderPaid.
+func (r *orderRepository) MarkPaid(ctx context.Context, paymentNo, tradeNo string, paidAt time.Time, raw string) (*MarkPaidResult, error) {
+	res := &MarkPaidResult{Order: &models.Order{}}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var payment models.Payment
+		if err := tx.Where("payment_no = ?", paymentNo).First(&payment).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Payment{}).
+			Where("id = ? AND status IN ?", payment.ID, []int8{models.PaymentStatusPending, models.PaymentStatusClosed}).
+			Updates(map[string]interface{}{
+				"status":      models.PaymentStatusSuccess,
+				"trade_no":    tradeNo,
+				"paid_at":     paidAt,
+				"notify_data": raw,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		res.Changed = result.RowsAffected > 0
+		if !res.Changed && payment.Status != models.PaymentStatusSuccess {
+			return ErrPaymentClosed
+		}
+
+		if res.Changed {
+			paid, err := markOrderPaid(tx, &payment, paidAt)
+			if err != nil {
+				return err
+			}
+			res.Duplicate = !paid
+		}
+
+		return tx.Preload("Items").First(res.Order, payment.OrderID).Error
+	})
+	return res, err
+}
+
+// markOrderPaid marks the order of a successful payment paid. An order closed
+// for timeout is still settled by a late payment if it can take back the
+// promotions it released on closing; otherwise it stays closed and the
+// payment is reported like one for an order paid twice, to be refunded.
+func markOrderPaid(tx *gorm.DB, payment *models.Payment, paidAt time.Time) (bool, error) {
+	updates := map[string]interface{}{
+		"status":         models.OrderStatusPaid,
+		"paid_at":        paidAt,
+		"payment_method": payment.Method,
+	}
+	result := tx.Model(&models.Order{}).
+		Where("id = ? AND status = ?", payment.OrderID, models.OrderStatusPending).
+		Updates(updates)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.RowsAffected > 0, result.Error
+	}
+
+	// A savepoint, so that a promotion taken by someone else leaves the order
+	// closed but keeps the payment recorded
+	paid := false
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Order{}).
+			Where("id = ? AND status = ?", payment.OrderID, models.OrderStatusClosed).
+			Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		var order models.Order
+		if err := tx.Select("id", "user_id").First(&order, payment.OrderID).Error; err != nil {
+			return err
+		}
+		if err := reclaimPromotions(tx, &order); err != nil {
+			return err
+		}
+		paid = true
+		return nil
+	})
+	if errors.Is(err, ErrPromotionExhausted) {
+		return false, nil
+	}
+	return paid, err
+}
+
+// MarkFulfilled stamps a paid order fulfilled, returning false when it
+// already was. Run in the unit of work that delivers the items, it makes sure
+// an order is delivered at most once.
+func (r *orderRepository) MarkFulfilled(ctx context.Context, orderID int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("id = ? AND fulfilled_at IS NULL", orderID).
+		Update("fulfilled_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetItemFulfilment links an order item to the card, lesson package or wallet
+// entry delivered for it
+func (r *orderRepository) SetItemFulfilment(ctx context.Context, itemID, refID int64) error {
+	return r.db.WithContext(ctx).Model(&models.OrderItem{}).Where("id = ?", itemID).
+		Update("fulfilled_ref_id", refID).Error
+}
+
+func (r *orderRepository) CreateLessonPackage(ctx context.Context, pkg *models.LessonPackage) error {
+	return r.db.WithContext(ctx).Create(pkg).Error
+}
+
+// ReserveRefund sets aside the amount of a refund under way on a paid order,
+// so that concurrent requests can never refund more than was paid
+func (r *orderRepository) ReserveRefund(ctx context.Context, orderID int64, amount float64) error {
+	result := r.db.WithContext(ctx).Model(&models.Order{}).
+		Where("id = ? AND status = ? AND pay_amount - refunded_amount - refunding_amount >= ?", orderID, models.OrderStatusPaid, amount).
+		Update("refunding_amount", gorm.Expr("refunding_amount + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefundExceedsPaid
+	}
+	return nil
+}
+
+// ReleaseRefund gives back the amount of a refund that was turned down or failed
+func (r *orderRepository) ReleaseRefund(ctx context.Context, orderID int64, amount float64) error {
+	return r.db.WithContext(ctx).Model(&models.Order{}).Where("id = ?", orderID).
+		Update("refunding_amount", gorm.Expr("refunding_amount - ?", amount)).Error
+}
+
+// SettleRefund moves the amount of a completed refund from refunding to
+// refunded. An order refunded in full is closed as refunded.
+func (r *orderRepository) SettleRefund(ctx context.Context, orderID int64, amount float64) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Model(&models.Order{}).Where("id = ?", orderID).
+		Updates(map[string]interface{}{
+			"refunded_amount":  gorm.Expr("refunded_amount + ?", amount),
+			"refunding_amount": gorm.Expr("refunding_amount - ?", amount),
+		}).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.Order{}).
+		Where("id = ? AND status = ? AND refunded_amount >= pay_amount", orderID, models.OrderStatusPaid).
+		Update("status", models.OrderStatusRefunded).Error
+}
@@ -0,0 +1,430 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInsufficientPoints = apperr.Conflict("INSUFFICIENT_POINTS", "not enough points")
+	ErrRewardUnavailable  = apperr.Conflict("REWARD_UNAVAILABLE", "reward is off the shelf or out of stock")
+)
+
+type PointsRepository interface {
+	GetAccount(ctx context.Context, userID int64) (*models.PointAccount, error)
+	ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.PointTransaction, listquery.Page, error)
+	Earn(ctx context.Context, userID int64, entries []models.PointTransaction) (int64, error)
+	Spend(ctx context.Context, userID int64, entry models.PointTransaction) error
+	GetEntry(ctx context.Context, txType int8, sourceID int64) (*models.PointTransaction, error)
+	TakeBack(ctx context.Context, earned *models.PointTransaction, entry models.PointTransaction) (int64, error)
+	TakeReward(ctx context.Context, rewardID int64) error
+	CreateRedemption(ctx context.Context, redemption *models.PointRedemption) error
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]models.PointTransaction, error)
+	Expire(ctx context.Context, entryID, userID int64) (int64, error)
+	CheckInsSince(ctx context.Context, since time.Time) ([]models.CheckIn, error)
+	CheckInPointsSince(ctx context.Context, since time.Time) ([]models.PointTransaction, error)
+	PaidOrdersSince(ctx context.Context, since time.Time) ([]models.Order, error)
+	Leaderboard(ctx context.Context, from, to time.Time, limit int) ([]PointsRank, error)
+	EarnedBetween(ctx context.Context, userID int64, from, to time.Time) (int64, error)
+	CountEarnedMore(ctx context.Context, points int64, from, to time.Time) (int64, error)
+	CreateRule(ctx context.Context, rule *models.PointRule) error
+	GetRule(ctx context.Context, id int64) (*models.PointRule, error)
+	UpdateRule(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListRules(ctx context.Context, status *int8) ([]models.PointRule, error)
+	CreateReward(ctx context.Context, reward *models.PointReward) error
+	GetReward(ctx context.Context, id int64) (*models.PointReward, error)
+	UpdateReward(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListRewards(ctx context.Context, status *int8) ([]models.PointReward, error)
+}
+
+type pointsRepository struct {
+	db *gorm.DB
+}
+
+func NewPointsRepository(db *gorm.DB) PointsRepository {
+	return &pointsRepository{db: db}
+}
+
+// PointsRank is the points a member earned within a period
+type PointsRank struct {
+	UserID int64
+	Points int64
+}
+
+func (r *pointsRepository) GetAccount(ctx context.Context, userID int64) (*models.PointAccount, error) {
+	var account models.PointAccount
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&account).Error
+	return &account, err
+}
+
+// PointTransactionListSpec is what the points history of a member can be
+// filtered, sorted and selected by
+var PointTransactionListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"type":        {Column: "type", Kind: listquery.Int, Ops: []listquery.Op{listquery.In}},
+		"occurred_at": {Column: "occurred_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+	},
+	Sorts:       map[string]string{"occurred_at": "occurred_at", "points": "points"},
+	Fields:      []string{"user_id", "type", "source_id", "points", "remaining", "balance_after", "expire_at", "occurred_at", "remark", "created_at"},
+	DefaultSort: "-id",
+}
+
+func (r *pointsRepository) ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.PointTransaction, listquery.Page, error) {
+	var entries []models.PointTransaction
+	query := r.db.WithContext(ctx).Model(&models.PointTransaction{}).Where("user_id = ?", userID)
+	page, err := listquery.Find(query, q, &entries)
+	return entries, page, err
+}
+
+// Earn posts earned points for one member. Entries already posted for the
+// same source are skipped; it returns the points actually posted. The account
+// stays locked until the unit of work it runs in commits.
+func (r *pointsRepository) Earn(ctx context.Context, userID int64, entries []models.PointTransaction) (int64, error) {
+	tx := r.db.WithContext(ctx)
+	account, err := lockPointAccount(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	var fresh []models.PointTransaction
+	var posted int64
+	for _, e := range entries {
+		var n int64
+		if err := tx.Model(&models.PointTransaction{}).
+			Where("type = ? AND source_id = ?", e.Type, e.SourceID).
+			Count(&n).Error; err != nil {
+			return 0, err
+		}
+		if n == 0 && e.Points > 0 {
+			e.Remaining = e.Points
+			fresh = append(fresh, e)
+			posted += e.Points
+		}
+	}
+	if len(fresh) == 0 {
+		return 0, nil
+	}
+	return posted, postPoints(tx, account, fresh)
+}
+
+// Spend takes -entry.Points out of the member's balance, from the earned
+// points that expire first, and posts entry. It fails with
+// ErrInsufficientPoints when the balance does not cover it.
+func (r *pointsRepository) Spend(ctx context.Context, userID int64, entry models.PointTransaction) error {
+	tx := r.db.WithContext(ctx)
+	account, err := lockPointAccount(tx, userID)
+	if err != nil {
+		return err
+	}
+	if account.Balance < -entry.Points {
+		return ErrInsufficientPoints
+	}
+	if err := consumePoints(tx, userID, -entry.Points); err != nil {
+		return err
+	}
+	return postPoints(tx, account, []models.PointTransaction{entry})
+}
+
+// GetEntry returns the ledger entry of the given type posted for a source, or
+// nil if there is none
+func (r *pointsRepository) GetEntry(ctx context.Context, txType int8, sourceID int64) (*models.PointTransaction, error) {
+	var entries []models.PointTransaction
+	err := r.db.WithContext(ctx).Where("type = ? AND source_id = ?", txType, sourceID).Limit(1).Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// TakeBack posts entry, which takes back -entry.Points of the earned entry,
+// and returns the points taken. They come out of what is left of the earned
+// entry first, then of the entries expiring first. Points the member already
+// spent stay spent, so no more than the balance is taken; an entry already
+// posted for the same source is skipped.
+func (r *pointsRepository) TakeBack(ctx context.Context, earned *models.PointTransaction, entry models.PointTransaction) (int64, error) {
+	tx := r.db.WithContext(ctx)
+	account, err := lockPointAccount(tx, earned.UserID)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	if err := tx.Model(&models.PointTransaction{}).
+		Where("type = ? AND source_id = ?", entry.Type, entry.SourceID).
+		Count(&n).Error; err != nil || n > 0 {
+		return 0, err
+	}
+
+	points := -entry.Points
+	if points > account.Balance {
+		points = account.Balance
+	}
+	if points <= 0 {
+		return 0, nil
+	}
+	var lot models.PointTransaction
+	if err := tx.First(&lot, earned.ID).Error; err != nil {
+		return 0, err
+	}
+	fromLot := lot.Remaining
+	if fromLot > points {
+		fromLot = points
+	}
+	if fromLot > 0 {
+		if err := tx.Model(&models.PointTransaction{}).Where("id = ?", lot.ID).
+			Update("remaining", gorm.Expr("remaining - ?", fromLot)).Error; err != nil {
+			return 0, err
+		}
+	}
+	if err := consumePoints(tx, earned.UserID, points-fromLot); err != nil {
+		return 0, err
+	}
+	entry.Points = -points
+	return points, postPoints(tx, account, []models.PointTransaction{entry})
+}
+
+// TakeReward counts one redemption against the stock of a reward on the shelf
+func (r *pointsRepository) TakeReward(ctx context.Context, rewardID int64) error {
+	result := r.db.WithContext(ctx).Model(&models.PointReward{}).
+		Where("id = ? AND status = 1 AND (stock = 0 OR redeemed_count < stock)", rewardID).
+		Update("redeemed_count", gorm.Expr("redeemed_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRewardUnavailable
+	}
+	return nil
+}
+
+func (r *pointsRepository) CreateRedemption(ctx context.Context, redemption *models.PointRedemption) error {
+	return r.db.WithContext(ctx).Create(redemption).Error
+}
+
+// ListExpired returns earned entries past their expiry that still have points left
+func (r *pointsRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]models.PointTransaction, error) {
+	var entries []models.PointTransaction
+	err := r.db.WithContext(ctx).Where("expire_at <= ? AND remaining > 0", now).
+		Order("expire_at, id").
+		Limit(limit).
+		Find(&entries).Error
+	return entries, err
+}
+
+// Expire writes off what is left of an earned entry; it returns the points
+// expired. Run it in a unit of work, which keeps the account locked.
+func (r *pointsRepository) Expire(ctx context.Context, entryID, userID int64) (int64, error) {
+	tx := r.db.WithContext(ctx)
+	account, err := lockPointAccount(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+	var entry models.PointTransaction
+	if err := tx.First(&entry, entryID).Error; err != nil {
+		return 0, err
+	}
+	if entry.Remaining <= 0 {
+		return 0, nil
+	}
+	if err := tx.Model(&models.PointTransaction{}).Where("id = ?", entry.ID).
+		Update("remaining", 0).Error; err != nil {
+		return 0, err
+	}
+	return entry.Remaining, postPoints(tx, account, []models.PointTransaction{{
+		Type:       models.PointTxExpire,
+		SourceID:   entry.ID,
+		Points:     -entry.Remaining,
+		OccurredAt: *entry.ExpireAt,
+	}})
+}
+
+// CheckInsSince returns the check-ins from since on that have not earned points yet
+func (r *pointsRepository) CheckInsSince(ctx context.Context, since time.Time) ([]models.CheckIn, error) {
+	var checkIns []models.CheckIn
+	err := r.db.WithContext(ctx).Where("check_in_time >= ?", since).
+		Where("NOT EXISTS (SELECT 1 FROM point_transactions pt WHERE pt.type = ? AND pt.source_id = check_ins.id)", models.PointTxCheckIn).
+		Order("check_in_time, id").
+		Find(&checkIns).Error
+	return checkIns, err
+}
+
+// CheckInPointsSince returns the check-in and streak entries posted for
+// check-ins from since on
+func (r *pointsRepository) CheckInPointsSince(ctx context.Context, since time.Time) ([]models.PointTransaction, error) {
+	var entries []models.PointTransaction
+	err := r.db.WithContext(ctx).Select("user_id, occurred_at").
+		Where("type IN ? AND occurred_at >= ?", []int8{models.PointTxCheckIn, models.PointTxStreak}, since).
+		Find(&entries).Error
+	return entries, err
+}
+
+// PaidOrdersSince returns the paid orders from since on that have not earned points yet
+func (r *pointsRepository) PaidOrdersSince(ctx context.Context, since time.Time) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.db.WithContext(ctx).Preload("Items").
+		Where("status = ? AND paid_at >= ?", models.OrderStatusPaid, since).
+		Where("NOT EXISTS (SELECT 1 FROM point_transactions pt WHERE pt.type = ? AND pt.source_id = orders.id)", models.PointTxPurchase).
+		Order("paid_at, id").
+		Find(&orders).Error
+	return orders, err
+}
+
+// Leaderboard ranks members by the points they earned in [from, to). Points
+// spent or expired do not lower a member's rank; points taken back do.
+func (r *pointsRepository) Leaderboard(ctx context.Context, from, to time.Time, limit int) ([]PointsRank, error) {
+	var ranks []PointsRank
+	err := r.earnedBetween(ctx, from, to).
+		Order("SUM(points) DESC, user_id").
+		Limit(limit).
+		Scan(&ranks).Error
+	return ranks, err
+}
+
+// EarnedBetween returns the points one member earned in [from, to)
+func (r *pointsRepository) EarnedBetween(ctx context.Context, userID int64, from, to time.Time) (int64, error) {
+	var points int64
+	err := r.db.WithContext(ctx).Model(&models.PointTransaction{}).
+		Select("COALESCE(SUM(points), 0)").
+		Where("user_id = ? AND type IN ? AND occurred_at >= ? AND occurred_at < ?", userID, earnedTypes, from, to).
+		Scan(&points).Error
+	return points, err
+}
+
+// CountEarnedMore counts the members who earned more than points in [from, to)
+func (r *pointsRepository) CountEarnedMore(ctx context.Context, points int64, from, to time.Time) (int64, error) {
+	var n int64
+	sub := r.earnedBetween(ctx, from, to).Having("SUM(points) > ?", points)
+	err := r.db.WithContext(ctx).Table("(?) AS ranked", sub).Count(&n).Error
+	return n, err
+}
+
+// earnedTypes are the entries that count as earning points. Points taken
+// back after a refund were never really earned.
+var earnedTypes = []int8{models.PointTxCheckIn, models.PointTxStreak, models.PointTxPurchase, models.PointTxRefund}
+
+func (r *pointsRepository) earnedBetween(ctx context.Context, from, to time.Time) *gorm.DB {
+	return r.db.WithContext(ctx).Model(&models.PointTransaction{}).
+		Select("user_id, SUM(points) AS points").
+		Where("type IN ? AND occurred_at >= ? AND occurred_at < ?", earnedTypes, from, to).
+		Group("user_id")
+}
+
+func (r *pointsRepository) CreateRule(ctx context.Context, rule *models.PointRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *pointsRepository) GetRule(ctx context.Context, id int64) (*models.PointRule, error) {
+	var rule models.PointRule
+	err := r.db.WithContext(ctx).First(&rule, id).Error
+	return &rule, err
+}
+
+func (r *pointsRepository) UpdateRule(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&models.PointRule{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *pointsRepository) ListRules(ctx context.Context, status *int8) ([]models.PointRule, error) {
+	var rules []models.PointRule
+	query := r.db.WithContext(ctx).Model(&models.PointRule{})
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	err := query.Order("rule_type, id").Find(&rules).Error
+	return rules, err
+}
+
+func (r *pointsRepository) CreateReward(ctx context.Context, reward *models.PointReward) error {
+	return r.db.WithContext(ctx).Create(reward).Error
+}
+
+func (r *pointsRepository) GetReward(ctx context.Context, id int64) (*models.PointReward, error) {
+	var reward models.PointReward
+	err := r.db.WithContext(ctx).First(&reward, id).Error
+	return &reward, err
+}
+
+func (r *pointsRepository) UpdateReward(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&models.PointReward{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *pointsRepository) ListRewards(ctx context.Context, status *int8) ([]models.PointReward, error) {
+	var rewards []models.PointReward
+	query := r.db.WithContext(ctx).Model(&models.PointReward{})
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	err := query.Order("points, id").Find(&rewards).Error
+	return rewards, err
+}
+
+// lockPointAccount locks the member's points account, creating it on first
+// use. It must come first in the transaction: reads after it then see every
+// posting committed by earlier holders of the lock.
+func lockPointAccount(tx *gorm.DB, userID int64) (*models.PointAccount, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PointAccount{UserID: userID}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.PointAccount{}).Where("user_id = ?", userID).
+		Update("version", gorm.Expr("version + 1")).Error; err != nil {
+		return nil, err
+	}
+	var account models.PointAccount
+	if err := tx.Where("user_id = ?", userID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// postPoints writes the entries to the ledger of the locked account and
+// updates its balance
+func postPoints(tx *gorm.DB, account *models.PointAccount, entries []models.PointTransaction) error {
+	for i := range entries {
+		account.Balance += entries[i].Points
+		if entries[i].Points > 0 {
+			account.TotalEarned += entries[i].Points
+		}
+		entries[i].UserID = account.UserID
+		entries[i].BalanceAfter = account.Balance
+	}
+	if account.Balance < 0 {
+		return ErrInsufficientPoints
+	}
+	if err := tx.Model(&models.PointAccount{}).Where("id = ?", account.ID).
+		Updates(map[string]interface{}{"balance": account.Balance, "total_earned": account.TotalEarned}).Error; err != nil {
+		return err
+	}
+	return tx.Create(&entries).Error
+}
+
+// consumePoints takes points out of the earned entries that expire first
+func consumePoints(tx *gorm.DB, userID, points int64) error {
+	var lots []models.PointTransaction
+	if err := tx.Where("user_id = ? AND remaining > 0", userID).
+		Order("CASE WHEN expire_at IS NULL THEN 1 ELSE 0 END, expire_at, id").
+		Find(&lots).Error; err != nil {
+		return err
+	}
+	for _, lot := range lots {
+		if points == 0 {
+			break
+		}
+		take := lot.Remaining
+		if take > points {
+			take = points
+		}
+		if err := tx.Model(&models.PointTransaction{}).Where("id = ?", lot.ID).
+			Update("remaining", gorm.Expr("remaining - ?", take)).Error; err != nil {
+			return err
+		}
+		points -= take
+	}
+	if points > 0 {
+		return ErrInsufficientPoints
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrPromotionExhausted = apperr.Conflict("PROMOTION_EXHAUSTED", "promotion or coupon has been used up")
+
+type PromotionRepository interface {
+	Create(ctx context.Context, promotion *models.Promotion) error
+	GetByID(ctx context.Context, id int64) (*models.Promotion, error)
+	Update(ctx context.Context, id int64, updates map[string]interface{}) error
+	List(ctx context.Context, q listquery.Query) ([]models.Promotion, listquery.Page, error)
+	ListAutomatic(ctx context.Context, now time.Time) ([]models.Promotion, error)
+	CreateCoupons(ctx context.Context, coupons []models.Coupon) error
+	ListCoupons(ctx context.Context, promotionID int64, q listquery.Query) ([]models.Coupon, listquery.Page, error)
+	GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error)
+	CouponCodeExists(ctx context.Context, code string) (bool, error)
+	VoidCoupon(ctx context.Context, id int64) (bool, error)
+	CountUserUsages(ctx context.Context, promotionID, userID int64) (int64, error)
+	IsNewMember(ctx context.Context, userID int64) (bool, error)
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{db: db}
+}
+
+func (r *promotionRepository) Create(ctx context.Context, promotion *models.Promotion) error {
+	return r.db.WithContext(ctx).Create(promotion).Error
+}
+
+func (r *promotionRepository) GetByID(ctx context.Context, id int64) (*models.Promotion, error) {
+	var promotion models.Promotion
+	err := r.db.WithContext(ctx).First(&promotion, id).Error
+	return &promotion, err
+}
+
+func (r *promotionRepository) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&models.Promotion{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// PromotionListSpec is what the promotion list can be filtered, sorted and selected by
+var PromotionListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":          {Column: "status", Kind: listquery.Int},
+		"rule_type":       {Column: "rule_type", Kind: listquery.Int, Ops: []listquery.Op{listquery.In}},
+		"item_type":       {Column: "item_type", Kind: listquery.Int},
+		"requires_coupon": {Column: "requires_coupon", Kind: listquery.Int},
+		"name":            {Column: "name", Kind: listquery.String, Ops: []listquery.Op{listquery.Like}},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "used_count": "used_count", "name": "name"},
+	Fields: []string{"name", "rule_type", "value", "buy_days", "item_type", "card_type_ids", "new_members_only",
+		"requires_coupon", "start_at", "end_at", "per_user_limit", "total_limit", "used_count", "status",
+		"description", "created_by", "created_at", "updated_at"},
+	DefaultSort: "-created_at",
+}
+
+func (r *promotionRepository) List(ctx context.Context, q listquery.Query) ([]models.Promotion, listquery.Page, error) {
+	var promotions []models.Promotion
+	page, err := listquery.Find(r.db.WithContext(ctx).Model(&models.Promotion{}), q, &promotions)
+	return promotions, page, err
+}
+
+// ListAutomatic returns the enabled promotions that apply without a coupon at the given time
+func (r *promotionRepository) ListAutomatic(ctx context.Context, now time.Time) ([]models.Promotion, error) {
+	var promotions []models.Promotion
+	err := r.db.WithContext(ctx).Where("status = 1 AND requires_coupon = 0").
+		Where("(start_at IS NULL OR start_at <= ?) AND (end_at IS NULL OR end_at >= ?)", now, now).
+		Where("total_limit = 0 OR used_count < total_limit").
+		Find(&promotions).Error
+	return promotions, err
+}
+
+func (r *promotionRepository) CreateCoupons(ctx context.Context, coupons []models.Coupon) error {
+	return r.db.WithContext(ctx).Create(&coupons).Error
+}
+
+// CouponListSpec is what the coupons of a promotion can be filtered, sorted and selected by
+var CouponListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":  {Column: "status", Kind: listquery.Int},
+		"user_id": {Column: "user_id", Kind: listquery.Int},
+		"code":    {Column: "code", Kind: listquery.String},
+	},
+	Sorts:       map[string]string{"created_at": "created_at", "used_count": "used_count"},
+	Fields:      []string{"code", "promotion_id", "user_id", "max_uses", "used_count", "status", "expire_at", "created_at", "updated_at"},
+	DefaultSort: "id",
+}
+
+func (r *promotionRepository) ListCoupons(ctx context.Context, promotionID int64, q listquery.Query) ([]models.Coupon, listquery.Page, error) {
+	var coupons []models.Coupon
+	query := r.db.WithContext(ctx).Model(&models.Coupon{}).Where("promotion_id = ?", promotionID)
+	page, err := listquery.Find(query, q, &coupons)
+	return coupons, page, err
+}
+
+func (r *promotionRepository) GetCouponByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	err := r.db.WithContext(ctx).Where("code = ?", code).First(&coupon).Error
+	return &coupon, err
+}
+
+func (r *promotionRepository) CouponCodeExists(ctx context.Context, code string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Coupon{}).Where("code = ?", code).Count(&count).Error
+	return count > 0, err
+}
+
+// VoidCoupon disables a coupon nobody has used yet, returning false when it
+// was used or already disabled
+func (r *promotionRepository) VoidCoupon(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Coupon{}).
+		Where("id = ? AND status = ? AND used_count = 0", id, models.CouponStatusActive).
+		Update("status", models.CouponStatusDisabled)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountUserUsages counts how often a member has used a promotion on live orders
+func (r *promotionRepository) CountUserUsages(ctx context.Context, promotionID, userID int64) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.PromotionUsage{}).
+		Where("promotion_id = ? AND user_id = ? AND status = 1", promotionID, userID).
+		Count(&count).Error
+	return count, err
+}
+
+// IsNewMember reports whether a member has never held a card nor paid an order
+func (r *promotionRepository) IsNewMember(ctx context.Context, userID int64) (bool, error) {
+	var cards, orders int64
+	if err := r.db.WithContext(ctx).Unscoped().Model(&models.MembershipCard{}).Where("user_id = ?", userID).Count(&cards).Error; err != nil {
+		return false, err
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Order{}).Where("user_id = ? AND status IN ?", userID, []int8{2, 5}).Count(&orders).Error; err != nil {
+		return false, err
+	}
+	return cards == 0 && orders == 0, nil
+}
+
+// reservePromotions records the usages of a new order and counts them against
+// the promotion and coupon limits. The conditional increments make the limits
+// hold under concurrent orders.
+func reservePromotions(tx *gorm.DB, order *models.Order, usages []models.PromotionUsage) error {
+	for i := range usages {
+		u := &usages[i]
+		u.OrderID = order.ID
+		u.OrderItemID = order.Items[u.ItemIndex].ID
+		u.UserID = order.UserID
+
+		result := tx.Model(&models.Promotion{}).
+			Where("id = ? AND (total_limit = 0 OR used_count < total_limit)", u.PromotionID).
+			Update("used_count", gorm.Expr("used_count + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPromotionExhausted
+		}
+
+		if u.CouponID != nil {
+			result := tx.Model(&models.Coupon{}).
+				Where("id = ? AND status = 1 AND used_count < max_uses", *u.CouponID).
+				Update("used_count", gorm.Expr("used_count + 1"))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrPromotionExhausted
+			}
+		}
+
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reclaimPromotions takes back the usages an order released when it was
+// closed unpaid, for a late payment reviving it. It fails with
+// ErrPromotionExhausted when other orders have used up a promotion, a coupon
+// or the member's own limit in the meantime.
+func reclaimPromotions(tx *gorm.DB, order *models.Order) error {
+	var usages []models.PromotionUsage
+	if err := tx.Where("order_id = ? AND status = 2", order.ID).Find(&usages).Error; err != nil {
+		return err
+	}
+	for _, u := range usages {
+		result := tx.Model(&models.Promotion{}).
+			Where("id = ? AND (total_limit = 0 OR used_count < total_limit)", u.PromotionID).
+			Where("per_user_limit = 0 OR per_user_limit > (?)", tx.Model(&models.PromotionUsage{}).
+				Select("COUNT(*)").Where("promotion_id = ? AND user_id = ? AND status = 1", u.PromotionID, order.UserID)).
+			Update("used_count", gorm.Expr("used_count + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPromotionExhausted
+		}
+
+		if u.CouponID != nil {
+			result := tx.Model(&models.Coupon{}).
+				Where("id = ? AND status = 1 AND used_count < max_uses", *u.CouponID).
+				Update("used_count", gorm.Expr("used_count + 1"))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrPromotionExhausted
+			}
+		}
+
+		if err := tx.Model(&models.PromotionUsage{}).Where("id = ?", u.ID).Update("status", 1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// releasePromotions gives back the usages of orders that were cancelled or
+// closed unpaid
+func releasePromotions(tx *gorm.DB, orderIDs []int64) error {
+	var usages []models.PromotionUsage
+	if err := tx.Where("order_id IN ? AND status = 1", orderIDs).Find(&usages).Error; err != nil {
+		return err
+	}
+	for _, u := range usages {
+		result := tx.Model(&models.PromotionUsage{}).Where("id = ? AND status = 1", u.ID).Update("status", 2)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := tx.Model(&models.Promotion{}).Where("id = ? AND used_count > 0", u.PromotionID).
+			Update("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+			return err
+		}
+		if u.CouponID != nil {
+			if err := tx.Model(&models.Coupon{}).Where("id = ? AND used_count > 0", *u.CouponID).
+				Update("used_count", gorm.Expr("used_count - 1")).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrReferralRewarded = apperr.Conflict("REFERRAL_REWARDED", "referral already rewarded")
+
+type ReferralRepository interface {
+	Create(ctx context.Context, referral *models.Referral) error
+	GetByReferee(ctx context.Context, refereeID int64) (*models.Referral, error)
+	ListByReferrer(ctx context.Context, referrerID int64, q listquery.Query) ([]models.Referral, listquery.Page, error)
+	ListPending(ctx context.Context, afterID int64, limit int) ([]models.Referral, error)
+	FirstPaidCard(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error)
+	ActiveTimeCard(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error)
+	MarkRewarded(ctx context.Context, referral *models.Referral) error
+	GetRewardedByCard(ctx context.Context, cardID int64) (*models.Referral, error)
+	MarkRevoked(ctx context.Context, id int64) (bool, error)
+	CountBetween(ctx context.Context, from, to time.Time) (int64, int64, error)
+	RewardsBetween(ctx context.Context, from, to time.Time) (int64, int64, error)
+	TopReferrers(ctx context.Context, from, to time.Time, limit int) ([]ReferrerStats, error)
+	ReferrerSummary(ctx context.Context, referrerID int64) (*ReferrerStats, error)
+	CreateRule(ctx context.Context, rule *models.ReferralRule) error
+	GetRule(ctx context.Context, id int64) (*models.ReferralRule, error)
+	UpdateRule(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListRules(ctx context.Context, status *int8) ([]models.ReferralRule, error)
+	CurrentRule(ctx context.Context) (*models.ReferralRule, error)
+}
+
+type referralRepository struct {
+	db *gorm.DB
+}
+
+func NewReferralRepository(db *gorm.DB) ReferralRepository {
+	return &referralRepository{db: db}
+}
+
+// ReferrerStats sums up the referrals of one referrer
+type ReferrerStats struct {
+	ReferrerID int64
+	Referred   int64
+	Rewarded   int64
+	RewardDays int64
+	Coupons    int64
+}
+
+// Create records a referral; the referred member is created by the caller in
+// the same unit of work
+func (r *referralRepository) Create(ctx context.Context, referral *models.Referral) error {
+	return r.db.WithContext(ctx).Create(referral).Error
+}
+
+func (r *referralRepository) GetByReferee(ctx context.Context, refereeID int64) (*models.Referral, error) {
+	var referral models.Referral
+	err := r.db.WithContext(ctx).Where("referee_id = ?", refereeID).First(&referral).Error
+	return &referral, err
+}
+
+// ReferralListSpec is what the referrals of a member can be filtered and
+// sorted by. Entries carry the referee's name, so fields cannot be selected.
+var ReferralListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":     {Column: "status", Kind: listquery.Int},
+		"created_at": {Column: "created_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+	},
+	Sorts:       map[string]string{"created_at": "created_at"},
+	DefaultSort: "-id",
+}
+
+func (r *referralRepository) ListByReferrer(ctx context.Context, referrerID int64, q listquery.Query) ([]models.Referral, listquery.Page, error) {
+	var referrals []models.Referral
+	query := r.db.WithContext(ctx).Model(&models.Referral{}).Where("referrer_id = ?", referrerID)
+	page, err := listquery.Find(query, q, &referrals)
+	return referrals, page, err
+}
+
+// ListPending returns referrals not rewarded yet, oldest first
+func (r *referralRepository) ListPending(ctx context.Context, afterID int64, limit int) ([]models.Referral, error) {
+	var referrals []models.Referral
+	// 待奖励
+	err := r.db.WithContext(ctx).Where("status = ? AND id > ?", models.ReferralStatusPending, afterID).Order("id").Limit(limit).Find(&referrals).Error
+	return referrals, err
+}
+
+// FirstPaidCard returns the member's earliest card that has started by day
+// and was bought in a paid order for more than nothing. Imported, transferred
+// and free cards do not qualify, nor do refunded ones.
+func (r *referralRepository) FirstPaidCard(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error) {
+	var cards []models.MembershipCard
+	err := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Joins("JOIN order_items ON order_items.fulfilled_ref_id = membership_cards.id AND order_items.item_type = ?", models.OrderItemCard).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("membership_cards.user_id = ? AND membership_cards.status <> ? AND membership_cards.start_date <= ?",
+			userID, models.CardStatusRefunded, day).
+		Where("orders.status = ? AND order_items.amount > 0", models.OrderStatusPaid).
+		Order("membership_cards.start_date, membership_cards.id").Limit(1).Find(&cards).Error
+	if err != nil || len(cards) == 0 {
+		return nil, err
+	}
+	return &cards[0], nil
+}
+
+// ActiveTimeCard returns the member's time card in use on day that runs the
+// longest, or nil if there is none. Frozen cards and count cards are skipped.
+func (r *referralRepository) ActiveTimeCard(ctx context.Context, userID int64, day time.Time) (*models.MembershipCard, error) {
+	var cards []models.MembershipCard
+	err := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Joins("JOIN card_types ON card_types.id = membership_cards.card_type_id").
+		Where("membership_cards.user_id = ? AND membership_cards.status = ? AND membership_cards.is_frozen = 0", userID, models.CardStatusNormal).
+		Where("membership_cards.start_date <= ? AND membership_cards.end_date >= ?", day, day).
+		Where("card_types.duration_type <> 5"). // 次卡
+		Order("membership_cards.end_date DESC, membership_cards.id").
+		Limit(1).
+		Find(&cards).Error
+	if err != nil || len(cards) == 0 {
+		return nil, err
+	}
+	return &cards[0], nil
+}
+
+// MarkRewarded records the reward of a pending referral, handed out by the
+// caller in the same unit of work. A referral already rewarded gives
+// ErrReferralRewarded, so a reward is never given twice.
+func (r *referralRepository) MarkRewarded(ctx context.Context, referral *models.Referral) error {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.Referral{}).
+		Where("id = ? AND status = ?", referral.ID, models.ReferralStatusPending).
+		Updates(map[string]interface{}{
+			"status":         models.ReferralStatusRewarded,
+			"card_id":        referral.CardID,
+			"rule_id":        referral.RuleID,
+			"reward_type":    referral.RewardType,
+			"reward_days":    referral.RewardDays,
+			"reward_card_id": referral.RewardCardID,
+			"coupon_id":      referral.CouponID,
+			"rewarded_at":    now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrReferralRewarded
+	}
+	referral.Status = models.ReferralStatusRewarded
+	referral.RewardedAt = &now
+	return nil
+}
+
+// GetRewardedByCard returns the rewarded referral that the card qualified
+// for, or nil if there is none
+func (r *referralRepository) GetRewardedByCard(ctx context.Context, cardID int64) (*models.Referral, error) {
+	var referrals []models.Referral
+	err := r.db.WithContext(ctx).Where("card_id = ? AND status = ?", cardID, models.ReferralStatusRewarded).
+		Limit(1).Find(&referrals).Error
+	if err != nil || len(referrals) == 0 {
+		return nil, err
+	}
+	return &referrals[0], nil
+}
+
+// MarkRevoked records that the reward of a referral was taken back, returning
+// false when it was not rewarded. The reward itself is taken back by the
+// caller in the same unit of work.
+func (r *referralRepository) MarkRevoked(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Referral{}).
+		Where("id = ? AND status = ?", id, models.ReferralStatusRewarded).
+		Update("status", models.ReferralStatusRevoked)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountBetween counts the referrals made and the rewards given over [from, to).
+// Rewards taken back since do not count, here or in the sums below.
+func (r *referralRepository) CountBetween(ctx context.Context, from, to time.Time) (int64, int64, error) {
+	var referred, rewarded int64
+	if err := r.db.WithContext(ctx).Model(&models.Referral{}).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&referred).Error; err != nil {
+		return 0, 0, err
+	}
+	err := r.db.WithContext(ctx).Model(&models.Referral{}).
+		Where("status = ? AND rewarded_at >= ? AND rewarded_at < ?", models.ReferralStatusRewarded, from, to).
+		Count(&rewarded).Error
+	return referred, rewarded, err
+}
+
+// RewardsBetween sums the card days and counts the coupons given over [from, to)
+func (r *referralRepository) RewardsBetween(ctx context.Context, from, to time.Time) (int64, int64, error) {
+	var totals struct {
+		Days    int64
+		Coupons int64
+	}
+	err := r.db.WithContext(ctx).Model(&models.Referral{}).
+		Select("COALESCE(SUM(reward_days), 0) AS days, COUNT(coupon_id) AS coupons").
+		Where("status = ? AND rewarded_at >= ? AND rewarded_at < ?", models.ReferralStatusRewarded, from, to).
+		Scan(&totals).Error
+	return totals.Days, totals.Coupons, err
+}
+
+// TopReferrers ranks referrers by the members they brought in over [from, to)
+func (r *referralRepository) TopReferrers(ctx context.Context, from, to time.Time, limit int) ([]ReferrerStats, error) {
+	var stats []ReferrerStats
+	err := r.db.WithContext(ctx).Model(&models.Referral{}).
+		Select("referrer_id, COUNT(*) AS referred, "+rewardSums, rewardSumArgs()...).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("referrer_id").
+		Order("referred DESC, rewarded DESC, referrer_id").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
+
+// ReferrerSummary sums up all referrals of one referrer
+func (r *referralRepository) ReferrerSummary(ctx context.Context, referrerID int64) (*ReferrerStats, error) {
+	stats := ReferrerStats{ReferrerID: referrerID}
+	err := r.db.WithContext(ctx).Model(&models.Referral{}).
+		Select("COUNT(*) AS referred, "+rewardSums, rewardSumArgs()...).
+		Where("referrer_id = ?", referrerID).
+		Scan(&stats).Error
+	return &stats, err
+}
+
+// rewardSums totals the rewards of referrals still rewarded
+const rewardSums = "COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) AS rewarded, " +
+	"COALESCE(SUM(CASE WHEN status = ? THEN reward_days ELSE 0 END), 0) AS reward_days, " +
+	"COUNT(CASE WHEN status = ? THEN coupon_id END) AS coupons"
+
+func rewardSumArgs() []interface{} {
+	return []interface{}{models.ReferralStatusRewarded, models.ReferralStatusRewarded, models.ReferralStatusRewarded}
+}
+
+func (r *referralRepository) CreateRule(ctx context.Context, rule *models.ReferralRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *referralRepository) GetRule(ctx context.Context, id int64) (*models.ReferralRule, error) {
+	var rule models.ReferralRule
+	err := r.db.WithContext(ctx).First(&rule, id).Error
+	return &rule, err
+}
+
+func (r *referralRepository) UpdateRule(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&models.ReferralRule{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *referralRepository) ListRules(ctx context.Context, status *int8) ([]models.ReferralRule, error) {
+	var rules []models.ReferralRule
+	query := r.db.WithContext(ctx).Model(&models.ReferralRule{})
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	err := query.Order("id DESC").Find(&rules).Error
+	return rules, err
+}
+
+// CurrentRule returns the most recently created enabled rule, or nil if
+// referrals are not rewarded at the moment
+func (r *referralRepository) CurrentRule(ctx context.Context) (*models.ReferralRule, error) {
+	var rules []models.ReferralRule
+	err := r.db.WithContext(ctx).Where("status = ?", models.ReferralRuleEnabled).Order("id DESC").Limit(1).Find(&rules).Error
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	return &rules[0], nil
+}
@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/listquery"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RefundRepository interface {
+	Create(ctx context.Context, refund *models.Refund) error
+	GetByID(ctx context.Context, id int64) (*models.Refund, error)
+	ListByOrder(ctx context.Context, orderID int64) ([]models.Refund, error)
+	List(ctx context.Context, q listquery.Query) ([]models.Refund, listquery.Page, error)
+	ListProcessing(ctx context.Context, limit int) ([]models.Refund, error)
+	GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error)
+	GetSuccessfulPayment(ctx context.Context, orderID int64) (*models.Payment, error)
+	GetLessonPackage(ctx context.Context, id int64) (*models.LessonPackage, error)
+	Approve(ctx context.Context, id, approverID int64) (bool, error)
+	Reject(ctx context.Context, id, approverID int64, reason string) (bool, error)
+	MarkFailed(ctx context.Context, id int64, reason string) (bool, error)
+	Retry(ctx context.Context, id int64) (bool, error)
+	SetGatewayRefundNo(ctx context.Context, id int64, gatewayRefundNo string) error
+	Complete(ctx context.Context, id int64, gatewayRefundNo string, refundedAt time.Time) (bool, error)
+	RemoveSessions(ctx context.Context, packageID int64, sessions int) error
+}
+
+type refundRepository struct {
+	db *gorm.DB
+}
+
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
+// Create saves a refund request. Its amount is reserved on the order with
+// OrderRepository.ReserveRefund in the same unit of work.
+func (r *refundRepository) Create(ctx context.Context, refund *models.Refund) error {
+	return r.db.WithContext(ctx).Create(refund).Error
+}
+
+func (r *refundRepository) GetByID(ctx context.Context, id int64) (*models.Refund, error) {
+	var refund models.Refund
+	err := r.db.WithContext(ctx).First(&refund, id).Error
+	return &refund, err
+}
+
+func (r *refundRepository) ListByOrder(ctx context.Context, orderID int64) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderID).Order("created_at").Find(&refunds).Error
+	return refunds, err
+}
+
+// RefundListSpec is what the refund list can be filtered, sorted and selected by
+var RefundListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":       {Column: "status", Kind: listquery.Int, Ops: []listquery.Op{listquery.In}},
+		"order_id":     {Column: "order_id", Kind: listquery.Int},
+		"requested_by": {Column: "requested_by", Kind: listquery.Int},
+		"amount":       {Column: "amount", Kind: listquery.Float, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"created_at":   {Column: "created_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+	},
+	Sorts: map[string]string{"created_at": "created_at", "amount": "amount"},
+	Fields: []string{"refund_no", "order_id", "payment_id", "order_item_id", "sessions", "amount", "reason",
+		"status", "requested_by", "approved_by", "approved_at", "reject_reason", "gateway_refund_no",
+		"fail_reason", "refunded_at", "created_at", "updated_at"},
+	DefaultSort: "-created_at",
+}
+
+func (r *refundRepository) List(ctx context.Context, q listquery.Query) ([]models.Refund, listquery.Page, error) {
+	var refunds []models.Refund
+	page, err := listquery.Find(r.db.WithContext(ctx).Model(&models.Refund{}), q, &refunds)
+	return refunds, page, err
+}
+
+// ListProcessing returns the refunds still waiting on their payment channel
+func (r *refundRepository) ListProcessing(ctx context.Context, limit int) ([]models.Refund, error) {
+	var refunds []models.Refund
+	err := r.db.WithContext(ctx).Where("status = ?", models.RefundStatusProcessing).Order("updated_at").Limit(limit).Find(&refunds).Error
+	return refunds, err
+}
+
+func (r *refundRepository) GetPaymentByID(ctx context.Context, id int64) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).First(&payment, id).Error
+	return &payment, err
+}
+
+// GetSuccessfulPayment returns the payment that settled the order
+func (r *refundRepository) GetSuccessfulPayment(ctx context.Context, orderID int64) (*models.Payment, error) {
+	var payment models.Payment
+	err := r.db.WithContext(ctx).Where("order_id = ? AND status = ?", orderID, models.PaymentStatusSuccess).Order("paid_at").First(&payment).Error
+	return &payment, err
+}
+
+func (r *refundRepository) GetLessonPackage(ctx context.Context, id int64) (*models.LessonPackage, error) {
+	var pkg models.LessonPackage
+	err := r.db.WithContext(ctx).First(&pkg, id).Error
+	return &pkg, err
+}
+
+// Approve moves a refund awaiting approval to processing
+func (r *refundRepository) Approve(ctx context.Context, id, approverID int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Refund{}).
+		Where("id = ? AND status = ?", id, models.RefundStatusPendingApproval).
+		Updates(map[string]interface{}{
+			"status":      models.RefundStatusProcessing,
+			"approved_by": approverID,
+			"approved_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Reject turns a refund request down, returning false when it was no longer
+// awaiting approval. Its reserved amount is released by the caller in the
+// same unit of work.
+func (r *refundRepository) Reject(ctx context.Context, id, approverID int64, reason string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Refund{}).
+		Where("id = ? AND status = ?", id, models.RefundStatusPendingApproval).
+		Updates(map[string]interface{}{
+			"status":        models.RefundStatusRejected,
+			"approved_by":   approverID,
+			"approved_at":   time.Now(),
+			"reject_reason": reason,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// MarkFailed records that the channel refused the refund, returning false
+// when it was no longer processing
+func (r *refundRepository) MarkFailed(ctx context.Context, id int64, reason string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Refund{}).
+		Where("id = ? AND status = ?", id, models.RefundStatusProcessing).
+		Updates(map[string]interface{}{"status": models.RefundStatusFailed, "fail_reason": reason})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Retry moves a failed refund back to processing, returning false when it
+// had not failed
+func (r *refundRepository) Retry(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Refund{}).
+		Where("id = ? AND status = ?", id, models.RefundStatusFailed).
+		Updates(map[string]interface{}{"status": models.RefundStatusProcessing, "fail_reason": ""})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *refundRepository) SetGatewayRefundNo(ctx context.Context, id int64, gatewayRefundNo string) error {
+	return r.db.WithContext(ctx).Model(&models.Refund{}).Where("id = ?", id).
+		Update("gateway_refund_no", gatewayRefundNo).Error
+}
+
+// Complete records a successful refund. It is idempotent and returns false
+// when the refund had already been completed; the amount is settled on the
+// order and the fulfilment reversed by the caller in the same unit of work.
+func (r *refundRepository) Complete(ctx context.Context, id int64, gatewayRefundNo string, refundedAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Refund{}).
+		Where("id = ? AND status = ?", id, models.RefundStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":            models.RefundStatusSuccess,
+			"gateway_refund_no": gatewayRefundNo,
+			"refunded_at":       refundedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// RemoveSessions takes refunded sessions out of a lesson package, zero taking
+// all that are left. A package left without sessions is marked refunded, so
+// it runs in the unit of work completing the refund.
+func (r *refundRepository) RemoveSessions(ctx context.Context, packageID int64, sessions int) error {
+	db := r.db.WithContext(ctx)
+	remaining := gorm.Expr("0")
+	if sessions > 0 {
+		remaining = gorm.Expr("CASE WHEN remaining_sessions > ? THEN remaining_sessions - ? ELSE 0 END", sessions, sessions)
+	}
+	if err := db.Model(&models.LessonPackage{}).Where("id = ?", packageID).
+		Update("remaining_sessions", remaining).Error; err != nil {
+		return err
+	}
+	return db.Model(&models.LessonPackage{}).
+		Where("id = ? AND remaining_sessions = 0 AND status = ?", packageID, models.LessonPackageActive).
+		Update("status", models.LessonPackageRefunded).Error
+}
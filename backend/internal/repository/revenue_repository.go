@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type RevenueRepository interface {
+	CardTypes(ctx context.Context) ([]models.CardType, error)
+	EachCardSoldBefore(ctx context.Context, before time.Time, batchSize int, fn func([]models.MembershipCard) error) error
+	FreezeRecords(ctx context.Context, cardIDs []int64) ([]models.CardFreezeRecord, error)
+	OperationLogs(ctx context.Context, cardIDs []int64, operationTypes []int8) ([]models.CardOperationLog, error)
+	CountVisits(ctx context.Context, cardIDs []int64, from, to time.Time) ([]CardVisitCount, error)
+}
+
+type revenueRepository struct {
+	db *gorm.DB
+}
+
+func NewRevenueRepository(db *gorm.DB) RevenueRepository {
+	return &revenueRepository{db: db}
+}
+
+func (r *revenueRepository) CardTypes(ctx context.Context) ([]models.CardType, error) {
+	var cardTypes []models.CardType
+	err := r.db.WithContext(ctx).Find(&cardTypes).Error
+	return cardTypes, err
+}
+
+// EachCardSoldBefore streams the cards sold before the given time, batchSize at a time
+func (r *revenueRepository) EachCardSoldBefore(ctx context.Context, before time.Time, batchSize int, fn func([]models.MembershipCard) error) error {
+	var batch []models.MembershipCard
+	return r.db.WithContext(ctx).Where("created_at < ? OR start_date < ?", before, before).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
+
+func (r *revenueRepository) FreezeRecords(ctx context.Context, cardIDs []int64) ([]models.CardFreezeRecord, error) {
+	var records []models.CardFreezeRecord
+	err := r.db.WithContext(ctx).Where("card_id IN ?", cardIDs).Order("freeze_start_date").Find(&records).Error
+	return records, err
+}
+
+func (r *revenueRepository) OperationLogs(ctx context.Context, cardIDs []int64, operationTypes []int8) ([]models.CardOperationLog, error) {
+	var logs []models.CardOperationLog
+	err := r.db.WithContext(ctx).Where("card_id IN ? AND operation_type IN ?", cardIDs, operationTypes).
+		Order("created_at").Find(&logs).Error
+	return logs, err
+}
+
+type CardVisitCount struct {
+	CardID int64
+	Visits int
+}
+
+// CountVisits counts the check-ins made with each of the cards in [from, to)
+func (r *revenueRepository) CountVisits(ctx context.Context, cardIDs []int64, from, to time.Time) ([]CardVisitCount, error) {
+	var counts []CardVisitCount
+	query := r.db.WithContext(ctx).Model(&models.CheckIn{}).
+		Select("card_id, COUNT(*) AS visits").
+		Where("card_id IN ? AND check_in_time < ?", cardIDs, to)
+	if !from.IsZero() {
+		query = query.Where("check_in_time >= ?", from)
+	}
+	err := query.Group("card_id").Scan(&counts).Error
+	return counts, err
+}
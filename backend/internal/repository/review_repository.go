@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/listquery"
+
+	"gorm.io/gorm"
+)
+
+type ReviewRepository interface {
+	Create(ctx context.Context, review *models.CourseReview) error
+	GetByID(ctx context.Context, id int64) (*models.CourseReview, error)
+	ExistsByBookingID(ctx context.Context, bookingID int64) (bool, error)
+	List(ctx context.Context, q listquery.Query) ([]models.CourseReview, listquery.Page, error)
+	ListVisibleByCoach(ctx context.Context, coachID int64, q listquery.Query) ([]models.CourseReview, listquery.Page, error)
+	Update(ctx context.Context, review *models.CourseReview) error
+	CoachRatingSummary(ctx context.Context, coachID int64) (*RatingSummary, error)
+}
+
+type reviewRepository struct {
+	db *gorm.DB
+}
+
+var reviewColumns = []string{"booking_id", "user_id", "course_id", "coach_id", "course_rating",
+	"coach_rating", "comment", "status", "hidden_by", "hidden_at", "hidden_reason", "created_at", "updated_at"}
+
+var reviewSorts = map[string]string{"created_at": "created_at", "course_rating": "course_rating", "coach_rating": "coach_rating"}
+
+// ReviewListSpec is what the moderation list of reviews can be filtered,
+// sorted and selected by
+var ReviewListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"coach_id":      {Column: "coach_id", Kind: listquery.Int},
+		"course_id":     {Column: "course_id", Kind: listquery.Int},
+		"user_id":       {Column: "user_id", Kind: listquery.Int},
+		"status":        {Column: "status", Kind: listquery.Int},
+		"coach_rating":  {Column: "coach_rating", Kind: listquery.Int, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"course_rating": {Column: "course_rating", Kind: listquery.Int, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"created_at":    {Column: "created_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+	},
+	Sorts:       reviewSorts,
+	Fields:      reviewColumns,
+	DefaultSort: "-created_at",
+}
+
+// CoachReviewSpec is what the public reviews of a coach can be filtered,
+// sorted and selected by
+var CoachReviewSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"course_id":    {Column: "course_id", Kind: listquery.Int},
+		"coach_rating": {Column: "coach_rating", Kind: listquery.Int, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+	},
+	Sorts:       reviewSorts,
+	Fields:      reviewColumns,
+	DefaultSort: "-created_at",
+}
+
+type RatingSummary struct {
+	Average float64
+	Count   int
+}
+
+func NewReviewRepository(db *gorm.DB) ReviewRepository {
+	return &reviewRepository{db: db}
+}
+
+func (r *reviewRepository) Create(ctx context.Context, review *models.CourseReview) error {
+	return r.db.WithContext(ctx).Create(review).Error
+}
+
+func (r *reviewRepository) GetByID(ctx context.Context, id int64) (*models.CourseReview, error) {
+	var review models.CourseReview
+	err := r.db.WithContext(ctx).First(&review, id).Error
+	return &review, err
+}
+
+func (r *reviewRepository) ExistsByBookingID(ctx context.Context, bookingID int64) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.CourseReview{}).Where("booking_id = ?", bookingID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *reviewRepository) List(ctx context.Context, q listquery.Query) ([]models.CourseReview, listquery.Page, error) {
+	var reviews []models.CourseReview
+	page, err := listquery.Find(r.db.WithContext(ctx).Model(&models.CourseReview{}), q, &reviews)
+	return reviews, page, err
+}
+
+func (r *reviewRepository) ListVisibleByCoach(ctx context.Context, coachID int64, q listquery.Query) ([]models.CourseReview, listquery.Page, error) {
+	var reviews []models.CourseReview
+	query := r.db.WithContext(ctx).Model(&models.CourseReview{}).Where("coach_id = ? AND status = 1", coachID)
+	page, err := listquery.Find(query, q, &reviews)
+	return reviews, page, err
+}
+
+func (r *reviewRepository) Update(ctx context.Context, review *models.CourseReview) error {
+	return r.db.WithContext(ctx).Save(review).Error
+}
+
+// CoachRatingSummary aggregates the visible reviews of a coach
+func (r *reviewRepository) CoachRatingSummary(ctx context.Context, coachID int64) (*RatingSummary, error) {
+	var summary RatingSummary
+	err := r.db.WithContext(ctx).Model(&models.CourseReview{}).
+		Select("COALESCE(AVG(coach_rating), 0) AS average, COUNT(*) AS count").
+		Where("coach_id = ? AND status = ?", coachID, 1).
+		Scan(&summary).Error
+	return &summary, err
+}
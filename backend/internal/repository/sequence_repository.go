@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SequenceRepository interface {
+	Next(ctx context.Context, name string) (int64, error)
+}
+
+type sequenceRepository struct {
+	db *gorm.DB
+}
+
+func NewSequenceRepository(db *gorm.DB) SequenceRepository {
+	return &sequenceRepository{db: db}
+}
+
+// Next atomically increments the named sequence and returns the new value.
+// The UPDATE takes a row lock, so concurrent callers always get distinct values.
+func (r *sequenceRepository) Next(ctx context.Context, name string) (int64, error) {
+	var value int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		seq := models.Sequence{Name: name}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Sequence{}).Where("name = ?", name).
+			Update("value", gorm.Expr("value + 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Sequence{}).Where("name = ?", name).Pluck("value", &value).Error
+	})
+	return value, err
+}
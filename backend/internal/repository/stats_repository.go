@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type StatsRepository interface {
+	CountCheckIns(ctx context.Context, from, to time.Time) (int64, error)
+	CountDistinctCheckInUsers(ctx context.Context, from, to time.Time) (int64, error)
+	CountNewUsers(ctx context.Context, from, to time.Time) (int64, error)
+	CardSales(ctx context.Context, from, to time.Time) (*CardSales, error)
+	CountBookings(ctx context.Context, from, to time.Time) (int64, error)
+	CountVoucherRedemptions(ctx context.Context, from, to time.Time) (int64, error)
+	CountCardsEnding(ctx context.Context, from, to time.Time) (int64, error)
+}
+
+type statsRepository struct {
+	db *gorm.DB
+}
+
+func NewStatsRepository(db *gorm.DB) StatsRepository {
+	return &statsRepository{db: db}
+}
+
+// CountCheckIns counts check-ins in [from, to)
+func (r *statsRepository) CountCheckIns(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.CheckIn{}).
+		Where("check_in_time >= ? AND check_in_time < ?", from, to).
+		Count(&count).Error
+	return count, err
+}
+
+// CountDistinctCheckInUsers counts the members who checked in during [from, to)
+func (r *statsRepository) CountDistinctCheckInUsers(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.CheckIn{}).
+		Where("check_in_time >= ? AND check_in_time < ?", from, to).
+		Distinct("user_id").
+		Count(&count).Error
+	return count, err
+}
+
+// CountNewUsers counts users registered in [from, to)
+func (r *statsRepository) CountNewUsers(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Count(&count).Error
+	return count, err
+}
+
+type CardSales struct {
+	Count   int64
+	Revenue float64
+}
+
+// CardSales sums the cards opened in [from, to) and their purchase prices
+func (r *statsRepository) CardSales(ctx context.Context, from, to time.Time) (*CardSales, error) {
+	var sales CardSales
+	err := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Select("COUNT(*) AS count, COALESCE(SUM(purchase_price), 0) AS revenue").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Scan(&sales).Error
+	return &sales, err
+}
+
+// CountBookings counts bookings made in [from, to)
+func (r *statsRepository) CountBookings(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Booking{}).
+		Where("booked_at >= ? AND booked_at < ?", from, to).
+		Count(&count).Error
+	return count, err
+}
+
+// CountVoucherRedemptions counts vouchers verified in [from, to)
+func (r *statsRepository) CountVoucherRedemptions(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.VoucherRecord{}).
+		Where("status = ? AND verified_at >= ? AND verified_at < ?", 2, from, to).
+		Count(&count).Error
+	return count, err
+}
+
+// CountCardsEnding counts normal cards whose end date falls in [from, to)
+func (r *statsRepository) CountCardsEnding(ctx context.Context, from, to time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.MembershipCard{}).
+		Where("status IN ? AND end_date >= ? AND end_date < ?", []int8{1, 2}, from.Format("2006-01-02"), to.Format("2006-01-02")).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// Repositories is the set of repositories bound to one database handle. A
+// unit of work gets a set bound to its transaction.
+type Repositories struct {
+	Users      UserRepository
+	Cards      CardRepository
+	Coaches    CoachRepository
+	Courses    CourseRepository
+	Sequences  SequenceRepository
+	Orders     OrderRepository
+	Refunds    RefundRepository
+	Promotions PromotionRepository
+	Wallets    WalletRepository
+	Points     PointsRepository
+	Referrals  ReferralRepository
+	CheckIns   CheckInRepository
+	Vouchers   VoucherRepository
+	Reviews    ReviewRepository
+	Stats      StatsRepository
+	Analytics  AnalyticsRepository
+	Occupancy  OccupancyRepository
+	Revenue    RevenueRepository
+}
+
+func NewRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		Users:      NewUserRepository(db),
+		Cards:      NewCardRepository(db),
+		Coaches:    NewCoachRepository(db),
+		Courses:    NewCourseRepository(db),
+		Sequences:  NewSequenceRepository(db),
+		Orders:     NewOrderRepository(db),
+		Refunds:    NewRefundRepository(db),
+		Promotions: NewPromotionRepository(db),
+		Wallets:    NewWalletRepository(db),
+		Points:     NewPointsRepository(db),
+		Referrals:  NewReferralRepository(db),
+		CheckIns:   NewCheckInRepository(db),
+		Vouchers:   NewVoucherRepository(db),
+		Reviews:    NewReviewRepository(db),
+		Stats:      NewStatsRepository(db),
+		Analytics:  NewAnalyticsRepository(db),
+		Occupancy:  NewOccupancyRepository(db),
+		Revenue:    NewRevenueRepository(db),
+	}
+}
+
+// TxManager runs units of work that write several tables atomically
+type TxManager interface {
+	// Do runs fn in a transaction with repositories bound to it, committing
+	// if fn returns nil. Called with the ctx of a running unit of work, fn
+	// joins that transaction instead of opening its own. A transaction
+	// aborted by a deadlock is retried, so fn may run more than once and
+	// must not have effects outside the database.
+	Do(ctx context.Context, fn func(ctx context.Context, repos *Repositories) error) error
+}
+
+const (
+	txMaxAttempts = 3
+	txRetryDelay  = 20 * time.Millisecond
+)
+
+type txKey struct{}
+
+// txState is what a running unit of work stores in its context
+type txState struct {
+	repos *Repositories
+}
+
+type txManager struct {
+	db *gorm.DB
+}
+
+func NewTxManager(db *gorm.DB) TxManager {
+	return &txManager{db: db}
+}
+
+func (m *txManager) Do(ctx context.Context, fn func(ctx context.Context, repos *Repositories) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return fn(ctx, state.repos)
+	}
+
+	var err error
+	for attempt := 1; attempt <= txMaxAttempts; attempt++ {
+		err = m.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			state := &txState{repos: NewRepositories(tx)}
+			return fn(context.WithValue(ctx, txKey{}, state), state.repos)
+		})
+		if !isRetryableTxError(err) || attempt == txMaxAttempts {
+			break
+		}
+
+		// Back off with jitter so that the two sides of a deadlock do not
+		// collide again straight away
+		delay := time.Duration(attempt)*txRetryDelay + time.Duration(rand.Int63n(int64(txRetryDelay)))
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+	return err
+}
+
+// isRetryableTxError reports whether MySQL rolled the transaction back
+// because of a deadlock or a lock wait timeout
+func isRetryableTxError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	switch mysqlErr.Number {
+	case 1213, // ER_LOCK_DEADLOCK
+		1205: // ER_LOCK_WAIT_TIMEOUT
+		return true
+	}
+	return false
+}
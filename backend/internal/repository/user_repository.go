@@ -1,71 +1,319 @@
 package repository
 
 import (
+	"context"
 	"gym-admin/internal/models"
-	"gym-admin/pkg/database"
+	"gym-admin/pkg/listquery"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-type UserRepository struct {
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int64) (*models.User, error)
+	GetByIDs(ctx context.Context, ids []int64) ([]models.User, error)
+	GetByPhone(ctx context.Context, phone string) (*models.User, error)
+	GetByReferralCode(ctx context.Context, code string) (*models.User, error)
+	ReferralCodeExists(ctx context.Context, code string) (bool, error)
+	SetReferralCode(ctx context.Context, id int64, code string) (bool, error)
+	List(ctx context.Context, q listquery.Query) ([]models.User, listquery.Page, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id int64) error
+	GetStats(ctx context.Context, userID int64) (*models.UserTrainingStats, error)
+	UpdateStatus(ctx context.Context, userID int64, change *models.UserStatusChange) error
+	UpdateRole(ctx context.Context, userID int64, role string) error
+	UpdatePassword(ctx context.Context, userID int64, passwordHash string) error
+	ListStatusChanges(ctx context.Context, userID int64) ([]models.UserStatusChange, error)
+	Search(ctx context.Context, filter UserSearchFilter, q listquery.Query) ([]models.User, listquery.Page, error)
+	UpdateNamePinyin(ctx context.Context, id int64, namePinyin, nameInitials string) error
+	FindWithoutPinyin(ctx context.Context, afterID int64, limit int) ([]models.User, error)
+	ExistingPhones(ctx context.Context, phones []string) (map[string]bool, error)
+	EachBatch(ctx context.Context, filter UserSearchFilter, batchSize int, fn func([]models.User) error) error
+}
+
+type userRepository struct {
 	db *gorm.DB
 }
 
-func NewUserRepository() *UserRepository {
-	return &UserRepository{db: database.GetDB()}
+// UserSearchFilter holds the front desk search criteria. Keyword is matched
+// against name, pinyin, phone suffix, UserNo and card number.
+type UserSearchFilter struct {
+	Keyword        string
+	Source         *int8
+	Gender         *int8
+	Status         *int8
+	RegisteredFrom *time.Time
+	RegisteredTo   *time.Time
+	HasActiveCard  *bool
+}
+
+// userColumns can be selected with fields on the user lists
+var userColumns = []string{"user_no", "name", "gender", "birthday", "id_card", "phone", "email",
+	"avatar_url", "address", "emergency_contact", "emergency_phone", "health_status", "training_goal",
+	"source", "referral_code", "referrer_id", "status", "remark", "created_at", "updated_at"}
+
+var userSorts = map[string]string{"created_at": "created_at", "name": "name_pinyin", "user_no": "user_no"}
+
+// UserListSpec is what the member list can be filtered, sorted and selected by
+var UserListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":     {Column: "status", Kind: listquery.Int, Ops: []listquery.Op{listquery.Ne, listquery.In}},
+		"gender":     {Column: "gender", Kind: listquery.Int},
+		"source":     {Column: "source", Kind: listquery.Int, Ops: []listquery.Op{listquery.In}},
+		"created_at": {Column: "created_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+		"name":       {Column: "name", Kind: listquery.String, Ops: []listquery.Op{listquery.Like}},
+	},
+	Sorts:       userSorts,
+	Fields:      userColumns,
+	DefaultSort: "-created_at",
+}
+
+// UserSearchSpec sorts and selects search results. Searches are filtered by
+// UserSearchFilter instead.
+var UserSearchSpec = listquery.Spec{
+	Sorts:       userSorts,
+	Fields:      userColumns,
+	DefaultSort: "-created_at",
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &userRepository{db: db}
 }
 
-func (r *UserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
 }
 
-func (r *UserRepository) GetByID(id int64) (*models.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := r.db.WithContext(ctx).First(&user, id).Error
 	return &user, err
 }
 
-func (r *UserRepository) GetByPhone(phone string) (*models.User, error) {
+func (r *userRepository) GetByIDs(ctx context.Context, ids []int64) ([]models.User, error) {
+	var users []models.User
+	if len(ids) == 0 {
+		return users, nil
+	}
+	err := r.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error
+	return users, err
+}
+
+func (r *userRepository) GetByPhone(ctx context.Context, phone string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("phone = ?", phone).First(&user).Error
+	err := r.db.WithContext(ctx).Where("phone = ?", phone).First(&user).Error
 	return &user, err
 }
 
-func (r *UserRepository) List(page, pageSize int, status *int8) ([]models.User, int64, error) {
-	var users []models.User
-	var total int64
+func (r *userRepository) GetByReferralCode(ctx context.Context, code string) (*models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("referral_code = ?", code).First(&user).Error
+	return &user, err
+}
 
-	query := r.db.Model(&models.User{})
-	if status != nil {
-		query = query.Where("status = ?", *status)
-	}
+func (r *userRepository) ReferralCodeExists(ctx context.Context, code string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Unscoped().Model(&models.User{}).Where("referral_code = ?", code).Count(&count).Error
+	return count > 0, err
+}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
-	}
+// SetReferralCode gives a member without a referral code one. It reports
+// false if the member got a code in the meantime.
+func (r *userRepository) SetReferralCode(ctx context.Context, id int64, code string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).Where("id = ? AND referral_code IS NULL", id).Update("referral_code", code)
+	return result.RowsAffected > 0, result.Error
+}
 
-	offset := (page - 1) * pageSize
-	err := query.Offset(offset).Limit(pageSize).Order("created_at DESC").Find(&users).Error
-	return users, total, err
+func (r *userRepository) List(ctx context.Context, q listquery.Query) ([]models.User, listquery.Page, error) {
+	var users []models.User
+	page, err := listquery.Find(r.db.WithContext(ctx).Model(&models.User{}), q, &users)
+	return users, page, err
 }
 
-func (r *UserRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+func (r *userRepository) Update(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Save(user).Error
 }
 
-func (r *UserRepository) Delete(id int64) error {
-	return r.db.Delete(&models.User{}, id).Error
+func (r *userRepository) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Delete(&models.User{}, id).Error
 }
 
-func (r *UserRepository) GetStats(userID int64) (*models.UserTrainingStats, error) {
+func (r *userRepository) GetStats(ctx context.Context, userID int64) (*models.UserTrainingStats, error) {
 	var stats models.UserTrainingStats
-	err := r.db.Where("user_id = ?", userID).First(&stats).Error
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&stats).Error
 	if err == gorm.ErrRecordNotFound {
 		// Create new stats if not exists
 		stats = models.UserTrainingStats{UserID: userID}
-		if err := r.db.Create(&stats).Error; err != nil {
+		if err := r.db.WithContext(ctx).Create(&stats).Error; err != nil {
 			return nil, err
 		}
+		return &stats, nil
 	}
 	return &stats, err
 }
+
+// UpdateStatus changes the user status and records the change in one transaction
+func (r *userRepository) UpdateStatus(ctx context.Context, userID int64, change *models.UserStatusChange) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND status = ?", userID, change.OldStatus).
+			Update("status", change.NewStatus)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Create(change).Error
+	})
+}
+
+func (r *userRepository) ListStatusChanges(ctx context.Context, userID int64) ([]models.UserStatusChange, error) {
+	var changes []models.UserStatusChange
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&changes).Error
+	return changes, err
+}
+
+// activeCardCondition matches users holding a normal, unexpired membership card
+const activeCardCondition = `EXISTS (SELECT 1 FROM membership_cards mc WHERE mc.user_id = users.id
+	AND mc.status = 1 AND mc.end_date >= ? AND mc.deleted_at IS NULL)`
+
+// cardNoCondition matches users owning the card with the given number
+const cardNoCondition = `EXISTS (SELECT 1 FROM membership_cards c WHERE c.user_id = users.id
+	AND c.card_no = ? AND c.deleted_at IS NULL)`
+
+// userSearchScope applies the filters and keyword matching of a search, without ordering
+func userSearchScope(filter UserSearchFilter) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		if filter.Source != nil {
+			query = query.Where("source = ?", *filter.Source)
+		}
+		if filter.Gender != nil {
+			query = query.Where("gender = ?", *filter.Gender)
+		}
+		if filter.Status != nil {
+			query = query.Where("status = ?", *filter.Status)
+		}
+		if filter.RegisteredFrom != nil {
+			query = query.Where("created_at >= ?", *filter.RegisteredFrom)
+		}
+		if filter.RegisteredTo != nil {
+			query = query.Where("created_at < ?", *filter.RegisteredTo)
+		}
+		if filter.HasActiveCard != nil {
+			today := time.Now().Format("2006-01-02")
+			if *filter.HasActiveCard {
+				query = query.Where(activeCardCondition, today)
+			} else {
+				query = query.Where("NOT "+activeCardCondition, today)
+			}
+		}
+
+		if keyword := strings.TrimSpace(filter.Keyword); keyword != "" {
+			like := escapeLike(keyword)
+			lower := strings.ToLower(like)
+			query = query.Where(
+				query.Session(&gorm.Session{NewDB: true}).Where("name LIKE ?", "%"+like+"%").
+					Or("phone LIKE ?", "%"+like).
+					Or("user_no = ?", keyword).
+					Or("name_initials LIKE ?", lower+"%").
+					Or("name_pinyin LIKE ?", lower+"%").
+					Or(cardNoCondition, keyword),
+			)
+		}
+		return query
+	}
+}
+
+// Search finds users by keyword and filters. Exact matches on name, phone,
+// UserNo or card number rank first, then prefix matches, then the rest, each
+// in the order of q.
+func (r *userRepository) Search(ctx context.Context, filter UserSearchFilter, q listquery.Query) ([]models.User, listquery.Page, error) {
+	query := r.db.WithContext(ctx).Model(&models.User{}).Scopes(userSearchScope(filter))
+
+	keyword := strings.TrimSpace(filter.Keyword)
+	if keyword != "" {
+		like := escapeLike(keyword)
+		lower := strings.ToLower(like)
+		var err error
+		q, err = q.OrderFirst(clause.Expr{
+			SQL: `CASE
+				WHEN name = ? OR phone = ? OR user_no = ? OR ` + cardNoCondition + ` THEN 0
+				WHEN name LIKE ? OR name_initials = ? OR name_pinyin = ? THEN 1
+				WHEN phone LIKE ? THEN 2
+				ELSE 3 END`,
+			Vars: []interface{}{
+				keyword, keyword, keyword, keyword,
+				like + "%", lower, lower,
+				"%" + like,
+			},
+			WithoutParentheses: true,
+		})
+		if err != nil {
+			return nil, listquery.Page{}, err
+		}
+	}
+
+	var users []models.User
+	page, err := listquery.Find(query, q, &users)
+	return users, page, err
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, userID int64, role string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("role", role).Error
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", userID).Update("password_hash", passwordHash).Error
+}
+
+// UpdateNamePinyin backfills the pinyin search columns without touching updated_at
+func (r *userRepository) UpdateNamePinyin(ctx context.Context, id int64, namePinyin, nameInitials string) error {
+	return r.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+		"name_pinyin":   namePinyin,
+		"name_initials": nameInitials,
+	}).Error
+}
+
+// FindWithoutPinyin returns a batch of users whose pinyin columns are empty
+func (r *userRepository) FindWithoutPinyin(ctx context.Context, afterID int64, limit int) ([]models.User, error) {
+	var users []models.User
+	err := r.db.WithContext(ctx).Where("id > ? AND name_pinyin = ?", afterID, "").Order("id").Limit(limit).Find(&users).Error
+	return users, err
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// ExistingPhones returns which of the given phones already belong to a user
+func (r *userRepository) ExistingPhones(ctx context.Context, phones []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	const chunkSize = 1000
+	for start := 0; start < len(phones); start += chunkSize {
+		end := start + chunkSize
+		if end > len(phones) {
+			end = len(phones)
+		}
+		var found []string
+		if err := r.db.WithContext(ctx).Model(&models.User{}).Where("phone IN ?", phones[start:end]).Pluck("phone", &found).Error; err != nil {
+			return nil, err
+		}
+		for _, phone := range found {
+			existing[phone] = true
+		}
+	}
+	return existing, nil
+}
+
+// EachBatch streams the users matching filter in primary key order, batchSize at a time
+func (r *userRepository) EachBatch(ctx context.Context, filter UserSearchFilter, batchSize int, fn func([]models.User) error) error {
+	var batch []models.User
+	return r.db.WithContext(ctx).Model(&models.User{}).Scopes(userSearchScope(filter)).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		}).Error
+}
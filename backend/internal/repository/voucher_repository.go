@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"gym-admin/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type VoucherRepository interface {
+	GetByCode(ctx context.Context, code string) (*models.VoucherRecord, error)
+	Create(ctx context.Context, voucher *models.VoucherRecord) error
+	MarkVerified(ctx context.Context, voucher *models.VoucherRecord) (bool, error)
+}
+
+type voucherRepository struct {
+	db *gorm.DB
+}
+
+func NewVoucherRepository(db *gorm.DB) VoucherRepository {
+	return &voucherRepository{db: db}
+}
+
+func (r *voucherRepository) GetByCode(ctx context.Context, code string) (*models.VoucherRecord, error) {
+	var voucher models.VoucherRecord
+	err := r.db.WithContext(ctx).Where("voucher_code = ?", code).First(&voucher).Error
+	return &voucher, err
+}
+
+// Create saves a voucher seen for the first time; the unique code makes a
+// second verification of the same voucher fail with gorm.ErrDuplicatedKey
+func (r *voucherRepository) Create(ctx context.Context, voucher *models.VoucherRecord) error {
+	return r.db.WithContext(ctx).Create(voucher).Error
+}
+
+// MarkVerified records the verification of a voucher synced from its
+// platform, returning false when it was verified in the meantime
+func (r *voucherRepository) MarkVerified(ctx context.Context, voucher *models.VoucherRecord) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.VoucherRecord{}).
+		Where("id = ? AND status = ?", voucher.ID, models.VoucherStatusUnverified).
+		Updates(map[string]interface{}{
+			"status":       models.VoucherStatusVerified,
+			"user_id":      voucher.UserID,
+			"card_type_id": voucher.CardTypeID,
+			"verified_at":  voucher.VerifiedAt,
+			"verified_by":  voucher.VerifiedBy,
+			"remark":       voucher.Remark,
+		})
+	return result.RowsAffected > 0, result.Error
+}
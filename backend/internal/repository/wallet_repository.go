@@ -0,0 +1,372 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInsufficientBalance = apperr.Conflict("INSUFFICIENT_BALANCE", "insufficient wallet balance")
+
+type WalletRepository interface {
+	GetByUserID(ctx context.Context, userID int64) (*models.Wallet, error)
+	GetTransaction(ctx context.Context, txType int8, reference string) (*models.WalletTransaction, error)
+	ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.WalletTransaction, listquery.Page, error)
+	Spend(ctx context.Context, userID int64, amount float64, reference string, orderID, operatorID *int64, remark string) (*models.WalletTransaction, error)
+	RefundSpend(ctx context.Context, spendReference, reference string, amount float64) (*models.WalletTransaction, error)
+	TopUp(ctx context.Context, t *WalletTopUp) (int64, error)
+	DebitTopUp(ctx context.Context, t *TopUpRefund) error
+	Summarize(ctx context.Context, from, to time.Time) ([]WalletTypeSum, error)
+	BalancesAt(ctx context.Context, t time.Time) (float64, float64, error)
+	CreateTopUpRule(ctx context.Context, rule *models.TopUpRule) error
+	GetTopUpRule(ctx context.Context, id int64) (*models.TopUpRule, error)
+	UpdateTopUpRule(ctx context.Context, id int64, updates map[string]interface{}) error
+	ListTopUpRules(ctx context.Context, status *int8) ([]models.TopUpRule, error)
+	BestTopUpRule(ctx context.Context, amount float64, now time.Time) (*models.TopUpRule, error)
+}
+
+type walletRepository struct {
+	db *gorm.DB
+}
+
+func NewWalletRepository(db *gorm.DB) WalletRepository {
+	return &walletRepository{db: db}
+}
+
+// WalletTopUp credits a paid top-up and its bonus to the member's wallet
+type WalletTopUp struct {
+	UserID     int64
+	Amount     float64
+	Bonus      float64
+	Reference  string
+	OrderID    *int64
+	OperatorID *int64
+}
+
+// TopUpRefund takes a refunded top-up back out of the wallet together with
+// the share of its bonus. Bonus already spent is not clawed back.
+type TopUpRefund struct {
+	UserID    int64
+	Amount    float64
+	Bonus     float64
+	Reference string
+	OrderID   *int64
+}
+
+// WalletTypeSum is the total of one ledger entry type
+type WalletTypeSum struct {
+	Type      int8
+	Principal float64
+	Bonus     float64
+}
+
+func (r *walletRepository) GetByUserID(ctx context.Context, userID int64) (*models.Wallet, error) {
+	var wallet models.Wallet
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&wallet).Error
+	return &wallet, err
+}
+
+func (r *walletRepository) GetTransaction(ctx context.Context, txType int8, reference string) (*models.WalletTransaction, error) {
+	var entry models.WalletTransaction
+	err := r.db.WithContext(ctx).Where("type = ? AND reference = ?", txType, reference).First(&entry).Error
+	return &entry, err
+}
+
+// WalletTransactionListSpec is what the wallet history of a member can be
+// filtered, sorted and selected by
+var WalletTransactionListSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"type":       {Column: "type", Kind: listquery.Int, Ops: []listquery.Op{listquery.In}},
+		"order_id":   {Column: "order_id", Kind: listquery.Int},
+		"created_at": {Column: "created_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+	},
+	Sorts: map[string]string{"created_at": "created_at"},
+	Fields: []string{"wallet_id", "user_id", "type", "reference", "principal_amount", "bonus_amount", "balance_after",
+		"bonus_balance_after", "order_id", "operator_id", "remark", "created_at"},
+	DefaultSort: "-id",
+}
+
+func (r *walletRepository) ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.WalletTransaction, listquery.Page, error) {
+	var entries []models.WalletTransaction
+	query := r.db.WithContext(ctx).Model(&models.WalletTransaction{}).Where("user_id = ?", userID)
+	page, err := listquery.Find(query, q, &entries)
+	return entries, page, err
+}
+
+// Spend debits the wallet, principal first and then bonus. A spend is recorded
+// once per reference: repeating it returns the existing entry.
+func (r *walletRepository) Spend(ctx context.Context, userID int64, amount float64, reference string, orderID, operatorID *int64, remark string) (*models.WalletTransaction, error) {
+	var entry *models.WalletTransaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing, err := findWalletEntry(tx, models.WalletTxSpend, reference)
+		if err != nil || existing != nil {
+			entry = existing
+			return err
+		}
+
+		entries, err := postWalletEntries(tx, userID, func(w *models.Wallet) ([]models.WalletTransaction, error) {
+			if toCents(w.Balance)+toCents(w.BonusBalance) < toCents(amount) {
+				return nil, ErrInsufficientBalance
+			}
+			principal := math.Min(w.Balance, amount)
+			return []models.WalletTransaction{{
+				Type:            models.WalletTxSpend,
+				Reference:       reference,
+				PrincipalAmount: roundCents(-principal),
+				BonusAmount:     roundCents(principal - amount),
+				OrderID:         orderID,
+				OperatorID:      operatorID,
+				Remark:          remark,
+			}}, nil
+		})
+		if err != nil {
+			return err
+		}
+		entry = &entries[0]
+		return nil
+	})
+	return entry, err
+}
+
+// RefundSpend pays part or all of a spend back into the wallet, split between
+// principal and bonus in the proportion the spend took them
+func (r *walletRepository) RefundSpend(ctx context.Context, spendReference, reference string, amount float64) (*models.WalletTransaction, error) {
+	var entry *models.WalletTransaction
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		existing, err := findWalletEntry(tx, models.WalletTxRefund, reference)
+		if err != nil || existing != nil {
+			entry = existing
+			return err
+		}
+		spend, err := findWalletEntry(tx, models.WalletTxSpend, spendReference)
+		if err != nil {
+			return err
+		}
+		if spend == nil {
+			return errors.New("wallet payment not found")
+		}
+
+		spent := -(spend.PrincipalAmount + spend.BonusAmount)
+		if toCents(amount) > toCents(spent) {
+			return errors.New("refund amount exceeds the wallet payment")
+		}
+		bonus := roundCents(-spend.BonusAmount * amount / spent)
+		entries, err := postWalletEntries(tx, spend.UserID, func(w *models.Wallet) ([]models.WalletTransaction, error) {
+			return []models.WalletTransaction{{
+				Type:            models.WalletTxRefund,
+				Reference:       reference,
+				PrincipalAmount: roundCents(amount - bonus),
+				BonusAmount:     bonus,
+				OrderID:         spend.OrderID,
+			}}, nil
+		})
+		if err != nil {
+			return err
+		}
+		entry = &entries[0]
+		return nil
+	})
+	return entry, err
+}
+
+// TopUp credits a paid top-up and its bonus, returning the ID of the top-up entry
+func (r *walletRepository) TopUp(ctx context.Context, t *WalletTopUp) (int64, error) {
+	var id int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		id, err = creditTopUp(tx, t)
+		return err
+	})
+	return id, err
+}
+
+// DebitTopUp takes a refunded top-up back out of the wallet
+func (r *walletRepository) DebitTopUp(ctx context.Context, t *TopUpRefund) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return debitTopUp(tx, t)
+	})
+}
+
+// Summarize totals the ledger entries by type over [from, to)
+func (r *walletRepository) Summarize(ctx context.Context, from, to time.Time) ([]WalletTypeSum, error) {
+	var sums []WalletTypeSum
+	err := r.db.WithContext(ctx).Model(&models.WalletTransaction{}).
+		Select("type, COALESCE(SUM(principal_amount), 0) AS principal, COALESCE(SUM(bonus_amount), 0) AS bonus").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("type").
+		Scan(&sums).Error
+	return sums, err
+}
+
+// BalancesAt returns the principal and bonus held in all wallets just before t
+func (r *walletRepository) BalancesAt(ctx context.Context, t time.Time) (float64, float64, error) {
+	var totals struct {
+		Principal float64
+		Bonus     float64
+	}
+	err := r.db.WithContext(ctx).Model(&models.WalletTransaction{}).
+		Select("COALESCE(SUM(principal_amount), 0) AS principal, COALESCE(SUM(bonus_amount), 0) AS bonus").
+		Where("created_at < ?", t).
+		Scan(&totals).Error
+	return roundCents(totals.Principal), roundCents(totals.Bonus), err
+}
+
+func (r *walletRepository) CreateTopUpRule(ctx context.Context, rule *models.TopUpRule) error {
+	return r.db.WithContext(ctx).Create(rule).Error
+}
+
+func (r *walletRepository) GetTopUpRule(ctx context.Context, id int64) (*models.TopUpRule, error) {
+	var rule models.TopUpRule
+	err := r.db.WithContext(ctx).First(&rule, id).Error
+	return &rule, err
+}
+
+func (r *walletRepository) UpdateTopUpRule(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&models.TopUpRule{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *walletRepository) ListTopUpRules(ctx context.Context, status *int8) ([]models.TopUpRule, error) {
+	var rules []models.TopUpRule
+	query := r.db.WithContext(ctx).Model(&models.TopUpRule{})
+	if status != nil {
+		query = query.Where("status = ?", *status)
+	}
+	err := query.Order("min_amount").Find(&rules).Error
+	return rules, err
+}
+
+// BestTopUpRule returns the enabled rule with the highest threshold the amount reaches
+func (r *walletRepository) BestTopUpRule(ctx context.Context, amount float64, now time.Time) (*models.TopUpRule, error) {
+	var rules []models.TopUpRule
+	err := r.db.WithContext(ctx).Where("status = ? AND min_amount <= ?", models.TopUpRuleEnabled, amount).
+		Where("(start_at IS NULL OR start_at <= ?) AND (end_at IS NULL OR end_at >= ?)", now, now).
+		Order("min_amount DESC, bonus_amount DESC").
+		Limit(1).
+		Find(&rules).Error
+	if err != nil || len(rules) == 0 {
+		return nil, err
+	}
+	return &rules[0], nil
+}
+
+// creditTopUp posts a top-up and its bonus inside the caller's transaction
+func creditTopUp(tx *gorm.DB, t *WalletTopUp) (int64, error) {
+	entries, err := postWalletEntries(tx, t.UserID, func(w *models.Wallet) ([]models.WalletTransaction, error) {
+		entries := []models.WalletTransaction{{
+			Type:            models.WalletTxTopUp,
+			Reference:       t.Reference,
+			PrincipalAmount: t.Amount,
+			OrderID:         t.OrderID,
+			OperatorID:      t.OperatorID,
+		}}
+		if t.Bonus > 0 {
+			entries = append(entries, models.WalletTransaction{
+				Type:        models.WalletTxBonus,
+				Reference:   t.Reference,
+				BonusAmount: t.Bonus,
+				OrderID:     t.OrderID,
+				OperatorID:  t.OperatorID,
+			})
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return entries[0].ID, nil
+}
+
+// debitTopUp takes a refunded top-up back inside the caller's transaction. The
+// refund is checked against the balance when it is requested; if the member
+// has spent the money since, only what is left is taken.
+func debitTopUp(tx *gorm.DB, t *TopUpRefund) error {
+	existing, err := findWalletEntry(tx, models.WalletTxTopUpRefund, t.Reference)
+	if err != nil || existing != nil {
+		return err
+	}
+	_, err = postWalletEntries(tx, t.UserID, func(w *models.Wallet) ([]models.WalletTransaction, error) {
+		return []models.WalletTransaction{{
+			Type:            models.WalletTxTopUpRefund,
+			Reference:       t.Reference,
+			PrincipalAmount: roundCents(-math.Min(t.Amount, w.Balance)),
+			BonusAmount:     roundCents(-math.Min(t.Bonus, w.BonusBalance)),
+			OrderID:         t.OrderID,
+		}}, nil
+	})
+	return err
+}
+
+// postWalletEntries locks the member's wallet, creating it on first use, lets
+// build work out the entries from the current balances and writes them along
+// with the new balances. Concurrent postings to one wallet are serialized by
+// the row lock the version bump takes.
+func postWalletEntries(tx *gorm.DB, userID int64, build func(w *models.Wallet) ([]models.WalletTransaction, error)) ([]models.WalletTransaction, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Wallet{UserID: userID}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.Wallet{}).Where("user_id = ?", userID).
+		Update("version", gorm.Expr("version + 1")).Error; err != nil {
+		return nil, err
+	}
+	var wallet models.Wallet
+	if err := tx.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+
+	entries, err := build(&wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, bonus := wallet.Balance, wallet.BonusBalance
+	for i := range entries {
+		balance = roundCents(balance + entries[i].PrincipalAmount)
+		bonus = roundCents(bonus + entries[i].BonusAmount)
+		if balance < 0 || bonus < 0 {
+			return nil, ErrInsufficientBalance
+		}
+		entries[i].WalletID = wallet.ID
+		entries[i].UserID = userID
+		entries[i].BalanceAfter = balance
+		entries[i].BonusBalanceAfter = bonus
+	}
+
+	if err := tx.Model(&models.Wallet{}).Where("id = ?", wallet.ID).
+		Updates(map[string]interface{}{"balance": balance, "bonus_balance": bonus}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func findWalletEntry(tx *gorm.DB, txType int8, reference string) (*models.WalletTransaction, error) {
+	var entries []models.WalletTransaction
+	if err := tx.Where("type = ? AND reference = ?", txType, reference).Limit(1).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return &entries[0], nil
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func roundCents(amount float64) float64 {
+	v := math.Round(amount*100) / 100
+	if v == 0 {
+		return 0 // no negative zero
+	}
+	return v
+}
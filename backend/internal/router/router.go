@@ -1,22 +1,53 @@
 package router
 
 import (
-	"gym-admin/internal/controller"
+	"gym-admin/internal/config"
+	"gym-admin/internal/container"
 	"gym-admin/internal/middleware"
+	"gym-admin/pkg/metrics"
+	"time"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
+func SetupRouter(c *container.Container, cfg config.ServerConfig) *gin.Engine {
 	r := gin.Default()
 
+	// Probes and metrics, registered ahead of the middleware below, which they
+	// do not need
+	r.GET("/healthz", c.HealthController.Liveness)
+	r.GET("/readyz", c.HealthController.Readiness)
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// Middleware
+	r.Use(middleware.Metrics())
 	r.Use(middleware.CORS())
 	r.Use(middleware.Logger())
+	r.Use(middleware.Timeout(cfg.RequestTimeout, map[string]time.Duration{
+		"POST /api/v1/users/search/rebuild-pinyin": cfg.LongRequestTimeout,
+		"POST /api/v1/users/import":                cfg.LongRequestTimeout,
+		"GET /api/v1/users/export":                 cfg.LongRequestTimeout,
+		"GET /api/v1/coaches/export":               cfg.LongRequestTimeout,
+		"POST /api/v1/analytics/refresh":           cfg.LongRequestTimeout,
+		"POST /api/v1/reports/occupancy/rebuild":   cfg.LongRequestTimeout,
+	}))
 
-	// Initialize controllers
-	authCtrl := controller.NewAuthController()
-	userCtrl := controller.NewUserController()
-	coachCtrl := controller.NewCoachController()
+	// Controllers
+	authCtrl := c.AuthController
+	userCtrl := c.UserController
+	cardCtrl := c.CardController
+	coachCtrl := c.CoachController
+	reviewCtrl := c.ReviewController
+	checkInCtrl := c.CheckInController
+	voucherCtrl := c.VoucherController
+	statsCtrl := c.StatsController
+	analyticsCtrl := c.AnalyticsController
+	reportCtrl := c.ReportController
+	orderCtrl := c.OrderController
+	refundCtrl := c.RefundController
+	promotionCtrl := c.PromotionController
+	walletCtrl := c.WalletController
+	pointsCtrl := c.PointsController
+	referralCtrl := c.ReferralController
 
 	// API v1 routes
 	v1 := r.Group("/api/v1")
@@ -26,6 +57,7 @@ func SetupRouter() *gin.Engine {
 		{
 			public.POST("/login", authCtrl.Login)
 			public.POST("/register", authCtrl.Register)
+			public.POST("/payments/notify/:gateway", orderCtrl.PaymentNotify)
 		}
 
 		// Protected routes
@@ -37,10 +69,22 @@ func SetupRouter() *gin.Engine {
 			{
 				users.GET("", userCtrl.ListUsers)
 				users.POST("", userCtrl.CreateUser)
+				users.GET("/search", userCtrl.SearchUsers)
+				users.POST("/search/rebuild-pinyin", userCtrl.RebuildNamePinyin)
+				users.POST("/import", userCtrl.ImportUsers)
+				users.GET("/import/template", userCtrl.DownloadImportTemplate)
+				users.GET("/export", userCtrl.ExportUsers)
 				users.GET("/:id", userCtrl.GetUser)
-				users.PUT("/:id", userCtrl.UpdateUser)
+				users.PUT("/:id", middleware.RequireRole("staff", "manager", "admin"), userCtrl.UpdateUser)
 				users.DELETE("/:id", userCtrl.DeleteUser)
 				users.GET("/:id/stats", userCtrl.GetUserStats)
+				users.POST("/:id/freeze", userCtrl.FreezeUser)
+				users.POST("/:id/unfreeze", userCtrl.UnfreezeUser)
+				users.POST("/:id/blacklist", userCtrl.AddToBlacklist)
+				users.DELETE("/:id/blacklist", userCtrl.RemoveFromBlacklist)
+				users.GET("/:id/status-history", userCtrl.GetStatusHistory)
+				users.PUT("/:id/role", middleware.RequireRole("admin"), userCtrl.SetUserRole)
+				users.PUT("/:id/password", userCtrl.SetPassword)
 			}
 
 			// Membership card routes
@@ -50,6 +94,7 @@ func SetupRouter() *gin.Engine {
 				cards.POST("", nil)          // TODO: implement
 				cards.GET("/:id", nil)       // TODO: implement
 				cards.PUT("/:id", nil)       // TODO: implement
+				cards.POST("/:id/transfer", middleware.RequireRole("staff", "manager", "admin"), cardCtrl.TransferCard)
 			}
 
 			// Coach routes
@@ -57,9 +102,11 @@ func SetupRouter() *gin.Engine {
 			{
 				coaches.GET("", coachCtrl.ListCoaches)
 				coaches.POST("", coachCtrl.CreateCoach)
+				coaches.GET("/export", coachCtrl.ExportCoaches)
 				coaches.GET("/:id", coachCtrl.GetCoach)
 				coaches.PUT("/:id", coachCtrl.UpdateCoach)
 				coaches.DELETE("/:id", coachCtrl.DeleteCoach)
+				coaches.GET("/:id/reviews", reviewCtrl.ListCoachReviews)
 			}
 
 			// Course routes
@@ -80,18 +127,130 @@ func SetupRouter() *gin.Engine {
 				bookings.DELETE("/:id", nil) // TODO: implement
 			}
 
+			// Order routes
+			orders := auth.Group("/orders")
+			{
+				orders.GET("", orderCtrl.ListOrders)
+				orders.POST("", orderCtrl.CreateOrder)
+				orders.POST("/preview", orderCtrl.PreviewOrder)
+				orders.GET("/:id", orderCtrl.GetOrder)
+				orders.GET("/:id/payments", orderCtrl.ListPayments)
+				orders.POST("/:id/pay", orderCtrl.PayOrder)
+				orders.POST("/:id/cancel", orderCtrl.CancelOrder)
+				orders.POST("/:id/fulfil", orderCtrl.FulfilOrder)
+				orders.GET("/:id/refunds", refundCtrl.ListOrderRefunds)
+				orders.POST("/:id/refunds", middleware.RequireRole("staff", "manager", "admin"), refundCtrl.CreateRefund)
+			}
+
+			// Refund routes
+			refunds := auth.Group("/refunds")
+			{
+				refunds.GET("", refundCtrl.ListRefunds)
+				refunds.GET("/:id", refundCtrl.GetRefund)
+				refunds.POST("/:id/retry", middleware.RequireRole("staff", "manager", "admin"), refundCtrl.RetryRefund)
+				refunds.POST("/:id/approve", middleware.RequireRole("manager", "admin"), refundCtrl.ApproveRefund)
+				refunds.POST("/:id/reject", middleware.RequireRole("manager", "admin"), refundCtrl.RejectRefund)
+			}
+
+			// Promotion routes
+			promotions := auth.Group("/promotions")
+			{
+				promotions.GET("", promotionCtrl.ListPromotions)
+				promotions.POST("", middleware.RequireRole("staff", "manager", "admin"), promotionCtrl.CreatePromotion)
+				promotions.GET("/:id", promotionCtrl.GetPromotion)
+				promotions.PUT("/:id", middleware.RequireRole("staff", "manager", "admin"), promotionCtrl.UpdatePromotion)
+				promotions.PUT("/:id/status", middleware.RequireRole("staff", "manager", "admin"), promotionCtrl.SetPromotionStatus)
+				promotions.GET("/:id/coupons", promotionCtrl.ListCoupons)
+				promotions.POST("/:id/coupons", middleware.RequireRole("staff", "manager", "admin"), promotionCtrl.CreateCoupons)
+			}
+
+			// Wallet routes
+			wallets := auth.Group("/wallets")
+			{
+				wallets.GET("/top-up-rules", walletCtrl.ListTopUpRules)
+				wallets.POST("/top-up-rules", middleware.RequireRole("staff", "manager", "admin"), walletCtrl.CreateTopUpRule)
+				wallets.PUT("/top-up-rules/:id", middleware.RequireRole("staff", "manager", "admin"), walletCtrl.UpdateTopUpRule)
+				wallets.PUT("/top-up-rules/:id/status", middleware.RequireRole("staff", "manager", "admin"), walletCtrl.SetTopUpRuleStatus)
+				wallets.GET("/:user_id", walletCtrl.GetWallet)
+				wallets.GET("/:user_id/transactions", walletCtrl.ListTransactions)
+				wallets.POST("/:user_id/spend", middleware.RequireRole("staff", "manager", "admin"), walletCtrl.Spend)
+			}
+
+			// Points routes
+			points := auth.Group("/points")
+			{
+				points.GET("/leaderboard", pointsCtrl.GetLeaderboard)
+				points.GET("/rules", pointsCtrl.ListRules)
+				points.POST("/rules", middleware.RequireRole("staff", "manager", "admin"), pointsCtrl.CreateRule)
+				points.PUT("/rules/:id", middleware.RequireRole("staff", "manager", "admin"), pointsCtrl.UpdateRule)
+				points.PUT("/rules/:id/status", middleware.RequireRole("staff", "manager", "admin"), pointsCtrl.SetRuleStatus)
+				points.GET("/rewards", pointsCtrl.ListRewards)
+				points.POST("/rewards", middleware.RequireRole("staff", "manager", "admin"), pointsCtrl.CreateReward)
+				points.PUT("/rewards/:id", middleware.RequireRole("staff", "manager", "admin"), pointsCtrl.UpdateReward)
+				points.PUT("/rewards/:id/status", middleware.RequireRole("staff", "manager", "admin"), pointsCtrl.SetRewardStatus)
+				points.GET("/:user_id", pointsCtrl.GetAccount)
+				points.GET("/:user_id/transactions", pointsCtrl.ListTransactions)
+				points.POST("/:user_id/redeem", pointsCtrl.Redeem)
+			}
+
+			// Referral routes
+			referrals := auth.Group("/referrals")
+			{
+				referrals.GET("/rules", referralCtrl.ListRules)
+				referrals.POST("/rules", middleware.RequireRole("staff", "manager", "admin"), referralCtrl.CreateRule)
+				referrals.PUT("/rules/:id", middleware.RequireRole("staff", "manager", "admin"), referralCtrl.UpdateRule)
+				referrals.PUT("/rules/:id/status", middleware.RequireRole("staff", "manager", "admin"), referralCtrl.SetRuleStatus)
+				referrals.GET("/:user_id", referralCtrl.GetInfo)
+				referrals.GET("/:user_id/referees", referralCtrl.ListReferrals)
+			}
+
+			// Review routes
+			reviews := auth.Group("/reviews")
+			{
+				reviews.GET("", reviewCtrl.ListReviews)
+				reviews.POST("", reviewCtrl.CreateReview)
+				reviews.GET("/:id", reviewCtrl.GetReview)
+				reviews.PUT("/:id/hide", middleware.RequireRole("staff", "manager", "admin"), reviewCtrl.HideReview)
+				reviews.PUT("/:id/show", middleware.RequireRole("staff", "manager", "admin"), reviewCtrl.ShowReview)
+			}
+
 			// Check-in routes
 			checkins := auth.Group("/checkins")
 			{
 				checkins.GET("", nil)        // TODO: implement
-				checkins.POST("", nil)       // TODO: implement
+				checkins.POST("", middleware.RequireRole("staff", "manager", "admin"), checkInCtrl.CreateCheckIn)
 			}
 
 			// Voucher routes
 			vouchers := auth.Group("/vouchers")
 			{
 				vouchers.GET("", nil)        // TODO: implement
-				vouchers.POST("/verify", nil) // TODO: implement
+				vouchers.POST("/verify", middleware.RequireRole("staff", "manager", "admin"), voucherCtrl.Verify)
+			}
+
+			// Statistics routes
+			stats := auth.Group("/stats")
+			{
+				stats.GET("/dashboard", statsCtrl.GetDashboard)
+			}
+
+			// Analytics routes
+			analytics := auth.Group("/analytics")
+			{
+				analytics.GET("/cohorts", analyticsCtrl.GetCohorts)
+				analytics.GET("/renewals", analyticsCtrl.GetRenewals)
+				analytics.GET("/at-risk", analyticsCtrl.ListAtRisk)
+				analytics.POST("/refresh", analyticsCtrl.Refresh)
+			}
+
+			// Report routes
+			reports := auth.Group("/reports")
+			{
+				reports.GET("/occupancy", reportCtrl.GetOccupancy)
+				reports.POST("/occupancy/rebuild", reportCtrl.RebuildOccupancy)
+				reports.GET("/revenue", reportCtrl.GetRevenue)
+				reports.GET("/wallet", reportCtrl.GetWallet)
+				reports.GET("/referrals", reportCtrl.GetReferrals)
 			}
 		}
 	}
@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/logger"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	cohortMonths  = 12
+	renewalMonths = 12
+	// renewalWindowDays is how close to the end date a new card must start to count as a renewal
+	renewalWindowDays = 30
+)
+
+type RenewalReport struct {
+	Months []models.RenewalStat `json:"months"`
+	Total  models.RenewalStat   `json:"total"`
+}
+
+type AnalyticsService struct {
+	repo repository.AnalyticsRepository
+}
+
+func NewAnalyticsService(repo repository.AnalyticsRepository) *AnalyticsService {
+	return &AnalyticsService{
+		repo: repo,
+	}
+}
+
+// RunNightly rebuilds the cohort, renewal and member activity tables.
+// It is registered as a nightly job and can also be triggered manually.
+func (s *AnalyticsService) RunNightly(ctx context.Context) error {
+	now := time.Now()
+	steps := []struct {
+		name string
+		run  func(context.Context, time.Time) error
+	}{
+		{"cohorts", s.buildCohorts},
+		{"renewals", s.buildRenewals},
+		{"member_activities", s.buildMemberActivities},
+	}
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		if err := step.run(ctx, now); err != nil {
+			logger.Error("Analytics aggregation failed", zap.String("step", step.name), zap.Error(err))
+			return err
+		}
+		logger.Info("Analytics aggregation finished", zap.String("step", step.name), zap.Duration("cost", time.Since(start)))
+	}
+	return nil
+}
+
+func (s *AnalyticsService) GetCohorts(ctx context.Context, months int) ([]models.CohortRetention, error) {
+	if months < 1 || months > cohortMonths {
+		months = cohortMonths
+	}
+	return s.repo.ListCohorts(ctx, monthStart(time.Now()).AddDate(0, -(months-1), 0))
+}
+
+func (s *AnalyticsService) GetRenewals(ctx context.Context, months int) (*RenewalReport, error) {
+	if months < 1 || months > renewalMonths {
+		months = renewalMonths
+	}
+	rows, err := s.repo.ListRenewals(ctx, monthStart(time.Now()).AddDate(0, -(months-1), 0))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RenewalReport{Months: rows}
+	for _, row := range rows {
+		report.Total.DueCards += row.DueCards
+		report.Total.RenewedCards += row.RenewedCards
+	}
+	report.Total.Rate = ratio(report.Total.RenewedCards, report.Total.DueCards)
+	return report, nil
+}
+
+// ListAtRisk lists members with an active card who have not checked in for
+// inactiveDays, or whose visits in the last 30 days dropped by dropRate
+func (s *AnalyticsService) ListAtRisk(ctx context.Context, inactiveDays int, dropRate float64, q listquery.Query) ([]models.MemberActivity, listquery.Page, error) {
+	if inactiveDays < 1 {
+		inactiveDays = 14
+	}
+	if dropRate <= 0 || dropRate > 1 {
+		dropRate = 0.5
+	}
+	return s.repo.ListAtRisk(ctx, repository.AtRiskFilter{
+		InactiveDays: inactiveDays,
+		DropRate:     dropRate,
+		MinPrevVisit: 4,
+	}, q)
+}
+
+// buildCohorts computes, for each of the last 12 registration months, the share
+// of members who checked in during each following month
+func (s *AnalyticsService) buildCohorts(ctx context.Context, now time.Time) error {
+	current := monthStart(now)
+	var rows []models.CohortRetention
+	for i := cohortMonths - 1; i >= 0; i-- {
+		cohortFrom := current.AddDate(0, -i, 0)
+		cohortTo := cohortFrom.AddDate(0, 1, 0)
+
+		size, err := s.repo.CountUsersRegistered(ctx, cohortFrom, cohortTo)
+		if err != nil {
+			return err
+		}
+		for offset := 0; offset <= i; offset++ {
+			from := cohortFrom.AddDate(0, offset, 0)
+			var active int64
+			if size > 0 {
+				if active, err = s.repo.CountCohortActive(ctx, cohortFrom, cohortTo, from, from.AddDate(0, 1, 0)); err != nil {
+					return err
+				}
+			}
+			rows = append(rows, models.CohortRetention{
+				CohortMonth: cohortFrom,
+				MonthOffset: offset,
+				CohortSize:  int(size),
+				ActiveUsers: int(active),
+				Rate:        ratio(int(active), int(size)),
+			})
+		}
+	}
+	return s.repo.ReplaceCohorts(ctx, rows)
+}
+
+// buildRenewals computes, for each of the last 12 months, how many of the cards
+// ending that month were followed by a new card starting within 30 days of the end date
+func (s *AnalyticsService) buildRenewals(ctx context.Context, now time.Time) error {
+	current := monthStart(now)
+	var rows []models.RenewalStat
+	for i := renewalMonths - 1; i >= 0; i-- {
+		from := current.AddDate(0, -i, 0)
+		to := from.AddDate(0, 1, 0)
+
+		due, err := s.repo.CardsEndingBetween(ctx, from, to)
+		if err != nil {
+			return err
+		}
+
+		userIDs := make([]int64, 0, len(due))
+		for _, card := range due {
+			userIDs = append(userIDs, card.UserID)
+		}
+		next, err := s.repo.CardsStartingBetween(ctx, userIDs,
+			from.AddDate(0, 0, -renewalWindowDays), to.AddDate(0, 0, renewalWindowDays+1))
+		if err != nil {
+			return err
+		}
+		startsByUser := make(map[int64][]models.MembershipCard)
+		for _, card := range next {
+			startsByUser[card.UserID] = append(startsByUser[card.UserID], card)
+		}
+
+		renewed := 0
+		for _, card := range due {
+			windowFrom := card.EndDate.AddDate(0, 0, -renewalWindowDays)
+			windowTo := card.EndDate.AddDate(0, 0, renewalWindowDays)
+			for _, candidate := range startsByUser[card.UserID] {
+				if candidate.ID != card.ID && !candidate.StartDate.Before(windowFrom) && !candidate.StartDate.After(windowTo) {
+					renewed++
+					break
+				}
+			}
+		}
+
+		rows = append(rows, models.RenewalStat{
+			Month:        from,
+			DueCards:     len(due),
+			RenewedCards: renewed,
+			Rate:         ratio(renewed, len(due)),
+		})
+	}
+	return s.repo.ReplaceRenewals(ctx, rows)
+}
+
+// buildMemberActivities snapshots the last visit and visit counts of every member with an active card
+func (s *AnalyticsService) buildMemberActivities(ctx context.Context, now time.Time) error {
+	today := truncateToDate(now)
+	recentFrom := today.AddDate(0, 0, -30)
+	prevFrom := today.AddDate(0, 0, -60)
+
+	activities, err := s.repo.ActiveCardActivities(ctx, today, recentFrom, prevFrom)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]models.MemberActivity, 0, len(activities))
+	for _, a := range activities {
+		since := a.CardStartDate
+		if a.LastCheckInAt != nil {
+			since = *a.LastCheckInAt
+		}
+		days := int(today.Sub(truncateToDate(since)).Hours() / 24)
+		if days < 0 {
+			days = 0
+		}
+		rows = append(rows, models.MemberActivity{
+			UserID:           a.UserID,
+			CardID:           a.CardID,
+			CardEndDate:      a.CardEndDate,
+			LastCheckInAt:    a.LastCheckInAt,
+			DaysSinceCheckIn: days,
+			VisitsLast30Days: a.VisitsLast30,
+			VisitsPrev30Days: a.VisitsPrev30,
+			SnapshotDate:     today,
+		})
+	}
+	return s.repo.ReplaceMemberActivities(ctx, rows)
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+func ratio(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*10000) / 10000
+}
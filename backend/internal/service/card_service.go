@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"time"
+)
+
+const (
+	CardDurationDay     int8 = 1
+	CardDurationMonth   int8 = 2
+	CardDurationQuarter int8 = 3
+	CardDurationYear    int8 = 4
+	CardDurationTimes   int8 = 5
+)
+
+const (
+	CardStatusNormal      = models.CardStatusNormal
+	CardStatusExpired     = models.CardStatusExpired
+	CardStatusFrozen      = models.CardStatusFrozen
+	CardStatusTransferred = models.CardStatusTransferred
+	CardStatusRefunded    = models.CardStatusRefunded
+)
+
+const (
+	CardOpOpen     = models.CardOpOpen
+	CardOpRenew    = models.CardOpRenew
+	CardOpFreeze   = models.CardOpFreeze
+	CardOpUnfreeze = models.CardOpUnfreeze
+	CardOpTransfer = models.CardOpTransfer
+	CardOpRefund   = models.CardOpRefund
+	CardOpRedeem   = models.CardOpRedeem
+	CardOpReferral = models.CardOpReferral
+)
+
+var ErrCardNotTransferable = apperr.Conflict("CARD_NOT_TRANSFERABLE", "card cannot be transferred")
+
+// timesCardValidityYears is how long a visit card stays valid when no end date is given
+const timesCardValidityYears = 1
+
+type CardService struct {
+	repo    repository.CardRepository
+	seqRepo repository.SequenceRepository
+	tx      repository.TxManager
+}
+
+func NewCardService(repo repository.CardRepository, seqRepo repository.SequenceRepository, tx repository.TxManager) *CardService {
+	return &CardService{
+		repo:    repo,
+		seqRepo: seqRepo,
+		tx:      tx,
+	}
+}
+
+func (s *CardService) GetCard(ctx context.Context, id int64) (*models.MembershipCard, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// IssueCard opens a new card of the given type for a user starting on start,
+// recording the opening in the card's operation log
+func (s *CardService) IssueCard(ctx context.Context, userID, cardTypeID int64, start time.Time, price float64, source int8, operatorID *int64) (*models.MembershipCard, error) {
+	cardType, err := s.repo.GetCardTypeByID(ctx, cardTypeID)
+	if err != nil {
+		return nil, notFound(err, ErrCardTypeNotFound)
+	}
+	if cardType.Status != 1 {
+		return nil, ErrCardTypeDisabled
+	}
+
+	card, err := s.BuildCard(ctx, userID, cardType, start, nil, price, source)
+	if err != nil {
+		return nil, err
+	}
+	card.OperatorID = operatorID
+
+	if err := s.SaveCard(ctx, card); err != nil {
+		return nil, err
+	}
+	return card, nil
+}
+
+// SaveCard saves a card made by BuildCard and records the opening in its
+// operation log. Called inside a unit of work, it joins that transaction.
+func (s *CardService) SaveCard(ctx context.Context, card *models.MembershipCard) error {
+	return s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		if err := repos.Cards.Create(ctx, card); err != nil {
+			return err
+		}
+		log := &models.CardOperationLog{
+			CardID:        card.ID,
+			OperationType: CardOpOpen,
+			Amount:        card.PurchasePrice,
+		}
+		if card.OperatorID != nil {
+			log.OperatorID = *card.OperatorID
+		}
+		return repos.Cards.CreateLog(ctx, log)
+	})
+}
+
+// TransferCard hands what is left of a running card over to another member:
+// the card is closed as transferred and the member gets a new card of the
+// same type, ending on the same day with the visits left. The transfer fee of
+// the card type is logged on the old card.
+func (s *CardService) TransferCard(ctx context.Context, cardID, toUserID, operatorID int64, remark string) (*models.MembershipCard, error) {
+	card, err := s.repo.GetByID(ctx, cardID)
+	if err != nil {
+		return nil, notFound(err, ErrCardNotFound)
+	}
+	if card.UserID == toUserID {
+		return nil, apperr.Invalid("card already belongs to this member")
+	}
+	today := truncateToDate(time.Now())
+	if card.Status == CardStatusExpired || card.EndDate.Before(today) {
+		return nil, ErrCardExpired
+	}
+	cardType, err := s.repo.GetCardTypeByID(ctx, card.CardTypeID)
+	if err != nil {
+		return nil, notFound(err, ErrCardTypeNotFound)
+	}
+	if card.Status != CardStatusNormal || card.IsFrozen == 1 || cardType.CanTransfer != 1 {
+		return nil, ErrCardNotTransferable
+	}
+
+	start := today
+	if card.StartDate.After(start) {
+		start = card.StartDate
+	}
+	newCard, err := s.BuildCard(ctx, toUserID, cardType, start, &card.EndDate, 0, card.Source)
+	if err != nil {
+		return nil, err
+	}
+	if card.RemainingTimes != nil {
+		remaining := *card.RemainingTimes
+		newCard.RemainingTimes = &remaining
+	}
+	newCard.OperatorID = &operatorID
+	newCard.Remark = "转入自 " + card.CardNo
+
+	err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		if _, err := repos.Users.GetByID(ctx, toUserID); err != nil {
+			return notFound(err, ErrUserNotFound)
+		}
+		ok, err := repos.Cards.MarkTransferred(ctx, card)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrCardNotTransferable
+		}
+		if err := s.SaveCard(ctx, newCard); err != nil {
+			return err
+		}
+		return repos.Cards.CreateLog(ctx, &models.CardOperationLog{
+			CardID:        card.ID,
+			OperationType: CardOpTransfer,
+			Amount:        cardType.TransferFee,
+			OperatorID:    operatorID,
+			Remark:        transferRemark(newCard.CardNo, remark),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newCard, nil
+}
+
+func transferRemark(cardNo, remark string) string {
+	if remark == "" {
+		return "转出至 " + cardNo
+	}
+	return "转出至 " + cardNo + "：" + remark
+}
+
+// extendCard moves the end date of a running card and logs the change, as
+// part of the caller's unit of work
+func extendCard(ctx context.Context, repos *repository.Repositories, card *models.MembershipCard, newEndDate time.Time, op int8, operatorID int64, remark string) error {
+	ok, err := repos.Cards.Extend(ctx, card, newEndDate)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCardNotExtendable
+	}
+	return repos.Cards.CreateLog(ctx, &models.CardOperationLog{
+		CardID:        card.ID,
+		OperationType: op,
+		OldEndDate:    &card.EndDate,
+		NewEndDate:    &newEndDate,
+		OperatorID:    operatorID,
+		Remark:        remark,
+	})
+}
+
+// BuildCard prepares an unsaved card with a fresh card number. The end date is
+// derived from the card type unless given; visit cards get their visit count.
+func (s *CardService) BuildCard(ctx context.Context, userID int64, cardType *models.CardType, start time.Time, end *time.Time, price float64, source int8) (*models.MembershipCard, error) {
+	cardNo, err := s.generateCardNo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	start = truncateToDate(start)
+	card := &models.MembershipCard{
+		CardNo:        cardNo,
+		UserID:        userID,
+		CardTypeID:    cardType.ID,
+		Status:        CardStatusNormal,
+		StartDate:     start,
+		Source:        source,
+		PurchasePrice: price,
+	}
+
+	if end != nil {
+		card.EndDate = truncateToDate(*end)
+	} else {
+		card.EndDate = cardEndDate(cardType, start)
+	}
+	if card.EndDate.Before(start) {
+		return nil, apperr.Invalid("end date is before start date")
+	}
+	if card.EndDate.Before(truncateToDate(time.Now())) {
+		card.Status = CardStatusExpired
+	}
+
+	if cardType.DurationType == CardDurationTimes {
+		times := cardType.DurationValue
+		card.TotalTimes = &times
+		remaining := times
+		card.RemainingTimes = &remaining
+	}
+	return card, nil
+}
+
+// generateCardNo allocates numbers like M2024010100001 from a per-day sequence
+func (s *CardService) generateCardNo(ctx context.Context) (string, error) {
+	date := time.Now().Format("20060102")
+	seq, err := s.seqRepo.Next(ctx, "card_no:"+date)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate card number: %w", err)
+	}
+	return fmt.Sprintf("M%s%05d", date, seq), nil
+}
+
+// cardEndDate returns the last valid day of a card. DurationValue is a number
+// of days for time cards and a number of visits for visit cards.
+func cardEndDate(cardType *models.CardType, start time.Time) time.Time {
+	if cardType.DurationType == CardDurationTimes {
+		return start.AddDate(timesCardValidityYears, 0, -1)
+	}
+	days := cardType.DurationValue
+	if days < 1 {
+		days = 1
+	}
+	return start.AddDate(0, 0, days-1)
+}
+
+func truncateToDate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
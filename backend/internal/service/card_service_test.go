@@ -0,0 +1,64 @@
+package service_test
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/internal/repository/fake"
+	"gym-admin/internal/service"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sequence() *fake.SequenceRepository {
+	var n int64
+	return &fake.SequenceRepository{
+		NextFunc: func(ctx context.Context, name string) (int64, error) {
+			n++
+			return n, nil
+		},
+	}
+}
+
+func TestIssueCardWritesInOneUnitOfWork(t *testing.T) {
+	cards := &fake.CardRepository{
+		GetCardTypeByIDFunc: func(ctx context.Context, id int64) (*models.CardType, error) {
+			return &models.CardType{ID: id, DurationType: service.CardDurationMonth, DurationValue: 1, Status: 1}, nil
+		},
+	}
+	var created []*models.MembershipCard
+	var logs []*models.CardOperationLog
+	txCards := &fake.CardRepository{
+		CreateFunc: func(ctx context.Context, card *models.MembershipCard) error {
+			card.ID = 100
+			created = append(created, card)
+			return nil
+		},
+		CreateLogFunc: func(ctx context.Context, log *models.CardOperationLog) error {
+			logs = append(logs, log)
+			return nil
+		},
+	}
+	tx := &fake.TxManager{Repos: &repository.Repositories{Cards: txCards}}
+	svc := service.NewCardService(cards, sequence(), tx)
+
+	operatorID := int64(9)
+	card, err := svc.IssueCard(context.Background(), 3, 5, time.Now(), 300, 1, &operatorID)
+	if err != nil {
+		t.Fatalf("IssueCard: %v", err)
+	}
+	if tx.Calls != 1 {
+		t.Errorf("units of work = %d, want 1", tx.Calls)
+	}
+	if len(created) != 1 || created[0] != card || !strings.HasPrefix(card.CardNo, "M") {
+		t.Fatalf("created cards = %v", created)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("logs = %d, want 1", len(logs))
+	}
+	log := logs[0]
+	if log.CardID != 100 || log.OperationType != service.CardOpOpen || log.Amount != 300 || log.OperatorID != operatorID {
+		t.Errorf("opening log = %+v", log)
+	}
+}
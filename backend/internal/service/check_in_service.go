@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/metrics"
+	"net/http"
+	"time"
+)
+
+const (
+	CheckInFace   = models.CheckInFace
+	CheckInCard   = models.CheckInCard
+	CheckInManual = models.CheckInManual
+)
+
+// checkInTypeLabels names the check-in types in metrics
+var checkInTypeLabels = map[int8]string{
+	CheckInFace:   "face",
+	CheckInCard:   "card",
+	CheckInManual: "manual",
+}
+
+var (
+	ErrNoValidCard = apperr.Conflict("NO_VALID_CARD", "member has no card valid for check-in")
+	ErrUserFrozen  = apperr.New(http.StatusForbidden, "USER_FROZEN", "user is frozen")
+)
+
+// CheckInInput is a member arriving at the gym. Without a CardID the card is
+// picked for the member: a time card if they hold one, else the visit card
+// ending first.
+type CheckInInput struct {
+	UserID   int64
+	CardID   *int64
+	Type     int8
+	DeviceID string
+	Remark   string
+}
+
+type CheckInService struct {
+	userRepo repository.UserRepository
+	cardRepo repository.CardRepository
+	tx       repository.TxManager
+}
+
+func NewCheckInService(userRepo repository.UserRepository, cardRepo repository.CardRepository, tx repository.TxManager) *CheckInService {
+	return &CheckInService{
+		userRepo: userRepo,
+		cardRepo: cardRepo,
+		tx:       tx,
+	}
+}
+
+// CheckIn admits a member on one of their cards. A visit card has a visit
+// taken off in the same unit of work that records the check-in.
+func (s *CheckInService) CheckIn(ctx context.Context, in CheckInInput) (*models.CheckIn, error) {
+	checkIn, err := s.checkIn(ctx, in)
+	if label, ok := checkInTypeLabels[in.Type]; ok {
+		metrics.CheckIns.WithLabelValues(label, outcome(err, "denied")).Inc()
+	}
+	return checkIn, err
+}
+
+func (s *CheckInService) checkIn(ctx context.Context, in CheckInInput) (*models.CheckIn, error) {
+	user, err := s.userRepo.GetByID(ctx, in.UserID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	switch user.Status {
+	case UserStatusBlacklist:
+		return nil, ErrUserBlacklisted
+	case UserStatusFrozen:
+		return nil, ErrUserFrozen
+	}
+
+	now := time.Now()
+	cards, err := s.cardRepo.ListUsable(ctx, in.UserID, truncateToDate(now))
+	if err != nil {
+		return nil, err
+	}
+	card := pickCheckInCard(cards, in.CardID)
+	if card == nil {
+		return nil, ErrNoValidCard
+	}
+
+	checkIn := &models.CheckIn{
+		UserID:      in.UserID,
+		CardID:      &card.ID,
+		CheckInType: in.Type,
+		CheckInTime: now,
+		DeviceID:    in.DeviceID,
+		Remark:      in.Remark,
+	}
+	err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		if card.RemainingTimes != nil {
+			ok, err := repos.Cards.UseVisit(ctx, card.ID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				// the last visit went to a check-in at the same time
+				return ErrNoValidCard
+			}
+		}
+		return repos.CheckIns.Create(ctx, checkIn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checkIn, nil
+}
+
+// outcome is the result label of an attempt for metrics: success, refused
+// when the request was turned down, or failed on a system error
+func outcome(err error, refused string) string {
+	switch {
+	case err == nil:
+		return "success"
+	case apperr.From(err).Status < http.StatusInternalServerError:
+		return refused
+	default:
+		return "failed"
+	}
+}
+
+// pickCheckInCard chooses among the usable cards of a member, which come
+// ending first. Time cards go before visit cards so that no visit is spent
+// while a time card covers the day.
+func pickCheckInCard(cards []models.MembershipCard, cardID *int64) *models.MembershipCard {
+	var visitCard *models.MembershipCard
+	for i := range cards {
+		card := &cards[i]
+		if card.RemainingTimes != nil && *card.RemainingTimes <= 0 {
+			continue
+		}
+		if cardID != nil {
+			if card.ID == *cardID {
+				return card
+			}
+			continue
+		}
+		if card.RemainingTimes == nil {
+			return card
+		}
+		if visitCard == nil {
+			visitCard = card
+		}
+	}
+	return visitCard
+}
@@ -1,70 +1,126 @@
 package service
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"gym-admin/internal/models"
 	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"strings"
 	"time"
 )
 
 type CoachService struct {
-	repo *repository.CoachRepository
+	repo repository.CoachRepository
 }
 
-func NewCoachService() *CoachService {
+func NewCoachService(repo repository.CoachRepository) *CoachService {
 	return &CoachService{
-		repo: repository.NewCoachRepository(),
+		repo: repo,
 	}
 }
 
-func (s *CoachService) CreateCoach(coach *models.Coach) error {
+func (s *CoachService) CreateCoach(ctx context.Context, coach *models.Coach) error {
 	// Generate coach number
 	coach.CoachNo = s.generateCoachNo()
 	coach.Status = 1 // Default status: active
 
-	return s.repo.Create(coach)
+	return s.repo.Create(ctx, coach)
 }
 
-func (s *CoachService) GetCoach(id int64) (*models.Coach, error) {
-	return s.repo.GetByID(id)
+func (s *CoachService) GetCoach(ctx context.Context, id int64) (*models.Coach, error) {
+	coach, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrCoachNotFound)
+	}
+	return coach, nil
 }
 
-func (s *CoachService) ListCoaches(page, pageSize int, status *int8) ([]models.Coach, int64, error) {
-	if page < 1 {
-		page = 1
-	}
-	if pageSize < 1 || pageSize > 100 {
-		pageSize = 10
-	}
-	return s.repo.List(page, pageSize, status)
+func (s *CoachService) ListCoaches(ctx context.Context, q listquery.Query) ([]models.Coach, listquery.Page, error) {
+	return s.repo.List(ctx, q)
 }
 
-func (s *CoachService) UpdateCoach(id int64, updates map[string]interface{}) error {
-	coach, err := s.repo.GetByID(id)
+// CoachUpdate holds the profile fields to change; nil fields are left as
+// they are
+type CoachUpdate struct {
+	Name           *string  `json:"name" binding:"omitempty,min=1,max=50"`
+	Gender         *int8    `json:"gender" binding:"omitempty,oneof=1 2"`
+	Phone          *string  `json:"phone" binding:"omitempty,min=1,mobile"`
+	Email          *string  `json:"email" binding:"omitempty,email,max=100"`
+	AvatarURL      *string  `json:"avatar_url" binding:"omitempty,max=255"`
+	Specialties    *string  `json:"specialties"`
+	Certifications *string  `json:"certifications"`
+	Experience     *int     `json:"experience" binding:"omitempty,min=0"`
+	Introduction   *string  `json:"introduction"`
+	HourlyRate     *float64 `json:"hourly_rate" binding:"omitempty,min=0"`
+	Status         *int8    `json:"status" binding:"omitempty,oneof=1 2"`
+	HireDate       *string  `json:"hire_date" binding:"omitempty,date,notfuture"`
+	Remark         *string  `json:"remark"`
+}
+
+func (s *CoachService) UpdateCoach(ctx context.Context, id int64, in CoachUpdate) error {
+	coach, err := s.repo.GetByID(ctx, id)
 	if err != nil {
-		return errors.New("coach not found")
+		return notFound(err, ErrCoachNotFound)
 	}
 
-	// Update fields
-	if name, ok := updates["name"].(string); ok {
-		coach.Name = name
+	if in.Name != nil {
+		if strings.TrimSpace(*in.Name) == "" {
+			return errNameRequired
+		}
+		coach.Name = *in.Name
+	}
+	if in.Gender != nil {
+		coach.Gender = *in.Gender
+	}
+	if in.Phone != nil {
+		coach.Phone = strings.TrimSpace(*in.Phone)
+	}
+	if in.Email != nil {
+		coach.Email = strings.TrimSpace(*in.Email)
+	}
+	if in.AvatarURL != nil {
+		coach.AvatarURL = *in.AvatarURL
+	}
+	if in.Specialties != nil {
+		coach.Specialties = *in.Specialties
+	}
+	if in.Certifications != nil {
+		coach.Certifications = *in.Certifications
+	}
+	if in.Experience != nil {
+		coach.Experience = *in.Experience
+	}
+	if in.Introduction != nil {
+		coach.Introduction = *in.Introduction
+	}
+	if in.HourlyRate != nil {
+		coach.HourlyRate = *in.HourlyRate
 	}
-	if phone, ok := updates["phone"].(string); ok {
-		coach.Phone = phone
+	if in.Status != nil {
+		coach.Status = *in.Status
 	}
-	if email, ok := updates["email"].(string); ok {
-		coach.Email = email
+	if in.HireDate != nil {
+		if *in.HireDate == "" {
+			coach.HireDate = nil
+		} else {
+			t, err := time.ParseInLocation("2006-01-02", *in.HireDate, time.Local)
+			if err != nil {
+				return apperr.Invalid("invalid hire_date, expected YYYY-MM-DD")
+			}
+			coach.HireDate = &t
+		}
 	}
-	if introduction, ok := updates["introduction"].(string); ok {
-		coach.Introduction = introduction
+	if in.Remark != nil {
+		coach.Remark = *in.Remark
 	}
 
-	return s.repo.Update(coach)
+	return s.repo.Update(ctx, coach)
 }
 
-func (s *CoachService) DeleteCoach(id int64) error {
-	return s.repo.Delete(id)
+func (s *CoachService) DeleteCoach(ctx context.Context, id int64) error {
+	return s.repo.Delete(ctx, id)
 }
 
 func (s *CoachService) generateCoachNo() string {
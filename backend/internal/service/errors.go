@@ -0,0 +1,40 @@
+package service
+
+import (
+	"errors"
+	"gym-admin/pkg/apperr"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Errors shared by several services. Errors specific to one service are
+// declared next to it.
+var (
+	ErrUserNotFound      = apperr.NotFound("USER_NOT_FOUND", "user not found")
+	ErrCoachNotFound     = apperr.NotFound("COACH_NOT_FOUND", "coach not found")
+	ErrCardNotFound      = apperr.NotFound("CARD_NOT_FOUND", "card not found")
+	ErrCardTypeNotFound  = apperr.NotFound("CARD_TYPE_NOT_FOUND", "card type not found")
+	ErrCourseNotFound    = apperr.NotFound("COURSE_NOT_FOUND", "course not found")
+	ErrOrderNotFound     = apperr.NotFound("ORDER_NOT_FOUND", "order not found")
+	ErrRefundNotFound    = apperr.NotFound("REFUND_NOT_FOUND", "refund not found")
+	ErrPromotionNotFound = apperr.NotFound("PROMOTION_NOT_FOUND", "promotion not found")
+
+	ErrCardTypeDisabled       = apperr.Conflict("CARD_TYPE_DISABLED", "card type is disabled")
+	ErrCardExpired            = apperr.Conflict("CARD_EXPIRED", "card has expired")
+	ErrCardNotExtendable      = apperr.Conflict("CARD_NOT_EXTENDABLE", "card cannot be extended")
+	ErrOrderNotPaid           = apperr.Conflict("ORDER_NOT_PAID", "order is not paid")
+	ErrPromotionTakesNoCoupon = apperr.New(http.StatusBadRequest, "PROMOTION_TAKES_NO_COUPON", "promotion does not take coupons")
+
+	errInvalidStatus = apperr.Invalid("invalid status")
+	errNameRequired  = apperr.Invalid("name is required")
+)
+
+// notFound reports a missing record as notFoundErr and passes other
+// errors through
+func notFound(err, notFoundErr error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return notFoundErr
+	}
+	return err
+}
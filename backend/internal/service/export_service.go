@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/spreadsheet"
+	"io"
+	"strconv"
+	"time"
+)
+
+const exportBatchSize = 500
+
+// ExportColumn describes one column of an export: its header and how to render a row
+type ExportColumn[T any] struct {
+	Header string
+	Value  func(*T) string
+}
+
+var userExportColumns = []ExportColumn[models.User]{
+	{"用户编号", func(u *models.User) string { return u.UserNo }},
+	{"姓名", func(u *models.User) string { return u.Name }},
+	{"性别", func(u *models.User) string { return label(GenderLabels, u.Gender) }},
+	{"手机号", func(u *models.User) string { return u.Phone }},
+	{"生日", func(u *models.User) string { return formatDate(u.Birthday) }},
+	{"邮箱", func(u *models.User) string { return u.Email }},
+	{"地址", func(u *models.User) string { return u.Address }},
+	{"来源", func(u *models.User) string { return label(UserSourceLabels, u.Source) }},
+	{"状态", func(u *models.User) string { return label(UserStatusLabels, u.Status) }},
+	{"注册时间", func(u *models.User) string { return u.CreatedAt.Format("2006-01-02 15:04:05") }},
+	{"备注", func(u *models.User) string { return u.Remark }},
+}
+
+var coachExportColumns = []ExportColumn[models.Coach]{
+	{"教练编号", func(c *models.Coach) string { return c.CoachNo }},
+	{"姓名", func(c *models.Coach) string { return c.Name }},
+	{"性别", func(c *models.Coach) string { return label(GenderLabels, c.Gender) }},
+	{"手机号", func(c *models.Coach) string { return c.Phone }},
+	{"邮箱", func(c *models.Coach) string { return c.Email }},
+	{"工作年限", func(c *models.Coach) string { return strconv.Itoa(c.Experience) }},
+	{"课时费", func(c *models.Coach) string { return formatMoney(c.HourlyRate) }},
+	{"评分", func(c *models.Coach) string { return strconv.FormatFloat(c.Rating, 'f', 2, 64) }},
+	{"评价数", func(c *models.Coach) string { return strconv.Itoa(c.TotalRatings) }},
+	{"状态", func(c *models.Coach) string { return label(CoachStatusLabels, c.Status) }},
+	{"入职日期", func(c *models.Coach) string { return formatDate(c.HireDate) }},
+}
+
+type ExportService struct {
+	userRepo  repository.UserRepository
+	coachRepo repository.CoachRepository
+}
+
+func NewExportService(userRepo repository.UserRepository, coachRepo repository.CoachRepository) *ExportService {
+	return &ExportService{
+		userRepo:  userRepo,
+		coachRepo: coachRepo,
+	}
+}
+
+// ExportUsers streams the users matching filter to w
+func (s *ExportService) ExportUsers(ctx context.Context, w io.Writer, format string, filter repository.UserSearchFilter) error {
+	return export(w, format, userExportColumns, func(fn func([]models.User) error) error {
+		return s.userRepo.EachBatch(ctx, filter, exportBatchSize, fn)
+	})
+}
+
+// ExportCoaches streams the coaches with the given status to w
+func (s *ExportService) ExportCoaches(ctx context.Context, w io.Writer, format string, status *int8) error {
+	return export(w, format, coachExportColumns, func(fn func([]models.Coach) error) error {
+		return s.coachRepo.EachBatch(ctx, status, exportBatchSize, fn)
+	})
+}
+
+// export writes the header and then every batch produced by each, one row at a time
+func export[T any](w io.Writer, format string, columns []ExportColumn[T], each func(func([]T) error) error) error {
+	writer, err := spreadsheet.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Header
+	}
+	if err := writer.WriteRow(header); err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+	err = each(func(batch []T) error {
+		for i := range batch {
+			for j, col := range columns {
+				row[j] = col.Value(&batch[i])
+			}
+			if err := writer.WriteRow(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func formatMoney(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
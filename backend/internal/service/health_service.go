@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"gym-admin/pkg/cache"
+	"gym-admin/pkg/database"
+	"gym-admin/pkg/logger"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// healthCheckTimeout bounds each dependency check, so a hung MySQL or Redis
+// fails the probe instead of stalling it
+const healthCheckTimeout = 2 * time.Second
+
+// Readiness is the state of the dependencies an instance needs to serve
+type Readiness struct {
+	Ready             bool              `json:"ready"`
+	Draining          bool              `json:"draining,omitempty"`
+	Checks            map[string]string `json:"checks"`             // "ok" or "unavailable"
+	PendingMigrations int               `json:"pending_migrations"` // migrations in this build not applied yet
+}
+
+// HealthService answers the liveness and readiness probes
+type HealthService struct {
+	db       *gorm.DB
+	cache    cache.Cache
+	draining atomic.Bool
+}
+
+func NewHealthService(db *gorm.DB, c cache.Cache) *HealthService {
+	return &HealthService{db: db, cache: c}
+}
+
+// Drain marks the instance as shutting down, so that load balancers stop
+// sending it new requests while the in-flight ones finish
+func (s *HealthService) Drain() {
+	s.draining.Store(true)
+}
+
+// Readiness checks MySQL and Redis and counts pending migrations. Pending
+// migrations are reported but do not fail the check, as the schema may be
+// migrated by the deploy after the new build starts. The probe is public, so
+// why a check failed is only logged.
+func (s *HealthService) Readiness(ctx context.Context) Readiness {
+	r := Readiness{Ready: true, Draining: s.draining.Load(), Checks: map[string]string{}}
+	if r.Draining {
+		r.Ready = false
+	}
+
+	check := func(name string, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		defer cancel()
+		if err := fn(ctx); err != nil {
+			r.Ready = false
+			r.Checks[name] = "unavailable"
+			logger.Warn("Readiness check failed", zap.String("check", name), zap.Error(err))
+			return
+		}
+		r.Checks[name] = "ok"
+	}
+
+	check("database", func(ctx context.Context) error {
+		sqlDB, err := s.db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	check("redis", s.cache.Ping)
+	if s.db.Dialector.Name() == database.DriverMySQL {
+		check("migrations", func(ctx context.Context) error {
+			migrator, err := database.NewMigrator(s.db)
+			if err != nil {
+				return err
+			}
+			r.PendingMigrations, err = migrator.Pending(ctx)
+			return err
+		})
+	}
+	return r
+}
@@ -0,0 +1,29 @@
+package service
+
+import "strconv"
+
+// Display labels for enum columns, used by exports
+var (
+	GenderLabels = map[int8]string{1: "男", 2: "女"}
+
+	UserStatusLabels = map[int8]string{1: "正常", 2: "冻结", 3: "黑名单"}
+
+	UserSourceLabels = map[int8]string{1: "前台录入", 2: "小程序注册", 3: "美团", 4: "抖音", 5: "会员推荐"}
+
+	CoachStatusLabels = map[int8]string{1: "在职", 2: "离职"}
+
+	CardStatusLabels = map[int8]string{1: "正常", 2: "已过期", 3: "已冻结", 4: "已转出", 5: "已退卡"}
+
+	CardSourceLabels = map[int8]string{1: "前台办理", 2: "小程序购买", 3: "美团", 4: "抖音"}
+)
+
+// label returns the display label of an enum value, or the raw value if unknown
+func label(labels map[int8]string, v int8) string {
+	if l, ok := labels[v]; ok {
+		return l
+	}
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(v))
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// AllDevices is the device ID of the rows aggregated across all devices
+	AllDevices = "_all"
+	// maxDwell ignores check-outs that are obviously missing or wrong
+	maxDwell = 12 * time.Hour
+	// maxOccupancyRangeDays bounds a single report or rebuild request
+	maxOccupancyRangeDays = 366
+)
+
+var ErrInvalidDateRange = apperr.Invalid("invalid date range")
+
+type DailyOccupancy struct {
+	Date          time.Time `json:"date"`
+	CheckIns      int       `json:"check_ins"`
+	PeakOccupancy int       `json:"peak_occupancy"`
+	PeakHour      int8      `json:"peak_hour"`
+}
+
+type OccupancyReport struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	DeviceID string    `json:"device_id"`
+	// Heatmap holds the average check-ins per day for weekday (0-Monday ... 6-Sunday) x hour
+	Heatmap [7][24]float64 `json:"heatmap"`
+	// AverageDwellMinutes is nil until exits are recorded
+	AverageDwellMinutes *float64         `json:"average_dwell_minutes"`
+	Daily               []DailyOccupancy `json:"daily"`
+}
+
+type OccupancyService struct {
+	repo repository.OccupancyRepository
+}
+
+func NewOccupancyService(repo repository.OccupancyRepository) *OccupancyService {
+	return &OccupancyService{
+		repo: repo,
+	}
+}
+
+// RefreshRecent re-aggregates yesterday and today. It runs hourly so that the
+// report includes the current day and late check-outs.
+func (s *OccupancyService) RefreshRecent(ctx context.Context) error {
+	today := truncateToDate(time.Now())
+	return s.Rebuild(ctx, today.AddDate(0, 0, -1), today)
+}
+
+// Rebuild re-aggregates every day in [from, to] from the raw check-ins
+func (s *OccupancyService) Rebuild(ctx context.Context, from, to time.Time) error {
+	from, to = truncateToDate(from), truncateToDate(to)
+	if to.Before(from) || to.Sub(from) > maxOccupancyRangeDays*24*time.Hour {
+		return ErrInvalidDateRange
+	}
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Include earlier check-ins that may still be inside at midnight
+		checkIns, err := s.repo.CheckInsBetween(ctx, day.Add(-maxDwell), day.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		if err := s.repo.ReplaceDay(ctx, day, aggregateCheckIns(day, checkIns)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReport builds the heatmap, dwell time and daily peaks for [from, to]
+func (s *OccupancyService) GetReport(ctx context.Context, from, to time.Time, deviceID string) (*OccupancyReport, error) {
+	from, to = truncateToDate(from), truncateToDate(to)
+	if to.Before(from) || to.Sub(from) > maxOccupancyRangeDays*24*time.Hour {
+		return nil, ErrInvalidDateRange
+	}
+	if deviceID == "" {
+		deviceID = AllDevices
+	}
+
+	rows, err := s.repo.HourlyStats(ctx, from, to, deviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OccupancyReport{From: from, To: to, DeviceID: deviceID, Daily: []DailyOccupancy{}}
+
+	// Number of occurrences of each weekday in the range, to average the heatmap
+	var weekdayDays [7]int
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		weekdayDays[weekdayIndex(day)]++
+	}
+
+	var totalDwell int64
+	var totalCheckOuts int
+	daily := make(map[string]*DailyOccupancy)
+	for _, row := range rows {
+		report.Heatmap[row.Weekday-1][row.Hour] += float64(row.CheckIns)
+		totalDwell += row.DwellMinutes
+		totalCheckOuts += row.CheckOuts
+
+		key := row.StatDate.Format("2006-01-02")
+		d, ok := daily[key]
+		if !ok {
+			d = &DailyOccupancy{Date: row.StatDate, PeakHour: row.Hour}
+			daily[key] = d
+		}
+		d.CheckIns += row.CheckIns
+		if row.PeakOccupancy > d.PeakOccupancy {
+			d.PeakOccupancy = row.PeakOccupancy
+			d.PeakHour = row.Hour
+		}
+	}
+
+	for weekday := range report.Heatmap {
+		if weekdayDays[weekday] == 0 {
+			continue
+		}
+		for hour := range report.Heatmap[weekday] {
+			report.Heatmap[weekday][hour] = roundTo(report.Heatmap[weekday][hour]/float64(weekdayDays[weekday]), 2)
+		}
+	}
+	if totalCheckOuts > 0 {
+		avg := roundTo(float64(totalDwell)/float64(totalCheckOuts), 1)
+		report.AverageDwellMinutes = &avg
+	}
+	for _, d := range daily {
+		report.Daily = append(report.Daily, *d)
+	}
+	sort.Slice(report.Daily, func(i, j int) bool { return report.Daily[i].Date.Before(report.Daily[j].Date) })
+	return report, nil
+}
+
+type hourBucket struct {
+	checkIns     int
+	users        map[int64]bool
+	checkOuts    int
+	dwellMinutes int64
+	peak         int
+}
+
+type occupancyEvent struct {
+	at    time.Time
+	delta int
+}
+
+// aggregateCheckIns computes the hourly rows of one day, per device and for all devices.
+// checkIns may start before the day; they only count towards occupancy.
+func aggregateCheckIns(day time.Time, checkIns []models.CheckIn) []models.CheckInHourlyStat {
+	dayEnd := day.AddDate(0, 0, 1)
+
+	byDevice := map[string][]models.CheckIn{AllDevices: checkIns}
+	for _, ci := range checkIns {
+		byDevice[ci.DeviceID] = append(byDevice[ci.DeviceID], ci)
+	}
+
+	var rows []models.CheckInHourlyStat
+	for deviceID, list := range byDevice {
+		var buckets [24]hourBucket
+		var events []occupancyEvent
+
+		for _, ci := range list {
+			leave := ci.CheckInTime.Add(occupancyWindow)
+			dwell, hasDwell := checkInDwell(ci)
+			if hasDwell {
+				leave = *ci.CheckOutAt
+			}
+			events = append(events, occupancyEvent{ci.CheckInTime, 1}, occupancyEvent{leave, -1})
+
+			if ci.CheckInTime.Before(day) || !ci.CheckInTime.Before(dayEnd) {
+				continue
+			}
+			b := &buckets[ci.CheckInTime.Hour()]
+			b.checkIns++
+			if b.users == nil {
+				b.users = make(map[int64]bool)
+			}
+			b.users[ci.UserID] = true
+			if hasDwell {
+				b.checkOuts++
+				b.dwellMinutes += int64(dwell / time.Minute)
+			}
+		}
+
+		// Sweep the enter/leave events to find the peak level within each hour
+		sort.Slice(events, func(i, j int) bool {
+			if events[i].at.Equal(events[j].at) {
+				return events[i].delta < events[j].delta
+			}
+			return events[i].at.Before(events[j].at)
+		})
+		level, next := 0, 0
+		for next < len(events) && events[next].at.Before(day) {
+			level += events[next].delta
+			next++
+		}
+		for hour := 0; hour < 24; hour++ {
+			hourEnd := day.Add(time.Duration(hour+1) * time.Hour)
+			peak := level
+			for next < len(events) && events[next].at.Before(hourEnd) {
+				level += events[next].delta
+				if level > peak {
+					peak = level
+				}
+				next++
+			}
+			buckets[hour].peak = peak
+		}
+
+		for hour, b := range buckets {
+			if b.checkIns == 0 && b.peak == 0 {
+				continue
+			}
+			rows = append(rows, models.CheckInHourlyStat{
+				StatDate:      day,
+				Hour:          int8(hour),
+				DeviceID:      deviceID,
+				Weekday:       int8(weekdayIndex(day) + 1),
+				CheckIns:      b.checkIns,
+				UniqueUsers:   len(b.users),
+				CheckOuts:     b.checkOuts,
+				DwellMinutes:  b.dwellMinutes,
+				PeakOccupancy: b.peak,
+			})
+		}
+	}
+	return rows
+}
+
+// checkInDwell returns how long the member stayed, if a plausible exit was recorded
+func checkInDwell(ci models.CheckIn) (time.Duration, bool) {
+	if ci.CheckOutAt == nil || !ci.CheckOutAt.After(ci.CheckInTime) {
+		return 0, false
+	}
+	dwell := ci.CheckOutAt.Sub(ci.CheckInTime)
+	if dwell > maxDwell {
+		return 0, false
+	}
+	return dwell, true
+}
+
+// weekdayIndex returns 0 for Monday through 6 for Sunday
+func weekdayIndex(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
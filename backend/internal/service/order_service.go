@@ -0,0 +1,558 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/logger"
+	"gym-admin/pkg/metrics"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	OrderStatusPending   = models.OrderStatusPending
+	OrderStatusPaid      = models.OrderStatusPaid
+	OrderStatusCancelled = models.OrderStatusCancelled
+	OrderStatusClosed    = models.OrderStatusClosed
+	OrderStatusRefunded  = models.OrderStatusRefunded
+)
+
+const (
+	PaymentStatusPending = models.PaymentStatusPending
+	PaymentStatusSuccess = models.PaymentStatusSuccess
+	PaymentStatusFailed  = models.PaymentStatusFailed
+	PaymentStatusClosed  = models.PaymentStatusClosed
+)
+
+const (
+	OrderItemCard          = models.OrderItemCard
+	OrderItemLessonPackage = models.OrderItemLessonPackage
+	OrderItemTopUp         = models.OrderItemTopUp
+)
+
+const (
+	LessonPackageActive   = models.LessonPackageActive
+	LessonPackageUsedUp   = models.LessonPackageUsedUp
+	LessonPackageRefunded = models.LessonPackageRefunded
+)
+
+// orderPayTimeout is how long an order waits for payment before it is closed
+const orderPayTimeout = 30 * time.Minute
+
+var (
+	ErrOrderNotPayable = apperr.Conflict("ORDER_NOT_PAYABLE", "order is not awaiting payment")
+	ErrAmountMismatch  = apperr.New(http.StatusBadRequest, "AMOUNT_MISMATCH", "paid amount does not match the payment")
+	ErrTopUpByWallet   = apperr.New(http.StatusBadRequest, "TOP_UP_BY_WALLET", "wallet top-ups cannot be paid from the wallet")
+	ErrCoachLeft       = apperr.Conflict("COACH_LEFT", "coach has left")
+)
+
+// OrderItemInput is one line of a new order. ItemID is a card type ID for
+// cards and a coach ID for lesson packages, whose Quantity is the number of
+// sessions. Wallet top-ups have no ItemID and carry their Amount.
+type OrderItemInput struct {
+	ItemType  int8       `json:"item_type"`
+	ItemID    int64      `json:"item_id"`
+	Quantity  int        `json:"quantity"`
+	Amount    float64    `json:"amount"`
+	StartDate *time.Time `json:"start_date"`
+}
+
+type PayOrderResult struct {
+	Payment *models.Payment   `json:"payment"`
+	Params  map[string]string `json:"params,omitempty"`
+}
+
+type OrderService struct {
+	repo        repository.OrderRepository
+	userRepo    repository.UserRepository
+	cardRepo    repository.CardRepository
+	coachRepo   repository.CoachRepository
+	seqRepo     repository.SequenceRepository
+	cardService *CardService
+	promotions  *PromotionService
+	wallets     *WalletService
+	gateways    *payment.Registry
+	tx          repository.TxManager
+}
+
+func NewOrderService(repo repository.OrderRepository, userRepo repository.UserRepository, cardRepo repository.CardRepository, coachRepo repository.CoachRepository, seqRepo repository.SequenceRepository, cardService *CardService, promotions *PromotionService, wallets *WalletService, gateways *payment.Registry, tx repository.TxManager) *OrderService {
+	return &OrderService{
+		repo:        repo,
+		userRepo:    userRepo,
+		cardRepo:    cardRepo,
+		coachRepo:   coachRepo,
+		seqRepo:     seqRepo,
+		cardService: cardService,
+		promotions:  promotions,
+		wallets:     wallets,
+		gateways:    gateways,
+		tx:          tx,
+	}
+}
+
+// CreateOrder prices the items from the catalogue, applies the best
+// promotions and the coupon if any, and opens an order awaiting payment
+func (s *OrderService) CreateOrder(ctx context.Context, userID int64, source int8, inputs []OrderItemInput, couponCode string, operatorID *int64, remark string) (*models.Order, error) {
+	order, usages, err := s.priceOrder(ctx, userID, source, inputs, couponCode)
+	if err != nil {
+		return nil, err
+	}
+	order.Status = OrderStatusPending
+	order.ExpireAt = time.Now().Add(orderPayTimeout)
+	order.OperatorID = operatorID
+	order.Remark = remark
+
+	orderNo, err := nextSerialNo(ctx, s.seqRepo, "O", "order_no:")
+	if err != nil {
+		return nil, err
+	}
+	order.OrderNo = orderNo
+
+	if err := s.repo.Create(ctx, order, usages); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// PreviewOrder prices an order like CreateOrder without saving it
+func (s *OrderService) PreviewOrder(ctx context.Context, userID int64, source int8, inputs []OrderItemInput, couponCode string) (*models.Order, error) {
+	order, _, err := s.priceOrder(ctx, userID, source, inputs, couponCode)
+	return order, err
+}
+
+func (s *OrderService) priceOrder(ctx context.Context, userID int64, source int8, inputs []OrderItemInput, couponCode string) (*models.Order, []models.PromotionUsage, error) {
+	if len(inputs) == 0 {
+		return nil, nil, apperr.Invalid("order has no items")
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, nil, notFound(err, ErrUserNotFound)
+	}
+	if source == 0 {
+		source = 1
+	}
+
+	order := &models.Order{UserID: userID, Source: source}
+	for _, in := range inputs {
+		item, err := s.buildItem(ctx, in)
+		if err != nil {
+			return nil, nil, err
+		}
+		order.Items = append(order.Items, *item)
+	}
+
+	usages, err := s.promotions.ApplyPromotions(ctx, userID, order.Items, couponCode)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, item := range order.Items {
+		order.TotalAmount += item.UnitPrice * float64(item.Quantity)
+		order.DiscountAmount += item.DiscountAmount
+		order.PayAmount += item.Amount
+	}
+	order.TotalAmount = roundTo(order.TotalAmount, 2)
+	order.DiscountAmount = roundTo(order.DiscountAmount, 2)
+	order.PayAmount = roundTo(order.PayAmount, 2)
+	return order, usages, nil
+}
+
+func (s *OrderService) buildItem(ctx context.Context, in OrderItemInput) (*models.OrderItem, error) {
+	item := &models.OrderItem{ItemType: in.ItemType, ItemID: in.ItemID, Quantity: in.Quantity}
+	switch in.ItemType {
+	case OrderItemCard:
+		cardType, err := s.cardRepo.GetCardTypeByID(ctx, in.ItemID)
+		if err != nil {
+			return nil, notFound(err, ErrCardTypeNotFound)
+		}
+		if cardType.Status != 1 {
+			return nil, ErrCardTypeDisabled
+		}
+		// One card per item, each with its own start date
+		item.Quantity = 1
+		item.ItemName = cardType.TypeName
+		item.UnitPrice = cardType.Price
+		if in.StartDate != nil {
+			start := truncateToDate(*in.StartDate)
+			if start.Before(truncateToDate(time.Now())) {
+				return nil, apperr.Invalid("start date is in the past")
+			}
+			item.StartDate = &start
+		}
+	case OrderItemLessonPackage:
+		coach, err := s.coachRepo.GetByID(ctx, in.ItemID)
+		if err != nil {
+			return nil, notFound(err, ErrCoachNotFound)
+		}
+		if coach.Status != 1 {
+			return nil, ErrCoachLeft
+		}
+		if in.Quantity < 1 {
+			return nil, apperr.Invalid("lesson package needs at least one session")
+		}
+		item.ItemName = fmt.Sprintf("%s私教课%d节", coach.Name, in.Quantity)
+		item.UnitPrice = coach.HourlyRate
+	case OrderItemTopUp:
+		amount := roundTo(in.Amount, 2)
+		if amount <= 0 {
+			return nil, apperr.Invalid("top-up amount must be positive")
+		}
+		bonus, err := s.wallets.TopUpBonus(ctx, amount)
+		if err != nil {
+			return nil, err
+		}
+		item.ItemID = 0
+		item.Quantity = 1
+		item.ItemName = "储值充值"
+		item.UnitPrice = amount
+		item.BonusAmount = bonus
+	default:
+		return nil, apperr.Invalid("invalid item type")
+	}
+	item.Amount = roundTo(item.UnitPrice*float64(item.Quantity)-item.DiscountAmount, 2)
+	return item, nil
+}
+
+func (s *OrderService) GetOrder(ctx context.Context, id int64) (*models.Order, error) {
+	order, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	return order, nil
+}
+
+func (s *OrderService) ListOrders(ctx context.Context, q listquery.Query) ([]models.Order, listquery.Page, error) {
+	return s.repo.List(ctx, q)
+}
+
+func (s *OrderService) ListPayments(ctx context.Context, orderID int64) ([]models.Payment, error) {
+	return s.repo.ListPayments(ctx, orderID)
+}
+
+// CancelOrder cancels an order that has not been paid
+func (s *OrderService) CancelOrder(ctx context.Context, id int64) error {
+	ok, err := s.repo.CloseUnpaid(ctx, id, OrderStatusCancelled)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrOrderNotPayable
+	}
+	return nil
+}
+
+// CloseExpiredOrders closes unpaid orders past their deadline, run as a background job
+func (s *OrderService) CloseExpiredOrders(ctx context.Context) error {
+	n, err := s.repo.CloseExpired(ctx, time.Now())
+	if n > 0 {
+		logger.Info("Closed expired orders", zap.Int64("count", n))
+	}
+	return err
+}
+
+// PayOrder starts a payment of the order with the given method. Offline
+// methods settle on the spot and fulfil the order right away; online methods
+// return the parameters the client needs and settle on notification.
+func (s *OrderService) PayOrder(ctx context.Context, orderID int64, method int8, reference, clientIP string) (*PayOrderResult, error) {
+	order, err := s.repo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	if order.Status != OrderStatusPending {
+		return nil, ErrOrderNotPayable
+	}
+	if time.Now().After(order.ExpireAt) {
+		if _, err := s.repo.CloseUnpaid(ctx, order.ID, OrderStatusClosed); err != nil {
+			return nil, err
+		}
+		return nil, ErrOrderNotPayable
+	}
+
+	gateway, err := s.gateways.Get(method)
+	if err != nil {
+		return nil, err
+	}
+	if method == payment.MethodWallet {
+		for _, item := range order.Items {
+			if item.ItemType == OrderItemTopUp {
+				return nil, ErrTopUpByWallet
+			}
+		}
+	}
+
+	paymentNo, err := nextSerialNo(ctx, s.seqRepo, "P", "payment_no:")
+	if err != nil {
+		return nil, err
+	}
+	p := &models.Payment{
+		PaymentNo: paymentNo,
+		OrderID:   order.ID,
+		Method:    method,
+		Amount:    order.PayAmount,
+		Status:    PaymentStatusPending,
+	}
+	if err := s.repo.CreatePayment(ctx, p); err != nil {
+		return nil, err
+	}
+	metrics.Payments.WithLabelValues(gateway.Name(), "started").Inc()
+
+	req := &payment.PayRequest{
+		PaymentNo: p.PaymentNo,
+		UserID:    order.UserID,
+		Amount:    p.Amount,
+		Subject:   orderSubject(order),
+		ClientIP:  clientIP,
+		Reference: reference,
+		ExpireAt:  order.ExpireAt,
+	}
+	var result *payment.PayResult
+	var paid *repository.MarkPaidResult
+	if settlesOnTheSpot(method) {
+		// The order is claimed, paid and, from the wallet, debited in one unit
+		// of work, so that concurrent payments cannot both take the money and
+		// a failure leaves neither
+		err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+			claimed, err := repos.Orders.ClaimPending(ctx, order.ID)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				return ErrOrderNotPayable
+			}
+			if result, err = gateway.Pay(ctx, req); err != nil {
+				return err
+			}
+			paid, err = repos.Orders.MarkPaid(ctx, p.PaymentNo, result.TradeNo, result.PaidAt, "")
+			return err
+		})
+	} else {
+		result, err = gateway.Pay(ctx, req)
+	}
+	if err != nil {
+		if uerr := s.repo.UpdatePaymentStatus(ctx, p.PaymentNo, PaymentStatusPending, PaymentStatusFailed); uerr != nil {
+			logger.Error("Failed to mark payment failed", zap.String("payment_no", p.PaymentNo), zap.Error(uerr))
+		}
+		metrics.Payments.WithLabelValues(gateway.Name(), "failed").Inc()
+		return nil, err
+	}
+
+	if result.Paid {
+		if paid != nil {
+			s.afterPaid(ctx, gateway.Name(), p.PaymentNo, paid)
+		} else if err := s.completePayment(ctx, gateway.Name(), p.PaymentNo, result.TradeNo, result.PaidAt, ""); err != nil {
+			return nil, err
+		}
+		if p, err = s.repo.GetPaymentByNo(ctx, p.PaymentNo); err != nil {
+			return nil, err
+		}
+	}
+	return &PayOrderResult{Payment: p, Params: result.Params}, nil
+}
+
+// HandleNotify verifies and applies an asynchronous payment callback. Repeated
+// callbacks for the same payment are acknowledged without side effects.
+func (s *OrderService) HandleNotify(ctx context.Context, gateway payment.Gateway, r *http.Request) error {
+	n, err := gateway.ParseNotify(r)
+	if err != nil {
+		logger.Warn("Rejected payment notification", zap.String("gateway", gateway.Name()), zap.Error(err))
+		return err
+	}
+	if !n.Paid {
+		return nil
+	}
+
+	p, err := s.repo.GetPaymentByNo(ctx, n.PaymentNo)
+	if err != nil {
+		return fmt.Errorf("payment %s not found", n.PaymentNo)
+	}
+	if p.Method != gateway.Method() {
+		return fmt.Errorf("payment %s was not made with %s", n.PaymentNo, gateway.Name())
+	}
+	if payment.ToFen(n.Amount) != payment.ToFen(p.Amount) {
+		logger.Error("Payment amount mismatch",
+			zap.String("payment_no", p.PaymentNo), zap.Float64("expected", p.Amount), zap.Float64("paid", n.Amount))
+		return ErrAmountMismatch
+	}
+
+	paidAt := n.PaidAt
+	if paidAt.IsZero() {
+		paidAt = time.Now()
+	}
+	return s.completePayment(ctx, gateway.Name(), p.PaymentNo, n.TradeNo, paidAt, n.Raw)
+}
+
+// completePayment marks the payment made through the named gateway and its
+// order paid, then fulfils the order. A fulfilment failure is logged rather
+// than returned: the payment stands and FulfilOrder can be retried.
+func (s *OrderService) completePayment(ctx context.Context, gatewayName, paymentNo, tradeNo string, paidAt time.Time, raw string) error {
+	var res *repository.MarkPaidResult
+	err := s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		var err error
+		res, err = repos.Orders.MarkPaid(ctx, paymentNo, tradeNo, paidAt, raw)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	s.afterPaid(ctx, gatewayName, paymentNo, res)
+	return nil
+}
+
+// afterPaid counts a payment MarkPaid recorded and fulfils its order
+func (s *OrderService) afterPaid(ctx context.Context, gatewayName, paymentNo string, res *repository.MarkPaidResult) {
+	if res.Changed {
+		metrics.Payments.WithLabelValues(gatewayName, "paid").Inc()
+	}
+	order := res.Order
+	if res.Duplicate {
+		// Only online payments get here, as payments settled on the spot claim
+		// the order first: the member paid twice through different channels,
+		// after cancelling, or so late that the order's promotions have gone
+		// to others, and the money has to be refunded by hand
+		logger.Warn("Payment succeeded for an order that is not payable",
+			zap.Int64("order_id", order.ID), zap.String("payment_no", paymentNo))
+	}
+	if order.Status == OrderStatusPaid && order.FulfilledAt == nil {
+		if err := s.fulfil(ctx, order); err != nil {
+			logger.Error("Order fulfilment failed", zap.Int64("order_id", order.ID), zap.Error(err))
+		}
+	}
+}
+
+// FulfilOrder delivers a paid order that has not been fulfilled yet
+func (s *OrderService) FulfilOrder(ctx context.Context, id int64) (*models.Order, error) {
+	order, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	if order.Status != OrderStatusPaid {
+		return nil, ErrOrderNotPaid
+	}
+	if order.FulfilledAt == nil {
+		if err := s.fulfil(ctx, order); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+// fulfil issues a card for each card item, a lesson package for each lesson
+// item and credits each top-up to the wallet, all in one unit of work. The
+// order is fulfilled at most once.
+func (s *OrderService) fulfil(ctx context.Context, order *models.Order) error {
+	// Cards get their numbers up front, as the unit of work may run more than once
+	cards := make(map[int64]*models.MembershipCard)
+	for _, item := range order.Items {
+		if item.ItemType != OrderItemCard {
+			continue
+		}
+		cardType, err := s.cardRepo.GetCardTypeByID(ctx, item.ItemID)
+		if err != nil {
+			return fmt.Errorf("card type %d not found", item.ItemID)
+		}
+		start := time.Now()
+		if item.StartDate != nil && item.StartDate.After(start) {
+			start = *item.StartDate
+		}
+		var end *time.Time
+		if item.BonusDays > 0 && cardType.DurationType != CardDurationTimes {
+			e := cardEndDate(cardType, truncateToDate(start)).AddDate(0, 0, item.BonusDays)
+			end = &e
+		}
+		card, err := s.cardService.BuildCard(ctx, order.UserID, cardType, start, end, item.Amount, order.Source)
+		if err != nil {
+			return err
+		}
+		card.OrderID = &order.ID
+		card.PromotionID = item.PromotionID
+		card.OperatorID = order.OperatorID
+		cards[item.ID] = card
+	}
+
+	return s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		fulfilled, err := repos.Orders.MarkFulfilled(ctx, order.ID)
+		if err != nil || !fulfilled {
+			return err
+		}
+
+		for _, item := range order.Items {
+			var refID int64
+			switch item.ItemType {
+			case OrderItemCard:
+				card := cards[item.ID]
+				if err := s.cardService.SaveCard(ctx, card); err != nil {
+					return err
+				}
+				refID = card.ID
+			case OrderItemLessonPackage:
+				pkg := &models.LessonPackage{
+					UserID:            order.UserID,
+					CoachID:           item.ItemID,
+					OrderID:           &order.ID,
+					TotalSessions:     item.Quantity,
+					RemainingSessions: item.Quantity,
+					UnitPrice:         roundTo(item.Amount/float64(item.Quantity), 2),
+					Status:            LessonPackageActive,
+				}
+				if err := repos.Orders.CreateLessonPackage(ctx, pkg); err != nil {
+					return err
+				}
+				refID = pkg.ID
+			case OrderItemTopUp:
+				refID, err = repos.Wallets.TopUp(ctx, &repository.WalletTopUp{
+					UserID:     order.UserID,
+					Amount:     item.Amount,
+					Bonus:      item.BonusAmount,
+					Reference:  fmt.Sprintf("%s-%d", order.OrderNo, item.ID),
+					OrderID:    &order.ID,
+					OperatorID: order.OperatorID,
+				})
+				if err != nil {
+					return err
+				}
+			default:
+				continue
+			}
+			if err := repos.Orders.SetItemFulfilment(ctx, item.ID, refID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// settlesOnTheSpot reports whether a payment method settles inside Pay without
+// calling out of the process, so that it can run in a database transaction
+func settlesOnTheSpot(method int8) bool {
+	switch method {
+	case payment.MethodCash, payment.MethodPOS, payment.MethodWallet:
+		return true
+	}
+	return false
+}
+
+// nextSerialNo allocates numbers like O20240101000001 from a per-day sequence
+func nextSerialNo(ctx context.Context, seqRepo repository.SequenceRepository, prefix, sequence string) (string, error) {
+	date := time.Now().Format("20060102")
+	seq, err := seqRepo.Next(ctx, sequence+date)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate number: %w", err)
+	}
+	return fmt.Sprintf("%s%s%06d", prefix, date, seq), nil
+}
+
+func orderSubject(order *models.Order) string {
+	if len(order.Items) == 0 {
+		return order.OrderNo
+	}
+	if len(order.Items) == 1 {
+		return order.Items[0].ItemName
+	}
+	return fmt.Sprintf("%s等%d件", order.Items[0].ItemName, len(order.Items))
+}
@@ -0,0 +1,196 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/internal/repository/fake"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/payment"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newOrderService(orders *fake.OrderRepository, cards *fake.CardRepository, tx *fake.TxManager) *service.OrderService {
+	seq := sequence()
+	cardService := service.NewCardService(cards, seq, tx)
+	gateways := payment.NewRegistry(service.NewWalletGateway(&fake.WalletRepository{}, tx))
+	return service.NewOrderService(orders, nil, cards, nil, seq, cardService, nil, nil, gateways, tx)
+}
+
+func TestFulfilOrder(t *testing.T) {
+	order := &models.Order{ID: 7, OrderNo: "O1", UserID: 3, Status: service.OrderStatusPaid, Items: []models.OrderItem{
+		{ID: 71, ItemType: service.OrderItemCard, ItemID: 5, Quantity: 1, Amount: 300},
+		{ID: 72, ItemType: service.OrderItemLessonPackage, ItemID: 8, Quantity: 10, Amount: 2000},
+		{ID: 73, ItemType: service.OrderItemTopUp, Quantity: 1, Amount: 500, BonusAmount: 50},
+	}}
+	orders := &fake.OrderRepository{
+		GetByIDFunc: func(ctx context.Context, id int64) (*models.Order, error) {
+			o := *order
+			return &o, nil
+		},
+	}
+	cards := &fake.CardRepository{
+		GetCardTypeByIDFunc: func(ctx context.Context, id int64) (*models.CardType, error) {
+			return &models.CardType{ID: id, DurationType: service.CardDurationMonth, DurationValue: 1, Status: 1}, nil
+		},
+	}
+
+	linked := make(map[int64]int64)
+	var pkg *models.LessonPackage
+	var topUp *repository.WalletTopUp
+	var log *models.CardOperationLog
+	txOrders := &fake.OrderRepository{
+		MarkFulfilledFunc: func(ctx context.Context, orderID int64) (bool, error) {
+			return true, nil
+		},
+		CreateLessonPackageFunc: func(ctx context.Context, p *models.LessonPackage) error {
+			p.ID = 200
+			pkg = p
+			return nil
+		},
+		SetItemFulfilmentFunc: func(ctx context.Context, itemID, refID int64) error {
+			linked[itemID] = refID
+			return nil
+		},
+	}
+	txCards := &fake.CardRepository{
+		CreateFunc: func(ctx context.Context, card *models.MembershipCard) error {
+			card.ID = 100
+			return nil
+		},
+		CreateLogFunc: func(ctx context.Context, l *models.CardOperationLog) error {
+			log = l
+			return nil
+		},
+	}
+	txWallets := &fake.WalletRepository{
+		TopUpFunc: func(ctx context.Context, t *repository.WalletTopUp) (int64, error) {
+			topUp = t
+			return 300, nil
+		},
+	}
+	tx := &fake.TxManager{Repos: &repository.Repositories{Orders: txOrders, Cards: txCards, Wallets: txWallets}}
+
+	if _, err := newOrderService(orders, cards, tx).FulfilOrder(context.Background(), order.ID); err != nil {
+		t.Fatalf("FulfilOrder: %v", err)
+	}
+
+	if want := map[int64]int64{71: 100, 72: 200, 73: 300}; !reflect.DeepEqual(linked, want) {
+		t.Errorf("linked items = %v, want %v", linked, want)
+	}
+	if log == nil || log.CardID != 100 || log.Amount != 300 {
+		t.Errorf("opening log = %+v", log)
+	}
+	if pkg == nil || pkg.TotalSessions != 10 || pkg.RemainingSessions != 10 || pkg.UnitPrice != 200 {
+		t.Errorf("lesson package = %+v", pkg)
+	}
+	if topUp == nil || topUp.Amount != 500 || topUp.Bonus != 50 || topUp.Reference != "O1-73" {
+		t.Errorf("top-up = %+v", topUp)
+	}
+}
+
+func TestFulfilOrderOnlyOnce(t *testing.T) {
+	orders := &fake.OrderRepository{
+		GetByIDFunc: func(ctx context.Context, id int64) (*models.Order, error) {
+			return &models.Order{ID: id, Status: service.OrderStatusPaid, Items: []models.OrderItem{
+				{ID: 81, ItemType: service.OrderItemTopUp, Quantity: 1, Amount: 100},
+			}}, nil
+		},
+	}
+	// Fulfilled by someone else in the meantime: no top-up is stubbed, so
+	// crediting it again would panic
+	txOrders := &fake.OrderRepository{
+		MarkFulfilledFunc: func(ctx context.Context, orderID int64) (bool, error) {
+			return false, nil
+		},
+	}
+	tx := &fake.TxManager{Repos: &repository.Repositories{Orders: txOrders, Wallets: &fake.WalletRepository{}}}
+
+	if _, err := newOrderService(orders, &fake.CardRepository{}, tx).FulfilOrder(context.Background(), 8); err != nil {
+		t.Fatalf("FulfilOrder: %v", err)
+	}
+}
+
+func TestPayOrderFromWallet(t *testing.T) {
+	errMarkPaid := errors.New("mark paid failed")
+	tests := []struct {
+		name        string
+		claimed     bool
+		markPaidErr error
+		wantErr     error
+		wantSpent   bool
+		wantStatus  int8 // what the payment attempt is left as
+	}{
+		{name: "paid", claimed: true, wantSpent: true, wantStatus: service.PaymentStatusSuccess},
+		{name: "claimed by another payment", claimed: false, wantErr: service.ErrOrderNotPayable, wantStatus: service.PaymentStatusFailed},
+		{name: "marking paid fails", claimed: true, markPaidErr: errMarkPaid, wantErr: errMarkPaid, wantSpent: true, wantStatus: service.PaymentStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Now()
+			status := service.PaymentStatusPending
+			var paymentNo string
+			orders := &fake.OrderRepository{
+				GetByIDFunc: func(ctx context.Context, id int64) (*models.Order, error) {
+					return &models.Order{ID: id, OrderNo: "O1", UserID: 3, Status: service.OrderStatusPending,
+						PayAmount: 300, ExpireAt: now.Add(time.Hour), Items: []models.OrderItem{
+							{ID: 11, ItemType: service.OrderItemCard, ItemName: "月卡", Amount: 300},
+						}}, nil
+				},
+				CreatePaymentFunc: func(ctx context.Context, p *models.Payment) error {
+					paymentNo = p.PaymentNo
+					return nil
+				},
+				UpdatePaymentStatusFunc: func(ctx context.Context, no string, from, to int8) error {
+					status = to
+					return nil
+				},
+				GetPaymentByNoFunc: func(ctx context.Context, no string) (*models.Payment, error) {
+					return &models.Payment{PaymentNo: no, Status: status}, nil
+				},
+			}
+
+			var spent *float64
+			txOrders := &fake.OrderRepository{
+				ClaimPendingFunc: func(ctx context.Context, id int64) (bool, error) {
+					return tt.claimed, nil
+				},
+				MarkPaidFunc: func(ctx context.Context, no, tradeNo string, paidAt time.Time, raw string) (*repository.MarkPaidResult, error) {
+					if tt.markPaidErr != nil {
+						return nil, tt.markPaidErr
+					}
+					status = service.PaymentStatusSuccess
+					return &repository.MarkPaidResult{Changed: true, Order: &models.Order{ID: 1, Status: service.OrderStatusPaid, FulfilledAt: &now}}, nil
+				},
+			}
+			txWallets := &fake.WalletRepository{
+				SpendFunc: func(ctx context.Context, userID int64, amount float64, reference string, orderID, operatorID *int64, remark string) (*models.WalletTransaction, error) {
+					if reference != paymentNo {
+						t.Errorf("spend reference = %q, want the payment number %q", reference, paymentNo)
+					}
+					spent = &amount
+					return &models.WalletTransaction{ID: 55, CreatedAt: now}, nil
+				},
+			}
+			tx := &fake.TxManager{Repos: &repository.Repositories{Orders: txOrders, Wallets: txWallets}}
+
+			_, err := newOrderService(orders, &fake.CardRepository{}, tx).PayOrder(context.Background(), 1, payment.MethodWallet, "", "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PayOrder error = %v, want %v", err, tt.wantErr)
+			}
+			if (spent != nil) != tt.wantSpent {
+				t.Errorf("wallet debited = %v, want %v", spent != nil, tt.wantSpent)
+			}
+			if spent != nil && *spent != 300 {
+				t.Errorf("debited %.2f, want 300", *spent)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("payment status = %d, want %d", status, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,777 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/logger"
+	"math"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	PointTxCheckIn  = models.PointTxCheckIn
+	PointTxStreak   = models.PointTxStreak
+	PointTxPurchase = models.PointTxPurchase
+	PointTxRedeem   = models.PointTxRedeem
+	PointTxExpire   = models.PointTxExpire
+	PointTxRefund   = models.PointTxRefund
+)
+
+const (
+	PointRuleCheckIn  int8 = 1
+	PointRuleStreak   int8 = 2
+	PointRulePurchase int8 = 3
+)
+
+const (
+	PointRewardCoupon   int8 = 1
+	PointRewardCardDays int8 = 2
+)
+
+const (
+	PointsStatusEnabled  int8 = 1
+	PointsStatusDisabled int8 = 2
+)
+
+const (
+	// Check-ins and payments are picked up by a periodic job; these bound how
+	// far back each run looks for ones that have not earned points yet
+	pointsCheckInLookback = 48 * time.Hour
+	pointsOrderLookback   = 7 * 24 * time.Hour
+	pointsExpireBatch     = 500
+	maxLeaderboardSize    = 100
+)
+
+var (
+	ErrPointsRuleNotFound = apperr.NotFound("POINTS_RULE_NOT_FOUND", "points rule not found")
+	ErrRewardNotFound     = apperr.NotFound("REWARD_NOT_FOUND", "reward not found")
+)
+
+// PointRuleInput describes an earning rule. Check-in rules give Points for the
+// first check-in of each day, streak rules give Points whenever the member's
+// run of consecutive training days reaches a multiple of StreakDays, and
+// purchase rules give Points for every full PerYuan paid.
+type PointRuleInput struct {
+	Name        string  `json:"name" binding:"required"`
+	RuleType    int8    `json:"rule_type" binding:"required,oneof=1 2 3"`
+	Points      int64   `json:"points" binding:"required,gt=0"`
+	StreakDays  int     `json:"streak_days"`
+	PerYuan     float64 `json:"per_yuan"`
+	ValidMonths int     `json:"valid_months"`
+}
+
+type PointRewardInput struct {
+	Name            string `json:"name" binding:"required"`
+	RewardType      int8   `json:"reward_type" binding:"required,oneof=1 2"`
+	Points          int64  `json:"points" binding:"required,gt=0"`
+	PromotionID     *int64 `json:"promotion_id"`
+	CouponValidDays int    `json:"coupon_valid_days"`
+	Days            int    `json:"days"`
+	Stock           int    `json:"stock"`
+}
+
+type PointsRankEntry struct {
+	Rank      int    `json:"rank"`
+	UserID    int64  `json:"user_id"`
+	Name      string `json:"name"` // 脱敏后的姓名
+	AvatarURL string `json:"avatar_url"`
+	Points    int64  `json:"points"`
+}
+
+type PointsLeaderboard struct {
+	Month string            `json:"month"`
+	List  []PointsRankEntry `json:"list"`
+	Me    *PointsRankEntry  `json:"me,omitempty"`
+}
+
+type PointsService struct {
+	repo          repository.PointsRepository
+	userRepo      repository.UserRepository
+	cardRepo      repository.CardRepository
+	promotionRepo repository.PromotionRepository
+	tx            repository.TxManager
+}
+
+func NewPointsService(repo repository.PointsRepository, userRepo repository.UserRepository, cardRepo repository.CardRepository, promotionRepo repository.PromotionRepository, tx repository.TxManager) *PointsService {
+	return &PointsService{
+		repo:          repo,
+		userRepo:      userRepo,
+		cardRepo:      cardRepo,
+		promotionRepo: promotionRepo,
+		tx:            tx,
+	}
+}
+
+// GetAccount returns the member's points account; members who never earned any have an empty one
+func (s *PointsService) GetAccount(ctx context.Context, userID int64) (*models.PointAccount, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	account, err := s.repo.GetAccount(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.PointAccount{UserID: userID}, nil
+	}
+	return account, err
+}
+
+func (s *PointsService) ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.PointTransaction, listquery.Page, error) {
+	return s.repo.ListTransactions(ctx, userID, q)
+}
+
+// Redeem exchanges points for a reward. Card day rewards need the card to
+// extend, which must be an active time card of the member.
+func (s *PointsService) Redeem(ctx context.Context, userID, rewardID int64, cardID *int64, operatorID int64) (*models.PointRedemption, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	reward, err := s.repo.GetReward(ctx, rewardID)
+	if err != nil {
+		return nil, notFound(err, ErrRewardNotFound)
+	}
+	if reward.Status != PointsStatusEnabled {
+		return nil, repository.ErrRewardUnavailable
+	}
+
+	redemption := &models.PointRedemption{UserID: userID, RewardID: reward.ID, Points: reward.Points}
+	var coupon *models.Coupon
+	var card *models.MembershipCard
+	var newEndDate time.Time
+	switch reward.RewardType {
+	case PointRewardCoupon:
+		if reward.PromotionID == nil {
+			return nil, apperr.Conflict("REWARD_UNAVAILABLE", "reward has no promotion")
+		}
+		code, err := s.newCouponCode(ctx)
+		if err != nil {
+			return nil, err
+		}
+		coupon = &models.Coupon{
+			Code:        code,
+			PromotionID: *reward.PromotionID,
+			UserID:      &userID,
+			MaxUses:     1,
+			Status:      CouponStatusActive,
+		}
+		if reward.CouponValidDays > 0 {
+			expireAt := time.Now().AddDate(0, 0, reward.CouponValidDays)
+			coupon.ExpireAt = &expireAt
+		}
+	case PointRewardCardDays:
+		if cardID == nil {
+			return nil, apperr.Invalid("card_id is required to redeem card days")
+		}
+		card, err = s.cardRepo.GetByID(ctx, *cardID)
+		if err != nil {
+			return nil, notFound(err, ErrCardNotFound)
+		}
+		if card.UserID != userID {
+			return nil, ErrCardNotFound
+		}
+		cardType, err := s.cardRepo.GetCardTypeByID(ctx, card.CardTypeID)
+		if err != nil {
+			return nil, notFound(err, ErrCardTypeNotFound)
+		}
+		if card.Status == CardStatusExpired {
+			return nil, ErrCardExpired
+		}
+		if card.Status != CardStatusNormal || cardType.DurationType == CardDurationTimes {
+			return nil, ErrCardNotExtendable
+		}
+		newEndDate = card.EndDate.AddDate(0, 0, reward.Days)
+		redemption.Days = reward.Days
+	default:
+		return nil, apperr.Invalid("invalid reward type")
+	}
+
+	// Stock, balance and card are all checked in the unit of work, so
+	// concurrent redemptions cannot oversell or overspend
+	err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		if err := repos.Points.TakeReward(ctx, reward.ID); err != nil {
+			return err
+		}
+		if coupon != nil {
+			coupons := []models.Coupon{*coupon}
+			if err := repos.Promotions.CreateCoupons(ctx, coupons); err != nil {
+				return err
+			}
+			redemption.CouponID = &coupons[0].ID
+		}
+		if card != nil {
+			if err := extendCard(ctx, repos, card, newEndDate, CardOpRedeem, operatorID, reward.Name); err != nil {
+				return err
+			}
+			redemption.CardID = &card.ID
+		}
+		if err := repos.Points.CreateRedemption(ctx, redemption); err != nil {
+			return err
+		}
+		return repos.Points.Spend(ctx, userID, models.PointTransaction{
+			Type:       PointTxRedeem,
+			SourceID:   redemption.ID,
+			Points:     -reward.Points,
+			OccurredAt: redemption.CreatedAt,
+			Remark:     reward.Name,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return redemption, nil
+}
+
+// AwardPoints posts the points earned by recent check-ins and payments and
+// writes off expired points, run as a background job
+func (s *PointsService) AwardPoints(ctx context.Context) error {
+	enabled := PointsStatusEnabled
+	rules, err := s.repo.ListRules(ctx, &enabled)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	checkInPoints, err := s.awardCheckIns(ctx, rules, now)
+	if err != nil {
+		return err
+	}
+	purchasePoints, err := s.awardPurchases(ctx, rules, now)
+	if err != nil {
+		return err
+	}
+	expired, err := s.expirePoints(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	if checkInPoints > 0 || purchasePoints > 0 || expired > 0 {
+		logger.Info("Points updated",
+			zap.Int64("check_in_points", checkInPoints),
+			zap.Int64("purchase_points", purchasePoints),
+			zap.Int64("expired_points", expired))
+	}
+	return nil
+}
+
+// awardCheckIns gives the check-in and streak points for the first check-in
+// of each member's day
+func (s *PointsService) awardCheckIns(ctx context.Context, rules []models.PointRule, now time.Time) (int64, error) {
+	var checkInRules, streakRules []models.PointRule
+	for _, rule := range rules {
+		switch rule.RuleType {
+		case PointRuleCheckIn:
+			checkInRules = append(checkInRules, rule)
+		case PointRuleStreak:
+			streakRules = append(streakRules, rule)
+		}
+	}
+	if len(checkInRules) == 0 && len(streakRules) == 0 {
+		return 0, nil
+	}
+
+	since := truncateToDate(now.Add(-pointsCheckInLookback))
+	checkIns, err := s.repo.CheckInsSince(ctx, since)
+	if err != nil {
+		return 0, err
+	}
+	posted, err := s.repo.CheckInPointsSince(ctx, since)
+	if err != nil {
+		return 0, err
+	}
+	type userDay struct {
+		userID int64
+		day    time.Time
+	}
+	awarded := make(map[userDay]bool, len(posted))
+	for _, e := range posted {
+		awarded[userDay{e.UserID, truncateToDate(e.OccurredAt)}] = true
+	}
+
+	var total int64
+	for _, ci := range checkIns {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		day := truncateToDate(ci.CheckInTime)
+		key := userDay{ci.UserID, day}
+		if awarded[key] {
+			continue
+		}
+		awarded[key] = true
+
+		var entries []models.PointTransaction
+		if points, expireAt := sumRulePoints(checkInRules, ci.CheckInTime, nil); points > 0 {
+			entries = append(entries, models.PointTransaction{
+				Type:       PointTxCheckIn,
+				SourceID:   ci.ID,
+				Points:     points,
+				ExpireAt:   expireAt,
+				OccurredAt: ci.CheckInTime,
+			})
+		}
+		if len(streakRules) > 0 {
+			stats, err := s.userRepo.GetStats(ctx, ci.UserID)
+			if err != nil {
+				return total, err
+			}
+			// The streak only counts for the day it was last updated for
+			if stats.LastCheckInDate != nil && truncateToDate(*stats.LastCheckInDate).Equal(day) && stats.ContinuousDays > 0 {
+				reached := func(rule models.PointRule) bool {
+					return rule.StreakDays > 0 && stats.ContinuousDays%rule.StreakDays == 0
+				}
+				if points, expireAt := sumRulePoints(streakRules, ci.CheckInTime, reached); points > 0 {
+					entries = append(entries, models.PointTransaction{
+						Type:       PointTxStreak,
+						SourceID:   ci.ID,
+						Points:     points,
+						ExpireAt:   expireAt,
+						OccurredAt: ci.CheckInTime,
+						Remark:     fmt.Sprintf("连续训练%d天", stats.ContinuousDays),
+					})
+				}
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		n, err := s.earn(ctx, ci.UserID, entries)
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// awardPurchases gives purchase points for paid orders. Wallet top-ups earn
+// nothing, the spending from the wallet does; amounts refunded before the
+// points are posted do not count.
+func (s *PointsService) awardPurchases(ctx context.Context, rules []models.PointRule, now time.Time) (int64, error) {
+	var purchaseRules []models.PointRule
+	for _, rule := range rules {
+		if rule.RuleType == PointRulePurchase && rule.PerYuan > 0 {
+			purchaseRules = append(purchaseRules, rule)
+		}
+	}
+	if len(purchaseRules) == 0 {
+		return 0, nil
+	}
+
+	orders, err := s.repo.PaidOrdersSince(ctx, now.Add(-pointsOrderLookback))
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, order := range orders {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		var spent float64
+		for _, item := range order.Items {
+			if item.ItemType != OrderItemTopUp {
+				spent += item.Amount
+			}
+		}
+		spent -= order.RefundedAmount
+		if spent <= 0 || order.PaidAt == nil {
+			continue
+		}
+
+		var points int64
+		var expireAt *time.Time
+		for _, rule := range purchaseRules {
+			units := int64(math.Floor(roundTo(spent/rule.PerYuan, 6)))
+			if units > 0 {
+				p, e := sumRulePoints([]models.PointRule{rule}, *order.PaidAt, nil)
+				points += p * units
+				expireAt = earlierExpiry(expireAt, e)
+			}
+		}
+		if points == 0 {
+			continue
+		}
+		n, err := s.earn(ctx, order.UserID, []models.PointTransaction{{
+			Type:       PointTxPurchase,
+			SourceID:   order.ID,
+			Points:     points,
+			ExpireAt:   expireAt,
+			OccurredAt: *order.PaidAt,
+			Remark:     order.OrderNo,
+		}})
+		if err != nil {
+			return total, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// takeBackPurchasePoints takes back the purchase points of an order in
+// proportion to the part of its spending a completed refund paid back, as part
+// of the caller's unit of work. Top-ups earn no points, so amount leaves out
+// what the refund took off the wallet.
+func takeBackPurchasePoints(ctx context.Context, repos *repository.Repositories, order *models.Order, refund *models.Refund, amount float64, refundedAt time.Time) error {
+	if amount <= 0 {
+		return nil
+	}
+	earned, err := repos.Points.GetEntry(ctx, PointTxPurchase, order.ID)
+	if err != nil || earned == nil {
+		return err
+	}
+	var spent float64
+	for _, item := range order.Items {
+		if item.ItemType != OrderItemTopUp {
+			spent += item.Amount
+		}
+	}
+	if spent <= 0 {
+		return nil
+	}
+	points := int64(math.Round(float64(earned.Points) * math.Min(amount/spent, 1)))
+	if points <= 0 {
+		return nil
+	}
+	_, err = repos.Points.TakeBack(ctx, earned, models.PointTransaction{
+		Type:       PointTxRefund,
+		SourceID:   refund.ID,
+		Points:     -points,
+		OccurredAt: refundedAt,
+		Remark:     refund.RefundNo,
+	})
+	return err
+}
+
+func (s *PointsService) expirePoints(ctx context.Context, now time.Time) (int64, error) {
+	var total int64
+	for {
+		entries, err := s.repo.ListExpired(ctx, now, pointsExpireBatch)
+		if err != nil || len(entries) == 0 {
+			return total, err
+		}
+		for _, e := range entries {
+			if ctx.Err() != nil {
+				return total, ctx.Err()
+			}
+			var n int64
+			err := s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+				var err error
+				n, err = repos.Points.Expire(ctx, e.ID, e.UserID)
+				return err
+			})
+			if err != nil {
+				return total, err
+			}
+			total += n
+		}
+		if len(entries) < pointsExpireBatch {
+			return total, nil
+		}
+	}
+}
+
+// earn posts earned points in a unit of work, returning the points posted
+func (s *PointsService) earn(ctx context.Context, userID int64, entries []models.PointTransaction) (int64, error) {
+	var posted int64
+	err := s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		var err error
+		posted, err = repos.Points.Earn(ctx, userID, entries)
+		return err
+	})
+	return posted, err
+}
+
+// Leaderboard ranks members by the points they earned in the month. Members
+// with equal points share a rank. With a userID the member's own rank is
+// included even when outside the list.
+func (s *PointsService) Leaderboard(ctx context.Context, month time.Time, limit int, userID int64) (*PointsLeaderboard, error) {
+	if limit < 1 || limit > maxLeaderboardSize {
+		limit = 20
+	}
+	from := monthStart(month)
+	to := from.AddDate(0, 1, 0)
+
+	ranks, err := s.repo.Leaderboard(ctx, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(ranks))
+	for _, r := range ranks {
+		ids = append(ids, r.UserID)
+	}
+	users, err := s.userRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	usersByID := make(map[int64]models.User, len(users))
+	for _, u := range users {
+		usersByID[u.ID] = u
+	}
+
+	board := &PointsLeaderboard{Month: from.Format("2006-01"), List: make([]PointsRankEntry, 0, len(ranks))}
+	for i, r := range ranks {
+		rank := i + 1
+		if i > 0 && r.Points == ranks[i-1].Points {
+			rank = board.List[i-1].Rank
+		}
+		u := usersByID[r.UserID]
+		entry := PointsRankEntry{Rank: rank, UserID: r.UserID, Name: maskName(u.Name), AvatarURL: u.AvatarURL, Points: r.Points}
+		board.List = append(board.List, entry)
+		if r.UserID == userID {
+			me := entry
+			board.Me = &me
+		}
+	}
+
+	if userID > 0 && board.Me == nil {
+		points, err := s.repo.EarnedBetween(ctx, userID, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if points > 0 {
+			ahead, err := s.repo.CountEarnedMore(ctx, points, from, to)
+			if err != nil {
+				return nil, err
+			}
+			u, err := s.userRepo.GetByID(ctx, userID)
+			if err != nil {
+				return nil, err
+			}
+			board.Me = &PointsRankEntry{Rank: int(ahead) + 1, UserID: userID, Name: maskName(u.Name), AvatarURL: u.AvatarURL, Points: points}
+		}
+	}
+	return board, nil
+}
+
+func (s *PointsService) CreateRule(ctx context.Context, in PointRuleInput) (*models.PointRule, error) {
+	rule := &models.PointRule{Status: PointsStatusEnabled}
+	if err := applyPointRuleInput(rule, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *PointsService) UpdateRule(ctx context.Context, id int64, in PointRuleInput) (*models.PointRule, error) {
+	rule, err := s.repo.GetRule(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrPointsRuleNotFound)
+	}
+	if err := applyPointRuleInput(rule, in); err != nil {
+		return nil, err
+	}
+	err = s.repo.UpdateRule(ctx, id, map[string]interface{}{
+		"name":         rule.Name,
+		"rule_type":    rule.RuleType,
+		"points":       rule.Points,
+		"streak_days":  rule.StreakDays,
+		"per_yuan":     rule.PerYuan,
+		"valid_months": rule.ValidMonths,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetRule(ctx, id)
+}
+
+func (s *PointsService) SetRuleStatus(ctx context.Context, id int64, status int8) error {
+	if status != PointsStatusEnabled && status != PointsStatusDisabled {
+		return errInvalidStatus
+	}
+	if _, err := s.repo.GetRule(ctx, id); err != nil {
+		return notFound(err, ErrPointsRuleNotFound)
+	}
+	return s.repo.UpdateRule(ctx, id, map[string]interface{}{"status": status})
+}
+
+func (s *PointsService) ListRules(ctx context.Context, status *int8) ([]models.PointRule, error) {
+	return s.repo.ListRules(ctx, status)
+}
+
+func (s *PointsService) CreateReward(ctx context.Context, in PointRewardInput) (*models.PointReward, error) {
+	reward := &models.PointReward{Status: PointsStatusEnabled}
+	if err := s.applyPointRewardInput(ctx, reward, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateReward(ctx, reward); err != nil {
+		return nil, err
+	}
+	return reward, nil
+}
+
+func (s *PointsService) UpdateReward(ctx context.Context, id int64, in PointRewardInput) (*models.PointReward, error) {
+	reward, err := s.repo.GetReward(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrRewardNotFound)
+	}
+	if err := s.applyPointRewardInput(ctx, reward, in); err != nil {
+		return nil, err
+	}
+	err = s.repo.UpdateReward(ctx, id, map[string]interface{}{
+		"name":              reward.Name,
+		"reward_type":       reward.RewardType,
+		"points":            reward.Points,
+		"promotion_id":      reward.PromotionID,
+		"coupon_valid_days": reward.CouponValidDays,
+		"days":              reward.Days,
+		"stock":             reward.Stock,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetReward(ctx, id)
+}
+
+func (s *PointsService) SetRewardStatus(ctx context.Context, id int64, status int8) error {
+	if status != PointsStatusEnabled && status != PointsStatusDisabled {
+		return errInvalidStatus
+	}
+	if _, err := s.repo.GetReward(ctx, id); err != nil {
+		return notFound(err, ErrRewardNotFound)
+	}
+	return s.repo.UpdateReward(ctx, id, map[string]interface{}{"status": status})
+}
+
+func (s *PointsService) ListRewards(ctx context.Context, status *int8) ([]models.PointReward, error) {
+	return s.repo.ListRewards(ctx, status)
+}
+
+func (s *PointsService) newCouponCode(ctx context.Context) (string, error) {
+	for {
+		code, err := randomCouponCode()
+		if err != nil {
+			return "", err
+		}
+		exists, err := s.promotionRepo.CouponCodeExists(ctx, code)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return code, nil
+		}
+	}
+}
+
+func applyPointRuleInput(rule *models.PointRule, in PointRuleInput) error {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return errNameRequired
+	}
+	if in.Points <= 0 {
+		return apperr.Invalid("points must be positive")
+	}
+	if in.ValidMonths < 0 {
+		return apperr.Invalid("valid_months cannot be negative")
+	}
+	switch in.RuleType {
+	case PointRuleCheckIn:
+		in.StreakDays, in.PerYuan = 0, 0
+	case PointRuleStreak:
+		if in.StreakDays < 2 {
+			return apperr.Invalid("streak_days must be at least 2")
+		}
+		in.PerYuan = 0
+	case PointRulePurchase:
+		if in.PerYuan <= 0 {
+			return apperr.Invalid("per_yuan must be positive")
+		}
+		in.StreakDays = 0
+	default:
+		return apperr.Invalid("invalid rule type")
+	}
+	rule.Name = name
+	rule.RuleType = in.RuleType
+	rule.Points = in.Points
+	rule.StreakDays = in.StreakDays
+	rule.PerYuan = roundTo(in.PerYuan, 2)
+	rule.ValidMonths = in.ValidMonths
+	return nil
+}
+
+func (s *PointsService) applyPointRewardInput(ctx context.Context, reward *models.PointReward, in PointRewardInput) error {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return errNameRequired
+	}
+	if in.Points <= 0 {
+		return apperr.Invalid("points must be positive")
+	}
+	if in.Stock < 0 || in.CouponValidDays < 0 {
+		return apperr.Invalid("stock and coupon_valid_days cannot be negative")
+	}
+	switch in.RewardType {
+	case PointRewardCoupon:
+		if in.PromotionID == nil {
+			return apperr.Invalid("promotion_id is required for coupon rewards")
+		}
+		promotion, err := s.promotionRepo.GetByID(ctx, *in.PromotionID)
+		if err != nil {
+			return notFound(err, ErrPromotionNotFound)
+		}
+		if promotion.RequiresCoupon != 1 {
+			return ErrPromotionTakesNoCoupon
+		}
+		in.Days = 0
+	case PointRewardCardDays:
+		if in.Days < 1 {
+			return apperr.Invalid("days must be positive")
+		}
+		in.PromotionID, in.CouponValidDays = nil, 0
+	default:
+		return apperr.Invalid("invalid reward type")
+	}
+	reward.Name = name
+	reward.RewardType = in.RewardType
+	reward.Points = in.Points
+	reward.PromotionID = in.PromotionID
+	reward.CouponValidDays = in.CouponValidDays
+	reward.Days = in.Days
+	reward.Stock = in.Stock
+	return nil
+}
+
+// sumRulePoints adds up the points of the rules that apply. Points earned
+// together expire with the shortest-lived of their rules.
+func sumRulePoints(rules []models.PointRule, at time.Time, applies func(models.PointRule) bool) (int64, *time.Time) {
+	var points int64
+	var expireAt *time.Time
+	for _, rule := range rules {
+		if applies != nil && !applies(rule) {
+			continue
+		}
+		points += rule.Points
+		if rule.ValidMonths > 0 {
+			e := at.AddDate(0, rule.ValidMonths, 0)
+			expireAt = earlierExpiry(expireAt, &e)
+		}
+	}
+	return points, expireAt
+}
+
+func earlierExpiry(a, b *time.Time) *time.Time {
+	if a == nil {
+		return b
+	}
+	if b == nil || a.Before(*b) {
+		return a
+	}
+	return b
+}
+
+// maskName keeps the first character of a name, as shown on public boards
+func maskName(name string) string {
+	runes := []rune(name)
+	if len(runes) == 0 {
+		return ""
+	}
+	return string(runes[0]) + strings.Repeat("*", len(runes)-1)
+}
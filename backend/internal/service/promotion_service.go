@@ -0,0 +1,527 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	PromotionPercentOff int8 = 1
+	PromotionAmountOff  int8 = 2
+	PromotionFixedPrice int8 = 3
+	PromotionBonusDays  int8 = 4
+)
+
+const (
+	PromotionStatusOn  int8 = 1
+	PromotionStatusOff int8 = 2
+)
+
+const (
+	CouponStatusActive   = models.CouponStatusActive
+	CouponStatusDisabled = models.CouponStatusDisabled
+)
+
+const (
+	couponCodeLength   = 10
+	couponCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I to avoid misreading
+	maxCouponBatch     = 1000
+)
+
+var (
+	ErrCouponInvalid    = apperr.New(http.StatusBadRequest, "COUPON_INVALID", "coupon code is invalid or expired")
+	ErrCouponNotApplied = apperr.New(http.StatusBadRequest, "COUPON_NOT_APPLICABLE", "coupon does not apply to this order")
+	ErrCouponCodeExists = apperr.Conflict("COUPON_CODE_EXISTS", "coupon code already exists")
+)
+
+// PromotionInput holds the editable fields of a promotion
+type PromotionInput struct {
+	Name           string     `json:"name" binding:"required"`
+	RuleType       int8       `json:"rule_type" binding:"required,oneof=1 2 3 4"`
+	Value          float64    `json:"value"`
+	BuyDays        int        `json:"buy_days"`
+	ItemType       int8       `json:"item_type" binding:"omitempty,oneof=1 2"`
+	CardTypeIDs    []int64    `json:"card_type_ids"`
+	NewMembersOnly bool       `json:"new_members_only"`
+	RequiresCoupon bool       `json:"requires_coupon"`
+	StartAt        *time.Time `json:"start_at"`
+	EndAt          *time.Time `json:"end_at" binding:"omitempty,notbefore=StartAt"`
+	PerUserLimit   int        `json:"per_user_limit"`
+	TotalLimit     int        `json:"total_limit"`
+	Description    string     `json:"description"`
+}
+
+// CouponBatchInput generates Count random codes, or one code when Code is given
+type CouponBatchInput struct {
+	Code     string     `json:"code"`
+	Count    int        `json:"count"`
+	MaxUses  int        `json:"max_uses"`
+	UserID   *int64     `json:"user_id"`
+	ExpireAt *time.Time `json:"expire_at"`
+}
+
+type PromotionService struct {
+	repo     repository.PromotionRepository
+	cardRepo repository.CardRepository
+}
+
+func NewPromotionService(repo repository.PromotionRepository, cardRepo repository.CardRepository) *PromotionService {
+	return &PromotionService{
+		repo:     repo,
+		cardRepo: cardRepo,
+	}
+}
+
+func (s *PromotionService) CreatePromotion(ctx context.Context, in PromotionInput, operatorID int64) (*models.Promotion, error) {
+	promotion := &models.Promotion{Status: PromotionStatusOn, CreatedBy: operatorID}
+	if err := applyPromotionInput(promotion, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, promotion); err != nil {
+		return nil, err
+	}
+	return promotion, nil
+}
+
+// UpdatePromotion changes the rule of a promotion; orders already placed keep their price
+func (s *PromotionService) UpdatePromotion(ctx context.Context, id int64, in PromotionInput) (*models.Promotion, error) {
+	promotion, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrPromotionNotFound)
+	}
+	if err := applyPromotionInput(promotion, in); err != nil {
+		return nil, err
+	}
+	err = s.repo.Update(ctx, id, map[string]interface{}{
+		"name":             promotion.Name,
+		"rule_type":        promotion.RuleType,
+		"value":            promotion.Value,
+		"buy_days":         promotion.BuyDays,
+		"item_type":        promotion.ItemType,
+		"card_type_ids":    promotion.CardTypeIDs,
+		"new_members_only": promotion.NewMembersOnly,
+		"requires_coupon":  promotion.RequiresCoupon,
+		"start_at":         promotion.StartAt,
+		"end_at":           promotion.EndAt,
+		"per_user_limit":   promotion.PerUserLimit,
+		"total_limit":      promotion.TotalLimit,
+		"description":      promotion.Description,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *PromotionService) SetPromotionStatus(ctx context.Context, id int64, status int8) error {
+	if status != PromotionStatusOn && status != PromotionStatusOff {
+		return errInvalidStatus
+	}
+	if _, err := s.repo.GetByID(ctx, id); err != nil {
+		return notFound(err, ErrPromotionNotFound)
+	}
+	return s.repo.Update(ctx, id, map[string]interface{}{"status": status})
+}
+
+func (s *PromotionService) GetPromotion(ctx context.Context, id int64) (*models.Promotion, error) {
+	promotion, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrPromotionNotFound)
+	}
+	return promotion, nil
+}
+
+func (s *PromotionService) ListPromotions(ctx context.Context, q listquery.Query) ([]models.Promotion, listquery.Page, error) {
+	return s.repo.List(ctx, q)
+}
+
+func applyPromotionInput(p *models.Promotion, in PromotionInput) error {
+	switch in.RuleType {
+	case PromotionPercentOff:
+		if in.Value <= 0 || in.Value >= 100 {
+			return apperr.Invalid("percentage must be between 0 and 100")
+		}
+	case PromotionAmountOff, PromotionFixedPrice:
+		if in.Value < 0 {
+			return apperr.Invalid("amount must not be negative")
+		}
+	case PromotionBonusDays:
+		if in.Value < 1 || in.Value != float64(int(in.Value)) {
+			return apperr.Invalid("bonus days must be a positive whole number")
+		}
+		if in.ItemType == OrderItemLessonPackage {
+			return apperr.Invalid("bonus days only apply to cards")
+		}
+		in.ItemType = OrderItemCard
+	default:
+		return apperr.Invalid("invalid rule type")
+	}
+	if in.ItemType != 0 && in.ItemType != OrderItemCard && in.ItemType != OrderItemLessonPackage {
+		return apperr.Invalid("invalid item type")
+	}
+	if in.StartAt != nil && in.EndAt != nil && in.EndAt.Before(*in.StartAt) {
+		return apperr.Invalid("end time is before start time")
+	}
+	if in.PerUserLimit < 0 || in.TotalLimit < 0 {
+		return apperr.Invalid("limits must not be negative")
+	}
+
+	ids := make([]string, 0, len(in.CardTypeIDs))
+	for _, id := range in.CardTypeIDs {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+
+	p.Name = in.Name
+	p.RuleType = in.RuleType
+	p.Value = in.Value
+	p.BuyDays = in.BuyDays
+	p.ItemType = in.ItemType
+	p.CardTypeIDs = strings.Join(ids, ",")
+	p.NewMembersOnly = boolToInt8(in.NewMembersOnly)
+	p.RequiresCoupon = boolToInt8(in.RequiresCoupon)
+	p.StartAt = in.StartAt
+	p.EndAt = in.EndAt
+	p.PerUserLimit = in.PerUserLimit
+	p.TotalLimit = in.TotalLimit
+	p.Description = in.Description
+	return nil
+}
+
+// CreateCoupons issues coupon codes for a promotion
+func (s *PromotionService) CreateCoupons(ctx context.Context, promotionID int64, in CouponBatchInput) ([]models.Coupon, error) {
+	if _, err := s.repo.GetByID(ctx, promotionID); err != nil {
+		return nil, notFound(err, ErrPromotionNotFound)
+	}
+	if in.MaxUses < 1 {
+		in.MaxUses = 1
+	}
+
+	var codes []string
+	if in.Code != "" {
+		code := strings.ToUpper(strings.TrimSpace(in.Code))
+		exists, err := s.repo.CouponCodeExists(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrCouponCodeExists
+		}
+		codes = append(codes, code)
+	} else {
+		if in.Count < 1 || in.Count > maxCouponBatch {
+			return nil, apperr.Invalid(fmt.Sprintf("count must be between 1 and %d", maxCouponBatch))
+		}
+		seen := make(map[string]bool, in.Count)
+		for len(codes) < in.Count {
+			code, err := randomCouponCode()
+			if err != nil {
+				return nil, err
+			}
+			exists, err := s.repo.CouponCodeExists(ctx, code)
+			if err != nil {
+				return nil, err
+			}
+			if !exists && !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+
+	coupons := make([]models.Coupon, 0, len(codes))
+	for _, code := range codes {
+		coupons = append(coupons, models.Coupon{
+			Code:        code,
+			PromotionID: promotionID,
+			UserID:      in.UserID,
+			MaxUses:     in.MaxUses,
+			Status:      CouponStatusActive,
+			ExpireAt:    in.ExpireAt,
+		})
+	}
+	if err := s.repo.CreateCoupons(ctx, coupons); err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+func (s *PromotionService) ListCoupons(ctx context.Context, promotionID int64, q listquery.Query) ([]models.Coupon, listquery.Page, error) {
+	return s.repo.ListCoupons(ctx, promotionID, q)
+}
+
+// ApplyPromotions picks the best promotion for each order item and returns
+// the usages to record with the order. Promotions don't stack: each item gets
+// its best automatic promotion, and a coupon replaces that on the item where
+// it saves the most on top of it.
+func (s *PromotionService) ApplyPromotions(ctx context.Context, userID int64, items []models.OrderItem, couponCode string) ([]models.PromotionUsage, error) {
+	now := time.Now()
+	candidates, err := s.repo.ListAutomatic(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var coupon *models.Coupon
+	var couponPromotion *models.Promotion
+	if couponCode != "" {
+		coupon, err = s.repo.GetCouponByCode(ctx, strings.ToUpper(strings.TrimSpace(couponCode)))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponInvalid
+		}
+		if err != nil {
+			return nil, err
+		}
+		if coupon.Status != CouponStatusActive || coupon.UsedCount >= coupon.MaxUses ||
+			(coupon.ExpireAt != nil && coupon.ExpireAt.Before(now)) ||
+			(coupon.UserID != nil && *coupon.UserID != userID) {
+			return nil, ErrCouponInvalid
+		}
+		if couponPromotion, err = s.repo.GetByID(ctx, coupon.PromotionID); err != nil {
+			return nil, ErrCouponInvalid
+		}
+		if !promotionActive(couponPromotion, now) {
+			return nil, ErrCouponInvalid
+		}
+	}
+
+	eval := &promotionEvaluator{repo: s.repo, cardRepo: s.cardRepo, userID: userID, usedByOrder: make(map[int64]int)}
+
+	// Best automatic promotion per item first
+	chosen := make([]*appliedPromotion, len(items))
+	for i := range items {
+		for j := range candidates {
+			a, err := eval.apply(ctx, &candidates[j], &items[i])
+			if err != nil {
+				return nil, err
+			}
+			if a != nil && a.better(chosen[i]) {
+				chosen[i] = a
+			}
+		}
+		if chosen[i] != nil {
+			eval.usedByOrder[chosen[i].promotion.ID]++
+		}
+	}
+
+	// The coupon replaces the automatic choice on the item it improves most
+	couponItem := -1
+	if couponPromotion != nil {
+		var couponBest *appliedPromotion
+		var bestGain float64
+		for i := range items {
+			a, err := eval.apply(ctx, couponPromotion, &items[i])
+			if err != nil {
+				return nil, err
+			}
+			if a == nil || !a.better(chosen[i]) {
+				continue
+			}
+			gain := a.discount
+			if chosen[i] != nil {
+				gain -= chosen[i].discount
+			}
+			if couponBest == nil || gain > bestGain {
+				couponItem, couponBest, bestGain = i, a, gain
+			}
+		}
+		if couponBest == nil {
+			return nil, ErrCouponNotApplied
+		}
+		if chosen[couponItem] != nil {
+			eval.usedByOrder[chosen[couponItem].promotion.ID]--
+		}
+		chosen[couponItem] = couponBest
+	}
+
+	usages := make([]models.PromotionUsage, 0)
+	for i, best := range chosen {
+		if best == nil {
+			continue
+		}
+		usage := models.PromotionUsage{
+			PromotionID:    best.promotion.ID,
+			DiscountAmount: best.discount,
+			BonusDays:      best.bonusDays,
+			ItemIndex:      i,
+		}
+		items[i].PromotionID = &best.promotion.ID
+		if i == couponItem {
+			usage.CouponID = &coupon.ID
+			items[i].CouponID = &coupon.ID
+		}
+		items[i].DiscountAmount = best.discount
+		items[i].BonusDays = best.bonusDays
+		items[i].Amount = roundTo(items[i].UnitPrice*float64(items[i].Quantity)-best.discount, 2)
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+type appliedPromotion struct {
+	promotion *models.Promotion
+	discount  float64
+	bonusDays int
+}
+
+// better prefers the larger discount, then the more bonus days
+func (a *appliedPromotion) better(other *appliedPromotion) bool {
+	if other == nil {
+		return a.discount > 0 || a.bonusDays > 0
+	}
+	if a.discount != other.discount {
+		return a.discount > other.discount
+	}
+	return a.bonusDays > other.bonusDays
+}
+
+// promotionEvaluator checks the conditions of promotions for one member,
+// caching what it looks up along the way
+type promotionEvaluator struct {
+	repo        repository.PromotionRepository
+	cardRepo    repository.CardRepository
+	userID      int64
+	newMember   *bool
+	userUsages  map[int64]int64
+	usedByOrder map[int64]int // usages already assigned within the order being priced
+	cardTypes   map[int64]*models.CardType
+}
+
+// apply returns what the promotion does for the item, or nil if it does not apply
+func (e *promotionEvaluator) apply(ctx context.Context, p *models.Promotion, item *models.OrderItem) (*appliedPromotion, error) {
+	// Top-ups have their own bonus rules
+	if item.ItemType == OrderItemTopUp {
+		return nil, nil
+	}
+	if p.ItemType != 0 && p.ItemType != item.ItemType {
+		return nil, nil
+	}
+	if p.CardTypeIDs != "" {
+		if item.ItemType != OrderItemCard || !containsID(p.CardTypeIDs, item.ItemID) {
+			return nil, nil
+		}
+	}
+	if p.TotalLimit > 0 && p.UsedCount+e.usedByOrder[p.ID] >= p.TotalLimit {
+		return nil, nil
+	}
+	if p.NewMembersOnly == 1 {
+		if e.newMember == nil {
+			isNew, err := e.repo.IsNewMember(ctx, e.userID)
+			if err != nil {
+				return nil, err
+			}
+			e.newMember = &isNew
+		}
+		if !*e.newMember {
+			return nil, nil
+		}
+	}
+	if p.PerUserLimit > 0 {
+		if e.userUsages == nil {
+			e.userUsages = make(map[int64]int64)
+		}
+		used, ok := e.userUsages[p.ID]
+		if !ok {
+			var err error
+			if used, err = e.repo.CountUserUsages(ctx, p.ID, e.userID); err != nil {
+				return nil, err
+			}
+			e.userUsages[p.ID] = used
+		}
+		if used+int64(e.usedByOrder[p.ID]) >= int64(p.PerUserLimit) {
+			return nil, nil
+		}
+	}
+
+	base := item.UnitPrice * float64(item.Quantity)
+	a := &appliedPromotion{promotion: p}
+	switch p.RuleType {
+	case PromotionPercentOff:
+		a.discount = base * p.Value / 100
+	case PromotionAmountOff:
+		a.discount = p.Value
+	case PromotionFixedPrice:
+		a.discount = base - p.Value
+	case PromotionBonusDays:
+		if item.ItemType != OrderItemCard {
+			return nil, nil
+		}
+		cardType, err := e.cardType(ctx, item.ItemID)
+		if err != nil {
+			return nil, err
+		}
+		if cardType.DurationType == CardDurationTimes || cardType.DurationValue < p.BuyDays {
+			return nil, nil
+		}
+		a.bonusDays = int(p.Value)
+	}
+	if a.discount > base {
+		a.discount = base
+	}
+	if a.discount < 0 {
+		a.discount = 0
+	}
+	a.discount = roundTo(a.discount, 2)
+	return a, nil
+}
+
+func (e *promotionEvaluator) cardType(ctx context.Context, id int64) (*models.CardType, error) {
+	if e.cardTypes == nil {
+		e.cardTypes = make(map[int64]*models.CardType)
+	}
+	if ct, ok := e.cardTypes[id]; ok {
+		return ct, nil
+	}
+	ct, err := e.cardRepo.GetCardTypeByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	e.cardTypes[id] = ct
+	return ct, nil
+}
+
+func promotionActive(p *models.Promotion, now time.Time) bool {
+	return p.Status == PromotionStatusOn &&
+		(p.StartAt == nil || !now.Before(*p.StartAt)) &&
+		(p.EndAt == nil || !now.After(*p.EndAt))
+}
+
+func containsID(list string, id int64) bool {
+	for _, v := range strings.Split(list, ",") {
+		if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil && n == id {
+			return true
+		}
+	}
+	return false
+}
+
+func randomCouponCode() (string, error) {
+	b := make([]byte, couponCodeLength)
+	max := big.NewInt(int64(len(couponCodeAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = couponCodeAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func boolToInt8(b bool) int8 {
+	if b {
+		return 1
+	}
+	return 0
+}
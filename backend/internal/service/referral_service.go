@@ -0,0 +1,514 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/logger"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const UserSourceReferral int8 = 5
+
+const (
+	ReferralStatusPending  = models.ReferralStatusPending
+	ReferralStatusRewarded = models.ReferralStatusRewarded
+	ReferralStatusRevoked  = models.ReferralStatusRevoked
+)
+
+const (
+	ReferralRewardCardDays = models.ReferralRewardCardDays
+	ReferralRewardCoupon   = models.ReferralRewardCoupon
+)
+
+const (
+	ReferralRuleEnabled  = models.ReferralRuleEnabled
+	ReferralRuleDisabled = models.ReferralRuleDisabled
+)
+
+const (
+	referralCodeLength  = 6
+	referralRewardBatch = 200
+	maxReferrerRanking  = 100
+)
+
+var (
+	ErrInvalidReferralCode  = apperr.New(http.StatusBadRequest, "INVALID_REFERRAL_CODE", "invalid referral code")
+	ErrReferralRuleNotFound = apperr.NotFound("REFERRAL_RULE_NOT_FOUND", "referral rule not found")
+)
+
+// ReferralRuleInput describes the referrer's reward. Days go onto the
+// referrer's active time card; PromotionID gives a coupon to referrers who
+// have none. At least one of them must be set.
+type ReferralRuleInput struct {
+	Name            string `json:"name" binding:"required"`
+	Days            int    `json:"days"`
+	PromotionID     *int64 `json:"promotion_id"`
+	CouponValidDays int    `json:"coupon_valid_days"`
+}
+
+// ReferralInfo is a member's referral code and how their referrals did
+type ReferralInfo struct {
+	UserID       int64  `json:"user_id"`
+	ReferralCode string `json:"referral_code"`
+	ReferrerID   *int64 `json:"referrer_id"`
+	Referred     int64  `json:"referred"`    // 推荐注册人数
+	Rewarded     int64  `json:"rewarded"`    // 已获奖励次数
+	RewardDays   int64  `json:"reward_days"` // 累计获得会员卡天数
+	Coupons      int64  `json:"coupons"`     // 累计获得优惠券张数
+}
+
+type ReferralEntry struct {
+	models.Referral
+	RefereeName string `json:"referee_name"`
+}
+
+type ReferralReportRow struct {
+	Month      string `json:"month"`       // YYYY-MM
+	Referred   int64  `json:"referred"`    // 推荐注册人数
+	Rewarded   int64  `json:"rewarded"`    // 发放奖励次数
+	RewardDays int64  `json:"reward_days"` // 赠送会员卡天数
+	Coupons    int64  `json:"coupons"`     // 发放优惠券张数
+}
+
+type ReferralMemberRow struct {
+	UserID     int64  `json:"user_id"`
+	UserNo     string `json:"user_no"`
+	Name       string `json:"name"`
+	Referred   int64  `json:"referred"`
+	Rewarded   int64  `json:"rewarded"` // 已办付费卡并发放奖励的人数
+	RewardDays int64  `json:"reward_days"`
+	Coupons    int64  `json:"coupons"`
+}
+
+// ReferralReport counts referrals by the month they were made and rewards by
+// the month they were given, and ranks the referrers of the period
+type ReferralReport struct {
+	From    string              `json:"from"`
+	To      string              `json:"to"`
+	Totals  ReferralReportRow   `json:"totals"`
+	Rows    []ReferralReportRow `json:"rows"`
+	Members []ReferralMemberRow `json:"members"`
+}
+
+type ReferralService struct {
+	repo          repository.ReferralRepository
+	userRepo      repository.UserRepository
+	cardRepo      repository.CardRepository
+	promotionRepo repository.PromotionRepository
+	tx            repository.TxManager
+}
+
+func NewReferralService(repo repository.ReferralRepository, userRepo repository.UserRepository, cardRepo repository.CardRepository, promotionRepo repository.PromotionRepository, tx repository.TxManager) *ReferralService {
+	return &ReferralService{
+		repo:          repo,
+		userRepo:      userRepo,
+		cardRepo:      cardRepo,
+		promotionRepo: promotionRepo,
+		tx:            tx,
+	}
+}
+
+// GetInfo returns the member's referral code and referral totals. Members
+// registered before referrals existed get a code on first request.
+func (s *ReferralService) GetInfo(ctx context.Context, userID int64) (*ReferralInfo, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	for user.ReferralCode == nil {
+		code, err := newReferralCode(ctx, s.userRepo)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.userRepo.SetReferralCode(ctx, userID, code); err != nil {
+			return nil, err
+		}
+		if user, err = s.userRepo.GetByID(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	stats, err := s.repo.ReferrerSummary(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &ReferralInfo{
+		UserID:       userID,
+		ReferralCode: *user.ReferralCode,
+		ReferrerID:   user.ReferrerID,
+		Referred:     stats.Referred,
+		Rewarded:     stats.Rewarded,
+		RewardDays:   stats.RewardDays,
+		Coupons:      stats.Coupons,
+	}, nil
+}
+
+// ListReferrals lists the members the referrer brought in
+func (s *ReferralService) ListReferrals(ctx context.Context, referrerID int64, q listquery.Query) ([]ReferralEntry, listquery.Page, error) {
+	referrals, page, err := s.repo.ListByReferrer(ctx, referrerID, q)
+	if err != nil {
+		return nil, page, err
+	}
+
+	ids := make([]int64, 0, len(referrals))
+	for _, r := range referrals {
+		ids = append(ids, r.RefereeID)
+	}
+	users, err := s.userRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, page, err
+	}
+	names := make(map[int64]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Name
+	}
+
+	entries := make([]ReferralEntry, 0, len(referrals))
+	for _, r := range referrals {
+		entries = append(entries, ReferralEntry{Referral: r, RefereeName: names[r.RefereeID]})
+	}
+	return entries, page, nil
+}
+
+// RewardReferrals rewards the referrers whose referees' first paid card has
+// started. It runs as a periodic job; referrals that cannot be rewarded yet,
+// because no rule is enabled or the referrer has nothing to receive the
+// reward, are retried on the next run.
+func (s *ReferralService) RewardReferrals(ctx context.Context) error {
+	rule, err := s.repo.CurrentRule(ctx)
+	if err != nil || rule == nil {
+		return err
+	}
+	today := truncateToDate(time.Now())
+
+	var rewarded int
+	var afterID int64
+	for {
+		referrals, err := s.repo.ListPending(ctx, afterID, referralRewardBatch)
+		if err != nil {
+			return err
+		}
+		for i := range referrals {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			ok, err := s.reward(ctx, &referrals[i], rule, today)
+			if errors.Is(err, ErrCardNotExtendable) || errors.Is(err, repository.ErrReferralRewarded) {
+				// the card changed or another run got there first
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if ok {
+				rewarded++
+			}
+		}
+		if len(referrals) < referralRewardBatch {
+			break
+		}
+		afterID = referrals[len(referrals)-1].ID
+	}
+
+	if rewarded > 0 {
+		logger.Info("Referrals rewarded", zap.Int("count", rewarded), zap.Int64("rule_id", rule.ID))
+	}
+	return nil
+}
+
+func (s *ReferralService) reward(ctx context.Context, referral *models.Referral, rule *models.ReferralRule, today time.Time) (bool, error) {
+	card, err := s.repo.FirstPaidCard(ctx, referral.RefereeID, today)
+	if err != nil || card == nil {
+		return false, err
+	}
+
+	var active *models.MembershipCard
+	if rule.Days > 0 {
+		if active, err = s.repo.ActiveTimeCard(ctx, referral.ReferrerID, today); err != nil {
+			return false, err
+		}
+	}
+	var coupon *models.Coupon
+	if active != nil {
+		referral.RewardType = ReferralRewardCardDays
+		referral.RewardDays = rule.Days
+	} else {
+		if rule.PromotionID == nil {
+			return false, nil
+		}
+		code, err := s.newCouponCode(ctx)
+		if err != nil {
+			return false, err
+		}
+		coupon = &models.Coupon{
+			Code:        code,
+			PromotionID: *rule.PromotionID,
+			UserID:      &referral.ReferrerID,
+			MaxUses:     1,
+			Status:      CouponStatusActive,
+		}
+		if rule.CouponValidDays > 0 {
+			expireAt := time.Now().AddDate(0, 0, rule.CouponValidDays)
+			coupon.ExpireAt = &expireAt
+		}
+		referral.RewardType = ReferralRewardCoupon
+	}
+
+	referral.CardID = &card.ID
+	referral.RuleID = &rule.ID
+	// The card is only extended if its end date is still the one the reward
+	// was worked out from
+	err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		if coupon != nil {
+			coupons := []models.Coupon{*coupon}
+			if err := repos.Promotions.CreateCoupons(ctx, coupons); err != nil {
+				return err
+			}
+			referral.CouponID = &coupons[0].ID
+		}
+		if active != nil {
+			newEndDate := active.EndDate.AddDate(0, 0, rule.Days)
+			if err := extendCard(ctx, repos, active, newEndDate, CardOpReferral, 0, "推荐奖励"); err != nil {
+				return err
+			}
+			referral.RewardCardID = &active.ID
+		}
+		return repos.Referrals.MarkRewarded(ctx, referral)
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// revokeReferralReward takes back the reward a referrer got for the card,
+// which is being refunded, as part of the caller's unit of work. The days
+// come off the referrer's card while it still runs and an unused coupon is
+// voided; what the referrer already used stays theirs.
+func revokeReferralReward(ctx context.Context, repos *repository.Repositories, cardID, operatorID int64) error {
+	referral, err := repos.Referrals.GetRewardedByCard(ctx, cardID)
+	if err != nil || referral == nil {
+		return err
+	}
+	ok, err := repos.Referrals.MarkRevoked(ctx, referral.ID)
+	if err != nil || !ok {
+		return err
+	}
+
+	if referral.RewardCardID != nil && referral.RewardDays > 0 {
+		card, err := repos.Cards.GetByID(ctx, *referral.RewardCardID)
+		if err != nil {
+			return err
+		}
+		newEndDate := card.EndDate.AddDate(0, 0, -referral.RewardDays)
+		if newEndDate.Before(card.StartDate) {
+			newEndDate = card.StartDate
+		}
+		err = extendCard(ctx, repos, card, newEndDate, CardOpReferral, operatorID, "推荐奖励撤回")
+		if err != nil && !errors.Is(err, ErrCardNotExtendable) {
+			return err
+		}
+	}
+	if referral.CouponID != nil {
+		if _, err := repos.Promotions.VoidCoupon(ctx, *referral.CouponID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetReport builds the monthly referral report over [from, to], both months
+// inclusive, with the top referrers of the period
+func (s *ReferralService) GetReport(ctx context.Context, from, to time.Time, limit int) (*ReferralReport, error) {
+	fromMonth, toMonth := monthStart(from), monthStart(to)
+	if toMonth.Before(fromMonth) || toMonth.After(fromMonth.AddDate(0, maxRevenueMonths-1, 0)) {
+		return nil, ErrInvalidMonthRange
+	}
+	if limit < 1 || limit > maxReferrerRanking {
+		limit = 20
+	}
+
+	report := &ReferralReport{From: fromMonth.Format("2006-01"), To: toMonth.Format("2006-01")}
+	for m := fromMonth; !m.After(toMonth); m = m.AddDate(0, 1, 0) {
+		next := m.AddDate(0, 1, 0)
+		row := ReferralReportRow{Month: m.Format("2006-01")}
+		var err error
+		if row.Referred, row.Rewarded, err = s.repo.CountBetween(ctx, m, next); err != nil {
+			return nil, err
+		}
+		if row.RewardDays, row.Coupons, err = s.repo.RewardsBetween(ctx, m, next); err != nil {
+			return nil, err
+		}
+		report.Rows = append(report.Rows, row)
+
+		report.Totals.Referred += row.Referred
+		report.Totals.Rewarded += row.Rewarded
+		report.Totals.RewardDays += row.RewardDays
+		report.Totals.Coupons += row.Coupons
+	}
+	report.Totals.Month = report.From + "~" + report.To
+
+	stats, err := s.repo.TopReferrers(ctx, fromMonth, toMonth.AddDate(0, 1, 0), limit)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(stats))
+	for _, st := range stats {
+		ids = append(ids, st.ReferrerID)
+	}
+	users, err := s.userRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int64]models.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	report.Members = make([]ReferralMemberRow, 0, len(stats))
+	for _, st := range stats {
+		u := byID[st.ReferrerID]
+		report.Members = append(report.Members, ReferralMemberRow{
+			UserID:     st.ReferrerID,
+			UserNo:     u.UserNo,
+			Name:       u.Name,
+			Referred:   st.Referred,
+			Rewarded:   st.Rewarded,
+			RewardDays: st.RewardDays,
+			Coupons:    st.Coupons,
+		})
+	}
+	return report, nil
+}
+
+func (s *ReferralService) CreateRule(ctx context.Context, in ReferralRuleInput) (*models.ReferralRule, error) {
+	rule := &models.ReferralRule{Status: ReferralRuleEnabled}
+	if err := s.applyReferralRuleInput(ctx, rule, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *ReferralService) UpdateRule(ctx context.Context, id int64, in ReferralRuleInput) (*models.ReferralRule, error) {
+	rule, err := s.repo.GetRule(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrReferralRuleNotFound)
+	}
+	if err := s.applyReferralRuleInput(ctx, rule, in); err != nil {
+		return nil, err
+	}
+	err = s.repo.UpdateRule(ctx, id, map[string]interface{}{
+		"name":              rule.Name,
+		"days":              rule.Days,
+		"promotion_id":      rule.PromotionID,
+		"coupon_valid_days": rule.CouponValidDays,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetRule(ctx, id)
+}
+
+func (s *ReferralService) SetRuleStatus(ctx context.Context, id int64, status int8) error {
+	if status != ReferralRuleEnabled && status != ReferralRuleDisabled {
+		return errInvalidStatus
+	}
+	if _, err := s.repo.GetRule(ctx, id); err != nil {
+		return notFound(err, ErrReferralRuleNotFound)
+	}
+	return s.repo.UpdateRule(ctx, id, map[string]interface{}{"status": status})
+}
+
+func (s *ReferralService) ListRules(ctx context.Context, status *int8) ([]models.ReferralRule, error) {
+	return s.repo.ListRules(ctx, status)
+}
+
+func (s *ReferralService) applyReferralRuleInput(ctx context.Context, rule *models.ReferralRule, in ReferralRuleInput) error {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return errNameRequired
+	}
+	if in.Days < 0 || in.CouponValidDays < 0 {
+		return apperr.Invalid("days and coupon_valid_days cannot be negative")
+	}
+	if in.Days == 0 && in.PromotionID == nil {
+		return apperr.Invalid("either days or promotion_id is required")
+	}
+	if in.PromotionID != nil {
+		promotion, err := s.promotionRepo.GetByID(ctx, *in.PromotionID)
+		if err != nil {
+			return notFound(err, ErrPromotionNotFound)
+		}
+		if promotion.RequiresCoupon != 1 {
+			return ErrPromotionTakesNoCoupon
+		}
+	} else {
+		in.CouponValidDays = 0
+	}
+	rule.Name = name
+	rule.Days = in.Days
+	rule.PromotionID = in.PromotionID
+	rule.CouponValidDays = in.CouponValidDays
+	return nil
+}
+
+func (s *ReferralService) newCouponCode(ctx context.Context) (string, error) {
+	for {
+		code, err := randomCouponCode()
+		if err != nil {
+			return "", err
+		}
+		exists, err := s.promotionRepo.CouponCodeExists(ctx, code)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return code, nil
+		}
+	}
+}
+
+// resolveReferrer finds the member a referral code belongs to
+func resolveReferrer(ctx context.Context, userRepo repository.UserRepository, code string) (*models.User, error) {
+	referrer, err := userRepo.GetByReferralCode(ctx, strings.ToUpper(strings.TrimSpace(code)))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidReferralCode
+	}
+	if err != nil {
+		return nil, err
+	}
+	if referrer.Status == UserStatusBlacklist {
+		return nil, ErrInvalidReferralCode
+	}
+	return referrer, nil
+}
+
+// newReferralCode picks an unused referral code, drawn from the coupon code
+// alphabet so it reads unambiguously
+func newReferralCode(ctx context.Context, userRepo repository.UserRepository) (string, error) {
+	for {
+		code, err := randomCouponCode()
+		if err != nil {
+			return "", err
+		}
+		code = code[:referralCodeLength]
+		exists, err := userRepo.ReferralCodeExists(ctx, code)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return code, nil
+		}
+	}
+}
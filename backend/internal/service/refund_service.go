@@ -0,0 +1,605 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/logger"
+	"gym-admin/pkg/payment"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	RefundStatusPendingApproval = models.RefundStatusPendingApproval
+	RefundStatusProcessing      = models.RefundStatusProcessing
+	RefundStatusSuccess         = models.RefundStatusSuccess
+	RefundStatusFailed          = models.RefundStatusFailed
+	RefundStatusRejected        = models.RefundStatusRejected
+)
+
+// refundSyncBatch bounds how many processing refunds one sync run queries
+const refundSyncBatch = 100
+
+var (
+	ErrRefundNotPending      = apperr.Conflict("REFUND_NOT_PENDING", "refund is not awaiting approval")
+	ErrRefundNotFailed       = apperr.Conflict("REFUND_NOT_FAILED", "refund has not failed")
+	ErrOrderItemNotFound     = apperr.NotFound("ORDER_ITEM_NOT_FOUND", "order item not found")
+	ErrOrderItemNotFulfilled = apperr.Conflict("ORDER_ITEM_NOT_FULFILLED", "order item has not been fulfilled")
+	ErrLessonPackageNotFound = apperr.NotFound("LESSON_PACKAGE_NOT_FOUND", "lesson package not found")
+	ErrNoSuccessfulPayment   = apperr.Conflict("NO_SUCCESSFUL_PAYMENT", "order has no successful payment")
+)
+
+// RefundInput describes a refund request. With OrderItemID only that item is
+// taken back; Sessions is the number of lesson sessions to remove from a
+// lesson package item (zero for all that are left). Without an item the
+// fulfilment is reversed only once the whole order has been refunded.
+type RefundInput struct {
+	Amount      float64
+	Reason      string
+	OrderItemID *int64
+	Sessions    int
+}
+
+// cardRefund logs the amount a refund paid back for a card. Revoke closes the
+// card too, once everything paid for it has been paid back.
+type cardRefund struct {
+	CardID int64
+	Amount float64
+	Revoke bool
+}
+
+// packageRefund removes sessions from a lesson package; zero removes all that are left
+type packageRefund struct {
+	PackageID int64
+	Sessions  int
+}
+
+// refundReversal is the fulfilment a completed refund takes back
+type refundReversal struct {
+	Cards    []cardRefund
+	Packages []packageRefund
+	TopUps   []repository.TopUpRefund
+}
+
+type RefundService struct {
+	repo       repository.RefundRepository
+	orderRepo  repository.OrderRepository
+	walletRepo repository.WalletRepository
+	seqRepo    repository.SequenceRepository
+	gateways   *payment.Registry
+	tx         repository.TxManager
+	// approvalThreshold is the refund amount above which a manager has to
+	// approve; zero means every refund needs approval
+	approvalThreshold float64
+}
+
+func NewRefundService(repo repository.RefundRepository, orderRepo repository.OrderRepository, walletRepo repository.WalletRepository, seqRepo repository.SequenceRepository, gateways *payment.Registry, approvalThreshold float64, tx repository.TxManager) *RefundService {
+	return &RefundService{
+		repo:              repo,
+		orderRepo:         orderRepo,
+		walletRepo:        walletRepo,
+		seqRepo:           seqRepo,
+		gateways:          gateways,
+		tx:                tx,
+		approvalThreshold: approvalThreshold,
+	}
+}
+
+// RequestRefund opens a refund on a paid order. Refunds up to the approval
+// threshold are sent to the payment channel right away; larger ones wait for
+// a manager. A channel error does not fail the request: the refund is
+// returned as failed with the reason and can be retried.
+func (s *RefundService) RequestRefund(ctx context.Context, orderID int64, in RefundInput, requestedBy int64) (*models.Refund, error) {
+	order, err := s.orderRepo.GetByID(ctx, orderID)
+	if err != nil {
+		return nil, notFound(err, ErrOrderNotFound)
+	}
+	if order.Status != OrderStatusPaid {
+		return nil, ErrOrderNotPaid
+	}
+
+	amount := roundTo(in.Amount, 2)
+	if amount <= 0 {
+		return nil, apperr.Invalid("refund amount must be positive")
+	}
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" {
+		return nil, apperr.Invalid("refund reason is required")
+	}
+
+	refund := &models.Refund{
+		OrderID:     order.ID,
+		Amount:      amount,
+		Reason:      reason,
+		RequestedBy: requestedBy,
+	}
+
+	if in.OrderItemID != nil {
+		item := findOrderItem(order, *in.OrderItemID)
+		if item == nil {
+			return nil, ErrOrderItemNotFound
+		}
+		if item.FulfilledRefID == nil {
+			return nil, ErrOrderItemNotFulfilled
+		}
+		if err := s.checkItemRefundable(ctx, item, amount); err != nil {
+			return nil, err
+		}
+		if item.ItemType == OrderItemLessonPackage {
+			pkg, err := s.repo.GetLessonPackage(ctx, *item.FulfilledRefID)
+			if err != nil {
+				return nil, notFound(err, ErrLessonPackageNotFound)
+			}
+			if in.Sessions < 0 || in.Sessions > pkg.RemainingSessions {
+				return nil, apperr.Invalid("not enough sessions left in the lesson package")
+			}
+			refund.Sessions = in.Sessions
+		}
+		refund.OrderItemID = &item.ID
+	}
+	if err := s.checkTopUpRefundable(ctx, order, refund.OrderItemID, amount); err != nil {
+		return nil, err
+	}
+
+	p, err := s.repo.GetSuccessfulPayment(ctx, order.ID)
+	if err != nil {
+		return nil, notFound(err, ErrNoSuccessfulPayment)
+	}
+	refund.PaymentID = p.ID
+
+	refundNo, err := nextSerialNo(ctx, s.seqRepo, "R", "refund_no:")
+	if err != nil {
+		return nil, err
+	}
+	refund.RefundNo = refundNo
+
+	refund.Status = RefundStatusProcessing
+	if amount > s.approvalThreshold {
+		refund.Status = RefundStatusPendingApproval
+	}
+	// The amount is reserved on the order with the request, so that
+	// concurrent requests can never refund more than was paid
+	err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		if err := repos.Orders.ReserveRefund(ctx, refund.OrderID, refund.Amount); err != nil {
+			return err
+		}
+		return repos.Refunds.Create(ctx, refund)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if refund.Status == RefundStatusProcessing {
+		s.process(ctx, refund)
+	}
+	return s.repo.GetByID(ctx, refund.ID)
+}
+
+func (s *RefundService) GetRefund(ctx context.Context, id int64) (*models.Refund, error) {
+	refund, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrRefundNotFound)
+	}
+	return refund, nil
+}
+
+func (s *RefundService) ListOrderRefunds(ctx context.Context, orderID int64) ([]models.Refund, error) {
+	return s.repo.ListByOrder(ctx, orderID)
+}
+
+func (s *RefundService) ListRefunds(ctx context.Context, q listquery.Query) ([]models.Refund, listquery.Page, error) {
+	return s.repo.List(ctx, q)
+}
+
+// ApproveRefund lets a manager release a refund above the threshold
+func (s *RefundService) ApproveRefund(ctx context.Context, id, approverID int64) (*models.Refund, error) {
+	ok, err := s.repo.Approve(ctx, id, approverID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRefundNotPending
+	}
+
+	refund, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.process(ctx, refund)
+	return s.repo.GetByID(ctx, id)
+}
+
+// RejectRefund turns a refund request down
+func (s *RefundService) RejectRefund(ctx context.Context, id, approverID int64, reason string) error {
+	refund, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return notFound(err, ErrRefundNotFound)
+	}
+	return s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		ok, err := repos.Refunds.Reject(ctx, refund.ID, approverID, reason)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrRefundNotPending
+		}
+		return repos.Orders.ReleaseRefund(ctx, refund.OrderID, refund.Amount)
+	})
+}
+
+// RetryRefund sends a failed refund to its channel again. The refund number
+// is reused, so the channel pays out at most once even if the earlier attempt
+// went through after all.
+func (s *RefundService) RetryRefund(ctx context.Context, id int64) (*models.Refund, error) {
+	refund, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrRefundNotFound)
+	}
+	if refund.OrderItemID != nil && refund.Status == RefundStatusFailed {
+		order, err := s.orderRepo.GetByID(ctx, refund.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		if item := findOrderItem(order, *refund.OrderItemID); item != nil {
+			if err := s.checkItemRefundable(ctx, item, refund.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+	err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		ok, err := repos.Refunds.Retry(ctx, refund.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrRefundNotFailed
+		}
+		return repos.Orders.ReserveRefund(ctx, refund.OrderID, refund.Amount)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.process(ctx, refund)
+	return s.repo.GetByID(ctx, id)
+}
+
+// SyncProcessingRefunds asks the channels about refunds still processing, run
+// as a background job
+func (s *RefundService) SyncProcessingRefunds(ctx context.Context) error {
+	refunds, err := s.repo.ListProcessing(ctx, refundSyncBatch)
+	if err != nil {
+		return err
+	}
+
+	for i := range refunds {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		refund := &refunds[i]
+		gateway, req, err := s.gatewayRequest(ctx, refund)
+		if err != nil {
+			logger.Error("Failed to sync refund", zap.String("refund_no", refund.RefundNo), zap.Error(err))
+			continue
+		}
+		result, err := gateway.QueryRefund(ctx, req)
+		if err != nil {
+			logger.Warn("Failed to query refund", zap.String("refund_no", refund.RefundNo), zap.Error(err))
+			continue
+		}
+		s.apply(ctx, refund, result)
+	}
+	return nil
+}
+
+// process sends a processing refund to the channel of the original payment
+func (s *RefundService) process(ctx context.Context, refund *models.Refund) {
+	gateway, req, err := s.gatewayRequest(ctx, refund)
+	if err == nil {
+		var result *payment.RefundResult
+		if result, err = gateway.Refund(ctx, req); err == nil {
+			s.apply(ctx, refund, result)
+			return
+		}
+	}
+
+	logger.Error("Refund failed", zap.String("refund_no", refund.RefundNo), zap.Error(err))
+	if err := s.markFailed(ctx, refund, err.Error()); err != nil {
+		logger.Error("Failed to mark refund failed", zap.String("refund_no", refund.RefundNo), zap.Error(err))
+	}
+}
+
+func (s *RefundService) gatewayRequest(ctx context.Context, refund *models.Refund) (payment.Gateway, *payment.RefundRequest, error) {
+	p, err := s.repo.GetPaymentByID(ctx, refund.PaymentID)
+	if err != nil {
+		return nil, nil, err
+	}
+	gateway, err := s.gateways.Get(p.Method)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gateway, &payment.RefundRequest{
+		RefundNo:  refund.RefundNo,
+		PaymentNo: p.PaymentNo,
+		TradeNo:   p.TradeNo,
+		Amount:    refund.Amount,
+		Total:     p.Amount,
+		Reason:    refund.Reason,
+	}, nil
+}
+
+// apply records the state the channel reported for a refund
+func (s *RefundService) apply(ctx context.Context, refund *models.Refund, result *payment.RefundResult) {
+	var err error
+	switch result.Status {
+	case payment.RefundSuccess:
+		err = s.complete(ctx, refund, result)
+	case payment.RefundFailed:
+		err = s.markFailed(ctx, refund, result.FailReason)
+	default:
+		if result.GatewayRefundNo != "" && result.GatewayRefundNo != refund.GatewayRefundNo {
+			err = s.repo.SetGatewayRefundNo(ctx, refund.ID, result.GatewayRefundNo)
+		}
+	}
+	if err != nil {
+		logger.Error("Failed to update refund", zap.String("refund_no", refund.RefundNo), zap.Error(err))
+	}
+}
+
+// markFailed records that the channel refused the refund and releases its amount
+func (s *RefundService) markFailed(ctx context.Context, refund *models.Refund, reason string) error {
+	return s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		failed, err := repos.Refunds.MarkFailed(ctx, refund.ID, reason)
+		if err != nil || !failed {
+			return err
+		}
+		return repos.Orders.ReleaseRefund(ctx, refund.OrderID, refund.Amount)
+	})
+}
+
+// complete records the successful refund and takes back what it paid for in
+// one unit of work
+func (s *RefundService) complete(ctx context.Context, refund *models.Refund, result *payment.RefundResult) error {
+	order, err := s.orderRepo.GetByID(ctx, refund.OrderID)
+	if err != nil {
+		return err
+	}
+
+	refunds, err := s.repo.ListByOrder(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	reversal := reverseRefund(order, refunds, refund)
+
+	refundedAt := result.RefundedAt
+	if refundedAt.IsZero() {
+		refundedAt = time.Now()
+	}
+	return s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		completed, err := repos.Refunds.Complete(ctx, refund.ID, result.GatewayRefundNo, refundedAt)
+		if err != nil || !completed {
+			return err
+		}
+		if err := repos.Orders.SettleRefund(ctx, refund.OrderID, refund.Amount); err != nil {
+			return err
+		}
+
+		for _, c := range reversal.Cards {
+			revoked := false
+			if c.Revoke {
+				if revoked, err = repos.Cards.MarkRefunded(ctx, c.CardID); err != nil {
+					return err
+				}
+			}
+			// A card closed by an earlier refund has nothing more to record
+			if c.Amount <= 0 && !revoked {
+				continue
+			}
+			log := &models.CardOperationLog{
+				CardID:        c.CardID,
+				OperationType: CardOpRefund,
+				Amount:        c.Amount,
+				OperatorID:    refund.RequestedBy,
+				Remark:        refund.Reason,
+				CreatedAt:     refundedAt,
+			}
+			if err := repos.Cards.CreateLog(ctx, log); err != nil {
+				return err
+			}
+			// A referral rewarded for the card loses its reward with it
+			if revoked {
+				if err := revokeReferralReward(ctx, repos, c.CardID, refund.RequestedBy); err != nil {
+					return err
+				}
+			}
+		}
+		for _, p := range reversal.Packages {
+			if err := repos.Refunds.RemoveSessions(ctx, p.PackageID, p.Sessions); err != nil {
+				return err
+			}
+		}
+		spent := refund.Amount
+		for i := range reversal.TopUps {
+			if err := repos.Wallets.DebitTopUp(ctx, &reversal.TopUps[i]); err != nil {
+				return err
+			}
+			spent -= reversal.TopUps[i].Amount
+		}
+		return takeBackPurchasePoints(ctx, repos, order, refund, spent, refundedAt)
+	})
+}
+
+// checkItemRefundable makes sure an item refund together with the earlier
+// refunds of the item, done or still under way, stays within what was paid
+// for the item
+func (s *RefundService) checkItemRefundable(ctx context.Context, item *models.OrderItem, amount float64) error {
+	refunds, err := s.repo.ListByOrder(ctx, item.OrderID)
+	if err != nil {
+		return err
+	}
+	refunded := itemRefunded(refunds, item.ID, RefundStatusPendingApproval, RefundStatusProcessing, RefundStatusSuccess)
+	if amount > roundTo(item.Amount-refunded, 2) {
+		return apperr.Invalid("refund amount exceeds what is left to refund of the item")
+	}
+	return nil
+}
+
+// checkTopUpRefundable makes sure a refund that takes back a wallet top-up is
+// still covered by the principal left in the wallet. An item refund of a
+// top-up takes back its amount; a whole-order refund takes back every top-up.
+func (s *RefundService) checkTopUpRefundable(ctx context.Context, order *models.Order, itemID *int64, amount float64) error {
+	var needed float64
+	for _, item := range order.Items {
+		if item.ItemType != OrderItemTopUp || item.FulfilledRefID == nil {
+			continue
+		}
+		if itemID == nil {
+			needed += item.Amount
+		} else if *itemID == item.ID {
+			needed = amount
+		}
+	}
+	if needed == 0 {
+		return nil
+	}
+	wallet, err := s.walletRepo.GetByUserID(ctx, order.UserID)
+	if err != nil {
+		return err
+	}
+	if wallet.Balance < roundTo(needed, 2) {
+		return apperr.Conflict("INSUFFICIENT_BALANCE", "wallet balance is less than the top-up to refund")
+	}
+	return nil
+}
+
+// reverseRefund works out the fulfilment a completing refund takes back. An
+// item refund takes back from its item, closing a card once the card has been
+// paid back in full. An order refund is shared between the items in
+// proportion to what was paid for them, which is logged on the cards. Either
+// way, a refund that pays back the rest of the order takes back everything
+// still running.
+func reverseRefund(order *models.Order, refunds []models.Refund, refund *models.Refund) refundReversal {
+	var reversal refundReversal
+	whole := roundTo(order.RefundedAmount+refund.Amount, 2) >= order.PayAmount
+	var shares map[int64]float64
+	if refund.OrderItemID == nil {
+		shares = orderRefundShares(order, refund.Amount)
+	}
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		if item.FulfilledRefID == nil {
+			continue
+		}
+		refID := *item.FulfilledRefID
+		ownRefund := refund.OrderItemID != nil && *refund.OrderItemID == item.ID
+		// amount is what this refund pays back for the item
+		amount := shares[item.ID]
+		if ownRefund {
+			amount = refund.Amount
+		}
+
+		switch item.ItemType {
+		case OrderItemCard:
+			revoke := whole || roundTo(cardPaidBack(order, refunds, item.ID)+amount, 2) >= item.Amount
+			if amount > 0 || revoke {
+				reversal.Cards = append(reversal.Cards, cardRefund{CardID: refID, Amount: amount, Revoke: revoke})
+			}
+		case OrderItemLessonPackage:
+			if whole {
+				reversal.Packages = append(reversal.Packages, packageRefund{PackageID: refID})
+			} else if ownRefund {
+				reversal.Packages = append(reversal.Packages, packageRefund{PackageID: refID, Sessions: refund.Sessions})
+			}
+		case OrderItemTopUp:
+			// Only item refunds have taken money back out of the wallet so far
+			if whole {
+				amount = roundTo(item.Amount-itemRefunded(refunds, item.ID, RefundStatusSuccess), 2)
+			} else if !ownRefund {
+				amount = 0
+			}
+			if amount <= 0 {
+				continue
+			}
+			// The bonus goes back in the same proportion as the top-up
+			var bonus float64
+			if item.Amount > 0 {
+				bonus = roundTo(item.BonusAmount*amount/item.Amount, 2)
+			}
+			reversal.TopUps = append(reversal.TopUps, repository.TopUpRefund{
+				UserID:    order.UserID,
+				Amount:    amount,
+				Bonus:     bonus,
+				Reference: fmt.Sprintf("%s-%d", refund.RefundNo, item.ID),
+				OrderID:   &order.ID,
+			})
+		}
+	}
+	return reversal
+}
+
+// cardPaidBack is what earlier successful refunds paid back for a card item:
+// its own refunds and its share of the order refunds
+func cardPaidBack(order *models.Order, refunds []models.Refund, itemID int64) float64 {
+	sum := itemRefunded(refunds, itemID, RefundStatusSuccess)
+	for _, r := range refunds {
+		if r.OrderItemID == nil && r.Status == RefundStatusSuccess {
+			sum += orderRefundShares(order, r.Amount)[itemID]
+		}
+	}
+	return roundTo(sum, 2)
+}
+
+// orderRefundShares splits an order refund between the items in proportion to
+// what was paid for them, the last item taking the rounding difference
+func orderRefundShares(order *models.Order, amount float64) map[int64]float64 {
+	shares := make(map[int64]float64, len(order.Items))
+	var total float64
+	for _, item := range order.Items {
+		total += item.Amount
+	}
+	if total <= 0 {
+		return shares
+	}
+	left := amount
+	for i, item := range order.Items {
+		share := roundTo(amount*item.Amount/total, 2)
+		if i == len(order.Items)-1 {
+			share = roundTo(left, 2)
+		}
+		shares[item.ID] = share
+		left -= share
+	}
+	return shares
+}
+
+// itemRefunded sums the refunds of an order item in the given statuses
+func itemRefunded(refunds []models.Refund, itemID int64, statuses ...int8) float64 {
+	var sum float64
+	for _, r := range refunds {
+		if r.OrderItemID == nil || *r.OrderItemID != itemID {
+			continue
+		}
+		for _, status := range statuses {
+			if r.Status == status {
+				sum += r.Amount
+				break
+			}
+		}
+	}
+	return roundTo(sum, 2)
+}
+
+func findOrderItem(order *models.Order, itemID int64) *models.OrderItem {
+	for i := range order.Items {
+		if order.Items[i].ID == itemID {
+			return &order.Items[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/internal/repository/fake"
+	"gym-admin/internal/service"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/payment"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	refundOrderID  int64 = 1
+	refundItemID   int64 = 11
+	refundCardID   int64 = 50
+	refundCardPaid       = 300.0
+)
+
+func refundedOrder() *models.Order {
+	cardID := refundCardID
+	return &models.Order{ID: refundOrderID, UserID: 3, Status: service.OrderStatusPaid, PayAmount: refundCardPaid, Items: []models.OrderItem{
+		{ID: refundItemID, OrderID: refundOrderID, ItemType: service.OrderItemCard, Amount: refundCardPaid, FulfilledRefID: &cardID},
+	}}
+}
+
+func itemRefund(id int64, amount float64, status int8) models.Refund {
+	itemID := refundItemID
+	return models.Refund{ID: id, OrderID: refundOrderID, OrderItemID: &itemID, Amount: amount, Status: status}
+}
+
+func cashOnly() *payment.Registry {
+	return payment.NewRegistry(payment.NewOfflineGateway(payment.MethodCash, "cash", false))
+}
+
+// noReferral finds no referral rewarded for a refunded card
+func noReferral() *fake.ReferralRepository {
+	return &fake.ReferralRepository{
+		GetRewardedByCardFunc: func(ctx context.Context, cardID int64) (*models.Referral, error) {
+			return nil, nil
+		},
+	}
+}
+
+// noPurchasePoints finds no points earned by a refunded order
+func noPurchasePoints() *fake.PointsRepository {
+	return &fake.PointsRepository{
+		GetEntryFunc: func(ctx context.Context, txType int8, sourceID int64) (*models.PointTransaction, error) {
+			return nil, nil
+		},
+	}
+}
+
+func TestApproveItemRefundOfCard(t *testing.T) {
+	tests := []struct {
+		name          string
+		amount        float64
+		earlier       []models.Refund
+		completed     bool // false when the refund had already been completed
+		wantCardLog   bool
+		wantRevoked   bool
+		wantLogAmount float64
+	}{
+		{name: "whole item", amount: 300, completed: true, wantCardLog: true, wantRevoked: true, wantLogAmount: 300},
+		{name: "part of the item", amount: 100, completed: true, wantCardLog: true, wantLogAmount: 100},
+		{name: "rest of the item", amount: 200, completed: true, wantCardLog: true, wantRevoked: true, wantLogAmount: 200,
+			earlier: []models.Refund{itemRefund(7, 100, service.RefundStatusSuccess), itemRefund(8, 50, service.RefundStatusFailed)}},
+		{name: "already completed", amount: 300},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refund := itemRefund(9, tt.amount, service.RefundStatusProcessing)
+			refund.PaymentID = 4
+			refund.RefundNo = "R1"
+			refund.RequestedBy = 2
+			refund.Reason = "退卡"
+
+			refunds := &fake.RefundRepository{
+				ApproveFunc: func(ctx context.Context, id, approverID int64) (bool, error) {
+					return true, nil
+				},
+				GetByIDFunc: func(ctx context.Context, id int64) (*models.Refund, error) {
+					r := refund
+					return &r, nil
+				},
+				GetPaymentByIDFunc: func(ctx context.Context, id int64) (*models.Payment, error) {
+					return &models.Payment{ID: id, Method: payment.MethodCash, Amount: refundCardPaid}, nil
+				},
+				ListByOrderFunc: func(ctx context.Context, orderID int64) ([]models.Refund, error) {
+					return append(tt.earlier, refund), nil
+				},
+			}
+			orders := &fake.OrderRepository{
+				GetByIDFunc: func(ctx context.Context, id int64) (*models.Order, error) {
+					return refundedOrder(), nil
+				},
+			}
+
+			completeCalls := 0
+			var log *models.CardOperationLog
+			txRefunds := &fake.RefundRepository{
+				CompleteFunc: func(ctx context.Context, id int64, gatewayRefundNo string, refundedAt time.Time) (bool, error) {
+					completeCalls++
+					return tt.completed, nil
+				},
+			}
+			// Only stubbed when expected, so revoking or logging the card
+			// otherwise panics
+			txCards := &fake.CardRepository{}
+			if tt.wantRevoked {
+				txCards.MarkRefundedFunc = func(ctx context.Context, id int64) (bool, error) {
+					if id != refundCardID {
+						t.Errorf("refunded card %d, want %d", id, refundCardID)
+					}
+					return true, nil
+				}
+			}
+			if tt.wantCardLog {
+				txCards.CreateLogFunc = func(ctx context.Context, l *models.CardOperationLog) error {
+					log = l
+					return nil
+				}
+			}
+			settled := 0.0
+			txOrders := &fake.OrderRepository{
+				SettleRefundFunc: func(ctx context.Context, orderID int64, amount float64) error {
+					settled += amount
+					return nil
+				},
+			}
+			tx := &fake.TxManager{Repos: &repository.Repositories{Refunds: txRefunds, Orders: txOrders, Cards: txCards, Referrals: noReferral(), Points: noPurchasePoints()}}
+			svc := service.NewRefundService(refunds, orders, &fake.WalletRepository{}, sequence(), cashOnly(), 0, tx)
+
+			if _, err := svc.ApproveRefund(context.Background(), refund.ID, 2); err != nil {
+				t.Fatalf("ApproveRefund: %v", err)
+			}
+			if completeCalls != 1 || tx.Calls != 1 {
+				t.Errorf("Complete calls = %d, units of work = %d, want 1 and 1", completeCalls, tx.Calls)
+			}
+			if wantSettled := map[bool]float64{true: tt.amount}[tt.completed]; settled != wantSettled {
+				t.Errorf("settled on the order = %.2f, want %.2f", settled, wantSettled)
+			}
+			if tt.wantCardLog {
+				if log == nil || log.OperationType != service.CardOpRefund || log.Amount != tt.wantLogAmount {
+					t.Errorf("refund log = %+v, want amount %.2f", log, tt.wantLogAmount)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestItemRefundCountsEarlierRefunds(t *testing.T) {
+	earlier := []models.Refund{
+		itemRefund(5, 100, service.RefundStatusSuccess),
+		itemRefund(6, 50, service.RefundStatusProcessing),
+		itemRefund(7, 50, service.RefundStatusPendingApproval),
+		itemRefund(8, 80, service.RefundStatusFailed),
+		itemRefund(9, 80, service.RefundStatusRejected),
+	}
+	refunds := &fake.RefundRepository{
+		ListByOrderFunc: func(ctx context.Context, orderID int64) ([]models.Refund, error) {
+			return earlier, nil
+		},
+		// Reached only once the amount has passed the checks
+		GetSuccessfulPaymentFunc: func(ctx context.Context, orderID int64) (*models.Payment, error) {
+			return nil, gorm.ErrRecordNotFound
+		},
+	}
+	orders := &fake.OrderRepository{
+		GetByIDFunc: func(ctx context.Context, id int64) (*models.Order, error) {
+			return refundedOrder(), nil
+		},
+	}
+	svc := service.NewRefundService(refunds, orders, &fake.WalletRepository{}, sequence(), cashOnly(), 0, &fake.TxManager{})
+
+	itemID := refundItemID
+	_, err := svc.RequestRefund(context.Background(), refundOrderID, service.RefundInput{Amount: 101, Reason: "退款", OrderItemID: &itemID}, 2)
+	var appErr *apperr.Error
+	if !errors.As(err, &appErr) || appErr.Code != apperr.CodeInvalidParameter {
+		t.Errorf("refunding more than the 100 left: error = %v, want invalid", err)
+	}
+
+	_, err = svc.RequestRefund(context.Background(), refundOrderID, service.RefundInput{Amount: 100, Reason: "退款", OrderItemID: &itemID}, 2)
+	if !errors.Is(err, service.ErrNoSuccessfulPayment) {
+		t.Errorf("refunding the 100 left: error = %v, want it to pass the item check", err)
+	}
+}
+
+func TestItemRefundCompletingOrderTakesBackTheRest(t *testing.T) {
+	const packageItemID, packageID int64 = 12, 60
+	cardID, pkgID := refundCardID, packageID
+	order := &models.Order{ID: refundOrderID, UserID: 3, Status: service.OrderStatusPaid, PayAmount: 500, RefundedAmount: 200,
+		Items: []models.OrderItem{
+			{ID: refundItemID, OrderID: refundOrderID, ItemType: service.OrderItemCard, Amount: 300, FulfilledRefID: &cardID},
+			{ID: packageItemID, OrderID: refundOrderID, ItemType: service.OrderItemLessonPackage, Amount: 200, FulfilledRefID: &pkgID},
+		}}
+	// An earlier refund of part of the order, not of an item
+	earlier := models.Refund{ID: 7, OrderID: refundOrderID, Amount: 200, Status: service.RefundStatusSuccess}
+	refund := itemRefund(9, 300, service.RefundStatusProcessing)
+	refund.PaymentID = 4
+	refund.RefundNo = "R1"
+
+	refunds := &fake.RefundRepository{
+		ApproveFunc: func(ctx context.Context, id, approverID int64) (bool, error) {
+			return true, nil
+		},
+		GetByIDFunc: func(ctx context.Context, id int64) (*models.Refund, error) {
+			r := refund
+			return &r, nil
+		},
+		GetPaymentByIDFunc: func(ctx context.Context, id int64) (*models.Payment, error) {
+			return &models.Payment{ID: id, Method: payment.MethodCash, Amount: 500}, nil
+		},
+		ListByOrderFunc: func(ctx context.Context, orderID int64) ([]models.Refund, error) {
+			return []models.Refund{earlier, refund}, nil
+		},
+	}
+	orders := &fake.OrderRepository{
+		GetByIDFunc: func(ctx context.Context, id int64) (*models.Order, error) {
+			o := *order
+			return &o, nil
+		},
+	}
+
+	var revoked []int64
+	var logs []*models.CardOperationLog
+	removed := make(map[int64]int)
+	txRefunds := &fake.RefundRepository{
+		CompleteFunc: func(ctx context.Context, id int64, gatewayRefundNo string, refundedAt time.Time) (bool, error) {
+			return true, nil
+		},
+		RemoveSessionsFunc: func(ctx context.Context, packageID int64, sessions int) error {
+			removed[packageID] = sessions
+			return nil
+		},
+	}
+	txCards := &fake.CardRepository{
+		MarkRefundedFunc: func(ctx context.Context, id int64) (bool, error) {
+			revoked = append(revoked, id)
+			return true, nil
+		},
+		CreateLogFunc: func(ctx context.Context, l *models.CardOperationLog) error {
+			logs = append(logs, l)
+			return nil
+		},
+	}
+	txOrders := &fake.OrderRepository{
+		SettleRefundFunc: func(ctx context.Context, orderID int64, amount float64) error {
+			return nil
+		},
+	}
+	tx := &fake.TxManager{Repos: &repository.Repositories{Refunds: txRefunds, Orders: txOrders, Cards: txCards, Referrals: noReferral(), Points: noPurchasePoints()}}
+	svc := service.NewRefundService(refunds, orders, &fake.WalletRepository{}, sequence(), cashOnly(), 0, tx)
+
+	if _, err := svc.ApproveRefund(context.Background(), refund.ID, 2); err != nil {
+		t.Fatalf("ApproveRefund: %v", err)
+	}
+	if len(revoked) != 1 || revoked[0] != refundCardID {
+		t.Errorf("revoked cards = %v, want [%d]", revoked, refundCardID)
+	}
+	if len(logs) != 1 || logs[0].Amount != 300 {
+		t.Errorf("refund logs = %v, want one of 300", logs)
+	}
+	if sessions, ok := removed[packageID]; !ok || sessions != 0 {
+		t.Errorf("removed sessions = %v, want all of package %d", removed, packageID)
+	}
+}
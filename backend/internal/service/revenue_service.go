@@ -0,0 +1,433 @@
+package service
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"sort"
+	"time"
+)
+
+const (
+	maxRevenueMonths = 24
+	revenueBatchSize = 500
+)
+
+var ErrInvalidMonthRange = apperr.Invalid("invalid month range, at most 24 months")
+
+// RevenueRow is the revenue of one month, broken down by card type and source.
+// DeferredBalance is what has been received but not yet recognized at month end.
+type RevenueRow struct {
+	Month           string  `json:"month"` // YYYY-MM
+	CardTypeID      int64   `json:"card_type_id,omitempty"`
+	CardTypeName    string  `json:"card_type_name,omitempty"`
+	Source          int8    `json:"source,omitempty"`
+	SourceName      string  `json:"source_name,omitempty"`
+	Sales           float64 `json:"sales"`         // 当月售卡收款
+	Recognized      float64 `json:"recognized"`    // 当月确认收入
+	Refunded        float64 `json:"refunded"`      // 当月退款
+	TransferFees    float64 `json:"transfer_fees"` // 当月转卡手续费，直接确认收入
+	DeferredBalance float64 `json:"deferred_balance"`
+}
+
+type RevenueReport struct {
+	From   string       `json:"from"`
+	To     string       `json:"to"`
+	Totals []RevenueRow `json:"totals"`
+	Rows   []RevenueRow `json:"rows"`
+}
+
+type revenueKey struct {
+	month      int // index into the report months, -1 before the range
+	cardTypeID int64
+	source     int8
+}
+
+type revenueAmounts struct {
+	sales, recognized, refunded, fees float64
+}
+
+// revenueLedger accumulates the cash and recognition events of the cards,
+// keyed by card type and source and bucketed by report month
+type revenueLedger struct {
+	rangeStart time.Time
+	months     []time.Time
+	amounts    map[revenueKey]*revenueAmounts
+}
+
+func (l *revenueLedger) monthIndex(day time.Time) int {
+	if day.Before(l.rangeStart) {
+		return -1
+	}
+	for i := len(l.months) - 1; i >= 0; i-- {
+		if !day.Before(l.months[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (l *revenueLedger) bucket(card *models.MembershipCard, day time.Time) *revenueAmounts {
+	key := revenueKey{month: l.monthIndex(day), cardTypeID: card.CardTypeID, source: card.Source}
+	a, ok := l.amounts[key]
+	if !ok {
+		a = &revenueAmounts{}
+		l.amounts[key] = a
+	}
+	return a
+}
+
+type RevenueService struct {
+	repo repository.RevenueRepository
+}
+
+func NewRevenueService(repo repository.RevenueRepository) *RevenueService {
+	return &RevenueService{
+		repo: repo,
+	}
+}
+
+// GetReport computes monthly recognized and deferred card revenue for the
+// months from..to (inclusive). Time cards are recognized evenly over their
+// unfrozen service days, visit cards per visit with unused visits recognized
+// at expiry. A refund recognizes the remaining deferred amount net of the
+// amount paid back. Transferred cards keep being recognized on schedule; a
+// card issued to the receiver of a transfer carries no purchase price.
+func (s *RevenueService) GetReport(ctx context.Context, from, to time.Time) (*RevenueReport, error) {
+	fromMonth, toMonth := monthStart(from), monthStart(to)
+	if toMonth.Before(fromMonth) || toMonth.After(fromMonth.AddDate(0, maxRevenueMonths-1, 0)) {
+		return nil, ErrInvalidMonthRange
+	}
+
+	ledger := &revenueLedger{rangeStart: fromMonth, amounts: make(map[revenueKey]*revenueAmounts)}
+	for m := fromMonth; !m.After(toMonth); m = m.AddDate(0, 1, 0) {
+		ledger.months = append(ledger.months, m)
+	}
+	rangeEnd := toMonth.AddDate(0, 1, 0)
+
+	// Nothing is recognized for days that have not happened yet
+	asOf := truncateToDate(time.Now()).AddDate(0, 0, 1)
+	if rangeEnd.Before(asOf) {
+		asOf = rangeEnd
+	}
+
+	cardTypes, err := s.repo.CardTypes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	typesByID := make(map[int64]models.CardType, len(cardTypes))
+	for _, ct := range cardTypes {
+		typesByID[ct.ID] = ct
+	}
+
+	err = s.repo.EachCardSoldBefore(ctx, rangeEnd, revenueBatchSize, func(cards []models.MembershipCard) error {
+		return s.recognizeBatch(ctx, ledger, cards, typesByID, asOf, rangeEnd)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return buildRevenueReport(ledger, typesByID), nil
+}
+
+func (s *RevenueService) recognizeBatch(ctx context.Context, ledger *revenueLedger, cards []models.MembershipCard, typesByID map[int64]models.CardType, asOf, rangeEnd time.Time) error {
+	ids := make([]int64, 0, len(cards))
+	var visitCardIDs []int64
+	for _, card := range cards {
+		ids = append(ids, card.ID)
+		if typesByID[card.CardTypeID].DurationType == CardDurationTimes {
+			visitCardIDs = append(visitCardIDs, card.ID)
+		}
+	}
+
+	freezes, err := s.repo.FreezeRecords(ctx, ids)
+	if err != nil {
+		return err
+	}
+	freezesByCard := make(map[int64][]models.CardFreezeRecord)
+	for _, f := range freezes {
+		freezesByCard[f.CardID] = append(freezesByCard[f.CardID], f)
+	}
+
+	logs, err := s.repo.OperationLogs(ctx, ids, []int8{CardOpTransfer, CardOpRefund})
+	if err != nil {
+		return err
+	}
+	logsByCard := make(map[int64][]models.CardOperationLog)
+	for _, l := range logs {
+		logsByCard[l.CardID] = append(logsByCard[l.CardID], l)
+	}
+
+	// visits[cardID][i] is the number of visits in month i, visitsBefore those before the range
+	visits := make(map[int64][]int)
+	visitsBefore := make(map[int64]int)
+	if len(visitCardIDs) > 0 {
+		before, err := s.repo.CountVisits(ctx, visitCardIDs, time.Time{}, ledger.rangeStart)
+		if err != nil {
+			return err
+		}
+		for _, v := range before {
+			visitsBefore[v.CardID] = v.Visits
+		}
+		for i, m := range ledger.months {
+			counts, err := s.repo.CountVisits(ctx, visitCardIDs, m, m.AddDate(0, 1, 0))
+			if err != nil {
+				return err
+			}
+			for _, v := range counts {
+				if visits[v.CardID] == nil {
+					visits[v.CardID] = make([]int, len(ledger.months))
+				}
+				visits[v.CardID][i] = v.Visits
+			}
+		}
+	}
+
+	for i := range cards {
+		card := &cards[i]
+		cardType := typesByID[card.CardTypeID]
+
+		saleDate := truncateToDate(card.CreatedAt)
+		if card.StartDate.Before(saleDate) {
+			saleDate = truncateToDate(card.StartDate)
+		}
+		if !saleDate.Before(rangeEnd) {
+			continue
+		}
+		ledger.bucket(card, saleDate).sales += card.PurchasePrice
+
+		var refunds []*models.CardOperationLog
+		for j := range logsByCard[card.ID] {
+			l := &logsByCard[card.ID][j]
+			switch l.OperationType {
+			case CardOpTransfer:
+				if l.CreatedAt.Before(asOf) {
+					ledger.bucket(card, truncateToDate(l.CreatedAt)).fees += l.Amount
+				}
+			case CardOpRefund:
+				refunds = append(refunds, l)
+			}
+		}
+		// The last refund of a refunded card closed it. The others paid back
+		// part of the price while the card kept running, which reverses as
+		// much revenue when it is paid.
+		var refund *models.CardOperationLog
+		if card.Status == CardStatusRefunded && len(refunds) > 0 {
+			refund = refunds[len(refunds)-1]
+			refunds = refunds[:len(refunds)-1]
+		}
+		for _, l := range refunds {
+			if l.CreatedAt.Before(asOf) {
+				b := ledger.bucket(card, truncateToDate(l.CreatedAt))
+				b.refunded += l.Amount
+				b.recognized -= l.Amount
+			}
+		}
+
+		stop := asOf
+		var refundDate time.Time
+		if refund != nil {
+			refundDate = truncateToDate(refund.CreatedAt)
+		} else if card.Status == CardStatusRefunded {
+			// Refunded before refunds were logged: assume the remaining balance was paid back
+			refundDate = truncateToDate(card.UpdatedAt)
+		}
+		if !refundDate.IsZero() && refundDate.Before(stop) {
+			stop = refundDate
+		}
+
+		var recognized float64
+		if cardType.DurationType == CardDurationTimes {
+			recognized = recognizeVisitCard(ledger, card, cardType, visitsBefore[card.ID], visits[card.ID], stop)
+		} else {
+			recognized = recognizeTimeCard(ledger, card, freezesByCard[card.ID], stop)
+		}
+
+		if !refundDate.IsZero() && refundDate.Before(asOf) {
+			deferred := card.PurchasePrice - recognized
+			amount := deferred
+			if refund != nil {
+				amount = refund.Amount
+			}
+			b := ledger.bucket(card, refundDate)
+			b.refunded += amount
+			// Whatever of the deferred balance is kept becomes revenue; refunding
+			// more than the balance reverses revenue already recognized
+			b.recognized += deferred - amount
+		}
+	}
+	return nil
+}
+
+// recognizeTimeCard spreads the price evenly over the unfrozen days of the card
+// before stop, returning the total recognized
+func recognizeTimeCard(ledger *revenueLedger, card *models.MembershipCard, freezes []models.CardFreezeRecord, stop time.Time) float64 {
+	start := truncateToDate(card.StartDate)
+	end := truncateToDate(card.EndDate)
+
+	type span struct{ from, to time.Time } // [from, to), zero to means still frozen
+	var frozen []span
+	closedDays := 0
+	for _, f := range freezes {
+		sp := span{from: truncateToDate(f.FreezeStartDate)}
+		if f.FreezeEndDate != nil {
+			sp.to = truncateToDate(*f.FreezeEndDate)
+			closedDays += f.FreezeDays
+		}
+		frozen = append(frozen, sp)
+	}
+	if len(freezes) == 0 {
+		closedDays = card.FreezeDays
+		if card.IsFrozen == 1 && card.FrozenAt != nil {
+			frozen = append(frozen, span{from: truncateToDate(*card.FrozenAt)})
+		}
+	}
+
+	// The end date has been pushed back by the closed freezes
+	plannedDays := int(end.Sub(start).Hours()/24) + 1 - closedDays
+	if plannedDays < 1 {
+		plannedDays = 1
+	}
+	daily := card.PurchasePrice / float64(plannedDays)
+
+	isFrozen := func(day time.Time) bool {
+		for _, sp := range frozen {
+			if !day.Before(sp.from) && (sp.to.IsZero() || day.Before(sp.to)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var recognized float64
+	days := 0
+	for day := start; day.Before(stop) && days < plannedDays; day = day.AddDate(0, 0, 1) {
+		if isFrozen(day) {
+			continue
+		}
+		ledger.bucket(card, day).recognized += daily
+		recognized += daily
+		days++
+	}
+	return recognized
+}
+
+// recognizeVisitCard recognizes the price per visit and the unused visits at
+// expiry, returning the total recognized before stop
+func recognizeVisitCard(ledger *revenueLedger, card *models.MembershipCard, cardType models.CardType, before int, monthly []int, stop time.Time) float64 {
+	total := cardType.DurationValue
+	if card.TotalTimes != nil {
+		total = *card.TotalTimes
+	}
+	if total < 1 {
+		total = 1
+	}
+	perVisit := card.PurchasePrice / float64(total)
+
+	var recognized float64
+	used := 0
+	take := func(n int, day time.Time) {
+		if n > total-used {
+			n = total - used
+		}
+		if n <= 0 || !day.Before(stop) {
+			return
+		}
+		used += n
+		amount := perVisit * float64(n)
+		ledger.bucket(card, day).recognized += amount
+		recognized += amount
+	}
+
+	take(before, ledger.rangeStart.AddDate(0, 0, -1))
+	for i, n := range monthly {
+		take(n, ledger.months[i])
+	}
+
+	// Unused visits are recognized the day after expiry, unless the card is frozen
+	expiry := truncateToDate(card.EndDate).AddDate(0, 0, 1)
+	if card.IsFrozen == 0 {
+		take(total-used, expiry)
+	}
+	return recognized
+}
+
+func buildRevenueReport(ledger *revenueLedger, typesByID map[int64]models.CardType) *RevenueReport {
+	report := &RevenueReport{
+		From:   ledger.months[0].Format("2006-01"),
+		To:     ledger.months[len(ledger.months)-1].Format("2006-01"),
+		Totals: []RevenueRow{},
+		Rows:   []RevenueRow{},
+	}
+
+	type group struct {
+		cardTypeID int64
+		source     int8
+	}
+	groups := make(map[group]bool)
+	for key := range ledger.amounts {
+		groups[group{key.cardTypeID, key.source}] = true
+	}
+
+	totals := make([]RevenueRow, len(ledger.months))
+	for i, m := range ledger.months {
+		totals[i].Month = m.Format("2006-01")
+	}
+
+	for g := range groups {
+		// Opening deferred balance carried in from before the range
+		var deferred float64
+		if a, ok := ledger.amounts[revenueKey{-1, g.cardTypeID, g.source}]; ok {
+			deferred = a.sales - a.recognized - a.refunded
+		}
+		for i, m := range ledger.months {
+			row := RevenueRow{
+				Month:        m.Format("2006-01"),
+				CardTypeID:   g.cardTypeID,
+				CardTypeName: typesByID[g.cardTypeID].TypeName,
+				Source:       g.source,
+				SourceName:   label(CardSourceLabels, g.source),
+			}
+			if a, ok := ledger.amounts[revenueKey{i, g.cardTypeID, g.source}]; ok {
+				row.Sales, row.Recognized, row.Refunded, row.TransferFees = a.sales, a.recognized, a.refunded, a.fees
+				deferred += a.sales - a.recognized - a.refunded
+			}
+			row.DeferredBalance = deferred
+
+			totals[i].Sales += row.Sales
+			totals[i].Recognized += row.Recognized
+			totals[i].Refunded += row.Refunded
+			totals[i].TransferFees += row.TransferFees
+			totals[i].DeferredBalance += row.DeferredBalance
+
+			if row.Sales != 0 || row.Recognized != 0 || row.Refunded != 0 || row.TransferFees != 0 || roundTo(row.DeferredBalance, 2) != 0 {
+				report.Rows = append(report.Rows, roundRevenueRow(row))
+			}
+		}
+	}
+
+	for _, t := range totals {
+		report.Totals = append(report.Totals, roundRevenueRow(t))
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Month != b.Month {
+			return a.Month < b.Month
+		}
+		if a.CardTypeID != b.CardTypeID {
+			return a.CardTypeID < b.CardTypeID
+		}
+		return a.Source < b.Source
+	})
+	return report
+}
+
+func roundRevenueRow(row RevenueRow) RevenueRow {
+	row.Sales = roundTo(row.Sales, 2)
+	row.Recognized = roundTo(row.Recognized, 2)
+	row.Refunded = roundTo(row.Refunded, 2)
+	row.TransferFees = roundTo(row.TransferFees, 2)
+	row.DeferredBalance = roundTo(row.DeferredBalance, 2)
+	return row
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"math"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+const maxReviewCommentLength = 500
+
+var (
+	ErrBookingNotFound     = apperr.NotFound("BOOKING_NOT_FOUND", "booking not found")
+	ErrReviewNotFound      = apperr.NotFound("REVIEW_NOT_FOUND", "review not found")
+	ErrBookingNotOwned     = apperr.New(http.StatusForbidden, "BOOKING_NOT_OWNED", "booking does not belong to user")
+	ErrBookingNotCompleted = apperr.Conflict("BOOKING_NOT_COMPLETED", "only completed bookings can be reviewed")
+	ErrBookingReviewed     = apperr.Conflict("BOOKING_REVIEWED", "booking has already been reviewed")
+	ErrReviewHidden        = apperr.Conflict("REVIEW_HIDDEN", "review is already hidden")
+	ErrReviewVisible       = apperr.Conflict("REVIEW_VISIBLE", "review is already visible")
+)
+
+type ReviewService struct {
+	repo       repository.ReviewRepository
+	courseRepo repository.CourseRepository
+	coachRepo  repository.CoachRepository
+}
+
+func NewReviewService(repo repository.ReviewRepository, courseRepo repository.CourseRepository, coachRepo repository.CoachRepository) *ReviewService {
+	return &ReviewService{
+		repo:       repo,
+		courseRepo: courseRepo,
+		coachRepo:  coachRepo,
+	}
+}
+
+// CreateReview rates the course and coach of a completed booking
+func (s *ReviewService) CreateReview(ctx context.Context, userID, bookingID int64, courseRating, coachRating int8, comment string) (*models.CourseReview, error) {
+	if !validRating(courseRating) || !validRating(coachRating) {
+		return nil, apperr.Invalid("rating must be between 1 and 5")
+	}
+	if utf8.RuneCountInString(comment) > maxReviewCommentLength {
+		return nil, apperr.Invalid("comment is too long")
+	}
+
+	booking, err := s.courseRepo.GetBookingByID(ctx, bookingID)
+	if err != nil {
+		return nil, notFound(err, ErrBookingNotFound)
+	}
+	if booking.UserID != userID {
+		return nil, ErrBookingNotOwned
+	}
+	if booking.Status != 3 {
+		return nil, ErrBookingNotCompleted
+	}
+
+	exists, err := s.repo.ExistsByBookingID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrBookingReviewed
+	}
+
+	course, err := s.courseRepo.GetByID(ctx, booking.CourseID)
+	if err != nil {
+		return nil, notFound(err, ErrCourseNotFound)
+	}
+
+	review := &models.CourseReview{
+		BookingID:    booking.ID,
+		UserID:       userID,
+		CourseID:     course.ID,
+		CoachID:      course.CoachID,
+		CourseRating: courseRating,
+		CoachRating:  coachRating,
+		Comment:      comment,
+		Status:       1,
+	}
+	if err := s.repo.Create(ctx, review); err != nil {
+		return nil, err
+	}
+
+	if err := s.refreshCoachRating(ctx, course.CoachID); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+func (s *ReviewService) GetReview(ctx context.Context, id int64) (*models.CourseReview, error) {
+	review, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrReviewNotFound)
+	}
+	return review, nil
+}
+
+func (s *ReviewService) ListReviews(ctx context.Context, q listquery.Query) ([]models.CourseReview, listquery.Page, error) {
+	return s.repo.List(ctx, q)
+}
+
+// ListCoachReviews lists the visible reviews of a coach
+func (s *ReviewService) ListCoachReviews(ctx context.Context, coachID int64, q listquery.Query) ([]models.CourseReview, listquery.Page, error) {
+	return s.repo.ListVisibleByCoach(ctx, coachID, q)
+}
+
+// HideReview hides an abusive review and removes it from the coach rating
+func (s *ReviewService) HideReview(ctx context.Context, id, operatorID int64, reason string) error {
+	review, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return notFound(err, ErrReviewNotFound)
+	}
+	if review.Status == 2 {
+		return ErrReviewHidden
+	}
+
+	now := time.Now()
+	review.Status = 2
+	review.HiddenBy = &operatorID
+	review.HiddenAt = &now
+	review.HiddenReason = reason
+	if err := s.repo.Update(ctx, review); err != nil {
+		return err
+	}
+
+	return s.refreshCoachRating(ctx, review.CoachID)
+}
+
+// ShowReview restores a hidden review
+func (s *ReviewService) ShowReview(ctx context.Context, id int64) error {
+	review, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return notFound(err, ErrReviewNotFound)
+	}
+	if review.Status == 1 {
+		return ErrReviewVisible
+	}
+
+	review.Status = 1
+	review.HiddenBy = nil
+	review.HiddenAt = nil
+	review.HiddenReason = ""
+	if err := s.repo.Update(ctx, review); err != nil {
+		return err
+	}
+
+	return s.refreshCoachRating(ctx, review.CoachID)
+}
+
+// refreshCoachRating recomputes the cached rating on the coach from visible reviews
+func (s *ReviewService) refreshCoachRating(ctx context.Context, coachID int64) error {
+	summary, err := s.repo.CoachRatingSummary(ctx, coachID)
+	if err != nil {
+		return err
+	}
+	rating := math.Round(summary.Average*100) / 100
+	return s.coachRepo.UpdateRating(ctx, coachID, rating, summary.Count)
+}
+
+func validRating(rating int8) bool {
+	return rating >= 1 && rating <= 5
+}
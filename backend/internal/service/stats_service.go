@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/cache"
+	"gym-admin/pkg/logger"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	dashboardCacheTTL = time.Minute
+	// occupancyWindow is how long a member is assumed to stay after checking in
+	occupancyWindow = 2 * time.Hour
+)
+
+// Metric is a number for the current period next to the same span of the previous period
+type Metric struct {
+	Current    float64  `json:"current"`
+	Previous   float64  `json:"previous"`
+	ChangeRate *float64 `json:"change_rate"` // (current-previous)/previous, nil when previous is 0
+}
+
+type DashboardStats struct {
+	Period             string    `json:"period"` // day, week, month
+	From               time.Time `json:"from"`
+	To                 time.Time `json:"to"`
+	CheckIns           Metric    `json:"check_ins"`
+	Occupancy          Metric    `json:"occupancy"`
+	NewMembers         Metric    `json:"new_members"`
+	CardsSold          Metric    `json:"cards_sold"`
+	CardRevenue        Metric    `json:"card_revenue"`
+	Bookings           Metric    `json:"bookings"`
+	VoucherRedemptions Metric    `json:"voucher_redemptions"`
+	CardsExpiringWeek  Metric    `json:"cards_expiring_week"` // previous: cards that expired during the last 7 days
+	GeneratedAt        time.Time `json:"generated_at"`
+}
+
+var ErrInvalidPeriod = apperr.Invalid("period must be day, week or month")
+
+type StatsService struct {
+	repo  repository.StatsRepository
+	cache cache.Cache
+}
+
+func NewStatsService(repo repository.StatsRepository, c cache.Cache) *StatsService {
+	return &StatsService{
+		repo:  repo,
+		cache: c,
+	}
+}
+
+// GetDashboard returns the dashboard numbers for the current day, week or month.
+// Results are cached in Redis briefly so that dashboards polling every few
+// seconds do not each hit MySQL.
+func (s *StatsService) GetDashboard(ctx context.Context, period string) (*DashboardStats, error) {
+	if period == "" {
+		period = "day"
+	}
+	now := time.Now()
+	from, prevFrom, prevTo, err := comparisonWindow(period, now)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := "stats:dashboard:" + period
+	if cached, err := s.cache.Get(ctx, cacheKey); err == nil {
+		var stats DashboardStats
+		if err := json.Unmarshal([]byte(cached), &stats); err == nil {
+			return &stats, nil
+		}
+	}
+
+	stats := &DashboardStats{Period: period, From: from, To: now, GeneratedAt: now}
+
+	if stats.CheckIns, err = s.compareCounts(ctx, s.repo.CountCheckIns, from, now, prevFrom, prevTo); err != nil {
+		return nil, err
+	}
+	if stats.NewMembers, err = s.compareCounts(ctx, s.repo.CountNewUsers, from, now, prevFrom, prevTo); err != nil {
+		return nil, err
+	}
+	if stats.Bookings, err = s.compareCounts(ctx, s.repo.CountBookings, from, now, prevFrom, prevTo); err != nil {
+		return nil, err
+	}
+	if stats.VoucherRedemptions, err = s.compareCounts(ctx, s.repo.CountVoucherRedemptions, from, now, prevFrom, prevTo); err != nil {
+		return nil, err
+	}
+
+	// Occupancy is compared with the same time yesterday
+	yesterday := now.AddDate(0, 0, -1)
+	if stats.Occupancy, err = s.compareCounts(ctx, s.repo.CountDistinctCheckInUsers,
+		now.Add(-occupancyWindow), now, yesterday.Add(-occupancyWindow), yesterday); err != nil {
+		return nil, err
+	}
+
+	sales, err := s.repo.CardSales(ctx, from, now)
+	if err != nil {
+		return nil, err
+	}
+	prevSales, err := s.repo.CardSales(ctx, prevFrom, prevTo)
+	if err != nil {
+		return nil, err
+	}
+	stats.CardsSold = newMetric(float64(sales.Count), float64(prevSales.Count))
+	stats.CardRevenue = newMetric(math.Round(sales.Revenue*100)/100, math.Round(prevSales.Revenue*100)/100)
+
+	today := truncateToDate(now)
+	if stats.CardsExpiringWeek, err = s.compareCounts(ctx, s.repo.CountCardsEnding,
+		today, today.AddDate(0, 0, 7), today.AddDate(0, 0, -7), today); err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(stats); err == nil {
+		if err := s.cache.Set(ctx, cacheKey, data, dashboardCacheTTL); err != nil {
+			logger.Warn("Failed to cache dashboard stats", zap.Error(err))
+		}
+	}
+	return stats, nil
+}
+
+func (s *StatsService) compareCounts(ctx context.Context, count func(ctx context.Context, from, to time.Time) (int64, error), from, to, prevFrom, prevTo time.Time) (Metric, error) {
+	current, err := count(ctx, from, to)
+	if err != nil {
+		return Metric{}, err
+	}
+	previous, err := count(ctx, prevFrom, prevTo)
+	if err != nil {
+		return Metric{}, err
+	}
+	return newMetric(float64(current), float64(previous)), nil
+}
+
+func newMetric(current, previous float64) Metric {
+	m := Metric{Current: current, Previous: previous}
+	if previous != 0 {
+		rate := math.Round((current-previous)/previous*10000) / 10000
+		m.ChangeRate = &rate
+	}
+	return m
+}
+
+// periodStart returns the start of the current period and of the one before it.
+// Weeks start on Monday.
+// comparisonWindow returns the start of the current period and the same
+// elapsed span of the previous period. The span is cut at the end of the
+// previous period, which for months can be shorter than the time elapsed in
+// this one: on 31 March the previous span ends with February, not on 3 March.
+func comparisonWindow(period string, now time.Time) (from, prevFrom, prevTo time.Time, err error) {
+	from, prevFrom, err = periodStart(period, now)
+	if err != nil {
+		return
+	}
+	prevTo = prevFrom.Add(now.Sub(from))
+	if prevTo.After(from) {
+		prevTo = from
+	}
+	return
+}
+
+func periodStart(period string, now time.Time) (time.Time, time.Time, error) {
+	today := truncateToDate(now)
+	switch period {
+	case "day":
+		return today, today.AddDate(0, 0, -1), nil
+	case "week":
+		start := today.AddDate(0, 0, -weekdayIndex(today))
+		return start, start.AddDate(0, 0, -7), nil
+	case "month":
+		start := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, today.Location())
+		return start, start.AddDate(0, -1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, ErrInvalidPeriod
+	}
+}
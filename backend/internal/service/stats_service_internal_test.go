@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComparisonWindow(t *testing.T) {
+	day := func(month time.Month, d, hour int) time.Time {
+		return time.Date(2024, month, d, hour, 0, 0, 0, time.Local)
+	}
+	tests := []struct {
+		name                   string
+		period                 string
+		now                    time.Time
+		from, prevFrom, prevTo time.Time
+	}{
+		{name: "day", period: "day", now: day(3, 12, 10),
+			from: day(3, 12, 0), prevFrom: day(3, 11, 0), prevTo: day(3, 11, 10)},
+		// 2024-03-13 is a Wednesday
+		{name: "week", period: "week", now: day(3, 13, 10),
+			from: day(3, 11, 0), prevFrom: day(3, 4, 0), prevTo: day(3, 6, 10)},
+		{name: "early in the month", period: "month", now: day(3, 10, 10),
+			from: day(3, 1, 0), prevFrom: day(2, 1, 0), prevTo: day(2, 10, 10)},
+		// 30 days into March would reach 2 March counted from 1 February
+		{name: "longer than the previous month", period: "month", now: day(3, 31, 10),
+			from: day(3, 1, 0), prevFrom: day(2, 1, 0), prevTo: day(3, 1, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, prevFrom, prevTo, err := comparisonWindow(tt.period, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !from.Equal(tt.from) || !prevFrom.Equal(tt.prevFrom) || !prevTo.Equal(tt.prevTo) {
+				t.Errorf("window = %v, %v - %v, want %v, %v - %v", from, prevFrom, prevTo, tt.from, tt.prevFrom, tt.prevTo)
+			}
+		})
+	}
+
+	if _, _, _, err := comparisonWindow("year", day(3, 1, 0)); err != ErrInvalidPeriod {
+		t.Errorf("unknown period: error = %v, want ErrInvalidPeriod", err)
+	}
+}
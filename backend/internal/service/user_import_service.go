@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/pinyin"
+	"gym-admin/pkg/spreadsheet"
+	"gym-admin/pkg/validate"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxImportRows   = 20000
+	importBatchSize = 200
+)
+
+// importColumns maps accepted header names (Chinese or English) to fields
+var importColumns = map[string]string{
+	"姓名":     "name",
+	"手机号":    "phone",
+	"性别":     "gender",
+	"生日":     "birthday",
+	"身份证号":   "id_card",
+	"邮箱":     "email",
+	"地址":     "address",
+	"紧急联系人":  "emergency_contact",
+	"紧急联系电话": "emergency_phone",
+	"健康状况":   "health_status",
+	"训练目标":   "training_goal",
+	"来源":     "source",
+	"备注":     "remark",
+	"卡类型编码":  "card_type_code",
+	"开卡日期":   "card_start_date",
+	"到期日期":   "card_end_date",
+	"剩余次数":   "card_remaining_times",
+	"购买价格":   "card_purchase_price",
+}
+
+// ImportTemplateHeaders is the column order of the downloadable template
+var ImportTemplateHeaders = []string{
+	"姓名", "手机号", "性别", "生日", "身份证号", "邮箱", "地址", "紧急联系人", "紧急联系电话",
+	"健康状况", "训练目标", "来源", "备注", "卡类型编码", "开卡日期", "到期日期", "剩余次数", "购买价格",
+}
+
+var genderValues = map[string]int8{"1": 1, "2": 2, "男": 1, "女": 2, "male": 1, "female": 2}
+
+var userSourceValues = map[string]int8{
+	"1": 1, "2": 2, "3": 3, "4": 4,
+	"前台录入": 1, "小程序注册": 2, "美团": 3, "抖音": 4,
+}
+
+type ImportRowError struct {
+	Row      int      `json:"row"` // 1-based row number in the file, header is row 1
+	Phone    string   `json:"phone"`
+	Messages []string `json:"messages"`
+}
+
+type ImportResult struct {
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Invalid  int              `json:"invalid"`
+	Imported int              `json:"imported"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+type importRow struct {
+	row  int
+	user *models.User
+	card *importCard
+}
+
+type importCard struct {
+	cardType       *models.CardType
+	start          time.Time
+	end            *time.Time
+	remainingTimes *int
+	price          float64
+}
+
+type UserImportService struct {
+	userService *UserService
+	cardService *CardService
+	userRepo    repository.UserRepository
+	cardRepo    repository.CardRepository
+	tx          repository.TxManager
+}
+
+func NewUserImportService(userService *UserService, cardService *CardService, userRepo repository.UserRepository, cardRepo repository.CardRepository, tx repository.TxManager) *UserImportService {
+	return &UserImportService{
+		userService: userService,
+		cardService: cardService,
+		userRepo:    userRepo,
+		cardRepo:    cardRepo,
+		tx:          tx,
+	}
+}
+
+// ImportUsers validates every row of a CSV/XLSX file. In dry-run mode only the
+// report is returned; otherwise valid rows are written in batched transactions.
+func (s *UserImportService) ImportUsers(ctx context.Context, r io.Reader, filename string, dryRun bool, operatorID int64) (*ImportResult, error) {
+	format, err := spreadsheet.DetectFormat(filename)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := spreadsheet.ReadRows(r, format, maxImportRows+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) < 2 {
+		return nil, apperr.Invalid("file has no data rows")
+	}
+
+	columns, err := mapImportHeader(rows[0])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{DryRun: dryRun, Errors: []ImportRowError{}}
+	cardTypes := make(map[string]*models.CardType)
+	seenPhones := make(map[string]int)
+
+	var parsed []importRow
+	for i, cells := range rows[1:] {
+		rowNo := i + 2
+		if isBlankRow(cells) {
+			continue
+		}
+		result.Total++
+
+		values := make(map[string]string)
+		for col, field := range columns {
+			if col < len(cells) {
+				values[field] = strings.TrimSpace(cells[col])
+			}
+		}
+
+		item, messages := s.parseRow(ctx, values, cardTypes)
+		if first, ok := seenPhones[values["phone"]]; ok && values["phone"] != "" {
+			messages = append(messages, fmt.Sprintf("duplicate phone, same as row %d", first))
+		} else {
+			seenPhones[values["phone"]] = rowNo
+		}
+
+		if len(messages) > 0 {
+			result.Errors = append(result.Errors, ImportRowError{Row: rowNo, Phone: values["phone"], Messages: messages})
+			continue
+		}
+		item.row = rowNo
+		parsed = append(parsed, item)
+	}
+
+	// Check against existing users in one pass
+	phones := make([]string, 0, len(parsed))
+	for _, item := range parsed {
+		phones = append(phones, item.user.Phone)
+	}
+	existing, err := s.userRepo.ExistingPhones(ctx, phones)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := parsed[:0]
+	for _, item := range parsed {
+		if existing[item.user.Phone] {
+			result.Errors = append(result.Errors, ImportRowError{
+				Row: item.row, Phone: item.user.Phone, Messages: []string{"phone already exists"},
+			})
+			continue
+		}
+		valid = append(valid, item)
+	}
+	result.Valid = len(valid)
+	result.Invalid = result.Total - result.Valid
+
+	if dryRun {
+		sortImportErrors(result.Errors)
+		return result, nil
+	}
+
+	for start := 0; start < len(valid); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(valid) {
+			end = len(valid)
+		}
+		batch := valid[start:end]
+
+		if err := s.importBatch(ctx, batch, operatorID); err != nil {
+			for _, item := range batch {
+				result.Errors = append(result.Errors, ImportRowError{
+					Row: item.row, Phone: item.user.Phone, Messages: []string{"import failed: " + err.Error()},
+				})
+			}
+			continue
+		}
+		result.Imported += len(batch)
+	}
+	sortImportErrors(result.Errors)
+	return result, nil
+}
+
+// importBatch creates the users of a batch and their cards, with the opening
+// of each card logged, in one unit of work
+func (s *UserImportService) importBatch(ctx context.Context, batch []importRow, operatorID int64) error {
+	cards := make([]*models.MembershipCard, len(batch))
+	for i, item := range batch {
+		userNo, err := s.userService.generateUserNo(ctx)
+		if err != nil {
+			return err
+		}
+		item.user.UserNo = userNo
+
+		if item.card != nil {
+			card, err := s.cardService.BuildCard(ctx, 0, item.card.cardType, item.card.start, item.card.end, item.card.price, item.user.Source)
+			if err != nil {
+				return err
+			}
+			if item.card.remainingTimes != nil && card.RemainingTimes != nil {
+				card.RemainingTimes = item.card.remainingTimes
+			}
+			card.OperatorID = &operatorID
+			card.Remark = "批量导入"
+			cards[i] = card
+		}
+	}
+
+	return s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		for i, item := range batch {
+			if err := repos.Users.Create(ctx, item.user); err != nil {
+				return err
+			}
+			if card := cards[i]; card != nil {
+				card.UserID = item.user.ID
+				if err := s.cardService.SaveCard(ctx, card); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// parseRow converts one row into a user and optional card, collecting every validation error
+func (s *UserImportService) parseRow(ctx context.Context, values map[string]string, cardTypes map[string]*models.CardType) (importRow, []string) {
+	var messages []string
+	user := &models.User{
+		Name:             values["name"],
+		Phone:            values["phone"],
+		IDCard:           strings.ToUpper(values["id_card"]),
+		Email:            values["email"],
+		Address:          values["address"],
+		EmergencyContact: values["emergency_contact"],
+		EmergencyPhone:   values["emergency_phone"],
+		HealthStatus:     values["health_status"],
+		TrainingGoal:     values["training_goal"],
+		Remark:           values["remark"],
+		Source:           1,
+		Status:           UserStatusNormal,
+	}
+
+	if user.Name == "" {
+		messages = append(messages, "name is required")
+	}
+	if err := validateUserContact(user.Phone, user.IDCard, user.Email); err != nil {
+		messages = append(messages, err.Error())
+	}
+	if user.EmergencyPhone != "" && !validate.IsMobile(user.EmergencyPhone) {
+		messages = append(messages, "invalid emergency phone")
+	}
+	if v := values["gender"]; v != "" {
+		if gender, ok := genderValues[strings.ToLower(v)]; ok {
+			user.Gender = gender
+		} else {
+			messages = append(messages, "invalid gender")
+		}
+	}
+	if v := values["source"]; v != "" {
+		if source, ok := userSourceValues[v]; ok {
+			user.Source = source
+		} else {
+			messages = append(messages, "invalid source")
+		}
+	}
+	if v := values["birthday"]; v != "" {
+		if birthday, err := parseImportDate(v); err == nil {
+			user.Birthday = &birthday
+		} else {
+			messages = append(messages, "invalid birthday")
+		}
+	}
+	user.NamePinyin, user.NameInitials = pinyin.Convert(user.Name)
+
+	item := importRow{user: user}
+	if code := values["card_type_code"]; code != "" {
+		card, cardMessages := s.parseCard(ctx, code, values, cardTypes)
+		item.card = card
+		messages = append(messages, cardMessages...)
+	}
+	return item, messages
+}
+
+func (s *UserImportService) parseCard(ctx context.Context, code string, values map[string]string, cardTypes map[string]*models.CardType) (*importCard, []string) {
+	var messages []string
+
+	cardType, ok := cardTypes[code]
+	if !ok {
+		ct, err := s.cardRepo.GetCardTypeByCode(ctx, code)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, []string{"failed to load card type: " + err.Error()}
+			}
+			ct = nil
+		}
+		cardTypes[code] = ct
+		cardType = ct
+	}
+	if cardType == nil {
+		return nil, []string{"unknown card type code " + code}
+	}
+
+	card := &importCard{cardType: cardType, start: time.Now(), price: cardType.Price}
+	if v := values["card_start_date"]; v != "" {
+		if start, err := parseImportDate(v); err == nil {
+			card.start = start
+		} else {
+			messages = append(messages, "invalid card start date")
+		}
+	}
+	if v := values["card_end_date"]; v != "" {
+		if end, err := parseImportDate(v); err == nil {
+			if end.Before(card.start) {
+				messages = append(messages, "card end date is before start date")
+			}
+			card.end = &end
+		} else {
+			messages = append(messages, "invalid card end date")
+		}
+	}
+	if v := values["card_remaining_times"]; v != "" {
+		times, err := strconv.Atoi(v)
+		if err != nil || times < 0 {
+			messages = append(messages, "invalid remaining times")
+		} else if cardType.DurationType != CardDurationTimes {
+			messages = append(messages, "remaining times only applies to visit cards")
+		} else {
+			card.remainingTimes = &times
+		}
+	}
+	if v := values["card_purchase_price"]; v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price < 0 {
+			messages = append(messages, "invalid purchase price")
+		} else {
+			card.price = price
+		}
+	}
+	return card, messages
+}
+
+func mapImportHeader(header []string) (map[int]string, error) {
+	fields := make(map[string]string, len(importColumns)*2)
+	for label, field := range importColumns {
+		fields[label] = field
+		fields[field] = field
+	}
+
+	columns := make(map[int]string)
+	seen := make(map[string]bool)
+	for i, name := range header {
+		field, ok := fields[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			continue
+		}
+		if seen[field] {
+			return nil, apperr.Invalid(fmt.Sprintf("duplicate column %q", name))
+		}
+		seen[field] = true
+		columns[i] = field
+	}
+
+	if !seen["name"] || !seen["phone"] {
+		return nil, apperr.Invalid("name and phone columns are required")
+	}
+	return columns, nil
+}
+
+func parseImportDate(v string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "2006/1/2", "20060102", "01-02-06"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", v)
+}
+
+func sortImportErrors(errs []ImportRowError) {
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Row < errs[j].Row })
+}
+
+func isBlankRow(cells []string) bool {
+	for _, cell := range cells {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+	return true
+}
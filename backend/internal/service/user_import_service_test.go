@@ -0,0 +1,67 @@
+package service_test
+
+import (
+	"context"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/internal/repository/fake"
+	"gym-admin/internal/service"
+	"strings"
+	"testing"
+)
+
+func TestImportUsersLogsCardOpening(t *testing.T) {
+	users := &fake.UserRepository{
+		ExistingPhonesFunc: func(ctx context.Context, phones []string) (map[string]bool, error) {
+			return map[string]bool{}, nil
+		},
+	}
+	cards := &fake.CardRepository{
+		GetCardTypeByCodeFunc: func(ctx context.Context, code string) (*models.CardType, error) {
+			return &models.CardType{ID: 5, TypeCode: code, DurationType: service.CardDurationMonth, DurationValue: 30, Status: 1}, nil
+		},
+	}
+
+	var nextID int64
+	txUsers := &fake.UserRepository{
+		CreateFunc: func(ctx context.Context, user *models.User) error {
+			nextID++
+			user.ID = nextID
+			return nil
+		},
+	}
+	var created []*models.MembershipCard
+	var logs []*models.CardOperationLog
+	txCards := &fake.CardRepository{
+		CreateFunc: func(ctx context.Context, card *models.MembershipCard) error {
+			card.ID = int64(100 + len(created))
+			created = append(created, card)
+			return nil
+		},
+		CreateLogFunc: func(ctx context.Context, log *models.CardOperationLog) error {
+			logs = append(logs, log)
+			return nil
+		},
+	}
+	tx := &fake.TxManager{Repos: &repository.Repositories{Users: txUsers, Cards: txCards}}
+	seq := sequence()
+	svc := service.NewUserImportService(service.NewUserService(users, seq, tx), service.NewCardService(cards, seq, tx), users, cards, tx)
+
+	csv := "姓名,手机号,卡类型编码,购买价格\n张三,13800000001,M30,300\n李四,13800000002,,\n"
+	result, err := svc.ImportUsers(context.Background(), strings.NewReader(csv), "members.csv", false, 9)
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+	if result.Imported != 2 || len(result.Errors) != 0 {
+		t.Fatalf("result = %+v, want 2 imported", result)
+	}
+	if tx.Calls != 1 {
+		t.Errorf("units of work = %d, want one for the batch", tx.Calls)
+	}
+	if len(created) != 1 || created[0].UserID != 1 {
+		t.Fatalf("created cards = %v, want one of the first member", created)
+	}
+	if len(logs) != 1 || logs[0].CardID != created[0].ID || logs[0].OperationType != service.CardOpOpen || logs[0].Amount != 300 || logs[0].OperatorID != 9 {
+		t.Errorf("opening logs = %v", logs)
+	}
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"gym-admin/pkg/pinyin"
+	"gym-admin/pkg/validate"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	UserStatusNormal    int8 = 1
+	UserStatusFrozen    int8 = 2
+	UserStatusBlacklist int8 = 3
+)
+
+// UserSourceApp marks members who signed up themselves in the mini program
+const UserSourceApp int8 = 2
+
+// Roles carried in login tokens. Members are "user"; the others are staff.
+const (
+	RoleMember  = "user"
+	RoleStaff   = "staff"
+	RoleManager = "manager"
+	RoleAdmin   = "admin"
+)
+
+var (
+	ErrPhoneExists          = apperr.Conflict("PHONE_EXISTS", "phone already exists")
+	ErrUserNotFrozen        = apperr.Conflict("USER_NOT_FROZEN", "user is not frozen")
+	ErrUserNotBlacklisted   = apperr.Conflict("USER_NOT_BLACKLISTED", "user is not blacklisted")
+	ErrUserStatusConcurrent = apperr.Conflict("CONCURRENT_UPDATE", "user status was changed concurrently, please retry")
+	ErrInvalidCredentials   = apperr.New(http.StatusUnauthorized, "INVALID_CREDENTIALS", "invalid phone or password")
+	ErrUserBlacklisted      = apperr.New(http.StatusForbidden, "USER_BLACKLISTED", "user is blacklisted")
+)
+
+// Password length bounds; bcrypt only uses the first 72 bytes
+const (
+	minPasswordLength = 6
+	maxPasswordLength = 72
+)
+
+// unknownUserHash is compared against when the phone is not registered, so
+// that a failed login takes as long whether or not the member exists
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+
+// userStatusTransitions lists the allowed target statuses for each status
+var userStatusTransitions = map[int8][]int8{
+	UserStatusNormal:    {UserStatusFrozen, UserStatusBlacklist},
+	UserStatusFrozen:    {UserStatusNormal, UserStatusBlacklist},
+	UserStatusBlacklist: {UserStatusNormal},
+}
+
+type UserService struct {
+	repo    repository.UserRepository
+	seqRepo repository.SequenceRepository
+	tx      repository.TxManager
+}
+
+func NewUserService(repo repository.UserRepository, seqRepo repository.SequenceRepository, tx repository.TxManager) *UserService {
+	return &UserService{
+		repo:    repo,
+		seqRepo: seqRepo,
+		tx:      tx,
+	}
+}
+
+// CreateUser registers a member. A referral code from an existing member
+// records them as the referrer.
+func (s *UserService) CreateUser(ctx context.Context, user *models.User, referralCode string) error {
+	user.Phone = strings.TrimSpace(user.Phone)
+	user.IDCard = strings.ToUpper(strings.TrimSpace(user.IDCard))
+	user.Email = strings.TrimSpace(user.Email)
+	if strings.TrimSpace(user.Name) == "" {
+		return errNameRequired
+	}
+	if err := validateUserContact(user.Phone, user.IDCard, user.Email); err != nil {
+		return err
+	}
+	if user.Source < 1 || user.Source > 4 {
+		user.Source = 1
+	}
+
+	if _, err := s.repo.GetByPhone(ctx, user.Phone); err == nil {
+		return ErrPhoneExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var referrer *models.User
+	if strings.TrimSpace(referralCode) != "" {
+		var err error
+		if referrer, err = resolveReferrer(ctx, s.repo, referralCode); err != nil {
+			return err
+		}
+	}
+
+	userNo, err := s.generateUserNo(ctx)
+	if err != nil {
+		return err
+	}
+	code, err := newReferralCode(ctx, s.repo)
+	if err != nil {
+		return err
+	}
+
+	user.ID = 0
+	user.UserNo = userNo
+	user.NamePinyin, user.NameInitials = pinyin.Convert(user.Name)
+	user.Status = UserStatusNormal // Default status: normal
+	user.ReferralCode = &code
+	user.ReferrerID = nil
+
+	if referrer == nil {
+		return s.repo.Create(ctx, user)
+	}
+	user.ReferrerID = &referrer.ID
+	user.Source = UserSourceReferral
+	return s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		if err := repos.Users.Create(ctx, user); err != nil {
+			return err
+		}
+		return repos.Referrals.Create(ctx, &models.Referral{
+			ReferrerID: referrer.ID,
+			RefereeID:  user.ID,
+			Code:       *referrer.ReferralCode,
+			Status:     ReferralStatusPending,
+		})
+	})
+}
+
+// Register signs up a member with a login password
+func (s *UserService) Register(ctx context.Context, name, phone, password string) (*models.User, error) {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{Name: name, Phone: phone, Source: UserSourceApp, PasswordHash: hash}
+	if err := s.CreateUser(ctx, user, ""); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Authenticate checks a login. Members created at the front desk have no
+// password until they set one and cannot log in before.
+func (s *UserService) Authenticate(ctx context.Context, phone, password string) (*models.User, error) {
+	user, err := s.repo.GetByPhone(ctx, strings.TrimSpace(phone))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if user.Status == UserStatusBlacklist {
+		return nil, ErrUserBlacklisted
+	}
+	return user, nil
+}
+
+// ChangePassword sets a user's own password after checking the current one
+func (s *UserService) ChangePassword(ctx context.Context, userID int64, current, password string) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if user.PasswordHash == "" || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return ErrInvalidCredentials
+	}
+	return s.setPassword(ctx, userID, password)
+}
+
+// ResetPassword sets a user's password without the current one, for an admin
+// handing out a first or forgotten password
+func (s *UserService) ResetPassword(ctx context.Context, userID int64, password string) error {
+	if _, err := s.repo.GetByID(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return s.setPassword(ctx, userID, password)
+}
+
+func (s *UserService) setPassword(ctx context.Context, userID int64, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpdatePassword(ctx, userID, hash)
+}
+
+func hashPassword(password string) (string, error) {
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return "", apperr.Invalid(fmt.Sprintf("password must be %d to %d characters", minPasswordLength, maxPasswordLength))
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (s *UserService) GetUser(ctx context.Context, id int64) (*models.User, error) {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	return user, nil
+}
+
+func (s *UserService) GetUserByPhone(ctx context.Context, phone string) (*models.User, error) {
+	return s.repo.GetByPhone(ctx, phone)
+}
+
+func (s *UserService) ListUsers(ctx context.Context, q listquery.Query) ([]models.User, listquery.Page, error) {
+	return s.repo.List(ctx, q)
+}
+
+// SearchUsers is the front desk lookup by name, pinyin, phone suffix, UserNo or card number
+func (s *UserService) SearchUsers(ctx context.Context, filter repository.UserSearchFilter, q listquery.Query) ([]models.User, listquery.Page, error) {
+	return s.repo.Search(ctx, filter, q)
+}
+
+// RebuildNamePinyin fills the pinyin search columns for users created before they existed
+func (s *UserService) RebuildNamePinyin(ctx context.Context) (int, error) {
+	const batchSize = 500
+
+	updated := 0
+	var lastID int64
+	for {
+		users, err := s.repo.FindWithoutPinyin(ctx, lastID, batchSize)
+		if err != nil {
+			return updated, err
+		}
+		for _, user := range users {
+			full, initials := pinyin.Convert(user.Name)
+			if err := s.repo.UpdateNamePinyin(ctx, user.ID, full, initials); err != nil {
+				return updated, err
+			}
+			lastID = user.ID
+			updated++
+		}
+		if len(users) < batchSize {
+			return updated, nil
+		}
+	}
+}
+
+// UserUpdate holds the profile fields to change; nil fields are left as
+// they are and empty strings clear optional ones
+type UserUpdate struct {
+	Name             *string `json:"name" binding:"omitempty,min=1,max=50"`
+	Gender           *int8   `json:"gender" binding:"omitempty,oneof=1 2"`
+	Birthday         *string `json:"birthday" binding:"omitempty,date,notfuture"`
+	Phone            *string `json:"phone" binding:"omitempty,min=1,mobile"`
+	IDCard           *string `json:"id_card" binding:"omitempty,idcard"`
+	Email            *string `json:"email" binding:"omitempty,email,max=100"`
+	AvatarURL        *string `json:"avatar_url" binding:"omitempty,max=255"`
+	Address          *string `json:"address" binding:"omitempty,max=255"`
+	EmergencyContact *string `json:"emergency_contact" binding:"omitempty,max=50"`
+	EmergencyPhone   *string `json:"emergency_phone" binding:"omitempty,mobile"`
+	HealthStatus     *string `json:"health_status"`
+	TrainingGoal     *string `json:"training_goal"`
+	Remark           *string `json:"remark"`
+}
+
+// UpdateUser updates profile fields. Status changes go through ChangeStatus.
+func (s *UserService) UpdateUser(ctx context.Context, id int64, in UserUpdate) error {
+	user, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+
+	if in.Name != nil {
+		if strings.TrimSpace(*in.Name) == "" {
+			return errNameRequired
+		}
+		user.Name = *in.Name
+		user.NamePinyin, user.NameInitials = pinyin.Convert(user.Name)
+	}
+	if in.Gender != nil {
+		user.Gender = *in.Gender
+	}
+	if in.Birthday != nil {
+		if *in.Birthday == "" {
+			user.Birthday = nil
+		} else {
+			t, err := time.ParseInLocation("2006-01-02", *in.Birthday, time.Local)
+			if err != nil {
+				return apperr.Invalid("invalid birthday, expected YYYY-MM-DD")
+			}
+			user.Birthday = &t
+		}
+	}
+	if in.Phone != nil {
+		user.Phone = strings.TrimSpace(*in.Phone)
+	}
+	if in.IDCard != nil {
+		user.IDCard = strings.ToUpper(strings.TrimSpace(*in.IDCard))
+	}
+	if in.Email != nil {
+		user.Email = strings.TrimSpace(*in.Email)
+	}
+	if in.AvatarURL != nil {
+		user.AvatarURL = *in.AvatarURL
+	}
+	if in.Address != nil {
+		user.Address = *in.Address
+	}
+	if in.EmergencyContact != nil {
+		user.EmergencyContact = *in.EmergencyContact
+	}
+	if in.EmergencyPhone != nil {
+		if *in.EmergencyPhone != "" && !validate.IsMobile(*in.EmergencyPhone) {
+			return apperr.Invalid("invalid emergency phone")
+		}
+		user.EmergencyPhone = *in.EmergencyPhone
+	}
+	if in.HealthStatus != nil {
+		user.HealthStatus = *in.HealthStatus
+	}
+	if in.TrainingGoal != nil {
+		user.TrainingGoal = *in.TrainingGoal
+	}
+	if in.Remark != nil {
+		user.Remark = *in.Remark
+	}
+
+	if err := validateUserContact(user.Phone, user.IDCard, user.Email); err != nil {
+		return err
+	}
+	if existing, err := s.repo.GetByPhone(ctx, user.Phone); err == nil && existing.ID != user.ID {
+		return ErrPhoneExists
+	}
+
+	return s.repo.Update(ctx, user)
+}
+
+func (s *UserService) DeleteUser(ctx context.Context, id int64) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *UserService) GetUserStats(ctx context.Context, userID int64) (*models.UserTrainingStats, error) {
+	return s.repo.GetStats(ctx, userID)
+}
+
+// SetRole makes a user a member or a staff member of the given role. The
+// new role takes effect at their next login.
+func (s *UserService) SetRole(ctx context.Context, userID int64, role string) error {
+	switch role {
+	case RoleMember, RoleStaff, RoleManager, RoleAdmin:
+	default:
+		return apperr.Invalid("unknown role: " + role)
+	}
+	if _, err := s.repo.GetByID(ctx, userID); err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	return s.repo.UpdateRole(ctx, userID, role)
+}
+
+// ChangeStatus moves a user between normal, frozen and blacklist, recording who did it and why
+func (s *UserService) ChangeStatus(ctx context.Context, userID int64, newStatus int8, reason string, operatorID int64) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return apperr.Invalid("reason is required")
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if !canTransitUserStatus(user.Status, newStatus) {
+		return apperr.Conflict("INVALID_STATUS_TRANSITION", fmt.Sprintf("cannot change user status from %d to %d", user.Status, newStatus))
+	}
+
+	err = s.repo.UpdateStatus(ctx, userID, &models.UserStatusChange{
+		UserID:     userID,
+		OldStatus:  user.Status,
+		NewStatus:  newStatus,
+		Reason:     reason,
+		OperatorID: operatorID,
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserStatusConcurrent
+	}
+	return err
+}
+
+func (s *UserService) FreezeUser(ctx context.Context, userID int64, reason string, operatorID int64) error {
+	return s.ChangeStatus(ctx, userID, UserStatusFrozen, reason, operatorID)
+}
+
+func (s *UserService) UnfreezeUser(ctx context.Context, userID int64, reason string, operatorID int64) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if user.Status != UserStatusFrozen {
+		return ErrUserNotFrozen
+	}
+	return s.ChangeStatus(ctx, userID, UserStatusNormal, reason, operatorID)
+}
+
+func (s *UserService) AddToBlacklist(ctx context.Context, userID int64, reason string, operatorID int64) error {
+	return s.ChangeStatus(ctx, userID, UserStatusBlacklist, reason, operatorID)
+}
+
+func (s *UserService) RemoveFromBlacklist(ctx context.Context, userID int64, reason string, operatorID int64) error {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return notFound(err, ErrUserNotFound)
+	}
+	if user.Status != UserStatusBlacklist {
+		return ErrUserNotBlacklisted
+	}
+	return s.ChangeStatus(ctx, userID, UserStatusNormal, reason, operatorID)
+}
+
+func (s *UserService) GetStatusHistory(ctx context.Context, userID int64) ([]models.UserStatusChange, error) {
+	return s.repo.ListStatusChanges(ctx, userID)
+}
+
+// generateUserNo allocates numbers like U202401010001 from a per-day sequence,
+// so concurrent registrations never collide
+func (s *UserService) generateUserNo(ctx context.Context) (string, error) {
+	date := time.Now().Format("20060102")
+	seq, err := s.seqRepo.Next(ctx, "user_no:"+date)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate user number: %w", err)
+	}
+	return fmt.Sprintf("U%s%04d", date, seq), nil
+}
+
+func canTransitUserStatus(from, to int8) bool {
+	for _, allowed := range userStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func validateUserContact(phone, idCard, email string) error {
+	if !validate.IsMobile(phone) {
+		return apperr.Invalid("invalid phone number")
+	}
+	if idCard != "" && !validate.IsIDCard(idCard) {
+		return apperr.Invalid("invalid ID card number")
+	}
+	if email != "" && !validate.IsEmail(email) {
+		return apperr.Invalid("invalid email")
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository/fake"
+	"gym-admin/internal/service"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// memberRepository keeps users by phone, enough for signing up and logging in
+func memberRepository(users map[string]*models.User) *fake.UserRepository {
+	return &fake.UserRepository{
+		GetByPhoneFunc: func(ctx context.Context, phone string) (*models.User, error) {
+			if u, ok := users[phone]; ok {
+				return u, nil
+			}
+			return nil, gorm.ErrRecordNotFound
+		},
+		ReferralCodeExistsFunc: func(ctx context.Context, code string) (bool, error) {
+			return false, nil
+		},
+		CreateFunc: func(ctx context.Context, user *models.User) error {
+			user.ID = int64(len(users) + 1)
+			users[user.Phone] = user
+			return nil
+		},
+	}
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	users := map[string]*models.User{
+		// Created at the front desk, never set a password
+		"13700000002": {ID: 100, Phone: "13700000002", Status: service.UserStatusNormal},
+	}
+	svc := service.NewUserService(memberRepository(users), sequence(), &fake.TxManager{})
+
+	if _, err := svc.Register(context.Background(), "张三", "13700000001", "12345"); err == nil {
+		t.Error("registering with a 5 character password succeeded")
+	}
+	registered, err := svc.Register(context.Background(), "张三", "13700000001", "secret1")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if registered.PasswordHash == "" || registered.PasswordHash == "secret1" {
+		t.Fatalf("password hash = %q", registered.PasswordHash)
+	}
+
+	tests := []struct {
+		name      string
+		phone     string
+		password  string
+		blacklist bool
+		wantErr   error
+	}{
+		{name: "right password", phone: "13700000001", password: "secret1"},
+		{name: "wrong password", phone: "13700000001", password: "secret2", wantErr: service.ErrInvalidCredentials},
+		{name: "unknown phone", phone: "13700000009", password: "secret1", wantErr: service.ErrInvalidCredentials},
+		{name: "no password set", phone: "13700000002", password: "", wantErr: service.ErrInvalidCredentials},
+		{name: "blacklisted", phone: "13700000001", password: "secret1", blacklist: true, wantErr: service.ErrUserBlacklisted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registered.Status = service.UserStatusNormal
+			if tt.blacklist {
+				registered.Status = service.UserStatusBlacklist
+			}
+			user, err := svc.Authenticate(context.Background(), tt.phone, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && user.ID != registered.ID {
+				t.Errorf("authenticated user %d, want %d", user.ID, registered.ID)
+			}
+		})
+	}
+}
+
+func TestChangePasswordNeedsTheCurrentOne(t *testing.T) {
+	users := map[string]*models.User{}
+	repo := memberRepository(users)
+	svc := service.NewUserService(repo, sequence(), &fake.TxManager{})
+	user, err := svc.Register(context.Background(), "李四", "13700000003", "secret1")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	repo.GetByIDFunc = func(ctx context.Context, id int64) (*models.User, error) {
+		return user, nil
+	}
+	repo.UpdatePasswordFunc = func(ctx context.Context, userID int64, hash string) error {
+		user.PasswordHash = hash
+		return nil
+	}
+
+	if err := svc.ChangePassword(context.Background(), user.ID, "wrong", "secret2"); !errors.Is(err, service.ErrInvalidCredentials) {
+		t.Errorf("wrong current password: error = %v, want invalid credentials", err)
+	}
+	if err := svc.ChangePassword(context.Background(), user.ID, "secret1", "secret2"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	if _, err := svc.Authenticate(context.Background(), user.Phone, "secret2"); err != nil {
+		t.Errorf("logging in with the new password: %v", err)
+	}
+}
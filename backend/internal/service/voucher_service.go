@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/metrics"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	VoucherPlatformMeituan = models.VoucherPlatformMeituan
+	VoucherPlatformDouyin  = models.VoucherPlatformDouyin
+)
+
+const (
+	VoucherStatusUnverified = models.VoucherStatusUnverified
+	VoucherStatusVerified   = models.VoucherStatusVerified
+	VoucherStatusExpired    = models.VoucherStatusExpired
+)
+
+// voucherPlatformLabels names the platforms in metrics
+var voucherPlatformLabels = map[int8]string{
+	VoucherPlatformMeituan: "meituan",
+	VoucherPlatformDouyin:  "douyin",
+}
+
+// voucherCardSources is the card source recorded for cards bought through
+// each platform
+var voucherCardSources = map[int8]int8{
+	VoucherPlatformMeituan: 3,
+	VoucherPlatformDouyin:  4,
+}
+
+var (
+	ErrVoucherAlreadyVerified = apperr.Conflict("VOUCHER_ALREADY_VERIFIED", "voucher has already been verified")
+	ErrVoucherExpired         = apperr.Conflict("VOUCHER_EXPIRED", "voucher has expired")
+)
+
+// VerifyVoucherInput is a voucher bought on a group-buying platform and
+// presented at the front desk, with the card it is exchanged for
+type VerifyVoucherInput struct {
+	Code       string
+	Platform   int8
+	UserID     int64
+	CardTypeID int64
+	Amount     float64 // what the member paid on the platform
+	Remark     string
+}
+
+type VoucherService struct {
+	cardRepo    repository.CardRepository
+	userRepo    repository.UserRepository
+	cardService *CardService
+	tx          repository.TxManager
+}
+
+func NewVoucherService(cardRepo repository.CardRepository, userRepo repository.UserRepository, cardService *CardService, tx repository.TxManager) *VoucherService {
+	return &VoucherService{
+		cardRepo:    cardRepo,
+		userRepo:    userRepo,
+		cardService: cardService,
+		tx:          tx,
+	}
+}
+
+// Verify redeems a platform voucher for a card. The voucher is marked
+// verified and the card opened in one unit of work, so a voucher is never
+// spent without its card nor verified twice.
+func (s *VoucherService) Verify(ctx context.Context, in VerifyVoucherInput, operatorID int64) (*models.MembershipCard, error) {
+	card, err := s.verify(ctx, in, operatorID)
+	if label, ok := voucherPlatformLabels[in.Platform]; ok {
+		metrics.VoucherVerifications.WithLabelValues(label, outcome(err, "rejected")).Inc()
+	}
+	return card, err
+}
+
+func (s *VoucherService) verify(ctx context.Context, in VerifyVoucherInput, operatorID int64) (*models.MembershipCard, error) {
+	source, ok := voucherCardSources[in.Platform]
+	if !ok {
+		return nil, apperr.Invalid("unsupported voucher platform")
+	}
+	user, err := s.userRepo.GetByID(ctx, in.UserID)
+	if err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	if user.Status == UserStatusBlacklist {
+		return nil, ErrUserBlacklisted
+	}
+	cardType, err := s.cardRepo.GetCardTypeByID(ctx, in.CardTypeID)
+	if err != nil {
+		return nil, notFound(err, ErrCardTypeNotFound)
+	}
+	if cardType.Status != 1 {
+		return nil, ErrCardTypeDisabled
+	}
+
+	now := time.Now()
+	card, err := s.cardService.BuildCard(ctx, in.UserID, cardType, now, nil, in.Amount, source)
+	if err != nil {
+		return nil, err
+	}
+	card.OperatorID = &operatorID
+	card.Remark = "团购核销 " + in.Code
+
+	err = s.tx.Do(ctx, func(ctx context.Context, repos *repository.Repositories) error {
+		voucher, err := repos.Vouchers.GetByCode(ctx, in.Code)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			voucher = &models.VoucherRecord{VoucherCode: in.Code, Platform: in.Platform}
+		case err != nil:
+			return err
+		case voucher.Platform != in.Platform:
+			return apperr.Invalid("voucher belongs to another platform")
+		case voucher.Status == VoucherStatusVerified:
+			return ErrVoucherAlreadyVerified
+		case voucher.Status == VoucherStatusExpired || voucher.ExpireAt != nil && voucher.ExpireAt.Before(now):
+			return ErrVoucherExpired
+		}
+
+		voucher.Status = VoucherStatusVerified
+		voucher.UserID = &in.UserID
+		voucher.CardTypeID = &in.CardTypeID
+		voucher.VerifiedAt = &now
+		voucher.VerifiedBy = &operatorID
+		voucher.Remark = in.Remark
+		if voucher.ID == 0 {
+			if err := repos.Vouchers.Create(ctx, voucher); err != nil {
+				if errors.Is(err, gorm.ErrDuplicatedKey) {
+					return ErrVoucherAlreadyVerified
+				}
+				return err
+			}
+		} else {
+			ok, err := repos.Vouchers.MarkVerified(ctx, voucher)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrVoucherAlreadyVerified
+			}
+		}
+		return s.cardService.SaveCard(ctx, card)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return card, nil
+}
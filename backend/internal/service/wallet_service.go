@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gym-admin/internal/models"
+	"gym-admin/internal/repository"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	WalletTxTopUp       = models.WalletTxTopUp
+	WalletTxBonus       = models.WalletTxBonus
+	WalletTxSpend       = models.WalletTxSpend
+	WalletTxRefund      = models.WalletTxRefund
+	WalletTxTopUpRefund = models.WalletTxTopUpRefund
+)
+
+const (
+	TopUpRuleEnabled  = models.TopUpRuleEnabled
+	TopUpRuleDisabled = models.TopUpRuleDisabled
+)
+
+var (
+	ErrTopUpRuleNotFound    = apperr.NotFound("TOP_UP_RULE_NOT_FOUND", "top-up rule not found")
+	ErrIdempotencyKeyReused = apperr.Conflict("IDEMPOTENCY_KEY_REUSED", "idempotency key was already used for a different charge")
+)
+
+// TopUpRuleInput describes a top-up bonus such as "top up 1000, get 100"
+type TopUpRuleInput struct {
+	Name        string     `json:"name" binding:"required"`
+	MinAmount   float64    `json:"min_amount" binding:"required,gt=0"`
+	BonusAmount float64    `json:"bonus_amount" binding:"required,gt=0"`
+	StartAt     *time.Time `json:"start_at"`
+	EndAt       *time.Time `json:"end_at" binding:"omitempty,notbefore=StartAt"`
+}
+
+// WalletReportRow is the wallet activity of one month. Balance and
+// BonusBalance are what all wallets held at month end.
+type WalletReportRow struct {
+	Month         string  `json:"month"`          // YYYY-MM
+	TopUps        float64 `json:"top_ups"`        // 充值收款
+	Bonuses       float64 `json:"bonuses"`        // 充值赠送
+	Spent         float64 `json:"spent"`          // 本金消费
+	BonusSpent    float64 `json:"bonus_spent"`    // 赠送金消费
+	Refunded      float64 `json:"refunded"`       // 退款退回钱包
+	TopUpRefunded float64 `json:"topup_refunded"` // 充值退款
+	Balance       float64 `json:"balance"`
+	BonusBalance  float64 `json:"bonus_balance"`
+}
+
+type WalletReport struct {
+	From   string            `json:"from"`
+	To     string            `json:"to"`
+	Totals WalletReportRow   `json:"totals"`
+	Rows   []WalletReportRow `json:"rows"`
+}
+
+type WalletService struct {
+	repo     repository.WalletRepository
+	userRepo repository.UserRepository
+}
+
+func NewWalletService(repo repository.WalletRepository, userRepo repository.UserRepository) *WalletService {
+	return &WalletService{
+		repo:     repo,
+		userRepo: userRepo,
+	}
+}
+
+// GetWallet returns the member's wallet; members who never topped up have an empty one
+func (s *WalletService) GetWallet(ctx context.Context, userID int64) (*models.Wallet, error) {
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+	wallet, err := s.repo.GetByUserID(ctx, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.Wallet{UserID: userID}, nil
+	}
+	return wallet, err
+}
+
+func (s *WalletService) ListTransactions(ctx context.Context, userID int64, q listquery.Query) ([]models.WalletTransaction, listquery.Page, error) {
+	return s.repo.ListTransactions(ctx, userID, q)
+}
+
+// Spend charges a purchase at the front desk, such as drinks or a locker, to
+// the member's wallet. The charge is made once per idempotency key, so that a
+// client retrying after a timeout gets the first charge back instead of paying
+// twice.
+func (s *WalletService) Spend(ctx context.Context, userID int64, amount float64, idempotencyKey string, operatorID int64, remark string) (*models.WalletTransaction, error) {
+	amount = roundTo(amount, 2)
+	if amount <= 0 {
+		return nil, apperr.Invalid("amount must be positive")
+	}
+	remark = strings.TrimSpace(remark)
+	if remark == "" {
+		return nil, apperr.Invalid("remark is required")
+	}
+	idempotencyKey = strings.TrimSpace(idempotencyKey)
+	if idempotencyKey == "" {
+		return nil, apperr.Invalid("idempotency key is required")
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return nil, notFound(err, ErrUserNotFound)
+	}
+
+	// Prefixed so that a client key cannot collide with the payment numbers
+	// that order payments spend under
+	entry, err := s.repo.Spend(ctx, userID, amount, "spend:"+idempotencyKey, nil, &operatorID, remark)
+	if err != nil {
+		return nil, err
+	}
+	if entry.UserID != userID || roundTo(-(entry.PrincipalAmount+entry.BonusAmount), 2) != amount {
+		return nil, ErrIdempotencyKeyReused
+	}
+	return entry, nil
+}
+
+// TopUpBonus is the bonus the best enabled rule grants for topping up amount
+func (s *WalletService) TopUpBonus(ctx context.Context, amount float64) (float64, error) {
+	rule, err := s.repo.BestTopUpRule(ctx, amount, time.Now())
+	if err != nil || rule == nil {
+		return 0, err
+	}
+	return rule.BonusAmount, nil
+}
+
+func (s *WalletService) CreateTopUpRule(ctx context.Context, in TopUpRuleInput) (*models.TopUpRule, error) {
+	rule := &models.TopUpRule{Status: TopUpRuleEnabled}
+	if err := applyTopUpRuleInput(rule, in); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateTopUpRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *WalletService) UpdateTopUpRule(ctx context.Context, id int64, in TopUpRuleInput) (*models.TopUpRule, error) {
+	rule, err := s.repo.GetTopUpRule(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrTopUpRuleNotFound)
+	}
+	if err := applyTopUpRuleInput(rule, in); err != nil {
+		return nil, err
+	}
+	err = s.repo.UpdateTopUpRule(ctx, id, map[string]interface{}{
+		"name":         rule.Name,
+		"min_amount":   rule.MinAmount,
+		"bonus_amount": rule.BonusAmount,
+		"start_at":     rule.StartAt,
+		"end_at":       rule.EndAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetTopUpRule(ctx, id)
+}
+
+func (s *WalletService) SetTopUpRuleStatus(ctx context.Context, id int64, status int8) error {
+	if status != TopUpRuleEnabled && status != TopUpRuleDisabled {
+		return errInvalidStatus
+	}
+	if _, err := s.repo.GetTopUpRule(ctx, id); err != nil {
+		return notFound(err, ErrTopUpRuleNotFound)
+	}
+	return s.repo.UpdateTopUpRule(ctx, id, map[string]interface{}{"status": status})
+}
+
+func (s *WalletService) ListTopUpRules(ctx context.Context, status *int8) ([]models.TopUpRule, error) {
+	return s.repo.ListTopUpRules(ctx, status)
+}
+
+// GetReport sums the wallet ledger per month for the months from..to
+// (inclusive). Top-ups are money received in advance: they count as revenue
+// only once spent, so the month-end balances are the outstanding liability.
+func (s *WalletService) GetReport(ctx context.Context, from, to time.Time) (*WalletReport, error) {
+	fromMonth, toMonth := monthStart(from), monthStart(to)
+	if toMonth.Before(fromMonth) || toMonth.After(fromMonth.AddDate(0, maxRevenueMonths-1, 0)) {
+		return nil, ErrInvalidMonthRange
+	}
+
+	report := &WalletReport{From: fromMonth.Format("2006-01"), To: toMonth.Format("2006-01")}
+	for m := fromMonth; !m.After(toMonth); m = m.AddDate(0, 1, 0) {
+		next := m.AddDate(0, 1, 0)
+		sums, err := s.repo.Summarize(ctx, m, next)
+		if err != nil {
+			return nil, err
+		}
+		row := WalletReportRow{Month: m.Format("2006-01")}
+		for _, sum := range sums {
+			switch sum.Type {
+			case WalletTxTopUp:
+				row.TopUps = sum.Principal
+			case WalletTxBonus:
+				row.Bonuses = sum.Bonus
+			case WalletTxSpend:
+				row.Spent = -sum.Principal
+				row.BonusSpent = -sum.Bonus
+			case WalletTxRefund:
+				row.Refunded = sum.Principal + sum.Bonus
+			case WalletTxTopUpRefund:
+				row.TopUpRefunded = -sum.Principal
+			}
+		}
+		if row.Balance, row.BonusBalance, err = s.repo.BalancesAt(ctx, next); err != nil {
+			return nil, err
+		}
+		row = roundWalletRow(row)
+		report.Rows = append(report.Rows, row)
+
+		report.Totals.TopUps += row.TopUps
+		report.Totals.Bonuses += row.Bonuses
+		report.Totals.Spent += row.Spent
+		report.Totals.BonusSpent += row.BonusSpent
+		report.Totals.Refunded += row.Refunded
+		report.Totals.TopUpRefunded += row.TopUpRefunded
+		report.Totals.Balance, report.Totals.BonusBalance = row.Balance, row.BonusBalance
+	}
+	report.Totals.Month = report.From + "~" + report.To
+	report.Totals = roundWalletRow(report.Totals)
+	return report, nil
+}
+
+func applyTopUpRuleInput(rule *models.TopUpRule, in TopUpRuleInput) error {
+	name := strings.TrimSpace(in.Name)
+	if name == "" {
+		return errNameRequired
+	}
+	if in.MinAmount <= 0 || in.BonusAmount <= 0 {
+		return apperr.Invalid("amounts must be positive")
+	}
+	if in.StartAt != nil && in.EndAt != nil && in.EndAt.Before(*in.StartAt) {
+		return apperr.Invalid("end time is before start time")
+	}
+	rule.Name = name
+	rule.MinAmount = roundTo(in.MinAmount, 2)
+	rule.BonusAmount = roundTo(in.BonusAmount, 2)
+	rule.StartAt = in.StartAt
+	rule.EndAt = in.EndAt
+	return nil
+}
+
+func roundWalletRow(row WalletReportRow) WalletReportRow {
+	row.TopUps = roundTo(row.TopUps, 2)
+	row.Bonuses = roundTo(row.Bonuses, 2)
+	row.Spent = roundTo(row.Spent, 2)
+	row.BonusSpent = roundTo(row.BonusSpent, 2)
+	row.Refunded = roundTo(row.Refunded, 2)
+	row.TopUpRefunded = roundTo(row.TopUpRefunded, 2)
+	row.Balance = roundTo(row.Balance, 2)
+	row.BonusBalance = roundTo(row.BonusBalance, 2)
+	return row
+}
@@ -0,0 +1,37 @@
+DROP TABLE IF EXISTS `member_activities`;
+DROP TABLE IF EXISTS `renewal_stats`;
+DROP TABLE IF EXISTS `cohort_retentions`;
+DROP TABLE IF EXISTS `referral_rules`;
+DROP TABLE IF EXISTS `referrals`;
+DROP TABLE IF EXISTS `point_redemptions`;
+DROP TABLE IF EXISTS `point_rewards`;
+DROP TABLE IF EXISTS `point_rules`;
+DROP TABLE IF EXISTS `point_transactions`;
+DROP TABLE IF EXISTS `point_accounts`;
+DROP TABLE IF EXISTS `top_up_rules`;
+DROP TABLE IF EXISTS `wallet_transactions`;
+DROP TABLE IF EXISTS `wallets`;
+DROP TABLE IF EXISTS `promotion_usages`;
+DROP TABLE IF EXISTS `coupons`;
+DROP TABLE IF EXISTS `promotions`;
+DROP TABLE IF EXISTS `refunds`;
+DROP TABLE IF EXISTS `payments`;
+DROP TABLE IF EXISTS `order_items`;
+DROP TABLE IF EXISTS `orders`;
+DROP TABLE IF EXISTS `voucher_records`;
+DROP TABLE IF EXISTS `face_records`;
+DROP TABLE IF EXISTS `check_in_hourly_stats`;
+DROP TABLE IF EXISTS `check_ins`;
+DROP TABLE IF EXISTS `course_reviews`;
+DROP TABLE IF EXISTS `lesson_packages`;
+DROP TABLE IF EXISTS `bookings`;
+DROP TABLE IF EXISTS `courses`;
+DROP TABLE IF EXISTS `coaches`;
+DROP TABLE IF EXISTS `card_freeze_records`;
+DROP TABLE IF EXISTS `card_operation_logs`;
+DROP TABLE IF EXISTS `membership_cards`;
+DROP TABLE IF EXISTS `card_types`;
+DROP TABLE IF EXISTS `sequences`;
+DROP TABLE IF EXISTS `user_status_changes`;
+DROP TABLE IF EXISTS `user_training_stats`;
+DROP TABLE IF EXISTS `users`;
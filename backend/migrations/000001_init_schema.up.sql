@@ -0,0 +1,703 @@
+-- Baseline schema, as AutoMigrate created it before versioned migrations.
+-- IF NOT EXISTS lets a database AutoMigrate kept up to date adopt it as is.
+
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` bigint AUTO_INCREMENT,
+  `user_no` varchar(32) NOT NULL,
+  `name` varchar(50) NOT NULL,
+  `name_pinyin` varchar(255),
+  `name_initials` varchar(50),
+  `gender` tinyint,
+  `birthday` date,
+  `id_card` varchar(18),
+  `phone` varchar(11) NOT NULL,
+  `email` varchar(100),
+  `avatar_url` varchar(255),
+  `address` varchar(255),
+  `emergency_contact` varchar(50),
+  `emergency_phone` varchar(11),
+  `health_status` text,
+  `training_goal` text,
+  `source` tinyint DEFAULT 1,
+  `referral_code` varchar(16),
+  `referrer_id` bigint,
+  `status` tinyint DEFAULT 1,
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_users_status` (`status`),
+  INDEX `idx_users_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_users_user_no` (`user_no`),
+  INDEX `idx_users_name_pinyin` (`name_pinyin`),
+  INDEX `idx_users_name_initials` (`name_initials`),
+  UNIQUE INDEX `idx_users_phone` (`phone`),
+  UNIQUE INDEX `idx_users_referral_code` (`referral_code`),
+  INDEX `idx_users_referrer_id` (`referrer_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_training_stats` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `total_days` bigint DEFAULT 0,
+  `total_times` bigint DEFAULT 0,
+  `continuous_days` bigint DEFAULT 0,
+  `last_check_in_date` date,
+  `month_times` bigint DEFAULT 0,
+  `year_times` bigint DEFAULT 0,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_user_training_stats_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `user_status_changes` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `old_status` tinyint NOT NULL,
+  `new_status` tinyint NOT NULL,
+  `reason` varchar(255),
+  `operator_id` bigint NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_user_status_changes_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `sequences` (
+  `name` varchar(64),
+  `value` bigint NOT NULL DEFAULT 0,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`name`)
+);
+
+CREATE TABLE IF NOT EXISTS `card_types` (
+  `id` bigint AUTO_INCREMENT,
+  `type_name` varchar(50) NOT NULL,
+  `type_code` varchar(20) NOT NULL,
+  `duration_type` tinyint NOT NULL,
+  `duration_value` bigint NOT NULL,
+  `price` decimal(10,2) NOT NULL,
+  `original_price` decimal(10,2),
+  `description` text,
+  `benefits` text,
+  `can_freeze` tinyint DEFAULT 1,
+  `max_freeze_times` bigint DEFAULT 0,
+  `max_freeze_days` bigint DEFAULT 0,
+  `can_transfer` tinyint DEFAULT 1,
+  `transfer_fee` decimal(10,2) DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `sort_order` bigint DEFAULT 0,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_card_types_status` (`status`),
+  INDEX `idx_card_types_sort_order` (`sort_order`),
+  UNIQUE INDEX `idx_card_types_type_code` (`type_code`)
+);
+
+CREATE TABLE IF NOT EXISTS `membership_cards` (
+  `id` bigint AUTO_INCREMENT,
+  `card_no` varchar(32) NOT NULL,
+  `user_id` bigint NOT NULL,
+  `card_type_id` bigint NOT NULL,
+  `status` tinyint DEFAULT 1,
+  `start_date` date NOT NULL,
+  `end_date` date NOT NULL,
+  `remaining_times` bigint,
+  `total_times` bigint,
+  `freeze_times` bigint DEFAULT 0,
+  `freeze_days` bigint DEFAULT 0,
+  `is_frozen` tinyint DEFAULT 0,
+  `frozen_at` datetime(3) NULL,
+  `source` tinyint DEFAULT 1,
+  `purchase_price` decimal(10,2) NOT NULL,
+  `order_id` bigint,
+  `promotion_id` bigint,
+  `operator_id` bigint,
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_membership_cards_order_id` (`order_id`),
+  INDEX `idx_membership_cards_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_membership_cards_card_no` (`card_no`),
+  INDEX `idx_membership_cards_user_id` (`user_id`),
+  INDEX `idx_membership_cards_status` (`status`),
+  INDEX `idx_membership_cards_end_date` (`end_date`)
+);
+
+CREATE TABLE IF NOT EXISTS `card_operation_logs` (
+  `id` bigint AUTO_INCREMENT,
+  `card_id` bigint NOT NULL,
+  `operation_type` tinyint NOT NULL,
+  `old_end_date` date,
+  `new_end_date` date,
+  `amount` decimal(10,2),
+  `operator_id` bigint NOT NULL,
+  `remark` varchar(255),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_card_operation_logs_card_id` (`card_id`),
+  INDEX `idx_card_operation_logs_operation_type` (`operation_type`)
+);
+
+CREATE TABLE IF NOT EXISTS `card_freeze_records` (
+  `id` bigint AUTO_INCREMENT,
+  `card_id` bigint NOT NULL,
+  `freeze_start_date` date NOT NULL,
+  `freeze_end_date` date,
+  `freeze_days` bigint NOT NULL,
+  `reason` varchar(255),
+  `operator_id` bigint NOT NULL,
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_card_freeze_records_card_id` (`card_id`),
+  INDEX `idx_card_freeze_records_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `coaches` (
+  `id` bigint AUTO_INCREMENT,
+  `coach_no` varchar(32) NOT NULL,
+  `name` varchar(50) NOT NULL,
+  `gender` tinyint,
+  `phone` varchar(11) NOT NULL,
+  `email` varchar(100),
+  `avatar_url` varchar(255),
+  `specialties` text,
+  `certifications` text,
+  `experience` bigint DEFAULT 0,
+  `introduction` text,
+  `hourly_rate` decimal(10,2),
+  `rating` decimal(3,2) DEFAULT 0,
+  `total_ratings` bigint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `hire_date` date,
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_coaches_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_coaches_coach_no` (`coach_no`),
+  UNIQUE INDEX `idx_coaches_phone` (`phone`),
+  INDEX `idx_coaches_rating` (`rating`),
+  INDEX `idx_coaches_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `courses` (
+  `id` bigint AUTO_INCREMENT,
+  `coach_id` bigint NOT NULL,
+  `course_name` varchar(100) NOT NULL,
+  `course_type` tinyint NOT NULL,
+  `start_time` datetime(3) NOT NULL,
+  `end_time` datetime(3) NOT NULL,
+  `max_capacity` bigint DEFAULT 1,
+  `current_count` bigint DEFAULT 0,
+  `price` decimal(10,2),
+  `status` tinyint DEFAULT 1,
+  `description` text,
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_courses_coach_id` (`coach_id`),
+  INDEX `idx_courses_start_time` (`start_time`),
+  INDEX `idx_courses_status` (`status`),
+  INDEX `idx_courses_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `bookings` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `course_id` bigint NOT NULL,
+  `status` tinyint DEFAULT 1,
+  `booked_at` datetime(3) NOT NULL,
+  `cancelled_at` datetime(3) NULL,
+  `checked_in_at` datetime(3) NULL,
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_bookings_status` (`status`),
+  INDEX `idx_bookings_deleted_at` (`deleted_at`),
+  INDEX `idx_bookings_user_id` (`user_id`),
+  INDEX `idx_bookings_course_id` (`course_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `lesson_packages` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `coach_id` bigint NOT NULL,
+  `order_id` bigint,
+  `total_sessions` bigint NOT NULL,
+  `remaining_sessions` bigint NOT NULL,
+  `unit_price` decimal(10,2),
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_lesson_packages_deleted_at` (`deleted_at`),
+  INDEX `idx_lesson_packages_user_id` (`user_id`),
+  INDEX `idx_lesson_packages_coach_id` (`coach_id`),
+  INDEX `idx_lesson_packages_order_id` (`order_id`),
+  INDEX `idx_lesson_packages_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `course_reviews` (
+  `id` bigint AUTO_INCREMENT,
+  `booking_id` bigint NOT NULL,
+  `user_id` bigint NOT NULL,
+  `course_id` bigint NOT NULL,
+  `coach_id` bigint NOT NULL,
+  `course_rating` tinyint NOT NULL,
+  `coach_rating` tinyint NOT NULL,
+  `comment` text,
+  `status` tinyint DEFAULT 1,
+  `hidden_by` bigint,
+  `hidden_at` datetime(3) NULL,
+  `hidden_reason` varchar(255),
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_course_reviews_course_id` (`course_id`),
+  INDEX `idx_course_reviews_coach_id` (`coach_id`),
+  INDEX `idx_course_reviews_status` (`status`),
+  INDEX `idx_course_reviews_deleted_at` (`deleted_at`),
+  UNIQUE INDEX `idx_course_reviews_booking_id` (`booking_id`),
+  INDEX `idx_course_reviews_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `check_ins` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `card_id` bigint,
+  `check_in_type` tinyint NOT NULL,
+  `check_in_time` datetime(3) NOT NULL,
+  `check_out_at` datetime(3) NULL,
+  `device_id` varchar(50),
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_check_ins_user_id` (`user_id`),
+  INDEX `idx_check_ins_card_id` (`card_id`),
+  INDEX `idx_check_ins_check_in_time` (`check_in_time`),
+  INDEX `idx_check_ins_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `check_in_hourly_stats` (
+  `id` bigint AUTO_INCREMENT,
+  `stat_date` date NOT NULL,
+  `hour` tinyint NOT NULL,
+  `device_id` varchar(50) NOT NULL,
+  `weekday` tinyint NOT NULL,
+  `check_ins` bigint NOT NULL,
+  `unique_users` bigint NOT NULL,
+  `check_outs` bigint NOT NULL,
+  `dwell_minutes` bigint NOT NULL,
+  `peak_occupancy` bigint NOT NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `uk_date_hour_device` (`stat_date`,`hour`,`device_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `face_records` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `face_image_url` varchar(255) NOT NULL,
+  `face_feature` text,
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_face_records_user_id` (`user_id`),
+  INDEX `idx_face_records_status` (`status`),
+  INDEX `idx_face_records_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `voucher_records` (
+  `id` bigint AUTO_INCREMENT,
+  `voucher_code` varchar(100) NOT NULL,
+  `platform` tinyint NOT NULL,
+  `user_id` bigint,
+  `card_type_id` bigint,
+  `status` tinyint DEFAULT 1,
+  `verified_at` datetime(3) NULL,
+  `verified_by` bigint,
+  `expire_at` datetime(3) NULL,
+  `platform_data` text,
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  `deleted_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_voucher_records_voucher_code` (`voucher_code`),
+  INDEX `idx_voucher_records_platform` (`platform`),
+  INDEX `idx_voucher_records_user_id` (`user_id`),
+  INDEX `idx_voucher_records_status` (`status`),
+  INDEX `idx_voucher_records_expire_at` (`expire_at`),
+  INDEX `idx_voucher_records_deleted_at` (`deleted_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `orders` (
+  `id` bigint AUTO_INCREMENT,
+  `order_no` varchar(32) NOT NULL,
+  `user_id` bigint NOT NULL,
+  `source` tinyint DEFAULT 1,
+  `total_amount` decimal(10,2) NOT NULL,
+  `discount_amount` decimal(10,2) DEFAULT 0,
+  `pay_amount` decimal(10,2) NOT NULL,
+  `refunded_amount` decimal(10,2) DEFAULT 0,
+  `refunding_amount` decimal(10,2) DEFAULT 0,
+  `payment_method` tinyint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `paid_at` datetime(3) NULL,
+  `fulfilled_at` datetime(3) NULL,
+  `expire_at` datetime(3) NULL,
+  `operator_id` bigint,
+  `remark` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_orders_status` (`status`),
+  INDEX `idx_orders_expire_at` (`expire_at`),
+  UNIQUE INDEX `idx_orders_order_no` (`order_no`),
+  INDEX `idx_orders_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `order_items` (
+  `id` bigint AUTO_INCREMENT,
+  `order_id` bigint NOT NULL,
+  `item_type` tinyint NOT NULL,
+  `item_id` bigint NOT NULL,
+  `item_name` varchar(100),
+  `quantity` bigint DEFAULT 1,
+  `unit_price` decimal(10,2) NOT NULL,
+  `discount_amount` decimal(10,2) DEFAULT 0,
+  `amount` decimal(10,2) NOT NULL,
+  `start_date` date,
+  `promotion_id` bigint,
+  `coupon_id` bigint,
+  `bonus_days` bigint DEFAULT 0,
+  `bonus_amount` decimal(10,2) DEFAULT 0,
+  `fulfilled_ref_id` bigint,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_order_items_order_id` (`order_id`),
+  CONSTRAINT `fk_orders_items` FOREIGN KEY (`order_id`) REFERENCES `orders`(`id`)
+);
+
+CREATE TABLE IF NOT EXISTS `payments` (
+  `id` bigint AUTO_INCREMENT,
+  `payment_no` varchar(32) NOT NULL,
+  `order_id` bigint NOT NULL,
+  `method` tinyint NOT NULL,
+  `amount` decimal(10,2) NOT NULL,
+  `status` tinyint DEFAULT 1,
+  `trade_no` varchar(64),
+  `paid_at` datetime(3) NULL,
+  `notify_data` text,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_payments_payment_no` (`payment_no`),
+  INDEX `idx_payments_order_id` (`order_id`),
+  INDEX `idx_payments_status` (`status`),
+  INDEX `idx_payments_trade_no` (`trade_no`)
+);
+
+CREATE TABLE IF NOT EXISTS `refunds` (
+  `id` bigint AUTO_INCREMENT,
+  `refund_no` varchar(32) NOT NULL,
+  `order_id` bigint NOT NULL,
+  `payment_id` bigint NOT NULL,
+  `order_item_id` bigint,
+  `sessions` bigint DEFAULT 0,
+  `amount` decimal(10,2) NOT NULL,
+  `reason` varchar(255) NOT NULL,
+  `status` tinyint DEFAULT 1,
+  `requested_by` bigint NOT NULL,
+  `approved_by` bigint,
+  `approved_at` datetime(3) NULL,
+  `reject_reason` varchar(255),
+  `gateway_refund_no` varchar(64),
+  `fail_reason` varchar(255),
+  `refunded_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_refunds_refund_no` (`refund_no`),
+  INDEX `idx_refunds_order_id` (`order_id`),
+  INDEX `idx_refunds_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `promotions` (
+  `id` bigint AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `rule_type` tinyint NOT NULL,
+  `value` decimal(10,2) NOT NULL,
+  `buy_days` bigint DEFAULT 0,
+  `item_type` tinyint DEFAULT 0,
+  `card_type_ids` varchar(255),
+  `new_members_only` tinyint DEFAULT 0,
+  `requires_coupon` tinyint DEFAULT 0,
+  `start_at` datetime(3) NULL,
+  `end_at` datetime(3) NULL,
+  `per_user_limit` bigint DEFAULT 0,
+  `total_limit` bigint DEFAULT 0,
+  `used_count` bigint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `description` text,
+  `created_by` bigint,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_promotions_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `coupons` (
+  `id` bigint AUTO_INCREMENT,
+  `code` varchar(32) NOT NULL,
+  `promotion_id` bigint NOT NULL,
+  `user_id` bigint,
+  `max_uses` bigint DEFAULT 1,
+  `used_count` bigint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `expire_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_coupons_code` (`code`),
+  INDEX `idx_coupons_promotion_id` (`promotion_id`),
+  INDEX `idx_coupons_user_id` (`user_id`),
+  INDEX `idx_coupons_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `promotion_usages` (
+  `id` bigint AUTO_INCREMENT,
+  `promotion_id` bigint NOT NULL,
+  `coupon_id` bigint,
+  `user_id` bigint NOT NULL,
+  `order_id` bigint NOT NULL,
+  `order_item_id` bigint,
+  `discount_amount` decimal(10,2) DEFAULT 0,
+  `bonus_days` bigint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_promotion_user` (`promotion_id`,`user_id`),
+  INDEX `idx_promotion_usages_coupon_id` (`coupon_id`),
+  INDEX `idx_promotion_usages_order_id` (`order_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `wallets` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `balance` decimal(10,2) DEFAULT 0,
+  `bonus_balance` decimal(10,2) DEFAULT 0,
+  `version` bigint DEFAULT 0,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_wallets_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `wallet_transactions` (
+  `id` bigint AUTO_INCREMENT,
+  `wallet_id` bigint NOT NULL,
+  `user_id` bigint NOT NULL,
+  `type` tinyint NOT NULL,
+  `reference` varchar(64) NOT NULL,
+  `principal_amount` decimal(10,2) DEFAULT 0,
+  `bonus_amount` decimal(10,2) DEFAULT 0,
+  `balance_after` decimal(10,2),
+  `bonus_balance_after` decimal(10,2),
+  `order_id` bigint,
+  `operator_id` bigint,
+  `remark` varchar(255),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_wallet_transactions_wallet_id` (`wallet_id`),
+  INDEX `idx_wallet_transactions_user_id` (`user_id`),
+  UNIQUE INDEX `uk_type_reference` (`type`,`reference`),
+  INDEX `idx_wallet_transactions_order_id` (`order_id`),
+  INDEX `idx_wallet_transactions_created_at` (`created_at`)
+);
+
+CREATE TABLE IF NOT EXISTS `top_up_rules` (
+  `id` bigint AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `min_amount` decimal(10,2) NOT NULL,
+  `bonus_amount` decimal(10,2) NOT NULL,
+  `start_at` datetime(3) NULL,
+  `end_at` datetime(3) NULL,
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_top_up_rules_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `point_accounts` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `balance` bigint DEFAULT 0,
+  `total_earned` bigint DEFAULT 0,
+  `version` bigint DEFAULT 0,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_point_accounts_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `point_transactions` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `type` tinyint NOT NULL,
+  `source_id` bigint NOT NULL,
+  `points` bigint NOT NULL,
+  `remaining` bigint DEFAULT 0,
+  `balance_after` bigint,
+  `expire_at` datetime(3) NULL,
+  `occurred_at` datetime(3) NULL,
+  `remark` varchar(255),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `uk_type_source` (`type`,`source_id`),
+  INDEX `idx_point_transactions_expire_at` (`expire_at`),
+  INDEX `idx_point_transactions_occurred_at` (`occurred_at`),
+  INDEX `idx_point_transactions_user_id` (`user_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `point_rules` (
+  `id` bigint AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `rule_type` tinyint NOT NULL,
+  `points` bigint NOT NULL,
+  `streak_days` bigint DEFAULT 0,
+  `per_yuan` decimal(10,2) DEFAULT 0,
+  `valid_months` bigint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_point_rules_rule_type` (`rule_type`),
+  INDEX `idx_point_rules_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `point_rewards` (
+  `id` bigint AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `reward_type` tinyint NOT NULL,
+  `points` bigint NOT NULL,
+  `promotion_id` bigint,
+  `coupon_valid_days` bigint DEFAULT 0,
+  `days` bigint DEFAULT 0,
+  `stock` bigint DEFAULT 0,
+  `redeemed_count` bigint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_point_rewards_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `point_redemptions` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `reward_id` bigint NOT NULL,
+  `points` bigint NOT NULL,
+  `coupon_id` bigint,
+  `card_id` bigint,
+  `days` bigint DEFAULT 0,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_point_redemptions_user_id` (`user_id`),
+  INDEX `idx_point_redemptions_reward_id` (`reward_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `referrals` (
+  `id` bigint AUTO_INCREMENT,
+  `referrer_id` bigint NOT NULL,
+  `referee_id` bigint NOT NULL,
+  `code` varchar(16) NOT NULL,
+  `status` tinyint DEFAULT 1,
+  `card_id` bigint,
+  `rule_id` bigint,
+  `reward_type` tinyint DEFAULT 0,
+  `reward_days` bigint DEFAULT 0,
+  `reward_card_id` bigint,
+  `coupon_id` bigint,
+  `rewarded_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_referrals_status` (`status`),
+  INDEX `idx_referrals_rewarded_at` (`rewarded_at`),
+  INDEX `idx_referrals_created_at` (`created_at`),
+  INDEX `idx_referrals_referrer_id` (`referrer_id`),
+  UNIQUE INDEX `idx_referrals_referee_id` (`referee_id`)
+);
+
+CREATE TABLE IF NOT EXISTS `referral_rules` (
+  `id` bigint AUTO_INCREMENT,
+  `name` varchar(100) NOT NULL,
+  `days` bigint DEFAULT 0,
+  `promotion_id` bigint,
+  `coupon_valid_days` bigint DEFAULT 0,
+  `status` tinyint DEFAULT 1,
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_referral_rules_status` (`status`)
+);
+
+CREATE TABLE IF NOT EXISTS `cohort_retentions` (
+  `id` bigint AUTO_INCREMENT,
+  `cohort_month` date NOT NULL,
+  `month_offset` bigint NOT NULL,
+  `cohort_size` bigint NOT NULL,
+  `active_users` bigint NOT NULL,
+  `rate` decimal(5,4) NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `uk_cohort_offset` (`cohort_month`,`month_offset`)
+);
+
+CREATE TABLE IF NOT EXISTS `renewal_stats` (
+  `id` bigint AUTO_INCREMENT,
+  `month` date NOT NULL,
+  `due_cards` bigint NOT NULL,
+  `renewed_cards` bigint NOT NULL,
+  `rate` decimal(5,4) NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_renewal_stats_month` (`month`)
+);
+
+CREATE TABLE IF NOT EXISTS `member_activities` (
+  `id` bigint AUTO_INCREMENT,
+  `user_id` bigint NOT NULL,
+  `card_id` bigint NOT NULL,
+  `card_end_date` date NOT NULL,
+  `last_check_in_at` datetime(3) NULL,
+  `days_since_check_in` bigint NOT NULL,
+  `visits_last_30_days` bigint NOT NULL,
+  `visits_prev_30_days` bigint NOT NULL,
+  `snapshot_date` date NOT NULL,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `idx_member_activities_user_id` (`user_id`),
+  INDEX `idx_member_activities_days_since_check_in` (`days_since_check_in`)
+);
@@ -0,0 +1,2 @@
+-- Staff roles carried in login tokens; members keep "user"
+ALTER TABLE `users` ADD COLUMN `role` varchar(20) NOT NULL DEFAULT 'user' AFTER `status`;
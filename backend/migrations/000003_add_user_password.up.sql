@@ -0,0 +1,2 @@
+-- bcrypt hash of the login password; users without one cannot log in
+ALTER TABLE `users` ADD COLUMN `password_hash` varchar(100) NOT NULL DEFAULT '' AFTER `role`;
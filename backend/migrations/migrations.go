@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL schema migrations into the binary. Add new
+// ones with `server migrate create <name>`.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
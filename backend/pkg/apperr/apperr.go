@@ -0,0 +1,120 @@
+// Package apperr defines the errors reported to API clients: an HTTP status,
+// a stable business code such as PHONE_EXISTS, and a message safe to show.
+// The cause, if any, is for the logs only.
+package apperr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Codes shared by all resources
+const (
+	CodeInvalidParameter = "INVALID_PARAMETER"
+	CodeUnauthorized     = "UNAUTHORIZED"
+	CodeForbidden        = "FORBIDDEN"
+	CodeNotFound         = "NOT_FOUND"
+	CodeConflict         = "CONFLICT"
+	CodeTimeout          = "TIMEOUT"
+	CodeInternal         = "INTERNAL_ERROR"
+)
+
+var (
+	ErrNotFound = New(http.StatusNotFound, CodeNotFound, "Record not found")
+	ErrConflict = New(http.StatusConflict, CodeConflict, "Record already exists")
+	ErrTimeout  = New(http.StatusGatewayTimeout, CodeTimeout, "Request timed out")
+	ErrInternal = New(http.StatusInternalServerError, CodeInternal, "Internal server error")
+)
+
+type Error struct {
+	Status  int    // HTTP status
+	Code    string // business code, stable across releases
+	Message string // shown to clients
+	cause   error
+}
+
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Invalid reports bad input under the shared INVALID_PARAMETER code
+func Invalid(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidParameter, message)
+}
+
+// NotFound reports a missing resource under its own code
+func NotFound(code, message string) *Error {
+	return New(http.StatusNotFound, code, message)
+}
+
+// Conflict reports a request the resource's current state does not allow
+func Conflict(code, message string) *Error {
+	return New(http.StatusConflict, code, message)
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is matches errors with the same code, so a wrapped copy still matches the
+// error it was made from
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Wrap returns a copy of e that records cause for the logs
+func (e *Error) Wrap(cause error) *Error {
+	wrapped := *e
+	wrapped.cause = cause
+	return &wrapped
+}
+
+// From turns any error into an *Error: application errors pass through,
+// missing records become 404, duplicate keys 409, deadlines 504 and
+// everything else a 500 that keeps the original error as its cause.
+func From(err error) *Error {
+	var e *Error
+	switch {
+	case errors.As(err, &e):
+		return e
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound.Wrap(err)
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrConflict.Wrap(err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout.Wrap(err)
+	default:
+		return ErrInternal.Wrap(err)
+	}
+}
+
+// CodeForStatus is the shared code for errors that only carry a status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidParameter
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGatewayTimeout:
+		return CodeTimeout
+	default:
+		return CodeInternal
+	}
+}
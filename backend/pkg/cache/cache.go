@@ -0,0 +1,24 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Get when the key does not exist
+var ErrNotFound = errors.New("cache: key not found")
+
+// Cache is the key-value store used for short-lived caches and job locks.
+// Values are stored as strings; []byte and string values round-trip as is.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Del(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	// SetNX sets key only if it does not exist, returning whether it was set
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+	// Ping checks that the store can be reached
+	Ping(ctx context.Context) error
+	Close() error
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Cache for tests and single-instance development.
+// Expired keys are dropped when they are next read.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time // zero means no expiry
+}
+
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]memoryEntry)}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.lookup(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return entry.value, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = m.entry(value, expiration)
+	return nil
+}
+
+func (m *Memory) Del(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.lookup(key)
+	return ok, nil
+}
+
+func (m *Memory) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.lookup(key); ok {
+		return false, nil
+	}
+	m.entries[key] = m.entry(value, expiration)
+	return true, nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) lookup(key string) (memoryEntry, bool) {
+	entry, ok := m.entries[key]
+	if ok && !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		delete(m.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, ok
+}
+
+// entry formats value the way Redis would store it
+func (m *Memory) entry(value interface{}, expiration time.Duration) memoryEntry {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	entry := memoryEntry{value: s}
+	if expiration > 0 {
+		entry.expiresAt = time.Now().Add(expiration)
+	}
+	return entry
+}
@@ -2,48 +2,100 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gym-admin/internal/config"
+	"gym-admin/pkg/metrics"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-var RedisClient *redis.Client
-var ctx = context.Background()
+// connectTimeout bounds the connection check at startup
+const connectTimeout = 5 * time.Second
 
-func InitRedis(cfg config.RedisConfig) error {
-	RedisClient = redis.NewClient(&redis.Options{
+type redisCache struct {
+	client *redis.Client
+}
+
+// NewRedis connects to Redis and checks the connection
+func NewRedis(cfg config.RedisConfig) (Cache, error) {
+	client := redis.NewClient(&redis.Options{
 		Addr:     fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
 
 	// Test connection
-	if err := RedisClient.Ping(ctx).Err(); err != nil {
-		return fmt.Errorf("failed to connect redis: %w", err)
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect redis: %w", err)
 	}
 
+	client.AddHook(metricsHook{})
+	return &redisCache{client: client}, nil
+}
+
+// metricsHook counts failed commands into metrics.CacheErrors
+type metricsHook struct{}
+
+func (metricsHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (metricsHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	countError(cmd)
 	return nil
 }
 
-func GetRedis() *redis.Client {
-	return RedisClient
+func (metricsHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
 }
 
-func Set(key string, value interface{}, expiration time.Duration) error {
-	return RedisClient.Set(ctx, key, value, expiration).Err()
+func (metricsHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		countError(cmd)
+	}
+	return nil
 }
 
-func Get(key string) (string, error) {
-	return RedisClient.Get(ctx, key).Result()
+func countError(cmd redis.Cmder) {
+	if err := cmd.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		metrics.CacheErrors.WithLabelValues(cmd.Name()).Inc()
+	}
 }
 
-func Del(key string) error {
-	return RedisClient.Del(ctx, key).Err()
+func (c *redisCache) Get(ctx context.Context, key string) (string, error) {
+	value, err := c.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return value, err
 }
 
-func Exists(key string) (bool, error) {
-	result, err := RedisClient.Exists(ctx, key).Result()
+func (c *redisCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	return c.client.Set(ctx, key, value, expiration).Err()
+}
+
+func (c *redisCache) Del(ctx context.Context, key string) error {
+	return c.client.Del(ctx, key).Err()
+}
+
+func (c *redisCache) Exists(ctx context.Context, key string) (bool, error) {
+	result, err := c.client.Exists(ctx, key).Result()
 	return result > 0, err
 }
+
+func (c *redisCache) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+func (c *redisCache) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+func (c *redisCache) Close() error {
+	return c.client.Close()
+}
@@ -4,50 +4,146 @@ import (
 	"fmt"
 	"gym-admin/internal/config"
 	"gym-admin/internal/models"
+	"gym-admin/migrations"
+	"gym-admin/pkg/migrate"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite"
+)
 
-func InitDB(cfg config.DatabaseConfig) error {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
-		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, cfg.Charset)
+// Open connects with the configured driver. SQLite databases get their
+// schema from the models; MySQL schemas are managed by the migrations.
+func Open(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driverName(cfg) {
+	case DriverMySQL:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
+			cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.DBName, cfg.Charset)
+		dialector = mysql.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(cfg.Path))
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Report duplicate keys as gorm.ErrDuplicatedKey whatever the driver
+		TranslateError: true,
 	})
 	if err != nil {
-		return fmt.Errorf("failed to connect database: %w", err)
+		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
 
-	DB = db
+	if err := registerMetrics(db); err != nil {
+		return nil, fmt.Errorf("failed to register query metrics: %w", err)
+	}
 
-	// Auto migrate tables
-	if err := autoMigrate(); err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
+	if cfg.QueryTimeout > 0 {
+		if err := registerQueryTimeout(db, cfg.QueryTimeout); err != nil {
+			return nil, fmt.Errorf("failed to register query timeout: %w", err)
+		}
 	}
 
-	return nil
+	if !UsesMigrations(cfg) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		// An in-memory database lives and dies with its connection, and a
+		// file allows one writer at a time anyway
+		sqlDB.SetMaxOpenConns(1)
+
+		// The SQL migrations are written for MySQL; SQLite databases, used for
+		// development and tests, take their schema from the models
+		if err := autoMigrate(db); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	return db, nil
+}
+
+// UsesMigrations reports whether the schema is managed by the SQL migrations
+func UsesMigrations(cfg config.DatabaseConfig) bool {
+	return driverName(cfg) == DriverMySQL
 }
 
-func autoMigrate() error {
-	return DB.AutoMigrate(
+func driverName(cfg config.DatabaseConfig) string {
+	if cfg.Driver == "" {
+		return DriverMySQL
+	}
+	return cfg.Driver
+}
+
+// sqliteDSN turns the configured path into a DSN, with foreign keys on and a
+// busy timeout for files shared with other processes
+func sqliteDSN(path string) string {
+	if path == "" {
+		path = ":memory:"
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return path + sep + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+}
+
+func autoMigrate(db *gorm.DB) error {
+	return db.AutoMigrate(
 		&models.User{},
 		&models.UserTrainingStats{},
+		&models.UserStatusChange{},
+		&models.Sequence{},
 		&models.CardType{},
 		&models.MembershipCard{},
+		&models.CardOperationLog{},
+		&models.CardFreezeRecord{},
 		&models.Coach{},
 		&models.Course{},
 		&models.Booking{},
+		&models.LessonPackage{},
+		&models.CourseReview{},
 		&models.CheckIn{},
+		&models.CheckInHourlyStat{},
 		&models.FaceRecord{},
 		&models.VoucherRecord{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.Payment{},
+		&models.Refund{},
+		&models.Promotion{},
+		&models.Coupon{},
+		&models.PromotionUsage{},
+		&models.Wallet{},
+		&models.WalletTransaction{},
+		&models.TopUpRule{},
+		&models.PointAccount{},
+		&models.PointTransaction{},
+		&models.PointRule{},
+		&models.PointReward{},
+		&models.PointRedemption{},
+		&models.Referral{},
+		&models.ReferralRule{},
+		&models.CohortRetention{},
+		&models.RenewalStat{},
+		&models.MemberActivity{},
 	)
 }
 
-func GetDB() *gorm.DB {
-	return DB
+// NewMigrator returns a migrator for the SQL migrations embedded in the binary
+func NewMigrator(db *gorm.DB) (*migrate.Migrator, error) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(sqlDB, migrations.FS)
 }
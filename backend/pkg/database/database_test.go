@@ -0,0 +1,43 @@
+package database_test
+
+import (
+	"context"
+	"gym-admin/pkg/database"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// The embedded migrations are MySQL scripts, so only their loading and the
+// bookkeeping around them can be checked on SQLite
+func TestNewMigratorLoadsEmbeddedMigrations(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "gym.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) == 0 || statuses[0].Version != 1 || statuses[0].Name != "init_schema" {
+		t.Fatalf("statuses = %+v, want init_schema first", statuses)
+	}
+	for i, s := range statuses {
+		if i > 0 && s.Version <= statuses[i-1].Version {
+			t.Errorf("version %d listed after %d", s.Version, statuses[i-1].Version)
+		}
+		if s.Applied || s.Missing {
+			t.Errorf("status of %d = %+v, want pending", s.Version, s)
+		}
+	}
+	if pending, err := m.Pending(context.Background()); err != nil || pending != len(statuses) {
+		t.Errorf("Pending = %d, %v; want %d", pending, err, len(statuses))
+	}
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"errors"
+	"gym-admin/pkg/metrics"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const queryStartKey = "gym-admin:query_start"
+
+// registerMetrics times every statement into metrics.DBQueryDuration. Like
+// the query timeout it leaves Row and Rows alone, whose results are read
+// after the callbacks return.
+func registerMetrics(db *gorm.DB) error {
+	start := func(tx *gorm.DB) {
+		tx.InstanceSet(queryStartKey, time.Now())
+	}
+	finish := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			v, ok := tx.InstanceGet(queryStartKey)
+			if !ok {
+				return
+			}
+			result := "ok"
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				result = "error"
+			}
+			metrics.DBQueryDuration.WithLabelValues(operation, tx.Statement.Table, result).
+				Observe(time.Since(v.(time.Time)).Seconds())
+		}
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("metrics:start", start),
+		cb.Create().After("gorm:create").Register("metrics:finish", finish("create")),
+		cb.Query().Before("gorm:query").Register("metrics:start", start),
+		cb.Query().After("gorm:query").Register("metrics:finish", finish("query")),
+		cb.Update().Before("gorm:update").Register("metrics:start", start),
+		cb.Update().After("gorm:update").Register("metrics:finish", finish("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:start", start),
+		cb.Delete().After("gorm:delete").Register("metrics:finish", finish("delete")),
+		cb.Raw().Before("gorm:raw").Register("metrics:start", start),
+		cb.Raw().After("gorm:raw").Register("metrics:finish", finish("raw")),
+	)
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"gym-admin/pkg/deadline"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const queryDeadlineKey = "gym-admin:query_deadline"
+
+type queryDeadline struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// registerQueryTimeout bounds every statement by timeout on top of the
+// caller's context. Row and Rows are left alone since their results are read
+// after the callbacks return.
+func registerQueryTimeout(db *gorm.DB, timeout time.Duration) error {
+	start := func(tx *gorm.DB) {
+		parent := tx.Statement.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(parent, timeout)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(queryDeadlineKey, &queryDeadline{parent: parent, cancel: cancel})
+	}
+
+	// finish restores the caller's context: a chained query builder that runs
+	// Count and then Find reuses the same statement
+	finish := func(tx *gorm.DB) {
+		v, ok := tx.InstanceGet(queryDeadlineKey)
+		if !ok {
+			return
+		}
+		d := v.(*queryDeadline)
+		if tx.Error != nil && errors.Is(tx.Statement.Context.Err(), context.DeadlineExceeded) && d.parent.Err() == nil {
+			deadline.Hit(d.parent)
+		}
+		d.cancel()
+		tx.Statement.Context = d.parent
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("timeout:start", start),
+		cb.Create().After("gorm:create").Register("timeout:finish", finish),
+		cb.Query().Before("gorm:query").Register("timeout:start", start),
+		cb.Query().After("gorm:query").Register("timeout:finish", finish),
+		cb.Update().Before("gorm:update").Register("timeout:start", start),
+		cb.Update().After("gorm:update").Register("timeout:finish", finish),
+		cb.Delete().Before("gorm:delete").Register("timeout:start", start),
+		cb.Delete().After("gorm:delete").Register("timeout:finish", finish),
+		cb.Raw().Before("gorm:raw").Register("timeout:start", start),
+		cb.Raw().After("gorm:raw").Register("timeout:finish", finish),
+	)
+}
@@ -0,0 +1,37 @@
+// Package deadline records, in a request's context, that an operation made
+// for the request ran out of time, so that the response can say so even when
+// the request's own deadline has not passed yet.
+package deadline
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+type key struct{}
+
+type state struct {
+	hit atomic.Bool
+}
+
+// Track returns a context in which timeouts of operations are recorded
+func Track(ctx context.Context) context.Context {
+	return context.WithValue(ctx, key{}, &state{})
+}
+
+// Hit records that an operation under ctx gave up on its own deadline
+func Hit(ctx context.Context) {
+	if s, ok := ctx.Value(key{}).(*state); ok {
+		s.hit.Store(true)
+	}
+}
+
+// Exceeded reports whether ctx ran out of time or an operation under it did
+func Exceeded(ctx context.Context) bool {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	s, ok := ctx.Value(key{}).(*state)
+	return ok && s.hit.Load()
+}
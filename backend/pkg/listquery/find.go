@@ -0,0 +1,256 @@
+package listquery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"gym-admin/pkg/apperr"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var errBadCursor = apperr.Invalid("invalid cursor")
+
+// cursor is the position after the last row of a page: the values of its
+// sort columns, and the sort they belong to
+type cursor struct {
+	Sort   string            `json:"s"`
+	Values []json.RawMessage `json:"v"`
+}
+
+// Filter is a scope applying the filters of q
+func (q Query) Filter(db *gorm.DB) *gorm.DB {
+	for _, c := range q.conds {
+		switch c.op {
+		case Eq:
+			db = db.Where(c.column+" = ?", c.value)
+		case Ne:
+			db = db.Where(c.column+" <> ?", c.value)
+		case Gt:
+			db = db.Where(c.column+" > ?", c.value)
+		case Gte:
+			db = db.Where(c.column+" >= ?", c.value)
+		case Lt:
+			db = db.Where(c.column+" < ?", c.value)
+		case Lte:
+			db = db.Where(c.column+" <= ?", c.value)
+		case In:
+			db = db.Where(c.column+" IN ?", c.value)
+		case Like:
+			db = db.Where(c.column+" LIKE ?", c.value)
+		}
+	}
+	return db
+}
+
+// Sort is a scope ordering by the sort of q
+func (q Query) Sort(db *gorm.DB) *gorm.DB {
+	columns := make([]clause.OrderByColumn, 0, len(q.sorts))
+	for _, s := range q.sorts {
+		columns = append(columns, clause.OrderByColumn{Column: clause.Column{Name: s.column}, Desc: s.desc})
+	}
+	if q.first == nil {
+		return db.Clauses(clause.OrderBy{Columns: columns})
+	}
+	return db.Clauses(clause.OrderBy{Expression: clause.CommaExpression{
+		Exprs: []clause.Expression{q.first, clause.OrderBy{Columns: columns}},
+	}})
+}
+
+// OrderFirst returns q ordering by expr ahead of the requested sort, such as
+// the relevance of a search. Cursors cannot follow such an order.
+func (q Query) OrderFirst(expr clause.Expression) (Query, error) {
+	if q.Keyset {
+		return q, apperr.Invalid("cursor pagination is not available for this order")
+	}
+	q.first = expr
+	return q, nil
+}
+
+// Find loads one page into dest, a pointer to a slice of models, from db
+// with the filters, sort, fields and pagination of q. db holds the model and
+// the endpoint's own conditions; scopes such as preloads apply to the rows
+// but not to the count.
+func Find(db *gorm.DB, q Query, dest interface{}, scopes ...func(*gorm.DB) *gorm.DB) (Page, error) {
+	var page Page
+	db = db.Scopes(q.Filter)
+	if !q.Keyset {
+		if err := db.Count(&page.Total).Error; err != nil {
+			return page, err
+		}
+	}
+
+	db = db.Scopes(scopes...).Scopes(q.Sort)
+	if len(q.fields) > 0 {
+		db = db.Select(q.columns())
+	}
+	if !q.Keyset {
+		err := db.Offset((q.Page - 1) * q.PageSize).Limit(q.PageSize).Find(dest).Error
+		return page, err
+	}
+
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(dest); err != nil {
+		return page, err
+	}
+	fields, err := q.sortFields(stmt.Schema)
+	if err != nil {
+		return page, err
+	}
+	if q.after != "" {
+		values, err := q.decodeCursor(fields)
+		if err != nil {
+			return page, err
+		}
+		db = db.Where(q.seek(values))
+	}
+
+	// One row more than asked for tells whether there is a next page
+	if err := db.Limit(q.PageSize + 1).Find(dest).Error; err != nil {
+		return page, err
+	}
+	rows := reflect.ValueOf(dest).Elem()
+	if rows.Len() <= q.PageSize {
+		return page, nil
+	}
+	rows.SetLen(q.PageSize)
+	page.NextCursor, err = q.encodeCursor(db, fields, rows.Index(q.PageSize-1))
+	return page, err
+}
+
+// columns are the selected fields plus the sort columns the cursor needs
+func (q Query) columns() []string {
+	columns := append([]string(nil), q.fields...)
+	for _, s := range q.sorts {
+		if !contains(columns, s.column) {
+			columns = append(columns, s.column)
+		}
+	}
+	return columns
+}
+
+func (q Query) sortFields(sch *schema.Schema) ([]*schema.Field, error) {
+	fields := make([]*schema.Field, 0, len(q.sorts))
+	for _, s := range q.sorts {
+		field := sch.LookUpField(s.column)
+		if field == nil {
+			return nil, apperr.ErrInternal.Wrap(gorm.ErrInvalidField)
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// seek matches the rows after values in the sort order of q:
+// (a > ?) OR (a = ? AND b > ?) OR ...
+func (q Query) seek(values []interface{}) clause.Expr {
+	var sql strings.Builder
+	var vars []interface{}
+	for i, s := range q.sorts {
+		if i > 0 {
+			sql.WriteString(" OR ")
+		}
+		sql.WriteString("(")
+		for j := 0; j < i; j++ {
+			sql.WriteString(q.sorts[j].column + " = ? AND ")
+			vars = append(vars, values[j])
+		}
+		if s.desc {
+			sql.WriteString(s.column + " < ?)")
+		} else {
+			sql.WriteString(s.column + " > ?)")
+		}
+		vars = append(vars, values[i])
+	}
+	return clause.Expr{SQL: "(" + sql.String() + ")", Vars: vars}
+}
+
+// sortSignature ties a cursor to the sort it was made for
+func (q Query) sortSignature() string {
+	keys := make([]string, 0, len(q.sorts))
+	for _, s := range q.sorts {
+		if s.desc {
+			keys = append(keys, "-"+s.column)
+		} else {
+			keys = append(keys, s.column)
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+func (q Query) encodeCursor(db *gorm.DB, fields []*schema.Field, row reflect.Value) (string, error) {
+	c := cursor{Sort: q.sortSignature()}
+	for _, field := range fields {
+		v, _ := field.ValueOf(db.Statement.Context, row)
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		c.Values = append(c.Values, data)
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func (q Query) decodeCursor(fields []*schema.Field) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(q.after)
+	if err != nil {
+		return nil, errBadCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || len(c.Values) != len(fields) {
+		return nil, errBadCursor
+	}
+	if c.Sort != q.sortSignature() {
+		return nil, apperr.Invalid("cursor belongs to a different sort")
+	}
+
+	values := make([]interface{}, len(fields))
+	for i, field := range fields {
+		v := reflect.New(field.FieldType)
+		if err := json.Unmarshal(c.Values[i], v.Interface()); err != nil {
+			return nil, errBadCursor
+		}
+		values[i] = v.Elem().Interface()
+	}
+	return values, nil
+}
+
+// Project keeps only the selected fields of each element of list, or
+// returns list as it is when no fields were selected
+func (q Query) Project(list interface{}) (interface{}, error) {
+	if len(q.fields) == 0 {
+		return list, nil
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	var rows []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		for key := range row {
+			if !contains(q.fields, key) {
+				delete(row, key)
+			}
+		}
+	}
+	return rows, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package listquery_test
+
+import (
+	"fmt"
+	"gym-admin/pkg/listquery"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// seed opens an in-memory database with 7 members: balances 0 to 60 and
+// statuses alternating 1 and 2, with the odd ones sharing a name so that
+// sorting by name needs id to break ties
+func seed(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&member{}); err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("member %d", i)
+		if i%2 == 1 {
+			name = "same"
+		}
+		m := member{Name: name, Phone: fmt.Sprintf("1380000000%d", i), Status: 1 + i%2, Balance: float64(i * 10), CreatedAt: created.AddDate(0, 0, i)}
+		if err := db.Create(&m).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func ids(members []member) []int64 {
+	list := make([]int64, 0, len(members))
+	for _, m := range members {
+		list = append(list, m.ID)
+	}
+	return list
+}
+
+func TestFindPages(t *testing.T) {
+	db := seed(t)
+	tests := []struct {
+		query     string
+		wantIDs   string
+		wantTotal int64
+	}{
+		{"page_size=3", "[7 6 5]", 7},
+		{"page=3&page_size=3", "[1]", 7},
+		{"page=4&page_size=3", "[]", 7},
+		{"status=2&sort=balance", "[2 4 6]", 3},
+		{"filter[created_at][gte]=2024-05-06&sort=created_at", "[6 7]", 2},
+	}
+	for _, tt := range tests {
+		var members []member
+		page, err := listquery.Find(db.Model(&member{}), parse(t, tt.query), &members)
+		if err != nil {
+			t.Fatalf("Find(%q): %v", tt.query, err)
+		}
+		if got := fmt.Sprint(ids(members)); got != tt.wantIDs || page.Total != tt.wantTotal || page.NextCursor != "" {
+			t.Errorf("Find(%q) = %s, total %d, cursor %q; want %s, total %d",
+				tt.query, got, page.Total, page.NextCursor, tt.wantIDs, tt.wantTotal)
+		}
+	}
+}
+
+func TestFindFollowsCursor(t *testing.T) {
+	db := seed(t)
+	var seen []int64
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 4 {
+			t.Fatalf("cursor never ran out, seen %v", seen)
+		}
+		var members []member
+		q := parse(t, "sort=-name&page_size=2&cursor="+cursor)
+		page, err := listquery.Find(db.Model(&member{}), q, &members)
+		if err != nil {
+			t.Fatalf("Find page %d: %v", pages+1, err)
+		}
+		if page.Total != 0 {
+			t.Errorf("cursor page total = %d, want none counted", page.Total)
+		}
+		seen = append(seen, ids(members)...)
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+	// the shared name sorts first descending, its rows by id descending
+	if got := fmt.Sprint(seen); got != "[6 4 2 7 5 3 1]" {
+		t.Errorf("rows = %s, want every member once", got)
+	}
+}
+
+func TestFindRejectsCursor(t *testing.T) {
+	db := seed(t)
+	var members []member
+	page, err := listquery.Find(db.Model(&member{}), parse(t, "sort=name&page_size=2&cursor="), &members)
+	if err != nil || page.NextCursor == "" {
+		t.Fatalf("first page: cursor %q, %v", page.NextCursor, err)
+	}
+
+	for _, query := range []string{
+		"sort=balance&page_size=2&cursor=" + page.NextCursor, // made for another sort
+		"sort=name&page_size=2&cursor=not-a-cursor",
+		"sort=name&page_size=2&cursor=e30", // {}
+	} {
+		if _, err := listquery.Find(db.Model(&member{}), parse(t, query), &members); !invalid(err) {
+			t.Errorf("Find(%q) error = %v, want invalid params", query, err)
+		}
+	}
+}
+
+func TestFindSelectsFields(t *testing.T) {
+	db := seed(t)
+	var members []member
+	if _, err := listquery.Find(db.Model(&member{}), parse(t, "fields=name&sort=balance&page_size=1"), &members); err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(members) != 1 {
+		t.Fatalf("members = %v", members)
+	}
+	// id and the sort columns come along for the cursor, the rest is left out
+	m := members[0]
+	if m.ID != 1 || m.Name != "member 0" || m.Phone != "" || !m.CreatedAt.IsZero() {
+		t.Errorf("member = %+v, want only id, name and balance", m)
+	}
+}
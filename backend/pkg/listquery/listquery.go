@@ -0,0 +1,328 @@
+// Package listquery turns the filter, sort, fields and pagination parameters
+// of list endpoints into gorm scopes. Clients only reach the columns an
+// endpoint allows in its Spec, and values are always bound as parameters.
+//
+//	?filter[status]=1&filter[created_at][gte]=2024-01-01   filters
+//	?status=1                                              same as filter[status]=1
+//	?sort=-rating,created_at                               descending with a minus
+//	?fields=id,name,phone                                  only these fields
+//	?page=2&page_size=20                                   offset pages with a total
+//	?cursor=&page_size=20                                  keyset pages, follow next_cursor
+package listquery
+
+import (
+	"fmt"
+	"gym-admin/pkg/apperr"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DefaultPageSize = 10
+	MaxPageSize     = 100
+
+	maxSortKeys = 3
+	maxInValues = 100
+)
+
+// Kind is the type a filter value is parsed as
+type Kind int
+
+const (
+	Int Kind = iota
+	Float
+	String
+	Bool
+	Date // YYYY-MM-DD, compared by whole days against DATE or DATETIME columns
+)
+
+// Op is a filter comparison, given as filter[name][op]
+type Op string
+
+const (
+	Eq   Op = "eq"
+	Ne   Op = "ne"
+	Gt   Op = "gt"
+	Gte  Op = "gte"
+	Lt   Op = "lt"
+	Lte  Op = "lte"
+	In   Op = "in"   // comma separated values
+	Like Op = "like" // contains, for strings
+)
+
+// Filter allows filtering on Column. Eq is always allowed; Ops lists the
+// other comparisons.
+type Filter struct {
+	Column string
+	Kind   Kind
+	Ops    []Op
+}
+
+// Spec is what an endpoint lets clients filter, sort and select. Sort
+// columns should be NOT NULL for cursor pagination to see every row.
+type Spec struct {
+	Filters     map[string]Filter // by parameter name
+	Sorts       map[string]string // sort key to column
+	Fields      []string          // columns that can be selected, named as in the JSON
+	DefaultSort string            // in the sort parameter syntax, e.g. -created_at
+}
+
+// Query is a parsed list request
+type Query struct {
+	Page     int
+	PageSize int
+	Keyset   bool // cursor pagination: no total, a next cursor instead
+
+	conds  []cond
+	sorts  []sortKey
+	fields []string
+	after  string // cursor of the previous page, empty for the first one
+	first  clause.Expression
+}
+
+type cond struct {
+	column string
+	op     Op
+	value  interface{}
+}
+
+type sortKey struct {
+	column string
+	desc   bool
+}
+
+// Page is what Find reports besides the rows: the total in page mode, the
+// cursor of the next page in cursor mode, empty after the last page
+type Page struct {
+	Total      int64
+	NextCursor string
+}
+
+// Parse reads the list parameters in values, rejecting anything spec does
+// not allow. Page numbers and sizes out of range fall back to defaults.
+func Parse(values url.Values, spec Spec) (Query, error) {
+	q := Query{Page: 1, PageSize: DefaultPageSize}
+	if n, err := strconv.Atoi(values.Get("page")); err == nil && n > 0 {
+		q.Page = n
+	}
+	if n, err := strconv.Atoi(values.Get("page_size")); err == nil && n > 0 && n <= MaxPageSize {
+		q.PageSize = n
+	}
+	if _, ok := values["cursor"]; ok {
+		q.Keyset = true
+		q.after = values.Get("cursor")
+	}
+
+	if err := q.parseFilters(values, spec); err != nil {
+		return q, err
+	}
+	if err := q.parseSort(values.Get("sort"), spec); err != nil {
+		return q, err
+	}
+	if err := q.parseFields(values.Get("fields"), spec); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+func (q *Query) parseFilters(values url.Values, spec Spec) error {
+	// In key order, so that the same request always builds the same SQL
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		vals := values[key]
+		name, op, ok := filterKey(key)
+		if !ok {
+			// Plain name=value parameters filter too, when the name is allowed
+			if _, allowed := spec.Filters[key]; !allowed || values.Has("filter["+key+"]") {
+				continue
+			}
+			name, op = key, Eq
+		}
+		f, allowed := spec.Filters[name]
+		if !allowed {
+			return apperr.Invalid("unknown filter: " + name)
+		}
+		if op != Eq && !f.allows(op) {
+			return apperr.Invalid(fmt.Sprintf("filter %s does not support %s", name, op))
+		}
+		raw := vals[len(vals)-1]
+		if raw == "" {
+			continue
+		}
+		c, err := f.cond(op, raw)
+		if err != nil {
+			return apperr.Invalid(fmt.Sprintf("invalid filter %s: %v", name, err))
+		}
+		q.conds = append(q.conds, c...)
+	}
+	return nil
+}
+
+// filterKey splits filter[name] and filter[name][op]
+func filterKey(key string) (name string, op Op, ok bool) {
+	rest, found := strings.CutPrefix(key, "filter[")
+	if !found {
+		return "", "", false
+	}
+	name, rest, found = strings.Cut(rest, "]")
+	if !found || name == "" {
+		return "", "", false
+	}
+	if rest == "" {
+		return name, Eq, true
+	}
+	opName, found := strings.CutPrefix(rest, "[")
+	if !found || !strings.HasSuffix(opName, "]") {
+		return "", "", false
+	}
+	return name, Op(strings.TrimSuffix(opName, "]")), true
+}
+
+func (f Filter) allows(op Op) bool {
+	for _, allowed := range f.Ops {
+		if allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (f Filter) cond(op Op, raw string) ([]cond, error) {
+	if op == In {
+		parts := strings.Split(raw, ",")
+		if len(parts) > maxInValues {
+			return nil, fmt.Errorf("at most %d values", maxInValues)
+		}
+		values := make([]interface{}, 0, len(parts))
+		for _, part := range parts {
+			v, err := f.value(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return []cond{{f.Column, In, values}}, nil
+	}
+
+	v, err := f.value(raw)
+	if err != nil {
+		return nil, err
+	}
+	if op == Like {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("like needs a text filter")
+		}
+		return []cond{{f.Column, Like, "%" + escapeLike(s) + "%"}}, nil
+	}
+
+	// A day covers everything from its midnight to the next one
+	if day, ok := v.(time.Time); ok && f.Kind == Date {
+		next := day.AddDate(0, 0, 1)
+		switch op {
+		case Eq:
+			return []cond{{f.Column, Gte, day}, {f.Column, Lt, next}}, nil
+		case Gt:
+			return []cond{{f.Column, Gte, next}}, nil
+		case Lte:
+			return []cond{{f.Column, Lt, next}}, nil
+		}
+	}
+	return []cond{{f.Column, op, v}}, nil
+}
+
+func (f Filter) value(raw string) (interface{}, error) {
+	switch f.Kind {
+	case Int:
+		return strconv.ParseInt(raw, 10, 64)
+	case Float:
+		return strconv.ParseFloat(raw, 64)
+	case Bool:
+		return strconv.ParseBool(raw)
+	case Date:
+		day, err := time.ParseInLocation("2006-01-02", raw, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("expected YYYY-MM-DD")
+		}
+		return day, nil
+	default:
+		return raw, nil
+	}
+}
+
+func (q *Query) parseSort(raw string, spec Spec) error {
+	trusted := raw == ""
+	if trusted {
+		raw = spec.DefaultSort
+	}
+	seen := map[string]bool{}
+	for _, key := range strings.Split(raw, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		column, ok := spec.Sorts[key]
+		if !ok && trusted {
+			column, ok = key, true
+		}
+		if !ok {
+			return apperr.Invalid("cannot sort by " + key)
+		}
+		if seen[column] {
+			continue
+		}
+		seen[column] = true
+		q.sorts = append(q.sorts, sortKey{column, desc})
+	}
+	if len(q.sorts) > maxSortKeys && !trusted {
+		return apperr.Invalid(fmt.Sprintf("sort by at most %d keys", maxSortKeys))
+	}
+
+	// id breaks ties so that pages neither repeat nor skip rows
+	if !seen["id"] {
+		desc := len(q.sorts) > 0 && q.sorts[len(q.sorts)-1].desc
+		q.sorts = append(q.sorts, sortKey{"id", desc})
+	}
+	return nil
+}
+
+func (q *Query) parseFields(raw string, spec Spec) error {
+	if raw == "" {
+		return nil
+	}
+	if len(spec.Fields) == 0 {
+		return apperr.Invalid("fields cannot be selected here")
+	}
+	allowed := make(map[string]bool, len(spec.Fields))
+	for _, f := range spec.Fields {
+		allowed[f] = true
+	}
+	q.fields = []string{"id"}
+	for _, f := range strings.Split(raw, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" || f == "id" {
+			continue
+		}
+		if !allowed[f] {
+			return apperr.Invalid("unknown field: " + f)
+		}
+		q.fields = append(q.fields, f)
+	}
+	return nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
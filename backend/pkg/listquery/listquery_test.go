@@ -0,0 +1,237 @@
+package listquery_test
+
+import (
+	"encoding/json"
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/listquery"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+var memberSpec = listquery.Spec{
+	Filters: map[string]listquery.Filter{
+		"status":     {Column: "status", Kind: listquery.Int, Ops: []listquery.Op{listquery.In, listquery.Ne}},
+		"name":       {Column: "name", Kind: listquery.String, Ops: []listquery.Op{listquery.Like}},
+		"balance":    {Column: "balance", Kind: listquery.Float, Ops: []listquery.Op{listquery.Gte, listquery.Lte}},
+		"vip":        {Column: "is_vip", Kind: listquery.Bool},
+		"created_at": {Column: "created_at", Kind: listquery.Date, Ops: []listquery.Op{listquery.Gt, listquery.Gte, listquery.Lt, listquery.Lte}},
+	},
+	Sorts:       map[string]string{"name": "name", "created_at": "created_at", "balance": "balance", "phone": "phone"},
+	Fields:      []string{"name", "phone", "status"},
+	DefaultSort: "-created_at",
+}
+
+type member struct {
+	ID        int64
+	Name      string
+	Phone     string
+	Status    int
+	Balance   float64
+	IsVip     bool
+	CreatedAt time.Time
+}
+
+// dryRun opens a SQLite session that builds statements without running them
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// statement is the SQL and variables of listing members with the filters and
+// sort of q
+func statement(t *testing.T, q listquery.Query) (string, []interface{}) {
+	t.Helper()
+	var members []member
+	stmt := dryRun(t).Model(&member{}).Scopes(q.Filter, q.Sort).Find(&members).Statement
+	return stmt.SQL.String(), stmt.Vars
+}
+
+func parse(t *testing.T, raw string) listquery.Query {
+	t.Helper()
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := listquery.Parse(values, memberSpec)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", raw, err)
+	}
+	return q
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{"unknown filter", "filter[password]=x"},
+		{"operator not allowed", "filter[status][gt]=1"},
+		{"unknown operator", "filter[balance][between]=1"},
+		{"like on a number", "filter[balance][like]=1"},
+		{"not an int", "filter[status]=active"},
+		{"not an int in a list", "filter[status][in]=1,x"},
+		{"not a float", "filter[balance][gte]=lots"},
+		{"not a bool", "filter[vip]=maybe"},
+		{"not a date", "filter[created_at][gte]=01/05/2024"},
+		{"unknown sort", "sort=password"},
+		{"unknown descending sort", "sort=-password"},
+		{"too many sort keys", "sort=name,-created_at,balance,phone"},
+		{"unknown field", "fields=name,password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := listquery.Parse(values, memberSpec); !invalid(err) {
+				t.Errorf("Parse(%q) error = %v, want invalid params", tt.query, err)
+			}
+		})
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	may := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name     string
+		query    string
+		wantSQL  string
+		wantVars []interface{}
+	}{
+		{"plain parameter", "status=1",
+			"WHERE status = ?", []interface{}{int64(1)}},
+		{"filter parameter", "filter[status]=1",
+			"WHERE status = ?", []interface{}{int64(1)}},
+		{"filter parameter wins over plain", "status=1&filter[status]=2",
+			"WHERE status = ?", []interface{}{int64(2)}},
+		{"explicit eq", "filter[status][eq]=1",
+			"WHERE status = ?", []interface{}{int64(1)}},
+		{"ne", "filter[status][ne]=3",
+			"WHERE status <> ?", []interface{}{int64(3)}},
+		{"in", "filter[status][in]=1, 2,3",
+			"WHERE status IN (?,?,?)", []interface{}{int64(1), int64(2), int64(3)}},
+		{"range", "filter[balance][gte]=10&filter[balance][lte]=99.5",
+			"WHERE balance >= ? AND balance <= ?", []interface{}{10.0, 99.5}},
+		{"bool", "vip=true",
+			"WHERE is_vip = ?", []interface{}{true}},
+		{"like escapes wildcards", "filter[name][like]=50%25_a",
+			"WHERE name LIKE ?", []interface{}{`%50\%\_a%`}},
+		{"date covers the day", "filter[created_at]=2024-05-01",
+			"WHERE created_at >= ? AND created_at < ?", []interface{}{may, may.AddDate(0, 0, 1)}},
+		{"after a day", "filter[created_at][gt]=2024-05-01",
+			"WHERE created_at >= ?", []interface{}{may.AddDate(0, 0, 1)}},
+		{"up to a day", "filter[created_at][lte]=2024-05-01",
+			"WHERE created_at < ?", []interface{}{may.AddDate(0, 0, 1)}},
+		{"before a day", "filter[created_at][lt]=2024-05-01",
+			"WHERE created_at < ?", []interface{}{may}},
+		{"empty value", "filter[status]=",
+			"", nil},
+		{"other parameters", "page=2&keyword=x&filter[bogus",
+			"", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, vars := statement(t, parse(t, tt.query))
+			where := ""
+			if i := strings.Index(sql, "WHERE"); i >= 0 {
+				where = sql[i:strings.Index(sql, " ORDER BY")]
+			}
+			if where != tt.wantSQL {
+				t.Errorf("SQL %q, want %q", where, tt.wantSQL)
+			}
+			if len(vars) != len(tt.wantVars) || len(vars) > 0 && !reflect.DeepEqual(vars, tt.wantVars) {
+				t.Errorf("vars %v, want %v", vars, tt.wantVars)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "ORDER BY `created_at` DESC,`id` DESC"},
+		{"sort=name", "ORDER BY `name`,`id`"},
+		{"sort=-balance,name", "ORDER BY `balance` DESC,`name`,`id`"},
+		{"sort=name,name", "ORDER BY `name`,`id`"},
+		{"sort=phone,-created_at", "ORDER BY `phone`,`created_at` DESC,`id` DESC"},
+	}
+	for _, tt := range tests {
+		sql, _ := statement(t, parse(t, tt.query))
+		if got := sql[strings.Index(sql, "ORDER BY"):]; got != tt.want {
+			t.Errorf("Parse(%q): %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParsePageBounds(t *testing.T) {
+	tests := []struct {
+		query      string
+		page, size int
+		wantKeyset bool
+	}{
+		{"", 1, listquery.DefaultPageSize, false},
+		{"page=3&page_size=20", 3, 20, false},
+		{"page=0&page_size=0", 1, listquery.DefaultPageSize, false},
+		{"page=-2&page_size=-5", 1, listquery.DefaultPageSize, false},
+		{"page=x&page_size=y", 1, listquery.DefaultPageSize, false},
+		{"page_size=100", 1, listquery.MaxPageSize, false},
+		{"page_size=101", 1, listquery.DefaultPageSize, false},
+		{"cursor=&page_size=5", 1, 5, true},
+	}
+	for _, tt := range tests {
+		q := parse(t, tt.query)
+		if q.Page != tt.page || q.PageSize != tt.size || q.Keyset != tt.wantKeyset {
+			t.Errorf("Parse(%q) = page %d, size %d, keyset %v; want %d, %d, %v",
+				tt.query, q.Page, q.PageSize, q.Keyset, tt.page, tt.size, tt.wantKeyset)
+		}
+	}
+}
+
+func TestParseFieldsNeedsAllowList(t *testing.T) {
+	spec := memberSpec
+	spec.Fields = nil
+	if _, err := listquery.Parse(url.Values{"fields": {"name"}}, spec); !invalid(err) {
+		t.Errorf("Parse error = %v, want invalid params", err)
+	}
+}
+
+func TestProject(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": 1, "name": "Li", "phone": "13800000001", "status": 1},
+	}
+
+	all, err := parse(t, "").Project(rows)
+	if err != nil {
+		t.Fatalf("Project: %v", err)
+	}
+	if !reflect.DeepEqual(all, rows) {
+		t.Errorf("without fields = %v, want the list as it is", all)
+	}
+
+	projected, err := parse(t, "fields=name, phone,id").Project(rows)
+	if err != nil {
+		t.Fatalf("Project: %v", err)
+	}
+	got := projected.([]map[string]json.RawMessage)
+	if len(got) != 1 || len(got[0]) != 3 || string(got[0]["id"]) != "1" || string(got[0]["name"]) != `"Li"` || got[0]["phone"] == nil {
+		t.Errorf("projected = %v, want id, name and phone", got)
+	}
+}
+
+// invalid tells whether err rejects the request as bad input
+func invalid(err error) bool {
+	return err != nil && apperr.From(err).Code == apperr.CodeInvalidParameter
+}
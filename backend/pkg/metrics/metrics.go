@@ -0,0 +1,106 @@
+// Package metrics holds the Prometheus collectors of the server and serves
+// them at /metrics. Label values are kept to small fixed sets: routes are
+// the registered patterns, never raw paths.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "gym_admin"
+
+var registry = prometheus.NewRegistry()
+
+var factory = promauto.With(registry)
+
+// HTTP
+var (
+	HTTPRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"method", "route", "status"})
+)
+
+// Database and cache
+var (
+	DBQueryDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Duration of gorm statements by operation (create, query, update, delete, raw), table and result (ok, error).",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 10},
+	}, []string{"operation", "table", "result"})
+
+	CacheErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_errors_total",
+		Help:      "Failed Redis commands by command. Missing keys are not errors.",
+	}, []string{"command"})
+)
+
+// Background jobs
+var (
+	JobRuns = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Background job runs by job and result (success, failed, panic, skipped when another instance ran it).",
+	}, []string{"job", "result"})
+
+	JobDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Duration of background job runs by job.",
+		Buckets:   []float64{.1, .5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"job"})
+)
+
+// Business events
+var (
+	// CheckIns is labelled with type face, card or manual and result success,
+	// denied (no valid card, blacklisted, ...) or failed (system error)
+	CheckIns = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "check_ins_total",
+		Help:      "Check-ins by type and result.",
+	}, []string{"type", "result"})
+
+	// VoucherVerifications is labelled with platform meituan or douyin and
+	// result success, rejected (already verified, expired, ...) or failed
+	VoucherVerifications = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "voucher_verifications_total",
+		Help:      "Group-buying voucher verifications by platform and result.",
+	}, []string{"platform", "result"})
+
+	// Payments is labelled with the gateway name and result started, paid or
+	// failed
+	Payments = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "payments_total",
+		Help:      "Payment attempts by method and result.",
+	}, []string{"method", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the collected metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,409 @@
+// Package migrate applies versioned SQL migrations. Each migration is a pair
+// of files, NNNNNN_name.up.sql and NNNNNN_name.down.sql, and the versions
+// applied are recorded in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockName    = "gym_admin_schema_migrations"
+	lockTimeout = 60 // seconds
+)
+
+var (
+	ErrLocked = errors.New("another migration is running")
+	ErrDirty  = errors.New("a migration failed half way; repair the schema by hand, then run migrate force")
+)
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is the state of one migration. Versions recorded in the database
+// but unknown to this binary are listed with Missing set.
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	Dirty     bool       `json:"dirty"`
+	Missing   bool       `json:"missing"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+type record struct {
+	version   int64
+	name      string
+	dirty     bool
+	appliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New loads the migrations in fsys
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Load reads the migration files in the root of fsys, ordered by version.
+// Every version needs both its up and its down file.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := fileNamePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if strings.TrimSpace(migration.Up) == "" || strings.TrimSpace(migration.Down) == "" {
+			return nil, fmt.Errorf("migration %06d_%s needs both an up and a down file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up applies up to n pending migrations in version order, or all of them if
+// n is 0. Migrations older than the latest applied one, as happens when
+// branches merge, are applied too.
+func (m *Migrator) Up(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, records map[int64]record) error {
+		for _, migration := range m.migrations {
+			if n > 0 && len(done) == n {
+				break
+			}
+			if _, ok := records[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, true); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the n most recently applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, n int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn, records map[int64]record) error {
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < n; i-- {
+			migration := m.migrations[i]
+			if _, ok := records[migration.Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, migration, false); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Force records version as cleanly applied without running it, after a
+// failed migration has been repaired by hand. Dirty migrations above it are
+// dropped from the record.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	var migration *Migration
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			migration = &m.migrations[i]
+		}
+	}
+	if migration == nil {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(conn)
+
+	if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE dirty = 1 AND version > ?", version); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", version); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, dirty, applied_at) VALUES (?, ?, 0, ?)",
+		version, migration.Name, time.Now())
+	return err
+}
+
+// Status lists every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if err := m.ensureTable(ctx, m.db); err != nil {
+		return nil, err
+	}
+	records, err := m.records(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if r, ok := records[migration.Version]; ok {
+			appliedAt := r.appliedAt
+			status.Applied, status.Dirty, status.AppliedAt = true, r.dirty, &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for _, r := range records {
+		if !known[r.version] {
+			appliedAt := r.appliedAt
+			statuses = append(statuses, Status{
+				Version: r.version, Name: r.name, Applied: true, Dirty: r.dirty, Missing: true, AppliedAt: &appliedAt,
+			})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending counts the migrations not applied yet
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// Create writes an empty up/down pair for a new migration into dir, numbered
+// after the highest version already there
+func Create(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(name, "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "", "", errors.New("migration name is required")
+	}
+
+	migrations, err := Load(os.DirFS(dir))
+	if err != nil {
+		return "", "", err
+	}
+	var version int64 = 1
+	if len(migrations) > 0 {
+		version = migrations[len(migrations)-1].Version + 1
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%06d_%s", version, name))
+	up, down := base+".up.sql", base+".down.sql"
+	if err := os.WriteFile(up, []byte("-- "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- revert "+name+"\n"), 0644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
+
+// withLock runs fn holding the migration lock, on the connection that holds
+// it, and refuses to go on while a migration is dirty
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, records map[int64]record) error) error {
+	conn, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.unlock(conn)
+
+	records, err := m.records(ctx, conn)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		if r.dirty {
+			return fmt.Errorf("migration %06d_%s: %w", r.version, r.name, ErrDirty)
+		}
+	}
+	return fn(conn, records)
+}
+
+// lock takes a MySQL named lock so that replicas starting together migrate
+// one at a time. Named locks belong to a session, so everything done under
+// the lock runs on the returned connection.
+func (m *Migrator) lock(ctx context.Context) (*sql.Conn, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, lockTimeout).Scan(&got); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !got.Valid || got.Int64 != 1 {
+		conn.Close()
+		return nil, ErrLocked
+	}
+	if err := m.ensureTable(ctx, conn); err != nil {
+		m.unlock(conn)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (m *Migrator) unlock(conn *sql.Conn) {
+	conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+	conn.Close()
+}
+
+// apply runs one migration up or down. The version is marked dirty while its
+// statements run, since MySQL commits DDL as it goes and a failure leaves the
+// schema half changed.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	script := migration.Up
+	if up {
+		if _, err := conn.ExecContext(ctx, "INSERT INTO schema_migrations (version, name, dirty, applied_at) VALUES (?, ?, 1, ?)",
+			migration.Version, migration.Name, time.Now()); err != nil {
+			return err
+		}
+	} else {
+		script = migration.Down
+		if _, err := conn.ExecContext(ctx, "UPDATE schema_migrations SET dirty = 1 WHERE version = ?", migration.Version); err != nil {
+			return err
+		}
+	}
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %06d_%s: %w", migration.Version, migration.Name, err)
+		}
+	}
+
+	var err error
+	if up {
+		_, err = conn.ExecContext(ctx, "UPDATE schema_migrations SET dirty = 0, applied_at = ? WHERE version = ?", time.Now(), migration.Version)
+	} else {
+		_, err = conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	}
+	return err
+}
+
+type execQueryer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, db execQueryer) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version bigint NOT NULL,
+  name varchar(255) NOT NULL,
+  dirty tinyint NOT NULL DEFAULT 0,
+  applied_at datetime NOT NULL,
+  PRIMARY KEY (version)
+)`)
+	return err
+}
+
+func (m *Migrator) records(ctx context.Context, db execQueryer) (map[int64]record, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version, name, dirty, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make(map[int64]record)
+	for rows.Next() {
+		var r record
+		if err := rows.Scan(&r.version, &r.name, &r.dirty, &r.appliedAt); err != nil {
+			return nil, err
+		}
+		records[r.version] = r
+	}
+	return records, rows.Err()
+}
+
+// splitStatements cuts a script into statements at semicolons ending a line.
+// Comment-only chunks are dropped.
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		current.Reset()
+		for _, line := range strings.Split(stmt, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "--") {
+				stmts = append(stmts, strings.TrimSuffix(stmt, ";"))
+				return
+			}
+		}
+	}
+	for _, line := range strings.Split(script, "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			flush()
+		}
+	}
+	flush()
+	return stmts
+}
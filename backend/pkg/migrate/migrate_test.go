@@ -0,0 +1,331 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"gym-admin/pkg/migrate"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	sqlite "github.com/glebarez/go-sqlite"
+)
+
+// locks stands in for the MySQL named locks the migrator takes. SQLite has no
+// GET_LOCK, so the tests register one: a held lock is refused at once, as
+// MySQL does once the timeout runs out.
+var locks = struct {
+	sync.Mutex
+	held map[string]bool
+}{held: map[string]bool{}}
+
+func TestMain(m *testing.M) {
+	sqlite.MustRegisterScalarFunction("GET_LOCK", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		locks.Lock()
+		defer locks.Unlock()
+		name := fmt.Sprint(args[0])
+		if locks.held[name] {
+			return int64(0), nil
+		}
+		locks.held[name] = true
+		return int64(1), nil
+	})
+	sqlite.MustRegisterScalarFunction("RELEASE_LOCK", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		locks.Lock()
+		defer locks.Unlock()
+		name := fmt.Sprint(args[0])
+		if !locks.held[name] {
+			return nil, nil
+		}
+		delete(locks.held, name)
+		return int64(1), nil
+	})
+	os.Exit(m.Run())
+}
+
+func lockHeld() bool {
+	locks.Lock()
+	defer locks.Unlock()
+	return len(locks.held) > 0
+}
+
+// openDB opens a file database, shared by the connections of the pool
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "gym.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// migrationFS holds a migration creating table name for each version
+func migrationFS(versions map[int64]string) fstest.MapFS {
+	fsys := fstest.MapFS{}
+	for version, name := range versions {
+		base := fmt.Sprintf("%06d_create_%s", version, name)
+		fsys[base+".up.sql"] = &fstest.MapFile{Data: []byte(fmt.Sprintf(
+			"-- %s\nCREATE TABLE %s (\n  id integer PRIMARY KEY\n);\nINSERT INTO %s (id) VALUES (%d);\n", name, name, name, version))}
+		fsys[base+".down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE " + name + ";\n")}
+	}
+	return fsys
+}
+
+func newMigrator(t *testing.T, db *sql.DB, fsys fstest.MapFS) *migrate.Migrator {
+	t.Helper()
+	m, err := migrate.New(db, fsys)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return m
+}
+
+func versions(migrations []migrate.Migration) []int64 {
+	list := []int64{}
+	for _, m := range migrations {
+		list = append(list, m.Version)
+	}
+	return list
+}
+
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name <> 'schema_migrations' ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	list := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, name)
+	}
+	return list
+}
+
+func TestLoad(t *testing.T) {
+	fsys := migrationFS(map[int64]string{10: "orders", 2: "cards", 1: "users"})
+	fsys["README.md"] = &fstest.MapFile{Data: []byte("not a migration")}
+	fsys["000003_skipped.up.sql/x"] = &fstest.MapFile{Data: []byte("in a directory")}
+
+	migrations, err := migrate.Load(fsys)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := versions(migrations); !reflect.DeepEqual(got, []int64{1, 2, 10}) {
+		t.Errorf("versions = %v, want [1 2 10]", got)
+	}
+	if migrations[2].Name != "create_orders" || migrations[2].Down != "DROP TABLE orders;\n" {
+		t.Errorf("migration 10 = %+v", migrations[2])
+	}
+
+	tests := []struct {
+		name   string
+		modify func(fstest.MapFS)
+	}{
+		{"missing down", func(fsys fstest.MapFS) { delete(fsys, "000002_create_cards.down.sql") }},
+		{"empty up", func(fsys fstest.MapFS) { fsys["000002_create_cards.up.sql"].Data = []byte("\n") }},
+		{"two names", func(fsys fstest.MapFS) {
+			fsys["000002_create_wallets.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+		}},
+	}
+	for _, tt := range tests {
+		fsys := migrationFS(map[int64]string{1: "users", 2: "cards"})
+		tt.modify(fsys)
+		if _, err := migrate.Load(fsys); err == nil {
+			t.Errorf("%s: Load accepted the migrations", tt.name)
+		}
+	}
+}
+
+func TestUpInVersionOrder(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m := newMigrator(t, db, migrationFS(map[int64]string{3: "orders", 1: "users", 2: "cards"}))
+
+	done, err := m.Up(ctx, 2)
+	if err != nil {
+		t.Fatalf("Up(2): %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("Up(2) applied %v, want [1 2]", got)
+	}
+	if pending, err := m.Pending(ctx); err != nil || pending != 1 {
+		t.Errorf("Pending = %d, %v; want 1", pending, err)
+	}
+
+	done, err = m.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{3}) {
+		t.Errorf("Up applied %v, want [3]", got)
+	}
+	if got := tables(t, db); !reflect.DeepEqual(got, []string{"cards", "orders", "users"}) {
+		t.Errorf("tables = %v", got)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied || s.Dirty || s.Missing || s.AppliedAt == nil {
+			t.Errorf("status of %d = %+v, want applied", s.Version, s)
+		}
+	}
+}
+
+func TestUpSkipsApplied(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	if _, err := newMigrator(t, db, migrationFS(map[int64]string{1: "users", 3: "orders"})).Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// a branch adding version 2 merged after 3 was applied: only 2 runs, and
+	// running the applied ones again would fail on their existing tables
+	m := newMigrator(t, db, migrationFS(map[int64]string{1: "users", 2: "cards", 3: "orders"}))
+	done, err := m.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("Up after merge: %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{2}) {
+		t.Errorf("Up after merge applied %v, want [2]", got)
+	}
+
+	if done, err := m.Up(ctx, 0); err != nil || len(done) != 0 {
+		t.Errorf("Up when current applied %v, %v; want nothing", versions(done), err)
+	}
+}
+
+func TestDownNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m := newMigrator(t, db, migrationFS(map[int64]string{1: "users", 2: "cards", 3: "orders"}))
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	done, err := m.Down(ctx, 2)
+	if err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if got := versions(done); !reflect.DeepEqual(got, []int64{3, 2}) {
+		t.Errorf("Down(2) reverted %v, want [3 2]", got)
+	}
+	if got := tables(t, db); !reflect.DeepEqual(got, []string{"users"}) {
+		t.Errorf("tables = %v, want [users]", got)
+	}
+	if pending, err := m.Pending(ctx); err != nil || pending != 2 {
+		t.Errorf("Pending = %d, %v; want 2", pending, err)
+	}
+}
+
+func TestFailedMigrationStaysDirty(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	fsys := migrationFS(map[int64]string{1: "users"})
+	fsys["000002_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE cards (id integer);\nINSERT INTO nowhere VALUES (1);\n")}
+	fsys["000002_broken.down.sql"] = &fstest.MapFile{Data: []byte("DROP TABLE cards;")}
+	m := newMigrator(t, db, fsys)
+
+	if _, err := m.Up(ctx, 0); err == nil {
+		t.Fatal("Up applied a broken migration")
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if s := statuses[1]; !s.Applied || !s.Dirty {
+		t.Errorf("status of the broken migration = %+v, want dirty", s)
+	}
+	if _, err := m.Up(ctx, 0); !errors.Is(err, migrate.ErrDirty) {
+		t.Errorf("Up on a dirty schema: %v, want %v", err, migrate.ErrDirty)
+	}
+
+	// repaired by hand, then forced
+	if err := m.Force(ctx, 2); err != nil {
+		t.Fatalf("Force: %v", err)
+	}
+	if pending, err := m.Pending(ctx); err != nil || pending != 0 {
+		t.Errorf("Pending after Force = %d, %v; want 0", pending, err)
+	}
+	if done, err := m.Up(ctx, 0); err != nil || len(done) != 0 {
+		t.Errorf("Up after Force applied %v, %v; want nothing", versions(done), err)
+	}
+}
+
+func TestStatusListsMissingVersions(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	if _, err := newMigrator(t, db, migrationFS(map[int64]string{1: "users", 2: "cards"})).Up(ctx, 0); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+
+	// a binary older than the schema
+	statuses, err := newMigrator(t, db, migrationFS(map[int64]string{1: "users"})).Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(statuses) != 2 || statuses[1].Version != 2 || !statuses[1].Missing || statuses[1].Name != "create_cards" {
+		t.Errorf("statuses = %+v, want version 2 missing", statuses)
+	}
+}
+
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	m := newMigrator(t, db, migrationFS(map[int64]string{1: "users"}))
+
+	// another replica is migrating
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var got int64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK('gym_admin_schema_migrations', 60)").Scan(&got); err != nil || got != 1 {
+		t.Fatalf("GET_LOCK = %d, %v", got, err)
+	}
+	if _, err := m.Up(ctx, 0); !errors.Is(err, migrate.ErrLocked) {
+		t.Errorf("Up while locked: %v, want %v", err, migrate.ErrLocked)
+	}
+	if err := m.Force(ctx, 1); !errors.Is(err, migrate.ErrLocked) {
+		t.Errorf("Force while locked: %v, want %v", err, migrate.ErrLocked)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK('gym_admin_schema_migrations')"); err != nil {
+		t.Fatal(err)
+	}
+
+	if done, err := m.Up(ctx, 0); err != nil || len(done) != 1 {
+		t.Fatalf("Up after release applied %v, %v", versions(done), err)
+	}
+	if lockHeld() {
+		t.Error("lock still held after Up")
+	}
+
+	// released after a failure too
+	if _, err := m.Down(ctx, 1); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE users (id integer)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx, 0); err == nil {
+		t.Fatal("Up over an existing table succeeded")
+	}
+	if lockHeld() {
+		t.Error("lock still held after a failed Up")
+	}
+}
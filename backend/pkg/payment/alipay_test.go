@@ -0,0 +1,142 @@
+package payment_test
+
+import (
+	"errors"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// alipaySign signs a callback the way Alipay does: the sorted non-empty
+// parameters other than sign and sign_type, joined as k=v pairs
+func alipaySign(t *testing.T, key keyPair, values url.Values) url.Values {
+	t.Helper()
+	var pairs []string
+	for k := range values {
+		if k != "sign" && k != "sign_type" && values.Get(k) != "" {
+			pairs = append(pairs, k+"="+values.Get(k))
+		}
+	}
+	sort.Strings(pairs)
+	signed := url.Values{}
+	for k, v := range values {
+		signed[k] = v
+	}
+	signed.Set("sign_type", "RSA2")
+	signed.Set("sign", key.sign(t, strings.Join(pairs, "&")))
+	return signed
+}
+
+func alipayNotify(values url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/payments/notify/alipay", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestAlipayParseNotify(t *testing.T) {
+	app := newKeyPair(t, "app")
+	alipay := newKeyPair(t, "alipay")
+	g, err := payment.NewAlipayGateway(payment.AlipayConfig{
+		AppID:               "2021000000000001",
+		PrivateKeyFile:      app.privateKey,
+		AlipayPublicKeyFile: alipay.publicKey,
+	}, "https://gym.example.com/api/v1/payments/notify/alipay")
+	if err != nil {
+		t.Fatalf("NewAlipayGateway: %v", err)
+	}
+
+	notify := func(status string) url.Values {
+		return url.Values{
+			"app_id":       {"2021000000000001"},
+			"out_trade_no": {"P1"},
+			"trade_no":     {"T1"},
+			"total_amount": {"99.90"},
+			"trade_status": {status},
+			"gmt_payment":  {"2024-05-01 10:00:00"},
+		}
+	}
+	tampered := alipaySign(t, alipay, notify("TRADE_SUCCESS"))
+	tampered.Set("total_amount", "0.01")
+	otherApp := notify("TRADE_SUCCESS")
+	otherApp.Set("app_id", "2021000000000002")
+
+	tests := []struct {
+		name     string
+		values   url.Values
+		wantErr  error // nil with wantFail for errors other than a bad signature
+		wantFail bool
+		wantPaid bool
+	}{
+		{name: "success", values: alipaySign(t, alipay, notify("TRADE_SUCCESS")), wantPaid: true},
+		{name: "finished", values: alipaySign(t, alipay, notify("TRADE_FINISHED")), wantPaid: true},
+		{name: "waiting", values: alipaySign(t, alipay, notify("WAIT_BUYER_PAY"))},
+		{name: "tampered amount", values: tampered, wantErr: payment.ErrInvalidSignature},
+		{name: "signed by the app key", values: alipaySign(t, app, notify("TRADE_SUCCESS")), wantErr: payment.ErrInvalidSignature},
+		{name: "unsigned", values: notify("TRADE_SUCCESS"), wantErr: payment.ErrInvalidSignature},
+		{name: "another app", values: alipaySign(t, alipay, otherApp), wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := g.ParseNotify(alipayNotify(tt.values))
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseNotify error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantFail:
+				if err == nil {
+					t.Fatalf("ParseNotify accepted %+v", n)
+				}
+				return
+			case err != nil:
+				t.Fatalf("ParseNotify: %v", err)
+			}
+			if n.PaymentNo != "P1" || n.TradeNo != "T1" || n.Amount != 99.9 || n.Paid != tt.wantPaid {
+				t.Errorf("notification = %+v", n)
+			}
+			if n.PaidAt.Format("2006-01-02 15:04:05") != "2024-05-01 10:00:00" {
+				t.Errorf("paid at = %v", n.PaidAt)
+			}
+		})
+	}
+}
+
+// Alipay resends a callback until it is acknowledged, so the same notification
+// arrives more than once; every delivery must identify the same payment and
+// trade for the order service to settle it only once
+func TestAlipayDuplicateNotify(t *testing.T) {
+	app := newKeyPair(t, "app")
+	alipay := newKeyPair(t, "alipay")
+	g, err := payment.NewAlipayGateway(payment.AlipayConfig{
+		AppID:               "2021000000000001",
+		PrivateKeyFile:      app.privateKey,
+		AlipayPublicKeyFile: alipay.publicKey,
+	}, "")
+	if err != nil {
+		t.Fatalf("NewAlipayGateway: %v", err)
+	}
+	values := alipaySign(t, alipay, url.Values{
+		"app_id":       {"2021000000000001"},
+		"out_trade_no": {"P1"},
+		"trade_no":     {"T1"},
+		"total_amount": {"10.00"},
+		"trade_status": {"TRADE_SUCCESS"},
+	})
+
+	first, err := g.ParseNotify(alipayNotify(values))
+	if err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	second, err := g.ParseNotify(alipayNotify(values))
+	if err != nil {
+		t.Fatalf("second delivery: %v", err)
+	}
+	if *first != *second {
+		t.Errorf("deliveries differ: %+v and %+v", first, second)
+	}
+}
@@ -0,0 +1,141 @@
+package payment
+
+import (
+	"context"
+	"gym-admin/pkg/apperr"
+	"math"
+	"net/http"
+	"time"
+)
+
+const (
+	MethodCash   int8 = 1
+	MethodPOS    int8 = 2
+	MethodWechat int8 = 3
+	MethodAlipay int8 = 4
+	MethodWallet int8 = 5
+	MethodMock   int8 = 9
+)
+
+var (
+	ErrInvalidSignature  = apperr.New(http.StatusBadRequest, "INVALID_SIGNATURE", "invalid payment notification signature")
+	ErrNotifyUnsupported = apperr.New(http.StatusBadRequest, "NOTIFY_UNSUPPORTED", "payment method does not send notifications")
+	ErrMethodUnavailable = apperr.New(http.StatusBadRequest, "PAYMENT_METHOD_UNAVAILABLE", "payment method is not available")
+)
+
+// PayRequest asks a gateway to collect Amount (yuan) for one payment
+type PayRequest struct {
+	PaymentNo string
+	UserID    int64
+	Amount    float64
+	Subject   string
+	ClientIP  string
+	Reference string // 线下支付凭证号，如POS小票号
+	ExpireAt  time.Time
+}
+
+// PayResult is what the gateway returned when the payment was started. Offline
+// methods are paid on the spot; online methods return the parameters the
+// client needs (e.g. a QR code URL) and confirm later through a notification.
+type PayResult struct {
+	Paid    bool
+	TradeNo string
+	PaidAt  time.Time
+	Params  map[string]string
+}
+
+// Notification is a verified asynchronous payment result
+type Notification struct {
+	PaymentNo string
+	TradeNo   string
+	Amount    float64
+	Paid      bool
+	PaidAt    time.Time
+	Raw       string
+}
+
+const (
+	RefundPending int8 = 1
+	RefundSuccess int8 = 2
+	RefundFailed  int8 = 3
+)
+
+// RefundRequest asks the channel of the original payment to pay Amount back.
+// Total is the amount of the original payment.
+type RefundRequest struct {
+	RefundNo  string
+	PaymentNo string
+	TradeNo   string
+	Amount    float64
+	Total     float64
+	Reason    string
+}
+
+// RefundResult is the state of a refund at the channel. Online channels may
+// answer RefundPending and settle later, which is picked up by QueryRefund.
+type RefundResult struct {
+	Status          int8
+	GatewayRefundNo string
+	RefundedAt      time.Time
+	FailReason      string
+}
+
+// Gateway is a payment channel. ParseNotify must verify the signature of the
+// callback before trusting any of its content.
+type Gateway interface {
+	Name() string
+	Method() int8
+	Pay(ctx context.Context, req *PayRequest) (*PayResult, error)
+	Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error)
+	QueryRefund(ctx context.Context, req *RefundRequest) (*RefundResult, error)
+	ParseNotify(r *http.Request) (*Notification, error)
+	// AckNotify writes the response the channel expects after a callback was
+	// handled; a non-nil err asks the channel to retry later
+	AckNotify(w http.ResponseWriter, err error)
+}
+
+// Registry holds the payment methods available to this server, looked up by
+// method when paying and by name when a notification comes in
+type Registry struct {
+	gateways map[int8]Gateway
+}
+
+func NewRegistry(gateways ...Gateway) *Registry {
+	r := &Registry{gateways: make(map[int8]Gateway)}
+	for _, g := range gateways {
+		r.Register(g)
+	}
+	return r
+}
+
+// Register adds a gateway, replacing any registered for the same method
+func (r *Registry) Register(g Gateway) {
+	r.gateways[g.Method()] = g
+}
+
+func (r *Registry) Get(method int8) (Gateway, error) {
+	g, ok := r.gateways[method]
+	if !ok {
+		return nil, ErrMethodUnavailable
+	}
+	return g, nil
+}
+
+// GetByName looks a gateway up by the name used in its notify URL
+func (r *Registry) GetByName(name string) (Gateway, error) {
+	for _, g := range r.gateways {
+		if g.Name() == name {
+			return g, nil
+		}
+	}
+	return nil, ErrMethodUnavailable
+}
+
+// ToFen converts yuan to the integer cents the online channels work in
+func ToFen(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+func FromFen(fen int64) float64 {
+	return float64(fen) / 100
+}
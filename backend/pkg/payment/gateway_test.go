@@ -0,0 +1,62 @@
+package payment_test
+
+import (
+	"errors"
+	"gym-admin/pkg/payment"
+	"testing"
+)
+
+func TestToFen(t *testing.T) {
+	tests := []struct {
+		yuan float64
+		want int64
+	}{
+		{0, 0},
+		{1, 100},
+		{19.99, 1999}, // 1998.9999999999998 before rounding
+		{0.1 + 0.2, 30},
+		{0.07, 7},
+		{1234.565, 123457},
+		{0.004, 0},
+		{0.006, 1},
+	}
+	for _, tt := range tests {
+		if got := payment.ToFen(tt.yuan); got != tt.want {
+			t.Errorf("ToFen(%v) = %d, want %d", tt.yuan, got, tt.want)
+		}
+	}
+}
+
+func TestFromFenRoundTrips(t *testing.T) {
+	for _, fen := range []int64{0, 1, 7, 1999, 123457} {
+		if got := payment.ToFen(payment.FromFen(fen)); got != fen {
+			t.Errorf("ToFen(FromFen(%d)) = %d", fen, got)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	cash := payment.NewOfflineGateway(payment.MethodCash, "cash", false)
+	mock := payment.NewMockGateway("secret")
+	gateways := payment.NewRegistry(cash, mock)
+
+	if g, err := gateways.Get(payment.MethodMock); err != nil || g != mock {
+		t.Errorf("Get(mock) = %v, %v", g, err)
+	}
+	if g, err := gateways.GetByName("cash"); err != nil || g != cash {
+		t.Errorf("GetByName(cash) = %v, %v", g, err)
+	}
+	if _, err := gateways.Get(payment.MethodWechat); !errors.Is(err, payment.ErrMethodUnavailable) {
+		t.Errorf("Get(wechat) error = %v, want %v", err, payment.ErrMethodUnavailable)
+	}
+	if _, err := gateways.GetByName("alipay"); !errors.Is(err, payment.ErrMethodUnavailable) {
+		t.Errorf("GetByName(alipay) error = %v, want %v", err, payment.ErrMethodUnavailable)
+	}
+
+	// a gateway registered again for a method replaces the first
+	other := payment.NewMockGateway("other")
+	gateways.Register(other)
+	if g, _ := gateways.Get(payment.MethodMock); g != other {
+		t.Errorf("Get(mock) after Register = %v, want the replacement", g)
+	}
+}
@@ -0,0 +1,95 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+const MockSignatureHeader = "X-Mock-Signature"
+
+// MockGateway is a local gateway for development and tests. Payments are
+// completed by posting a MockNotification signed with Sign to the notify URL.
+type MockGateway struct {
+	secret []byte
+}
+
+// MockNotification is the JSON body of a mock payment callback
+type MockNotification struct {
+	PaymentNo string    `json:"payment_no"`
+	TradeNo   string    `json:"trade_no"`
+	Amount    float64   `json:"amount"`
+	Status    string    `json:"status"` // SUCCESS or FAIL
+	PaidAt    time.Time `json:"paid_at"`
+}
+
+func NewMockGateway(secret string) *MockGateway {
+	return &MockGateway{secret: []byte(secret)}
+}
+
+func (g *MockGateway) Name() string {
+	return "mock"
+}
+
+func (g *MockGateway) Method() int8 {
+	return MethodMock
+}
+
+func (g *MockGateway) Pay(ctx context.Context, req *PayRequest) (*PayResult, error) {
+	return &PayResult{Params: map[string]string{"pay_url": "mock://pay/" + req.PaymentNo}}, nil
+}
+
+// Refund accepts every refund and leaves it processing, like the online
+// channels do; QueryRefund then reports it successful
+func (g *MockGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	return &RefundResult{Status: RefundPending, GatewayRefundNo: "MOCK" + req.RefundNo}, nil
+}
+
+func (g *MockGateway) QueryRefund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	return &RefundResult{Status: RefundSuccess, GatewayRefundNo: "MOCK" + req.RefundNo, RefundedAt: time.Now()}, nil
+}
+
+// Sign returns the signature header value for a callback body
+func (g *MockGateway) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, g.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (g *MockGateway) ParseNotify(r *http.Request) (*Notification, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxNotifyBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(g.Sign(body)), []byte(r.Header.Get(MockSignatureHeader))) {
+		return nil, ErrInvalidSignature
+	}
+
+	var n MockNotification
+	if err := json.Unmarshal(body, &n); err != nil {
+		return nil, err
+	}
+	return &Notification{
+		PaymentNo: n.PaymentNo,
+		TradeNo:   n.TradeNo,
+		Amount:    n.Amount,
+		Paid:      n.Status == "SUCCESS",
+		PaidAt:    n.PaidAt,
+		Raw:       string(body),
+	}, nil
+}
+
+func (g *MockGateway) AckNotify(w http.ResponseWriter, err error) {
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("FAIL"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("OK"))
+}
@@ -0,0 +1,59 @@
+package payment_test
+
+import (
+	"bytes"
+	"errors"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func mockNotify(body []byte, signature string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/payments/notify/mock", bytes.NewReader(body))
+	if signature != "" {
+		r.Header.Set(payment.MockSignatureHeader, signature)
+	}
+	return r
+}
+
+func TestMockParseNotify(t *testing.T) {
+	g := payment.NewMockGateway("secret")
+	body := []byte(`{"payment_no":"P1","trade_no":"T1","amount":99.9,"status":"SUCCESS","paid_at":"2024-05-01T10:00:00Z"}`)
+	failed := []byte(`{"payment_no":"P1","trade_no":"T1","amount":99.9,"status":"FAIL"}`)
+	tampered := bytes.Replace(body, []byte("99.9"), []byte("0.01"), 1)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		sig      string
+		wantErr  error
+		wantPaid bool
+	}{
+		{name: "valid", body: body, sig: g.Sign(body), wantPaid: true},
+		{name: "payment failed", body: failed, sig: g.Sign(failed)},
+		{name: "tampered body", body: tampered, sig: g.Sign(body), wantErr: payment.ErrInvalidSignature},
+		{name: "other secret", body: body, sig: payment.NewMockGateway("other").Sign(body), wantErr: payment.ErrInvalidSignature},
+		{name: "unsigned", body: body, wantErr: payment.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := g.ParseNotify(mockNotify(tt.body, tt.sig))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseNotify error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseNotify: %v", err)
+			}
+			if n.PaymentNo != "P1" || n.TradeNo != "T1" || n.Amount != 99.9 || n.Paid != tt.wantPaid {
+				t.Errorf("notification = %+v", n)
+			}
+			if n.Raw != string(tt.body) {
+				t.Errorf("raw = %q, want the body", n.Raw)
+			}
+		})
+	}
+}
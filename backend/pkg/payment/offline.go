@@ -0,0 +1,53 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// OfflineGateway records payments collected at the front desk, in cash or on
+// a POS terminal. They are settled immediately and never call back.
+type OfflineGateway struct {
+	method           int8
+	name             string
+	requireReference bool
+}
+
+func NewOfflineGateway(method int8, name string, requireReference bool) *OfflineGateway {
+	return &OfflineGateway{method: method, name: name, requireReference: requireReference}
+}
+
+func (g *OfflineGateway) Name() string {
+	return g.name
+}
+
+func (g *OfflineGateway) Method() int8 {
+	return g.method
+}
+
+func (g *OfflineGateway) Pay(ctx context.Context, req *PayRequest) (*PayResult, error) {
+	if g.requireReference && req.Reference == "" {
+		return nil, errors.New("reference number of the POS receipt is required")
+	}
+	return &PayResult{Paid: true, TradeNo: req.Reference, PaidAt: time.Now()}, nil
+}
+
+// Refund is settled on the spot: the money is handed back at the front desk or
+// reversed on the POS terminal by the operator
+func (g *OfflineGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	return &RefundResult{Status: RefundSuccess, RefundedAt: time.Now()}, nil
+}
+
+func (g *OfflineGateway) QueryRefund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	return &RefundResult{Status: RefundSuccess, RefundedAt: time.Now()}, nil
+}
+
+func (g *OfflineGateway) ParseNotify(r *http.Request) (*Notification, error) {
+	return nil, ErrNotifyUnsupported
+}
+
+func (g *OfflineGateway) AckNotify(w http.ResponseWriter, err error) {
+	w.WriteHeader(http.StatusNotFound)
+}
@@ -0,0 +1,106 @@
+package payment
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"strings"
+)
+
+func signSHA256WithRSA(key *rsa.PrivateKey, message string) (string, error) {
+	hashed := sha256.Sum256([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+func verifySHA256WithRSA(key *rsa.PublicKey, message, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	hashed := sha256.Sum256([]byte(message))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig) != nil {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// readKeyDER reads a PEM file, or a bare base64 key as handed out by the
+// Alipay console, and returns the DER bytes and PEM block type
+func readKeyDER(path string) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes, block.Type, nil
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, "", errors.New("key is neither PEM nor base64")
+	}
+	return der, "", nil
+}
+
+func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	der, _, err := readKeyDER(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	return key, nil
+}
+
+// loadPublicKey accepts a certificate or a public key
+func loadPublicKey(path string) (*rsa.PublicKey, error) {
+	der, blockType, err := readKeyDER(path)
+	if err != nil {
+		return nil, err
+	}
+	var parsed interface{}
+	switch blockType {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, err
+		}
+		parsed = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(der)
+	default:
+		if parsed, err = x509.ParsePKIXPublicKey(der); err != nil {
+			return nil, err
+		}
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("public key is not RSA")
+	}
+	return key, nil
+}
+
+func randomString(n int) string {
+	b := make([]byte, n/2)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
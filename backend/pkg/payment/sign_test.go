@@ -0,0 +1,61 @@
+package payment_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// keyPair is an RSA key written to disk the way the channels hand keys out:
+// a PKCS#1 private key and a PKIX public key, both PEM encoded
+type keyPair struct {
+	key        *rsa.PrivateKey
+	privateKey string
+	publicKey  string
+}
+
+func newKeyPair(t *testing.T, name string) keyPair {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	kp := keyPair{
+		key:        key,
+		privateKey: filepath.Join(dir, name+"_private.pem"),
+		publicKey:  filepath.Join(dir, name+"_public.pem"),
+	}
+	writePEM(t, kp.privateKey, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key))
+	writePEM(t, kp.publicKey, "PUBLIC KEY", pub)
+	return kp
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// sign is what the channel does to the callbacks it sends
+func (kp keyPair) sign(t *testing.T, message string) string {
+	t.Helper()
+	hashed := sha256.Sum256([]byte(message))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, kp.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sig)
+}
@@ -0,0 +1,307 @@
+package payment
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	wechatAPIBase = "https://api.mch.weixin.qq.com"
+	// wechatMaxClockSkew bounds the age of a signed callback to limit replays
+	wechatMaxClockSkew = 5 * time.Minute
+	maxNotifyBodySize  = 1 << 20
+)
+
+// WechatGateway implements WeChat Pay API v3 Native payments (QR code scanned
+// with WeChat). Requests are signed with the merchant key, responses and
+// callbacks verified with the platform certificate, and callback resources
+// decrypted with the APIv3 key.
+type WechatGateway struct {
+	cfg         WechatConfig
+	notifyURL   string
+	privateKey  *rsa.PrivateKey
+	platformKey *rsa.PublicKey
+	client      *http.Client
+}
+
+// WechatConfig is the merchant account a WechatGateway pays into
+type WechatConfig struct {
+	AppID            string
+	MchID            string
+	SerialNo         string // 商户API证书序列号
+	PrivateKeyFile   string
+	PlatformCertFile string
+	APIv3Key         string
+}
+
+func NewWechatGateway(cfg WechatConfig, notifyURL string) (*WechatGateway, error) {
+	if len(cfg.APIv3Key) != 32 {
+		return nil, errors.New("api v3 key must be 32 bytes")
+	}
+	privateKey, err := loadPrivateKey(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load merchant private key: %w", err)
+	}
+	platformKey, err := loadPublicKey(cfg.PlatformCertFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load platform certificate: %w", err)
+	}
+	return &WechatGateway{
+		cfg:         cfg,
+		notifyURL:   notifyURL,
+		privateKey:  privateKey,
+		platformKey: platformKey,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (g *WechatGateway) Name() string {
+	return "wechat"
+}
+
+func (g *WechatGateway) Method() int8 {
+	return MethodWechat
+}
+
+func (g *WechatGateway) Pay(ctx context.Context, req *PayRequest) (*PayResult, error) {
+	body := map[string]interface{}{
+		"appid":        g.cfg.AppID,
+		"mchid":        g.cfg.MchID,
+		"description":  req.Subject,
+		"out_trade_no": req.PaymentNo,
+		"notify_url":   g.notifyURL,
+		"amount":       map[string]interface{}{"total": ToFen(req.Amount), "currency": "CNY"},
+	}
+	if !req.ExpireAt.IsZero() {
+		body["time_expire"] = req.ExpireAt.Format(time.RFC3339)
+	}
+	if req.ClientIP != "" {
+		body["scene_info"] = map[string]string{"payer_client_ip": req.ClientIP}
+	}
+
+	var resp struct {
+		CodeURL string `json:"code_url"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/v3/pay/transactions/native", body, &resp); err != nil {
+		return nil, err
+	}
+	return &PayResult{Params: map[string]string{"code_url": resp.CodeURL}}, nil
+}
+
+func (g *WechatGateway) Refund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	body := map[string]interface{}{
+		"out_trade_no":  req.PaymentNo,
+		"out_refund_no": req.RefundNo,
+		"reason":        req.Reason,
+		"amount": map[string]interface{}{
+			"refund":   ToFen(req.Amount),
+			"total":    ToFen(req.Total),
+			"currency": "CNY",
+		},
+	}
+	var resp wechatRefund
+	if err := g.do(ctx, http.MethodPost, "/v3/refund/domestic/refunds", body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.result(), nil
+}
+
+func (g *WechatGateway) QueryRefund(ctx context.Context, req *RefundRequest) (*RefundResult, error) {
+	var resp wechatRefund
+	if err := g.do(ctx, http.MethodGet, "/v3/refund/domestic/refunds/"+url.PathEscape(req.RefundNo), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.result(), nil
+}
+
+type wechatRefund struct {
+	RefundID    string `json:"refund_id"`
+	Status      string `json:"status"` // SUCCESS, CLOSED, PROCESSING, ABNORMAL
+	SuccessTime string `json:"success_time"`
+}
+
+func (r *wechatRefund) result() *RefundResult {
+	res := &RefundResult{GatewayRefundNo: r.RefundID}
+	switch r.Status {
+	case "SUCCESS":
+		res.Status = RefundSuccess
+		if t, err := time.Parse(time.RFC3339, r.SuccessTime); err == nil {
+			res.RefundedAt = t
+		}
+	case "CLOSED", "ABNORMAL":
+		res.Status = RefundFailed
+		res.FailReason = r.Status
+	default:
+		res.Status = RefundPending
+	}
+	return res
+}
+
+func (g *WechatGateway) ParseNotify(r *http.Request) (*Notification, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxNotifyBodySize))
+	if err != nil {
+		return nil, err
+	}
+	if err := g.verify(r.Header, body); err != nil {
+		return nil, err
+	}
+
+	var notify struct {
+		EventType string `json:"event_type"`
+		Resource  struct {
+			Algorithm      string `json:"algorithm"`
+			Ciphertext     string `json:"ciphertext"`
+			AssociatedData string `json:"associated_data"`
+			Nonce          string `json:"nonce"`
+		} `json:"resource"`
+	}
+	if err := json.Unmarshal(body, &notify); err != nil {
+		return nil, err
+	}
+	if notify.Resource.Algorithm != "AEAD_AES_256_GCM" {
+		return nil, fmt.Errorf("unsupported resource algorithm %q", notify.Resource.Algorithm)
+	}
+	plaintext, err := g.decrypt(notify.Resource.Ciphertext, notify.Resource.Nonce, notify.Resource.AssociatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx struct {
+		OutTradeNo    string `json:"out_trade_no"`
+		TransactionID string `json:"transaction_id"`
+		TradeState    string `json:"trade_state"`
+		SuccessTime   string `json:"success_time"`
+		Amount        struct {
+			Total int64 `json:"total"`
+		} `json:"amount"`
+	}
+	if err := json.Unmarshal(plaintext, &tx); err != nil {
+		return nil, err
+	}
+
+	n := &Notification{
+		PaymentNo: tx.OutTradeNo,
+		TradeNo:   tx.TransactionID,
+		Amount:    FromFen(tx.Amount.Total),
+		Paid:      tx.TradeState == "SUCCESS",
+		Raw:       string(plaintext),
+	}
+	if t, err := time.Parse(time.RFC3339, tx.SuccessTime); err == nil {
+		n.PaidAt = t
+	}
+	return n, nil
+}
+
+func (g *WechatGateway) AckNotify(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"code":"FAIL","message":"失败"}`))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(`{"code":"SUCCESS","message":"成功"}`))
+}
+
+// do sends a signed API v3 request and verifies the signature of the answer
+func (g *WechatGateway) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := randomString(32)
+	message := method + "\n" + path + "\n" + timestamp + "\n" + nonce + "\n" + string(body) + "\n"
+	signature, err := signSHA256WithRSA(g.privateKey, message)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, wechatAPIBase+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf(
+		`WECHATPAY2-SHA256-RSA2048 mchid="%s",nonce_str="%s",signature="%s",timestamp="%s",serial_no="%s"`,
+		g.cfg.MchID, nonce, signature, timestamp, g.cfg.SerialNo))
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("wechat pay request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(respBody, &apiErr)
+		return fmt.Errorf("wechat pay: %s %s", apiErr.Code, apiErr.Message)
+	}
+	if err := g.verify(resp.Header, respBody); err != nil {
+		return fmt.Errorf("wechat pay response: %w", err)
+	}
+	if out != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
+
+func (g *WechatGateway) verify(h http.Header, body []byte) error {
+	timestamp := h.Get("Wechatpay-Timestamp")
+	nonce := h.Get("Wechatpay-Nonce")
+	signature := h.Get("Wechatpay-Signature")
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrInvalidSignature
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := time.Since(time.Unix(ts, 0)); d > wechatMaxClockSkew || d < -wechatMaxClockSkew {
+		return ErrInvalidSignature
+	}
+	return verifySHA256WithRSA(g.platformKey, timestamp+"\n"+nonce+"\n"+string(body)+"\n", signature)
+}
+
+func (g *WechatGateway) decrypt(ciphertext, nonce, associatedData string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher([]byte(g.cfg.APIv3Key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid resource nonce")
+	}
+	return gcm.Open(nil, []byte(nonce), data, []byte(associatedData))
+}
@@ -0,0 +1,176 @@
+package payment_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"gym-admin/pkg/payment"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testAPIv3Key = "0123456789abcdef0123456789abcdef"
+
+func newWechatGateway(t *testing.T, platform keyPair) *payment.WechatGateway {
+	t.Helper()
+	merchant := newKeyPair(t, "merchant")
+	g, err := payment.NewWechatGateway(payment.WechatConfig{
+		AppID:            "wx0000000000000001",
+		MchID:            "1900000001",
+		SerialNo:         "SERIAL",
+		PrivateKeyFile:   merchant.privateKey,
+		PlatformCertFile: platform.publicKey,
+		APIv3Key:         testAPIv3Key,
+	}, "https://gym.example.com/api/v1/payments/notify/wechat")
+	if err != nil {
+		t.Fatalf("NewWechatGateway: %v", err)
+	}
+	return g
+}
+
+// wechatBody builds a callback body with the transaction encrypted under key
+// the way WeChat Pay does
+func wechatBody(t *testing.T, key, transaction string) []byte {
+	t.Helper()
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := "0123456789ab"
+	ciphertext := gcm.Seal(nil, []byte(nonce), []byte(transaction), []byte("transaction"))
+
+	body, err := json.Marshal(map[string]interface{}{
+		"id":         "EV-1",
+		"event_type": "TRANSACTION.SUCCESS",
+		"resource": map[string]string{
+			"algorithm":       "AEAD_AES_256_GCM",
+			"ciphertext":      base64.StdEncoding.EncodeToString(ciphertext),
+			"associated_data": "transaction",
+			"nonce":           nonce,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// wechatNotify signs body as the platform at the given time
+func wechatNotify(t *testing.T, platform keyPair, body []byte, at time.Time) *http.Request {
+	t.Helper()
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	nonce := "NONCE"
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/payments/notify/wechat", bytes.NewReader(body))
+	r.Header.Set("Wechatpay-Timestamp", timestamp)
+	r.Header.Set("Wechatpay-Nonce", nonce)
+	r.Header.Set("Wechatpay-Signature", platform.sign(t, timestamp+"\n"+nonce+"\n"+string(body)+"\n"))
+	return r
+}
+
+func TestWechatParseNotify(t *testing.T) {
+	platform := newKeyPair(t, "platform")
+	g := newWechatGateway(t, platform)
+
+	paid := `{"out_trade_no":"P1","transaction_id":"T1","trade_state":"SUCCESS","success_time":"2024-05-01T10:00:00+08:00","amount":{"total":9990}}`
+	closed := `{"out_trade_no":"P1","transaction_id":"T1","trade_state":"CLOSED","amount":{"total":9990}}`
+	body := wechatBody(t, testAPIv3Key, paid)
+	now := time.Now()
+
+	tamperedBody := func() *http.Request {
+		signed := wechatNotify(t, platform, body, now)
+		r := httptest.NewRequest(http.MethodPost, signed.URL.Path, bytes.NewReader(wechatBody(t, testAPIv3Key, closed)))
+		r.Header = signed.Header
+		return r
+	}
+	tamperedTimestamp := func() *http.Request {
+		r := wechatNotify(t, platform, body, now)
+		r.Header.Set("Wechatpay-Timestamp", strconv.FormatInt(now.Unix()+1, 10))
+		return r
+	}
+	unsigned := func() *http.Request {
+		r := wechatNotify(t, platform, body, now)
+		r.Header.Del("Wechatpay-Signature")
+		return r
+	}
+
+	tests := []struct {
+		name     string
+		req      func() *http.Request
+		wantErr  error // nil with wantFail for errors other than a bad signature
+		wantFail bool
+		wantPaid bool
+	}{
+		{name: "paid", req: func() *http.Request { return wechatNotify(t, platform, body, now) }, wantPaid: true},
+		{name: "closed", req: func() *http.Request {
+			return wechatNotify(t, platform, wechatBody(t, testAPIv3Key, closed), now)
+		}},
+		{name: "tampered body", req: tamperedBody, wantErr: payment.ErrInvalidSignature},
+		{name: "tampered timestamp", req: tamperedTimestamp, wantErr: payment.ErrInvalidSignature},
+		{name: "unsigned", req: unsigned, wantErr: payment.ErrInvalidSignature},
+		{name: "signed by another key", req: func() *http.Request {
+			return wechatNotify(t, newKeyPair(t, "other"), body, now)
+		}, wantErr: payment.ErrInvalidSignature},
+		{name: "replayed too late", req: func() *http.Request {
+			return wechatNotify(t, platform, body, now.Add(-10*time.Minute))
+		}, wantErr: payment.ErrInvalidSignature},
+		{name: "encrypted with another key", req: func() *http.Request {
+			return wechatNotify(t, platform, wechatBody(t, "fedcba9876543210fedcba9876543210", paid), now)
+		}, wantFail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := g.ParseNotify(tt.req())
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ParseNotify error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantFail:
+				if err == nil {
+					t.Fatalf("ParseNotify accepted %+v", n)
+				}
+				return
+			case err != nil:
+				t.Fatalf("ParseNotify: %v", err)
+			}
+			if n.PaymentNo != "P1" || n.TradeNo != "T1" || n.Amount != 99.9 || n.Paid != tt.wantPaid {
+				t.Errorf("notification = %+v", n)
+			}
+			if tt.wantPaid && !n.PaidAt.Equal(time.Date(2024, 5, 1, 2, 0, 0, 0, time.UTC)) {
+				t.Errorf("paid at = %v", n.PaidAt)
+			}
+		})
+	}
+}
+
+// WeChat Pay retries a callback until it is acknowledged. Every delivery within
+// the allowed clock skew identifies the same payment and trade, for the order
+// service to settle it only once.
+func TestWechatDuplicateNotify(t *testing.T) {
+	platform := newKeyPair(t, "platform")
+	g := newWechatGateway(t, platform)
+	body := wechatBody(t, testAPIv3Key, `{"out_trade_no":"P1","transaction_id":"T1","trade_state":"SUCCESS","amount":{"total":1000}}`)
+
+	first, err := g.ParseNotify(wechatNotify(t, platform, body, time.Now()))
+	if err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	second, err := g.ParseNotify(wechatNotify(t, platform, body, time.Now().Add(-time.Minute)))
+	if err != nil {
+		t.Fatalf("second delivery: %v", err)
+	}
+	if *first != *second {
+		t.Errorf("deliveries differ: %+v and %+v", first, second)
+	}
+}
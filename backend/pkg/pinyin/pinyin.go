@@ -0,0 +1,31 @@
+package pinyin
+
+import (
+	"strings"
+	"unicode"
+
+	gopinyin "github.com/mozillazg/go-pinyin"
+)
+
+// Convert returns the full pinyin and the initials of a Chinese name,
+// e.g. "张三" -> ("zhangsan", "zs"). Latin letters and digits are kept as is.
+func Convert(name string) (full, initials string) {
+	args := gopinyin.NewArgs()
+	args.Style = gopinyin.Normal
+	args.Fallback = func(r rune, a gopinyin.Args) []string {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return []string{string(unicode.ToLower(r))}
+		}
+		return nil
+	}
+
+	var fullBuilder, initialsBuilder strings.Builder
+	for _, syllables := range gopinyin.Pinyin(name, args) {
+		if len(syllables) == 0 || syllables[0] == "" {
+			continue
+		}
+		fullBuilder.WriteString(syllables[0])
+		initialsBuilder.WriteByte(syllables[0][0])
+	}
+	return fullBuilder.String(), initialsBuilder.String()
+}
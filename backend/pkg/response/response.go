@@ -1,19 +1,34 @@
 package response
 
 import (
+	"gym-admin/pkg/apperr"
+	"gym-admin/pkg/deadline"
+	"gym-admin/pkg/logger"
+	"gym-admin/pkg/validate"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 )
 
 type Response struct {
 	Code      int         `json:"code"`
+	Error     string      `json:"error,omitempty"` // business error code, e.g. PHONE_EXISTS
 	Message   string      `json:"message"`
 	Data      interface{} `json:"data,omitempty"`
 	Timestamp int64       `json:"timestamp"`
 }
 
+// statusCompat answers errors with HTTP 200 and the status only in the body,
+// for clients written against the old behaviour
+var statusCompat bool
+
+// SetStatusCompat turns the HTTP 200 compatibility mode on or off
+func SetStatusCompat(enabled bool) {
+	statusCompat = enabled
+}
+
 func Success(c *gin.Context, data interface{}) {
 	c.JSON(http.StatusOK, Response{
 		Code:      200,
@@ -32,10 +47,53 @@ func SuccessWithMessage(c *gin.Context, message string, data interface{}) {
 	})
 }
 
+// Fail answers with err. Application errors keep their status, code and
+// message; anything else is mapped by apperr.From and only its status and
+// a generic message reach the client, the details go to the log.
+func Fail(c *gin.Context, err error) {
+	e := apperr.From(err)
+	if e.Status >= http.StatusInternalServerError {
+		logger.Error("Request failed",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+			zap.String("code", e.Code),
+			zap.Error(err))
+	}
+	write(c, e.Status, e.Code, e.Message, nil)
+}
+
+// BindError answers a request that failed to bind. Each invalid field is
+// listed in data.errors with a message in the client's language, and the
+// first of them is the response message.
+func BindError(c *gin.Context, err error) {
+	fields := validate.Fields(err, c.GetHeader("Accept-Language"))
+	if len(fields) == 0 {
+		BadRequest(c, "Invalid request: "+err.Error())
+		return
+	}
+	write(c, http.StatusBadRequest, apperr.CodeInvalidParameter, fields[0].Message, gin.H{"errors": fields})
+}
+
 func Error(c *gin.Context, code int, message string) {
-	c.JSON(http.StatusOK, Response{
-		Code:      code,
+	write(c, code, apperr.CodeForStatus(code), message, nil)
+}
+
+func write(c *gin.Context, status int, code, message string, data interface{}) {
+	// Whatever failed, a request that ran out of time reports that instead
+	if deadline.Exceeded(c.Request.Context()) {
+		status, code, message = apperr.ErrTimeout.Status, apperr.ErrTimeout.Code, apperr.ErrTimeout.Message
+		data = nil
+	}
+
+	httpStatus := status
+	if statusCompat {
+		httpStatus = http.StatusOK
+	}
+	c.JSON(httpStatus, Response{
+		Code:      status,
+		Error:     code,
 		Message:   message,
+		Data:      data,
 		Timestamp: time.Now().Unix(),
 	})
 }
@@ -0,0 +1,116 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"gym-admin/pkg/apperr"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+var ErrUnsupportedFormat = apperr.New(http.StatusBadRequest, "UNSUPPORTED_FILE_FORMAT", "unsupported file format, expected .csv or .xlsx")
+
+// DetectFormat returns the spreadsheet format from a file name
+func DetectFormat(filename string) (string, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xlsx":
+		return FormatXLSX, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// ReadRows reads all rows of a CSV file or the first sheet of an XLSX file.
+// At most maxRows rows are read (0 means no limit), including the header.
+func ReadRows(r io.Reader, format string, maxRows int) ([][]string, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, maxRows)
+	case FormatXLSX:
+		return readXLSX(r, maxRows)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+func readCSV(r io.Reader, maxRows int) ([][]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	// Excel on Chinese Windows saves CSV as GBK
+	if !utf8.Valid(data) {
+		if data, err = simplifiedchinese.GB18030.NewDecoder().Bytes(data); err != nil {
+			return nil, fmt.Errorf("failed to decode csv: %w", err)
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+		if maxRows > 0 && len(rows) > maxRows {
+			return nil, fmt.Errorf("too many rows, at most %d allowed", maxRows)
+		}
+	}
+	return rows, nil
+}
+
+func readXLSX(r io.Reader, maxRows int) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("workbook has no sheets")
+	}
+
+	iter, err := f.Rows(sheets[0])
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var rows [][]string
+	for iter.Next() {
+		row, err := iter.Columns()
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+		if maxRows > 0 && len(rows) > maxRows {
+			return nil, fmt.Errorf("too many rows, at most %d allowed", maxRows)
+		}
+	}
+	return rows, iter.Error()
+}
@@ -0,0 +1,105 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// maxXLSXRows is the row limit of a single Excel worksheet
+const maxXLSXRows = 1048576
+
+// Writer writes rows one at a time so that large exports never hold the
+// whole table in memory. Close must be called to finish the file.
+type Writer interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+// ContentType returns the MIME type of a spreadsheet format
+func ContentType(format string) string {
+	if format == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// NewWriter creates a streaming writer for the given format
+func NewWriter(w io.Writer, format string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		// BOM so that Excel opens the file as UTF-8
+		if _, err := w.Write([]byte("\xef\xbb\xbf")); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FormatXLSX:
+		f := excelize.NewFile()
+		sw, err := f.NewStreamWriter("Sheet1")
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &xlsxWriter{out: w, file: f, stream: sw}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+type csvWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+func (cw *csvWriter) WriteRow(cells []string) error {
+	if err := cw.w.Write(cells); err != nil {
+		return err
+	}
+	cw.rows++
+	if cw.rows%1000 == 0 {
+		cw.w.Flush()
+		return cw.w.Error()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// xlsxWriter uses excelize's stream writer, which spills rows to a temp file
+// instead of building the worksheet in memory
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	rows   int
+}
+
+func (xw *xlsxWriter) WriteRow(cells []string) error {
+	if xw.rows >= maxXLSXRows {
+		return fmt.Errorf("xlsx export is limited to %d rows", maxXLSXRows)
+	}
+	xw.rows++
+
+	cell, err := excelize.CoordinatesToCellName(1, xw.rows)
+	if err != nil {
+		return err
+	}
+	values := make([]interface{}, len(cells))
+	for i, v := range cells {
+		values[i] = v
+	}
+	return xw.stream.SetRow(cell, values)
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer xw.file.Close()
+	if err := xw.stream.Flush(); err != nil {
+		return err
+	}
+	return xw.file.Write(xw.out)
+}
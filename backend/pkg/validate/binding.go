@@ -0,0 +1,271 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	enTranslations "github.com/go-playground/validator/v10/translations/en"
+	zhTranslations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+const dateLayout = "2006-01-02"
+
+// FieldError is one invalid field of a request, with a message in the
+// client's language
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+var (
+	registerOnce sync.Once
+	registerErr  error
+	uni          *ut.UniversalTranslator
+)
+
+// tags are the validations added to gin's binding. They all let an empty
+// value through, so that optional fields can be cleared; combine them with
+// required where a value must be given.
+var tags = map[string]validator.Func{
+	"mobile": func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return s == "" || IsMobile(s)
+	},
+	"idcard": func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return s == "" || IsIDCard(s)
+	},
+	// email replaces the built-in check so that requests and services agree
+	"email": func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		return s == "" || IsEmail(s)
+	},
+	// date is a YYYY-MM-DD string
+	"date": func(fl validator.FieldLevel) bool {
+		s := fl.Field().String()
+		if s == "" {
+			return true
+		}
+		_, err := time.ParseInLocation(dateLayout, s, time.Local)
+		return err == nil
+	},
+	// notfuture is a date string or time no later than today
+	"notfuture": func(fl validator.FieldLevel) bool {
+		t, ok := timeOf(fl.Field())
+		if !ok {
+			return true
+		}
+		y, m, d := time.Now().Date()
+		return t.Before(time.Date(y, m, d+1, 0, 0, 0, 0, time.Local))
+	},
+	// notbefore=StartAt is a date string or time no earlier than the named
+	// sibling field, when both are set
+	"notbefore": func(fl validator.FieldLevel) bool {
+		t, ok := timeOf(fl.Field())
+		if !ok {
+			return true
+		}
+		other, _, _, found := fl.GetStructFieldOKAdvanced2(fl.Parent(), fl.Param())
+		if !found {
+			return true
+		}
+		start, ok := timeOf(other)
+		return !ok || !t.Before(start)
+	},
+}
+
+// messages are the translations of the tags above, by language
+var messages = map[string]map[string]string{
+	"zh": {
+		"mobile":        "{0}必须是有效的手机号码",
+		"idcard":        "{0}必须是有效的身份证号码",
+		"email":         "{0}必须是有效的邮箱",
+		"date":          "{0}必须是YYYY-MM-DD格式的日期",
+		"notfuture":     "{0}不能晚于今天",
+		"notbefore":     "{0}不能早于{1}",
+		"type-mismatch": "{0}的类型不正确",
+	},
+	"en": {
+		"mobile":        "{0} must be a valid mobile number",
+		"idcard":        "{0} must be a valid ID card number",
+		"email":         "{0} must be a valid email address",
+		"date":          "{0} must be a date in YYYY-MM-DD format",
+		"notfuture":     "{0} cannot be later than today",
+		"notbefore":     "{0} cannot be before {1}",
+		"type-mismatch": "{0} has the wrong type",
+	},
+}
+
+// Register adds the custom tags to gin's validator, reports fields by their
+// JSON names and loads the Chinese and English messages. It is safe to call
+// more than once.
+func Register() error {
+	registerOnce.Do(func() {
+		registerErr = register()
+	})
+	return registerErr
+}
+
+func register() error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("validate: gin is not using go-playground/validator")
+	}
+	v.RegisterTagNameFunc(jsonName)
+	for tag, fn := range tags {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+
+	uni = ut.New(zh.New(), zh.New(), en.New())
+	zhTrans, _ := uni.GetTranslator("zh")
+	if err := zhTranslations.RegisterDefaultTranslations(v, zhTrans); err != nil {
+		return err
+	}
+	enTrans, _ := uni.GetTranslator("en")
+	if err := enTranslations.RegisterDefaultTranslations(v, enTrans); err != nil {
+		return err
+	}
+
+	for lang, texts := range messages {
+		trans, _ := uni.GetTranslator(lang)
+		for tag, text := range texts {
+			if err := trans.Add(tag, text, true); err != nil {
+				return err
+			}
+			if tag == "type-mismatch" {
+				continue
+			}
+			if err := v.RegisterTranslation(tag, trans, noop, translate); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Fields lists the invalid fields in err, as returned by gin's binding, with
+// messages in the language asked for by acceptLanguage. It returns nil for
+// errors that are not about particular fields, such as malformed JSON.
+func Fields(err error, acceptLanguage string) []FieldError {
+	trans := translator(acceptLanguage)
+
+	var errs validator.ValidationErrors
+	if errors.As(err, &errs) {
+		fields := make([]FieldError, 0, len(errs))
+		for _, fe := range errs {
+			message := fe.Error()
+			if trans != nil {
+				message = fe.Translate(trans)
+			}
+			fields = append(fields, FieldError{Field: fieldPath(fe.Namespace()), Message: message})
+		}
+		return fields
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		message := typeErr.Field + " has the wrong type"
+		if trans != nil {
+			message, _ = trans.T("type-mismatch", typeErr.Field)
+		}
+		return []FieldError{{Field: typeErr.Field, Message: message}}
+	}
+	return nil
+}
+
+// translator picks Chinese or English from an Accept-Language header,
+// defaulting to Chinese
+func translator(acceptLanguage string) ut.Translator {
+	if uni == nil {
+		return nil
+	}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		lang := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		if strings.HasPrefix(lang, "zh") {
+			break
+		}
+		if strings.HasPrefix(lang, "en") {
+			trans, _ := uni.GetTranslator("en")
+			return trans
+		}
+	}
+	trans, _ := uni.GetTranslator("zh")
+	return trans
+}
+
+func noop(ut.Translator) error {
+	return nil
+}
+
+func translate(trans ut.Translator, fe validator.FieldError) string {
+	message, err := trans.T(fe.Tag(), fe.Field(), snakeCase(fe.Param()))
+	if err != nil {
+		return fe.Error()
+	}
+	return message
+}
+
+// jsonName reports struct fields by their JSON names
+func jsonName(f reflect.StructField) string {
+	name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// fieldPath drops the struct name from a namespace such as
+// CreateOrderRequest.items[0].item_type
+func fieldPath(namespace string) string {
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// snakeCase turns a field name parameter such as StartAt into start_at
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// timeOf reads a time or a YYYY-MM-DD string, reporting false when unset
+func timeOf(v reflect.Value) (time.Time, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return time.Time{}, false
+		}
+		v = v.Elem()
+	}
+	switch t := v.Interface().(type) {
+	case time.Time:
+		return t, !t.IsZero()
+	case string:
+		parsed, err := time.ParseInLocation(dateLayout, t, time.Local)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
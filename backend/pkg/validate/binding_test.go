@@ -0,0 +1,65 @@
+package validate_test
+
+import (
+	"gym-admin/pkg/validate"
+	"testing"
+)
+
+func TestIsMobile(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"13800138000", true},
+		{"19912345678", true},
+		{"12800138000", false}, // no 12x numbers
+		{"1380013800", false},
+		{"138001380001", false},
+		{"+8613800138000", false},
+		{"138-0013-8000", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validate.IsMobile(tt.s); got != tt.want {
+			t.Errorf("IsMobile(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestIsIDCard(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"11010519491231002X", true},
+		{"11010519491231002x", true},
+		{"110105194912310021", false}, // wrong check digit
+		{"11010519491331002X", false}, // no 13th month
+		{"1101051949123100", false},
+		{"11010A19491231002X", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validate.IsIDCard(tt.s); got != tt.want {
+			t.Errorf("IsIDCard(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestIsEmail(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"li.ming@example.com", true},
+		{"li@example", false},
+		{"Li Ming <li@example.com>", false},
+		{"li@@example.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := validate.IsEmail(tt.s); got != tt.want {
+			t.Errorf("IsEmail(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package validate
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var mobileRegexp = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
+// idCardWeights and idCardCheckCodes implement the GB 11643-1999 checksum
+var idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+var idCardCheckCodes = []byte{'1', '0', 'X', '9', '8', '7', '6', '5', '4', '3', '2'}
+
+// IsMobile reports whether s is a mainland China mobile number
+func IsMobile(s string) bool {
+	return mobileRegexp.MatchString(s)
+}
+
+// IsEmail reports whether s is a bare email address
+func IsEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s && strings.Contains(s[strings.LastIndex(s, "@"):], ".")
+}
+
+// IsIDCard reports whether s is a valid 18-digit resident ID card number
+func IsIDCard(s string) bool {
+	if len(s) != 18 {
+		return false
+	}
+	s = strings.ToUpper(s)
+
+	sum := 0
+	for i := 0; i < 17; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		sum += int(s[i]-'0') * idCardWeights[i]
+	}
+	if s[17] != idCardCheckCodes[sum%11] {
+		return false
+	}
+
+	// Birth date is encoded in digits 7-14
+	birthday, err := time.Parse("20060102", s[6:14])
+	if err != nil || birthday.After(time.Now()) {
+		return false
+	}
+	return true
+}
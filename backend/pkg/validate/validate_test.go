@@ -0,0 +1,176 @@
+package validate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"gym-admin/pkg/validate"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+type memberRequest struct {
+	Phone    string  `json:"phone" binding:"required,mobile"`
+	IDCard   string  `json:"id_card" binding:"idcard"`
+	Email    string  `json:"email" binding:"email"`
+	Gender   *int8   `json:"gender" binding:"omitempty,oneof=1 2"`
+	Status   int8    `json:"status" binding:"omitempty,oneof=1 2 3"`
+	Birthday string  `json:"birthday" binding:"date,notfuture"`
+	StartAt  string  `json:"start_at" binding:"date"`
+	EndAt    *string `json:"end_at" binding:"omitempty,date,notbefore=StartAt"`
+}
+
+func validRequest() memberRequest {
+	return memberRequest{Phone: "13800138000"}
+}
+
+// check binds req the way gin does and returns the invalid fields
+func check(t *testing.T, req memberRequest, acceptLanguage string) []validate.FieldError {
+	t.Helper()
+	if err := validate.Register(); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err := binding.Validator.ValidateStruct(&req)
+	if err == nil {
+		return nil
+	}
+	fields := validate.Fields(err, acceptLanguage)
+	if fields == nil {
+		t.Fatalf("Fields(%v) = nil, want the invalid fields", err)
+	}
+	return fields
+}
+
+func TestTags(t *testing.T) {
+	gender := func(g int8) *int8 { return &g }
+	date := func(s string) *string { return &s }
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	tests := []struct {
+		name   string
+		modify func(*memberRequest)
+		want   string // the invalid field, empty when valid
+	}{
+		{"valid", func(r *memberRequest) {}, ""},
+		{"all set", func(r *memberRequest) {
+			r.IDCard, r.Email, r.Gender, r.Status = "11010519491231002X", "li@example.com", gender(2), 3
+			r.Birthday, r.StartAt, r.EndAt = "1990-01-31", "2024-05-01", date("2024-05-01")
+		}, ""},
+		{"missing phone", func(r *memberRequest) { r.Phone = "" }, "phone"},
+		{"landline", func(r *memberRequest) { r.Phone = "01012345678" }, "phone"},
+		{"bad id card", func(r *memberRequest) { r.IDCard = "110105194912310021" }, "id_card"},
+		{"bad email", func(r *memberRequest) { r.Email = "li@" }, "email"},
+		{"gender out of range", func(r *memberRequest) { r.Gender = gender(0) }, "gender"},
+		{"status out of range", func(r *memberRequest) { r.Status = 4 }, "status"},
+		{"not a date", func(r *memberRequest) { r.Birthday = "1990/01/31" }, "birthday"},
+		{"born tomorrow", func(r *memberRequest) { r.Birthday = tomorrow }, "birthday"},
+		{"ends before it starts", func(r *memberRequest) { r.StartAt, r.EndAt = "2024-05-01", date("2024-04-30") }, "end_at"},
+		{"end without start", func(r *memberRequest) { r.EndAt = date("2024-04-30") }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(&req)
+			fields := check(t, req, "")
+			switch {
+			case tt.want == "" && fields != nil:
+				t.Errorf("invalid fields %v, want none", fields)
+			case tt.want != "" && (len(fields) != 1 || fields[0].Field != tt.want):
+				t.Errorf("invalid fields %v, want %s", fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldMessages(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(*memberRequest)
+		acceptLanguage string
+		want           string
+	}{
+		{"chinese by default", func(r *memberRequest) { r.Phone = "123" }, "", "phone必须是有效的手机号码"},
+		{"english", func(r *memberRequest) { r.Phone = "123" }, "en-US,en;q=0.9", "phone must be a valid mobile number"},
+		{"chinese first", func(r *memberRequest) { r.Phone = "123" }, "zh-CN,zh;q=0.9,en;q=0.8", "phone必须是有效的手机号码"},
+		{"unsupported language", func(r *memberRequest) { r.Phone = "123" }, "fr-FR", "phone必须是有效的手机号码"},
+		{"built-in tag", func(r *memberRequest) { r.Phone = "" }, "en", "phone is a required field"},
+		{"enum", func(r *memberRequest) { r.Status = 9 }, "en", "status must be one of [1 2 3]"},
+		{"empty end date", func(r *memberRequest) { r.StartAt, r.EndAt = "2024-05-01", new(string) }, "en", ""},
+		{"date order", func(r *memberRequest) {
+			end := "2024-04-30"
+			r.StartAt, r.EndAt = "2024-05-01", &end
+		}, "en", "end_at cannot be before start_at"},
+		{"date order in chinese", func(r *memberRequest) {
+			end := "2024-04-30"
+			r.StartAt, r.EndAt = "2024-05-01", &end
+		}, "zh", "end_at不能早于start_at"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(&req)
+			fields := check(t, req, tt.acceptLanguage)
+			if tt.want == "" {
+				if fields != nil {
+					t.Errorf("invalid fields %v, want none", fields)
+				}
+				return
+			}
+			if len(fields) != 1 || fields[0].Message != tt.want {
+				t.Errorf("invalid fields %v, want message %q", fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldsReportsNestedPaths(t *testing.T) {
+	type item struct {
+		ItemType int8 `json:"item_type" binding:"required,oneof=1 2 3"`
+	}
+	type orderRequest struct {
+		Items []item `json:"items" binding:"required,dive"`
+	}
+	if err := validate.Register(); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	err := binding.Validator.ValidateStruct(&orderRequest{Items: []item{{ItemType: 1}, {ItemType: 5}}})
+	fields := validate.Fields(err, "en")
+	want := []validate.FieldError{{Field: "items[1].item_type", Message: "item_type must be one of [1 2 3]"}}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("Fields = %v, want %v", fields, want)
+	}
+}
+
+func TestFieldsReportsTypeMismatch(t *testing.T) {
+	if err := validate.Register(); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	var req memberRequest
+	err := json.Unmarshal([]byte(`{"phone":"13800138000","status":"active"}`), &req)
+
+	tests := []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"en", "status has the wrong type"},
+		{"zh", "status的类型不正确"},
+	}
+	for _, tt := range tests {
+		want := []validate.FieldError{{Field: "status", Message: tt.want}}
+		if fields := validate.Fields(err, tt.acceptLanguage); !reflect.DeepEqual(fields, want) {
+			t.Errorf("Fields(%q) = %v, want %v", tt.acceptLanguage, fields, want)
+		}
+	}
+}
+
+func TestFieldsIgnoresOtherErrors(t *testing.T) {
+	var req memberRequest
+	syntaxErr := json.Unmarshal([]byte(`{"phone":`), &req)
+	for _, err := range []error{syntaxErr, errors.New("EOF"), nil} {
+		if fields := validate.Fields(err, "en"); fields != nil {
+			t.Errorf("Fields(%v) = %v, want nil", err, fields)
+		}
+	}
+}
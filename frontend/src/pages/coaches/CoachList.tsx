@@ -18,6 +18,12 @@ const CoachList: React.FC = () => {
     { title: '手机号', dataIndex: 'phone', key: 'phone' },
     { title: '专长', dataIndex: 'specialties', key: 'specialties' },
     { title: '工作年限', dataIndex: 'experience', key: 'experience' },
+    {
+      title: '评分',
+      key: 'rating',
+      render: (_: any, record: Coach) =>
+        record.total_ratings ? `${record.rating?.toFixed(2)} (${record.total_ratings})` : '暂无评价',
+    },
     {
       title: '操作',
       key: 'action',
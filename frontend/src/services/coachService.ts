@@ -9,6 +9,8 @@ export interface Coach {
   email?: string
   specialties?: string
   experience?: number
+  rating?: number
+  total_ratings?: number
   status: number
   created_at: string
 }